@@ -2,14 +2,17 @@ package api
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 
+	"github.com/abrander/agento/configuration"
 	"github.com/abrander/agento/core"
 	"github.com/abrander/agento/logger"
 	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
 	"github.com/abrander/agento/userdb"
 )
 
@@ -24,6 +27,17 @@ type (
 		Clock   time.Time     `json:"clock"`
 		Started time.Time     `json:"start"`
 	}
+
+	// Runner can run a probe once without saving the result.
+	Runner interface {
+		RunNow(subject userdb.Subject, id string) ([]*timeseries.Point, error)
+	}
+
+	// RunResult is the outcome of running a probe with Runner.
+	RunResult struct {
+		Points []*timeseries.Point `json:"points"`
+		Error  string              `json:"error,omitempty"`
+	}
 )
 
 var (
@@ -100,7 +114,17 @@ func getAccountId(c *gin.Context) string {
 	return ""
 }
 
-func Init(router gin.IRouter, store core.Store, emitter core.Emitter, db userdb.Database) {
+// Init will add the API to router. Probes are run on demand by runner.
+// Browsers from the origins in cfg.AllowedOrigins are allowed to read from
+// the API.
+func Init(router gin.IRouter, store core.Store, emitter core.Emitter, runner Runner, db userdb.Database, cfg configuration.APIConfiguration) {
+	// CORS must be handled before authentication, browsers will not send
+	// credentials in preflight requests.
+	router.Use(cors(cfg.AllowedOrigins))
+	router.OPTIONS("/*path", func(c *gin.Context) {
+		c.AbortWithStatus(http.StatusNotFound)
+	})
+
 	router.GET("/ws/:key", func(c *gin.Context) {
 		key := c.Param("key")
 		subject, error := db.ResolveKey(key)
@@ -176,7 +200,21 @@ func Init(router gin.IRouter, store core.Store, emitter core.Emitter, db userdb.
 			subject := getSubject(c)
 			accountId := getAccountId(c)
 
-			hosts, err := store.GetAllHosts(subject, accountId)
+			var hosts []core.Host
+			var err error
+
+			// Filter by tag if asked to, "?tag=key=value".
+			if tag := c.Query("tag"); tag != "" {
+				kv := strings.SplitN(tag, "=", 2)
+				if len(kv) != 2 {
+					c.AbortWithStatus(400)
+					return
+				}
+
+				hosts, err = store.GetHostsByTag(subject, accountId, kv[0], kv[1])
+			} else {
+				hosts, err = store.GetAllHosts(subject, accountId)
+			}
 			if err != nil {
 				c.AbortWithError(500, err)
 			} else {
@@ -205,7 +243,14 @@ func Init(router gin.IRouter, store core.Store, emitter core.Emitter, db userdb.
 			subject := getSubject(c)
 
 			c.Bind(&probe)
-			err := store.UpdateProbe(subject, &probe)
+
+			err := probe.Validate()
+			if err != nil {
+				c.AbortWithError(400, err)
+				return
+			}
+
+			err = store.UpdateProbe(subject, &probe)
 			if err != nil {
 				c.AbortWithError(500, err)
 			} else {
@@ -230,7 +275,14 @@ func Init(router gin.IRouter, store core.Store, emitter core.Emitter, db userdb.
 			subject := getSubject(c)
 
 			c.Bind(&probe)
-			err := store.AddProbe(subject, &probe)
+
+			err := probe.Validate()
+			if err != nil {
+				c.AbortWithError(400, err)
+				return
+			}
+
+			err = store.AddProbe(subject, &probe)
 			if err != nil {
 				logger.Yellow("api", "Error: %s", err.Error())
 				c.AbortWithError(500, err)
@@ -239,6 +291,63 @@ func Init(router gin.IRouter, store core.Store, emitter core.Emitter, db userdb.
 			}
 		})
 
+		// PUT on the collection will update the probe with the same host,
+		// agent and configuration or add a new one.
+		m.PUT("/", func(c *gin.Context) {
+			var probe core.Probe
+			subject := getSubject(c)
+
+			err := c.ShouldBind(&probe)
+			if err != nil {
+				c.AbortWithError(400, err)
+				return
+			}
+
+			// Existing probes are looked up in the account of the caller.
+			probe.AccountID = getAccountId(c)
+			if probe.AccountID == "" {
+				return
+			}
+
+			err = probe.Validate()
+			if err != nil {
+				c.AbortWithError(400, err)
+				return
+			}
+
+			err = core.UpsertProbe(subject, store, &probe)
+			if err != nil {
+				logger.Yellow("api", "Error: %s", err.Error())
+				c.AbortWithError(500, err)
+			} else {
+				c.JSON(200, probe)
+			}
+		})
+
+		// Run the probe once and return the result without saving
+		// anything. Errors from the agent are part of the result.
+		m.POST("/:id/run", func(c *gin.Context) {
+			id := c.Param("id")
+			subject := getSubject(c)
+
+			_, err := store.GetProbe(subject, id)
+			if err == core.ErrProbeNotFound {
+				c.AbortWithError(404, err)
+				return
+			} else if err != nil {
+				c.AbortWithError(500, err)
+				return
+			}
+
+			var result RunResult
+			result.Points, err = runner.RunNow(subject, id)
+			if err != nil {
+				result.Error = err.Error()
+			}
+
+			c.JSON(200, result)
+		})
+
 		m.GET("/", func(c *gin.Context) {
 			subject := getSubject(c)
 			accountId := getAccountId(c)
@@ -0,0 +1,340 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/core"
+	"github.com/abrander/agento/monitor"
+	_ "github.com/abrander/agento/plugins/agents/entropy"
+	_ "github.com/abrander/agento/plugins/agents/nginx"
+	"github.com/abrander/agento/userdb"
+)
+
+func newTestAPI(t *testing.T, origins ...string) (*gin.Engine, core.Store) {
+	gin.SetMode(gin.TestMode)
+
+	emitter := core.NewSimpleEmitter()
+
+	store, err := monitor.NewConfigurationStore(&configuration.Configuration{}, emitter)
+	if err != nil {
+		t.Fatalf("NewConfigurationStore() failed: %s", err.Error())
+	}
+
+	err = core.AddLocalhost(userdb.God, store)
+	if err != nil {
+		t.Fatalf("AddLocalhost() failed: %s", err.Error())
+	}
+
+	engine := gin.New()
+	runner := monitor.NewScheduler(store, emitter, emitter, userdb.God)
+	Init(engine.Group("/api"), store, emitter, runner, userdb.NewSingleUser("secret"), configuration.APIConfiguration{AllowedOrigins: origins})
+
+	return engine, store
+}
+
+func get(engine *gin.Engine, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", path, nil)
+	req.Header.Set("X-Agento-Secret", "secret")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestReadProbes(t *testing.T) {
+	engine, store := newTestAPI(t)
+
+	probe := &core.Probe{AgentID: "entropy", Interval: time.Minute}
+	err := store.AddProbe(userdb.God, probe)
+	if err != nil {
+		t.Fatalf("AddProbe() failed: %s", err.Error())
+	}
+
+	w := get(engine, "/api/probe/", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Got status %d, expected %d", w.Code, http.StatusOK)
+	}
+
+	var probes []map[string]interface{}
+	err = json.Unmarshal(w.Body.Bytes(), &probes)
+	if err != nil {
+		t.Fatalf("Response is not a JSON array of objects: %s", err.Error())
+	}
+
+	if len(probes) != 1 || probes[0]["id"] != probe.ID || probes[0]["agent"] != "entropy" {
+		t.Fatalf("Wrong probes returned: %s", w.Body.String())
+	}
+
+	w = get(engine, "/api/probe/"+probe.ID, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Got status %d, expected %d", w.Code, http.StatusOK)
+	}
+
+	var single map[string]interface{}
+	err = json.Unmarshal(w.Body.Bytes(), &single)
+	if err != nil {
+		t.Fatalf("Response is not a JSON object: %s", err.Error())
+	}
+
+	if single["id"] != probe.ID {
+		t.Fatalf("Wrong probe returned: %s", w.Body.String())
+	}
+}
+
+func TestReadHosts(t *testing.T) {
+	engine, store := newTestAPI(t)
+
+	host := &core.Host{Name: "web1", TransportID: "localtransport"}
+	store.AddHost(userdb.God, host)
+
+	w := get(engine, "/api/host/", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Got status %d, expected %d", w.Code, http.StatusOK)
+	}
+
+	var hosts []map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &hosts)
+	if err != nil {
+		t.Fatalf("Response is not a JSON array of objects: %s", err.Error())
+	}
+
+	found := false
+	for _, h := range hosts {
+		if h["name"] == "web1" {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Fatalf("Host not returned: %s", w.Body.String())
+	}
+}
+
+func TestCORS(t *testing.T) {
+	engine, _ := newTestAPI(t, "https://ui.example.com")
+
+	// Allowed origin.
+	w := get(engine, "/api/probe/", map[string]string{"Origin": "https://ui.example.com"})
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://ui.example.com" {
+		t.Errorf("Allowed origin got Access-Control-Allow-Origin '%s'", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	// Other origins get no CORS headers.
+	w = get(engine, "/api/probe/", map[string]string{"Origin": "https://evil.example.com"})
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Unknown origin got Access-Control-Allow-Origin '%s'", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	// Preflight requests are answered without credentials.
+	req, _ := http.NewRequest("OPTIONS", "/api/probe/", nil)
+	req.Header.Set("Origin", "https://ui.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Preflight got status %d, expected %d", w.Code, http.StatusNoContent)
+	}
+
+	if w.Header().Get("Access-Control-Allow-Methods") != "GET, OPTIONS" {
+		t.Errorf("Preflight allowed methods '%s'", w.Header().Get("Access-Control-Allow-Methods"))
+	}
+
+	if w.Header().Get("Access-Control-Allow-Headers") == "" {
+		t.Errorf("Preflight did not allow any headers")
+	}
+
+	// Preflight from unknown origins is not answered.
+	req.Header.Set("Origin", "https://evil.example.com")
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	if w.Code == http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Preflight from unknown origin was answered")
+	}
+}
+
+func TestRunProbe(t *testing.T) {
+	engine, store := newTestAPI(t)
+
+	probe := &core.Probe{
+		HostID:   "000000000000000000000000",
+		AgentID:  "entropy",
+		Interval: time.Minute,
+	}
+	store.AddProbe(userdb.God, probe)
+
+	req, _ := http.NewRequest("POST", "/api/probe/"+probe.ID+"/run", nil)
+	req.Header.Set("X-Agento-Secret", "secret")
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Got status %d, expected %d", w.Code, http.StatusOK)
+	}
+
+	var result struct {
+		Points []map[string]interface{} `json:"points"`
+		Error  string                   `json:"error"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &result)
+	if err != nil {
+		t.Fatalf("Response is not a JSON object: %s", err.Error())
+	}
+
+	// The entropy agent will fail on systems without /proc, but the result
+	// must have either points or an error.
+	if len(result.Points) == 0 && result.Error == "" {
+		t.Fatalf("Got neither points nor an error: %s", w.Body.String())
+	}
+
+	req, _ = http.NewRequest("POST", "/api/probe/nonexisting/run", nil)
+	req.Header.Set("X-Agento-Secret", "secret")
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Got status %d for unknown probe, expected %d", w.Code, http.StatusNotFound)
+	}
+
+	// A well-formed id not belonging to any probe.
+	w = send(engine, "POST", "/api/probe/5f0c6a1e9d3b2a0011aabbcc/run", "")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Got status %d for unknown well-formed id, expected %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func send(engine *gin.Engine, method string, path string, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("X-Agento-Secret", "secret")
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestUpsertProbe(t *testing.T) {
+	engine, store := newTestAPI(t)
+
+	body := `{"host": "000000000000000000000000", "agent": "entropy", "interval": 60000000000}`
+
+	for i := 0; i < 2; i++ {
+		w := send(engine, "PUT", "/api/probe/", body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%d: Got status %d, expected %d: %s", i, w.Code, http.StatusOK, w.Body.String())
+		}
+	}
+
+	probes, err := store.GetAllProbes(userdb.God, userdb.God.GetAccountId())
+	if err != nil {
+		t.Fatalf("GetAllProbes() failed: %s", err.Error())
+	}
+
+	if len(probes) != 1 {
+		t.Fatalf("Got %d probes after upserting the same probe twice, expected 1", len(probes))
+	}
+
+	if probes[0].AccountID != userdb.God.GetAccountId() {
+		t.Errorf("Probe was added to account '%s', expected '%s'", probes[0].AccountID, userdb.God.GetAccountId())
+	}
+
+	w := send(engine, "PUT", "/api/probe/", "{not json")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Got status %d for garbage, expected %d", w.Code, http.StatusBadRequest)
+	}
+
+	w = send(engine, "PUT", "/api/probe/", `{"agent": "nginx", "interval": 60000000000}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Got status %d for missing configuration, expected %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAddProbeValidate(t *testing.T) {
+	engine, store := newTestAPI(t)
+
+	w := send(engine, "POST", "/api/probe/new", `{"agent": "nginx", "interval": 3600000000000}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Got status %d for missing configuration, expected %d", w.Code, http.StatusBadRequest)
+	}
+
+	probes, _ := store.GetAllProbes(userdb.God, userdb.God.GetAccountId())
+	if len(probes) != 0 {
+		t.Fatalf("Invalid probe was stored")
+	}
+
+	w = send(engine, "POST", "/api/probe/new", `{"agent": "nginx", "interval": 3600000000000, "config": {"url": "http://localhost/status"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Got status %d, expected %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	var probe core.Probe
+	json.Unmarshal(w.Body.Bytes(), &probe)
+
+	probe.AgentConfig = map[string]interface{}{}
+	body, _ := json.Marshal(probe)
+
+	w = send(engine, "PUT", "/api/probe/"+probe.ID, string(body))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Got status %d for missing configuration, expected %d", w.Code, http.StatusBadRequest)
+	}
+
+	stored, _ := store.GetProbe(userdb.God, probe.ID)
+	if stored.AgentConfig["url"] != "http://localhost/status" {
+		t.Fatalf("Invalid update was stored")
+	}
+}
+
+func TestAddProbeInterval(t *testing.T) {
+	engine, _ := newTestAPI(t)
+	defer core.SetMinInterval(0)
+
+	cases := []struct {
+		interval time.Duration
+		valid    bool
+	}{
+		{0, false},
+		{-time.Second, false},
+		{100 * time.Millisecond, false},
+		{core.DefaultMinInterval, true},
+		{time.Minute, true},
+	}
+
+	for _, c := range cases {
+		body := fmt.Sprintf(`{"agent": "entropy", "interval": %d}`, c.interval)
+
+		w := send(engine, "POST", "/api/probe/new", body)
+		if c.valid && w.Code != http.StatusOK {
+			t.Errorf("Interval %s got status %d, expected %d", c.interval, w.Code, http.StatusOK)
+		}
+
+		if !c.valid && w.Code != http.StatusBadRequest {
+			t.Errorf("Interval %s got status %d, expected %d", c.interval, w.Code, http.StatusBadRequest)
+		}
+	}
+
+	core.SetMinInterval(time.Hour)
+
+	w := send(engine, "POST", "/api/probe/new", `{"agent": "entropy", "interval": 60000000000}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Interval below configured minimum got status %d, expected %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// corsMaxAge is the number of seconds browsers may cache a preflight
+// response.
+const corsMaxAge = "600"
+
+// cors will return a middleware adding CORS headers for requests from
+// origins. "*" allows any origin. Preflight requests are answered directly
+// without authentication. Only reads are allowed cross-origin.
+func cors(origins []string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[origin] = true
+	}
+
+	return func(c *gin.Context) {
+		origin := c.Request.Header.Get("Origin")
+		if origin == "" || !(allowed[origin] || allowed["*"]) {
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Add("Vary", "Origin")
+
+		if c.Request.Method == "OPTIONS" {
+			header.Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+			header.Set("Access-Control-Allow-Headers", "X-Agento-Secret, X-Agento-Account")
+			header.Set("Access-Control-Max-Age", corsMaxAge)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
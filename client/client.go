@@ -24,9 +24,12 @@ func GatherAndReport(clientConfig configuration.ClientConfiguration) {
 	// Randomize our start time to avoid a big cluster reporting at the exact same time
 	time.Sleep(time.Duration(rand.Intn(int(time.Second) * clientConfig.Interval)))
 
+	// previous is kept between ticks to report counters as rates.
+	var previous *linuxhost.LinuxHost
+
 	c := time.Tick(time.Second * time.Duration(clientConfig.Interval))
 	for _ = range c {
-		l := linuxhost.LinuxHost{}
+		l := &linuxhost.LinuxHost{}
 		t := localtransport.NewLocalTransport().(plugins.Transport)
 		e := l.Gather(t)
 		if e != nil {
@@ -34,7 +37,10 @@ func GatherAndReport(clientConfig configuration.ClientConfiguration) {
 			continue
 		}
 
-		json, e := json.Marshal(l.Agents)
+		diff := l.Sub(previous)
+		previous = l
+
+		json, e := json.Marshal(diff.Agents)
 
 		if e == nil {
 			client := &http.Client{}
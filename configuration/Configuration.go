@@ -20,14 +20,22 @@ var (
 	defaultConfig = `
 [main]
 includedir = "/etc/agento.d/"
+logFormat = "text"
 
 [client]
 enabled = false
 interval = 1
 secret = "insecure"
+hostname = ""
 
 [server]
 secret = "insecure"
+backend = "influxdb"
+maxConcurrentChecks = 100
+spreadFactor = 0.1
+tickResolution = 100
+minInterval = 1.0
+maxReportBytes = 5242880
 
 [server.http]
 enabled = false
@@ -46,14 +54,43 @@ enabled = false
 bind = "0.0.0.0"
 port = 12345
 interval = 60
+maxDatagramSize = 8192
 
 [server.influxdb]
+version = 1
 url = "http://localhost:8086/"
 username = "root"
 password = "root"
 database = "agento"
 retentionPolicy = "default"
-retries = 0
+retries = 3
+batchSize = 0
+flushInterval = 0
+databaseTemplate = ""
+org = ""
+bucket = ""
+token = ""
+
+[server.opentsdb]
+url = "http://localhost:4242/"
+timeout = 30
+
+[server.rateLimit]
+rate = 0.0
+burst = 10
+
+[server.spool]
+path = ""
+maxBytes = 104857600
+interval = 10
+
+[api]
+allowedOrigins = []
+
+[notifier]
+webhookUrl = ""
+authorization = ""
+retries = 3
 
 [mongo]
 enabled = false
@@ -98,6 +135,65 @@ type InfluxdbConfiguration struct {
 	Database        string `toml:"database"`
 	RetentionPolicy string `toml:"retentionPolicy"`
 	Retries         int    `toml:"retries"`
+	BatchSize       int    `toml:"batchSize"`
+	FlushInterval   int    `toml:"flushInterval"`
+
+	// Precision is the precision of timestamps written, one of "ns", "us",
+	// "ms" or "s". Defaults to "ns".
+	Precision string `toml:"precision"`
+
+	// DatabaseTemplate will route points to a database per account if set.
+	// "{account}" is replaced by the account id. Databases maps account ids
+	// to databases and takes precedence. Only used for version 1.
+	DatabaseTemplate string            `toml:"databaseTemplate"`
+	Databases        map[string]string `toml:"databases"`
+
+	// Version selects the InfluxDB API, 1 or 2. Org, Bucket and Token are
+	// only used for version 2.
+	Version int    `toml:"version"`
+	Org     string `toml:"org"`
+	Bucket  string `toml:"bucket"`
+	Token   string `toml:"token"`
+}
+
+// AccountRateLimit is a token bucket limit for reports.
+type AccountRateLimit struct {
+	// Rate is the number of reports per second allowed in the long run.
+	// Zero means no limit.
+	Rate float64 `toml:"rate"`
+
+	// Burst is the number of reports allowed at once.
+	Burst int `toml:"burst"`
+}
+
+// RateLimitConfiguration is the default limit for reports per account, with
+// overrides per account id.
+type RateLimitConfiguration struct {
+	AccountRateLimit
+	Accounts map[string]AccountRateLimit `toml:"accounts"`
+}
+
+// OpenTSDBConfiguration is the configuration for the OpenTSDB backend.
+type OpenTSDBConfiguration struct {
+	// URL is the base URL of the OpenTSDB HTTP API.
+	URL string `toml:"url"`
+
+	// Timeout is the request timeout in seconds.
+	Timeout int `toml:"timeout"`
+}
+
+// SpoolConfiguration is the configuration for spooling points to disk when
+// the timeseries backend is unavailable.
+type SpoolConfiguration struct {
+	// Path is the spool file. Spooling is disabled if empty.
+	Path string `toml:"path"`
+
+	// MaxBytes is the maximum size of the spool. When full, the oldest
+	// points are dropped.
+	MaxBytes int64 `toml:"maxBytes"`
+
+	// Interval is the number of seconds between replay attempts.
+	Interval int `toml:"interval"`
 }
 
 // ClientConfiguration stores the configuration for Agento as a client.
@@ -106,6 +202,9 @@ type ClientConfiguration struct {
 	Interval  int    `toml:"interval"`
 	Secret    string `toml:"secret"`
 	ServerURL string `toml:"server-url"`
+
+	// Hostname will be reported instead of the system hostname if set.
+	Hostname string `toml:"hostname"`
 }
 
 // HTTPConfiguration is the configuration for the built-in HTTP server.
@@ -117,19 +216,26 @@ type HTTPConfiguration struct {
 
 // HTTPSConfiguration is the configuration for the built-in HTTPS server.
 type HTTPSConfiguration struct {
-	Enabled  bool   `toml:"enabled"`
-	Bind     string `toml:"bind"`
-	Port     int16  `toml:"port"`
-	KeyPath  string `toml:"key"`
-	CertPath string `toml:"cert"`
+	Enabled      bool     `toml:"enabled"`
+	Bind         string   `toml:"bind"`
+	Port         int16    `toml:"port"`
+	KeyPath      string   `toml:"key"`
+	CertPath     string   `toml:"cert"`
+	MinVersion   string   `toml:"minVersion"`
+	CipherSuites []string `toml:"cipherSuites"`
 }
 
 // UDPConfiguration is the configuration for the UDP receiver.
 type UDPConfiguration struct {
-	Enabled  bool   `toml:"enabled"`
-	Bind     string `toml:"bind"`
-	Port     int16  `toml:"port"`
-	Interval int    `toml:"interval"`
+	Enabled         bool   `toml:"enabled"`
+	Bind            string `toml:"bind"`
+	Port            int16  `toml:"port"`
+	Interval        int    `toml:"interval"`
+	MaxDatagramSize int    `toml:"maxDatagramSize"`
+
+	// Readers is the number of goroutines reading and parsing datagrams
+	// from the socket. Defaults to 1.
+	Readers int `toml:"readers"`
 }
 
 // ServerConfiguration stores the configuration for Agento as a server.
@@ -139,6 +245,59 @@ type ServerConfiguration struct {
 	HTTPS    HTTPSConfiguration    `toml:"https"`
 	Secret   string                `toml:"secret"`
 	UDP      UDPConfiguration      `toml:"udp"`
+
+	// Backend is where points are written, "influxdb", "opentsdb" or
+	// "stdout".
+	Backend string `toml:"backend"`
+
+	// OpenTSDB is used if Backend is "opentsdb".
+	OpenTSDB OpenTSDBConfiguration `toml:"opentsdb"`
+
+	// Spool will keep points on disk while the backend is down.
+	Spool SpoolConfiguration `toml:"spool"`
+
+	// RateLimit limits reports per account.
+	RateLimit RateLimitConfiguration `toml:"rateLimit"`
+
+	// MaxConcurrentChecks is the maximum number of probes running at once.
+	// Zero means no limit.
+	MaxConcurrentChecks int `toml:"maxConcurrentChecks"`
+
+	// SpreadFactor is the part of the interval (0.0-1.0) used to jitter
+	// probe runs, unless set on the probe.
+	SpreadFactor float64 `toml:"spreadFactor"`
+
+	// TickResolution is the number of milliseconds between checking for
+	// due probes.
+	TickResolution int `toml:"tickResolution"`
+
+	// MinInterval is the shortest probe interval in seconds accepted when
+	// adding or updating probes.
+	MinInterval float64 `toml:"minInterval"`
+
+	// MaxReportBytes is the maximum size of a report after decompression.
+	MaxReportBytes int64 `toml:"maxReportBytes"`
+
+	// Health decides when /health reports the server as unhealthy.
+	Health HealthConfiguration `toml:"health"`
+}
+
+// HealthConfiguration is the configuration for the /health endpoint.
+type HealthConfiguration struct {
+	// MaxStaleness is the number of seconds without a report written
+	// before the server is unhealthy. Zero disables the check.
+	MaxStaleness int `toml:"maxStaleness"`
+
+	// MaxWriteFailures is the number of consecutive failed writes before
+	// the server is unhealthy. Defaults to 3, negative disables the check.
+	MaxWriteFailures int `toml:"maxWriteFailures"`
+}
+
+// APIConfiguration is the configuration for the HTTP API.
+type APIConfiguration struct {
+	// AllowedOrigins lists the origins allowed to read from the API from
+	// a browser. "*" allows all origins.
+	AllowedOrigins []string `toml:"allowedOrigins"`
 }
 
 // MongoConfiguration is the configuration for Agento's MongoDB client.
@@ -148,9 +307,37 @@ type MongoConfiguration struct {
 	Database string `toml:"database"`
 }
 
+// RedisConfiguration is the configuration for sharing changes between
+// Agento instances using Redis pub/sub.
+type RedisConfiguration struct {
+	// URL of the Redis server, like "redis://localhost:6379". Changes are
+	// only shared if set.
+	URL string `toml:"url"`
+
+	// Channel is the pub/sub channel used. Defaults to "agento".
+	Channel string `toml:"channel"`
+
+	// Election will make instances elect a leader running all probes.
+	// The leader holds a lease for LeaseTTL seconds, defaulting to 10.
+	Election bool `toml:"election"`
+	LeaseTTL int  `toml:"leaseTtl"`
+}
+
+// NotifierConfiguration is the configuration for notifications about probe
+// state changes.
+type NotifierConfiguration struct {
+	WebhookURL    string `toml:"webhookUrl"`
+	Authorization string `toml:"authorization"`
+	Retries       int    `toml:"retries"`
+}
+
 // MainConfiguration is the configuration for main behaviour of Agento.
 type MainConfiguration struct {
 	Includedir string `toml:"includedir"`
+
+	// LogFormat is "text" for colored output or "json" for one JSON
+	// object per line.
+	LogFormat string `toml:"logFormat"`
 }
 
 // Configuration is Agento's main configuration object.
@@ -158,9 +345,12 @@ type Configuration struct {
 	Client   ClientConfiguration       `toml:"client"`
 	Server   ServerConfiguration       `toml:"server"`
 	Mongo    MongoConfiguration        `toml:"mongo"`
+	Redis    RedisConfiguration        `toml:"redis"`
 	Hosts    map[string]toml.Primitive `toml:"host"`
 	Probes   map[string]toml.Primitive `toml:"probe"`
 	Main     MainConfiguration         `toml:"main"`
+	Notifier NotifierConfiguration     `toml:"notifier"`
+	API      APIConfiguration          `toml:"api"`
 	metadata toml.MetaData
 }
 
@@ -2,7 +2,9 @@ package core
 
 import (
 	"encoding/json"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/abrander/agento/plugins"
@@ -16,6 +18,19 @@ type (
 		Name            string                 `toml:"name" json:"name"`
 		TransportID     string                 `toml:"transport" json:"transport"`
 		TransportConfig map[string]interface{} `toml:"config" json:"config"`
+
+		// MaintenanceWindows lists periods where probes for the host should
+		// not run.
+		MaintenanceWindows []MaintenanceWindow `toml:"maintenance" json:"maintenanceWindows"`
+
+		// Tags can be used to group hosts.
+		Tags map[string]string `toml:"tags" json:"tags"`
+	}
+
+	// MaintenanceWindow is a period of planned work on a host.
+	MaintenanceWindow struct {
+		Start time.Time `toml:"start" json:"start"`
+		End   time.Time `toml:"end" json:"end"`
 	}
 )
 
@@ -47,12 +62,40 @@ func (h *Host) DecodeTOML(prim toml.Primitive) error {
 
 	// Remove known entries. Someone should find a better method.
 	delete(h.TransportConfig, "transport")
+	delete(h.TransportConfig, "maintenance")
+	delete(h.TransportConfig, "tags")
 
 	return nil
 }
 
-// Transport will return a usable transport for this host.
-func (h *Host) Transport() plugins.Transport {
+// Contains will return true if t is within the window. Start is inclusive,
+// end is exclusive.
+func (w MaintenanceWindow) Contains(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// InMaintenance will return true if t is within any of the maintenance
+// windows for the host.
+func (h *Host) InMaintenance(t time.Time) bool {
+	for _, w := range h.MaintenanceWindows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasTag will return true if the host is tagged with key set to value.
+func (h *Host) HasTag(key string, value string) bool {
+	v, found := h.Tags[key]
+
+	return found && v == value
+}
+
+// Transport will return a usable transport for this host. An error is
+// returned if the transport plugin is unknown.
+func (h *Host) Transport() (plugins.Transport, error) {
 	transportsLock.RLock()
 	transport, found := transports[h.ID]
 	transportsLock.RUnlock()
@@ -62,7 +105,7 @@ func (h *Host) Transport() plugins.Transport {
 
 		transport, err = plugins.GetTransport(h.TransportID)
 		if err != nil {
-			panic(err.Error())
+			return nil, fmt.Errorf("unknown transport '%s'", h.TransportID)
 		}
 
 		// Use JSON as an intermediary for setting configuration. Its ugly,
@@ -75,5 +118,5 @@ func (h *Host) Transport() plugins.Transport {
 		transportsLock.Unlock()
 	}
 
-	return transport
+	return transport, nil
 }
@@ -13,6 +13,9 @@ type HostStore interface {
 	AddHost(subject userdb.Subject, host *Host) error
 	GetHost(subject userdb.Subject, id string) (*Host, error)
 	GetHostByName(subject userdb.Subject, name string) (*Host, error)
+	GetHostsByTag(subject userdb.Subject, accountID string, key string, value string) ([]Host, error)
+
+	// DeleteHost will delete the host and all probes for the host.
 	DeleteHost(subject userdb.Subject, id string) error
 }
 
@@ -3,6 +3,7 @@ package core
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/BurntSushi/toml"
 
@@ -118,3 +119,52 @@ func TestHostDecodeJSON(t *testing.T) {
 		}
 	}
 }
+
+func TestHostDecodeTOMLMaintenance(t *testing.T) {
+	conf := `[host.testhost]
+        transport = "localtransport"
+        name = "testhost"
+
+        [[host.testhost.maintenance]]
+        start = 2020-01-01T10:00:00Z
+        end = 2020-01-01T12:00:00Z
+        `
+
+	c := Config{}
+	_, err := toml.Decode(conf, &c)
+	if err != nil {
+		t.Fatalf("Error: %s", err.Error())
+	}
+
+	host := Host{}
+	err = host.DecodeTOML(c.Hosts["testhost"])
+	if err != nil {
+		t.Fatalf("DecodeTOML error: %s", err.Error())
+	}
+
+	if len(host.MaintenanceWindows) != 1 {
+		t.Fatalf("Got %d maintenance windows, expected 1", len(host.MaintenanceWindows))
+	}
+
+	if _, found := host.TransportConfig["maintenance"]; found {
+		t.Errorf("Maintenance windows leaked into transport configuration")
+	}
+
+	cases := []struct {
+		t        string
+		expected bool
+	}{
+		{"2020-01-01T09:59:59Z", false},
+		{"2020-01-01T10:00:00Z", true},
+		{"2020-01-01T11:00:00Z", true},
+		{"2020-01-01T12:00:00Z", false},
+	}
+
+	for _, c := range cases {
+		ts, _ := time.Parse(time.RFC3339, c.t)
+
+		if host.InMaintenance(ts) != c.expected {
+			t.Errorf("InMaintenance(%s) returned %v, expected %v", c.t, !c.expected, c.expected)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package core
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/BurntSushi/toml"
@@ -14,19 +16,80 @@ import (
 type (
 	// Probe describes a probe measuring something with an agent though a transport.
 	Probe struct {
-		ID          string                 `json:"id"`
-		AccountID   string                 `json:"accountId"`
-		HostID      string                 `toml:"host" json:"host"`
-		Interval    time.Duration          `json:"interval"`
-		AgentID     string                 `toml:"agent" json:"agent"`
-		AgentConfig map[string]interface{} `json:"config"`
-		LastCheck   time.Time              `json:"lastCheck"`
-		NextCheck   time.Time              `json:"nextCheck"`
-		LastPoints  []*timeseries.Point    `json:"lastPoints"`
-		Tags        map[string]string      `json:"tags"`
+		ID                  string                 `json:"id"`
+		AccountID           string                 `json:"accountId"`
+		HostID              string                 `toml:"host" json:"host"`
+		Interval            time.Duration          `json:"interval"`
+		Timeout             time.Duration          `json:"timeout"`
+		AgentID             string                 `toml:"agent" json:"agent"`
+		AgentConfig         map[string]interface{} `json:"config"`
+		LastCheck           time.Time              `json:"lastCheck"`
+		NextCheck           time.Time              `json:"nextCheck"`
+		LastPoints          []*timeseries.Point    `json:"lastPoints"`
+		LastError           string                 `json:"lastError"`
+		LastWarnings        []string               `json:"lastWarnings"`
+		ConsecutiveFailures int                    `json:"consecutiveFailures"`
+		History             []ProbeRun             `json:"history"`
+		FlapThreshold       int                    `json:"flapThreshold"`
+		FlapWindow          time.Duration          `json:"flapWindow"`
+		Flapping            bool                   `json:"flapping"`
+		Tags                map[string]string      `json:"tags"`
+
+		// SpreadFactor is the part of the interval (0.0-1.0) used to
+		// jitter runs. If zero, the scheduler default is used.
+		SpreadFactor float64 `json:"spreadFactor"`
 	}
+
+	// ProbeRun is the outcome of a single run of a probe.
+	ProbeRun struct {
+		Time    time.Time `json:"time"`
+		Success bool      `json:"success"`
+		Error   string    `json:"error,omitempty"`
+
+		// Warnings are non-fatal problems reported by the agent.
+		Warnings []string `json:"warnings,omitempty"`
+	}
+)
+
+const (
+	// MaxHistory is the number of runs kept in Probe.History.
+	MaxHistory = 50
+
+	// DefaultFlapThreshold is the number of state changes allowed within
+	// the flap window before a probe is considered flapping.
+	DefaultFlapThreshold = 4
+
+	// defaultFlapIntervals is the default flap window in intervals.
+	defaultFlapIntervals = 10
+
+	// DefaultMinInterval is the shortest interval allowed for a probe
+	// unless changed by SetMinInterval().
+	DefaultMinInterval = time.Second
 )
 
+var (
+	// ErrInvalidSpreadFactor will be returned from Validate() if the spread
+	// factor is outside 0.0-1.0.
+	ErrInvalidSpreadFactor = errors.New("spreadFactor must be between 0.0 and 1.0")
+
+	// ErrNegativeInterval will be returned from Validate() if the interval
+	// is negative.
+	ErrNegativeInterval = errors.New("interval must not be negative")
+
+	// minInterval is the shortest interval accepted by Validate().
+	minInterval = DefaultMinInterval
+)
+
+// SetMinInterval will set the shortest interval accepted by Validate(). If
+// d is zero or negative, DefaultMinInterval is used.
+func SetMinInterval(d time.Duration) {
+	if d <= 0 {
+		d = DefaultMinInterval
+	}
+
+	minInterval = d
+}
+
 // GetAccountId will implement userdb.Subject.
 func (p *Probe) GetAccountId() string {
 	return p.AccountID
@@ -54,20 +117,136 @@ func (p *Probe) DecodeTOML(hostStore HostStore, prim toml.Primitive) error {
 		p.Interval = time.Second * p.Interval
 	}
 
+	p.Timeout = time.Second * p.Timeout
+	p.FlapWindow = time.Second * p.FlapWindow
+
 	return nil
 }
 
+// GetTimeout will return the time allowed for a single run of the probe. If
+// no timeout is set, the interval is used.
+func (p *Probe) GetTimeout() time.Duration {
+	if p.Timeout > 0 {
+		return p.Timeout
+	}
+
+	return p.Interval
+}
+
+// GetFlapThreshold will return the number of state changes allowed within
+// the flap window. DefaultFlapThreshold is used if not set.
+func (p *Probe) GetFlapThreshold() int {
+	if p.FlapThreshold > 0 {
+		return p.FlapThreshold
+	}
+
+	return DefaultFlapThreshold
+}
+
+// GetFlapWindow will return the window used for flap detection. If not set,
+// ten intervals are used.
+func (p *Probe) GetFlapWindow() time.Duration {
+	if p.FlapWindow > 0 {
+		return p.FlapWindow
+	}
+
+	return p.Interval * defaultFlapIntervals
+}
+
+// GetSpreadFactor will return the part of the interval to use for jitter. If
+// not set on the probe, fallback is returned.
+func (p *Probe) GetSpreadFactor(fallback float64) float64 {
+	if p.SpreadFactor > 0 {
+		return p.SpreadFactor
+	}
+
+	return fallback
+}
+
+// StateChanges will return the number of times the probe changed between
+// success and failure since since according to the history.
+func (p *Probe) StateChanges(since time.Time) int {
+	changes := 0
+
+	for i := 1; i < len(p.History); i++ {
+		if p.History[i].Time.Before(since) {
+			continue
+		}
+
+		if p.History[i].Success != p.History[i-1].Success {
+			changes++
+		}
+	}
+
+	return changes
+}
+
+// AddRun will record the outcome of a run at t in the history. A run with
+// warnings is still a success. Only the last MaxHistory runs are kept, oldest
+// first.
+func (p *Probe) AddRun(t time.Time, err error, warnings ...string) {
+	run := ProbeRun{
+		Time:     t,
+		Success:  err == nil,
+		Warnings: warnings,
+	}
+
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	p.History = append(p.History, run)
+
+	if len(p.History) > MaxHistory {
+		// Copy to avoid holding on to the old backing array forever.
+		p.History = append([]ProbeRun(nil), p.History[len(p.History)-MaxHistory:]...)
+	}
+}
+
 // Agent will return the agent for a probe.
 func (p *Probe) Agent() plugins.Agent {
 	// FIXME: Cache this somehow.
-	agent, err := plugins.GetAgent(p.AgentID)
+	agent, err := p.agent()
 
 	if err != nil {
 		panic(err.Error())
 	}
 
+	return agent
+}
+
+// Validate will check that the interval is sane, that the agent exists and
+// that all required configuration is present.
+func (p *Probe) Validate() error {
+	if p.SpreadFactor < 0.0 || p.SpreadFactor > 1.0 {
+		return ErrInvalidSpreadFactor
+	}
+
+	if p.Interval < 0 {
+		return ErrNegativeInterval
+	}
+
+	if p.Interval < minInterval {
+		return fmt.Errorf("interval %s is below the minimum of %s", p.Interval, minInterval)
+	}
+
+	agent, err := p.agent()
+	if err != nil {
+		return err
+	}
+
+	return plugins.ValidateConfig(agent)
+}
+
+// agent will instantiate the agent and apply the configuration.
+func (p *Probe) agent() (plugins.Agent, error) {
+	agent, err := plugins.GetAgent(p.AgentID)
+	if err != nil {
+		return nil, err
+	}
+
 	j, _ := json.Marshal(p.AgentConfig)
 	json.Unmarshal(j, agent)
 
-	return agent
+	return agent, nil
 }
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"encoding/json"
 	"errors"
 
 	"github.com/abrander/agento/userdb"
@@ -13,9 +14,63 @@ type ProbeStore interface {
 	GetProbe(subject userdb.Subject, id string) (*Probe, error)
 	UpdateProbe(subject userdb.Subject, probe *Probe) error
 	DeleteProbe(subject userdb.Subject, id string) error
+	DeleteProbesByHost(subject userdb.Subject, hostID string) error
 }
 
 var (
 	// ErrProbeNotFound will be returned if the probe cannot be found.
 	ErrProbeNotFound = errors.New("Probe not found")
 )
+
+// SameProbe will return true if a and b run the same agent with the same
+// configuration on the same host.
+func SameProbe(a *Probe, b *Probe) bool {
+	if a.HostID != b.HostID || a.AgentID != b.AgentID {
+		return false
+	}
+
+	// encoding/json sorts map keys, allowing us to compare the encoded
+	// configurations.
+	configA, _ := json.Marshal(a.AgentConfig)
+	configB, _ := json.Marshal(b.AgentConfig)
+
+	return string(configA) == string(configB)
+}
+
+// UpsertProbe will update the probe matching probe by host, agent and agent
+// configuration or add probe if none matches. This allows declarative
+// provisioning to be run repeatedly without duplicating probes. The state of
+// an existing probe (last check, history, ...) is kept, while interval,
+// timeout, tags and flap and spread settings are taken from probe. On
+// return probe.ID is set.
+func UpsertProbe(subject userdb.Subject, store ProbeStore, probe *Probe) error {
+	probes, err := store.GetAllProbes(subject, probe.AccountID)
+	if err != nil {
+		return err
+	}
+
+	for i := range probes {
+		existing := &probes[i]
+		if !SameProbe(existing, probe) {
+			continue
+		}
+
+		existing.Interval = probe.Interval
+		existing.Timeout = probe.Timeout
+		existing.Tags = probe.Tags
+		existing.FlapThreshold = probe.FlapThreshold
+		existing.FlapWindow = probe.FlapWindow
+		existing.SpreadFactor = probe.SpreadFactor
+
+		err = store.UpdateProbe(subject, existing)
+		if err != nil {
+			return err
+		}
+
+		probe.ID = existing.ID
+
+		return nil
+	}
+
+	return store.AddProbe(subject, probe)
+}
@@ -1,7 +1,9 @@
 package core
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/abrander/agento/userdb"
 )
@@ -29,6 +31,10 @@ func (s *mockHostStore) GetHostByName(subject userdb.Subject, name string) (*Hos
 	return nil, nil
 }
 
+func (s *mockHostStore) GetHostsByTag(subject userdb.Subject, accountID string, key string, value string) ([]Host, error) {
+	return nil, nil
+}
+
 func (s *mockHostStore) DeleteHost(subject userdb.Subject, id string) error {
 	return nil
 }
@@ -36,3 +42,91 @@ func (s *mockHostStore) DeleteHost(subject userdb.Subject, id string) error {
 func TestProbeDecodeTOML(t *testing.T) {
 
 }
+
+func TestProbeValidate(t *testing.T) {
+	p := &Probe{AgentID: "nonexisting"}
+	if p.Validate() == nil {
+		t.Errorf("Validate() accepted unknown agent")
+	}
+}
+
+func TestProbeAddRun(t *testing.T) {
+	p := &Probe{}
+	start := time.Now()
+
+	for i := 0; i < MaxHistory+10; i++ {
+		var err error
+		if i%2 == 1 {
+			err = errors.New("failed")
+		}
+
+		p.AddRun(start.Add(time.Duration(i)*time.Second), err)
+	}
+
+	if len(p.History) != MaxHistory {
+		t.Fatalf("Got %d runs, expected %d", len(p.History), MaxHistory)
+	}
+
+	// The ten oldest runs must be gone.
+	if !p.History[0].Time.Equal(start.Add(10 * time.Second)) {
+		t.Fatalf("Oldest run is at %s, expected %s", p.History[0].Time, start.Add(10*time.Second))
+	}
+
+	last := p.History[MaxHistory-1]
+	if last.Success || last.Error != "failed" {
+		t.Fatalf("Wrong last run: %+v", last)
+	}
+}
+
+func TestProbeAddRunWarnings(t *testing.T) {
+	p := &Probe{}
+
+	p.AddRun(time.Now(), nil, "line 3: invalid syntax")
+
+	run := p.History[0]
+	if !run.Success || run.Error != "" {
+		t.Fatalf("A run with warnings must be a success: %+v", run)
+	}
+
+	if len(run.Warnings) != 1 || run.Warnings[0] != "line 3: invalid syntax" {
+		t.Fatalf("Got warnings %v, expected one", run.Warnings)
+	}
+}
+
+func TestProbeSpreadFactor(t *testing.T) {
+	p := &Probe{}
+
+	if p.GetSpreadFactor(0.1) != 0.1 {
+		t.Errorf("GetSpreadFactor() did not return the fallback")
+	}
+
+	p.SpreadFactor = 0.5
+	if p.GetSpreadFactor(0.1) != 0.5 {
+		t.Errorf("GetSpreadFactor() ignored the probe spread factor")
+	}
+
+	p.SpreadFactor = 1.5
+	if p.Validate() != ErrInvalidSpreadFactor {
+		t.Errorf("Validate() accepted a spread factor of 1.5")
+	}
+}
+
+func TestSameProbe(t *testing.T) {
+	a := &Probe{HostID: "h", AgentID: "a", AgentConfig: map[string]interface{}{"x": 1, "y": "z"}}
+
+	cases := []struct {
+		b    *Probe
+		same bool
+	}{
+		{&Probe{HostID: "h", AgentID: "a", AgentConfig: map[string]interface{}{"y": "z", "x": 1.0}}, true},
+		{&Probe{HostID: "h", AgentID: "a", AgentConfig: map[string]interface{}{"x": 1}}, false},
+		{&Probe{HostID: "h", AgentID: "b", AgentConfig: a.AgentConfig}, false},
+		{&Probe{HostID: "g", AgentID: "a", AgentConfig: a.AgentConfig}, false},
+	}
+
+	for i, c := range cases {
+		if SameProbe(a, c.b) != c.same {
+			t.Errorf("%d: SameProbe() returned %v, expected %v", i, !c.same, c.same)
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package logger
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"strings"
+	"sync"
+	"time"
 )
 
 func init() {
@@ -21,30 +26,122 @@ func init() {
 	}
 }
 
+type (
+	// entry is a single log line in JSON format.
+	entry struct {
+		Level     string `json:"level"`
+		Subsystem string `json:"subsystem"`
+		Msg       string `json:"msg"`
+		Time      string `json:"time"`
+	}
+)
+
+const (
+	// FormatText is colored human readable output. This is the default.
+	FormatText = "text"
+
+	// FormatJSON is one JSON object per line.
+	FormatJSON = "json"
+)
+
 var (
 	positiveList map[string]bool
 	printAll     bool
+
+	// jsonLock protects jsonFormat.
+	jsonLock   sync.RWMutex
+	jsonFormat bool
+
+	// ErrUnknownFormat will be returned by SetFormat() for unknown formats.
+	ErrUnknownFormat = errors.New("unknown log format")
 )
 
-func Printf(pkg string, format string, args ...interface{}) {
+// SetFormat will select the output format, FormatText or FormatJSON. An
+// empty format is the same as FormatText.
+func SetFormat(format string) error {
+	var j bool
+
+	switch format {
+	case "", FormatText:
+	case FormatJSON:
+		j = true
+	default:
+		return ErrUnknownFormat
+	}
+
+	jsonLock.Lock()
+	jsonFormat = j
+	jsonLock.Unlock()
+
+	return nil
+}
+
+// printJSON will write a JSON line to the log output if JSON output is
+// selected. Returns true if the line was written.
+func printJSON(level string, pkg string, format string, args ...interface{}) bool {
+	jsonLock.RLock()
+	j := jsonFormat
+	jsonLock.RUnlock()
+
+	if !j {
+		return false
+	}
+
+	line, _ := json.Marshal(entry{
+		Level:     level,
+		Subsystem: pkg,
+		Msg:       strings.TrimRight(fmt.Sprintf(format, args...), "\n"),
+		Time:      time.Now().UTC().Format(time.RFC3339Nano),
+	})
+
+	log.Writer().Write(append(line, '\n'))
+
+	return true
+}
+
+// enabled returns true if debug output is enabled for pkg.
+func enabled(pkg string) bool {
 	_, print := positiveList[pkg]
-	if print || printAll {
-		log.Printf("\033[35m"+pkg+"\033[0m: "+format+"\n", args...)
+
+	return print || printAll
+}
+
+func Printf(pkg string, format string, args ...interface{}) {
+	if !enabled(pkg) || printJSON("debug", pkg, format, args...) {
+		return
 	}
+
+	log.Printf("\033[35m"+pkg+"\033[0m: "+format+"\n", args...)
 }
 
 func Red(pkg string, format string, args ...interface{}) {
+	if printJSON("error", pkg, format, args...) {
+		return
+	}
+
 	log.Printf("\033[35m"+pkg+"\033[0m: "+format+"\n", args...)
 }
 
 func Yellow(pkg string, format string, args ...interface{}) {
+	if !enabled(pkg) || printJSON("warning", pkg, format, args...) {
+		return
+	}
+
 	Printf(pkg, "\033[33m"+format+"\033[0m", args...)
 }
 
 func Green(pkg string, format string, args ...interface{}) {
+	if !enabled(pkg) || printJSON("info", pkg, format, args...) {
+		return
+	}
+
 	Printf(pkg, "\033[32m"+format+"\033[0m", args...)
 }
 
 func Error(pkg string, format string, args ...interface{}) {
+	if printJSON("error", pkg, format, args...) {
+		return
+	}
+
 	log.Printf("\033[31m"+pkg+"\033[0m: "+format+"\n", args...)
 }
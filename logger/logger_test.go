@@ -0,0 +1,99 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+// capture will return everything logged by f.
+func capture(t *testing.T, format string, f func()) string {
+	var buf bytes.Buffer
+
+	original := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(original)
+
+	err := SetFormat(format)
+	if err != nil {
+		t.Fatalf("SetFormat() failed: %s", err.Error())
+	}
+	defer SetFormat(FormatText)
+
+	f()
+
+	return buf.String()
+}
+
+func TestText(t *testing.T) {
+	out := capture(t, FormatText, func() {
+		Red("monitor", "failed %d times", 3)
+	})
+
+	if !strings.Contains(out, "\033[35mmonitor\033[0m: failed 3 times\n") {
+		t.Fatalf("Unexpected text output: %q", out)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	printAll = true
+	defer func() { printAll = false }()
+
+	out := capture(t, FormatJSON, func() {
+		Red("monitor", "failed %d times", 3)
+		Yellow("scheduler", "delayed")
+		Green("scheduler", "ran")
+		Printf("server", "request")
+		Error("config", "missing\n")
+	})
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	expected := []entry{
+		{Level: "error", Subsystem: "monitor", Msg: "failed 3 times"},
+		{Level: "warning", Subsystem: "scheduler", Msg: "delayed"},
+		{Level: "info", Subsystem: "scheduler", Msg: "ran"},
+		{Level: "debug", Subsystem: "server", Msg: "request"},
+		{Level: "error", Subsystem: "config", Msg: "missing"},
+	}
+
+	if len(lines) != len(expected) {
+		t.Fatalf("Got %d lines, expected %d: %q", len(lines), len(expected), out)
+	}
+
+	for i, line := range lines {
+		var e entry
+		err := json.Unmarshal([]byte(line), &e)
+		if err != nil {
+			t.Fatalf("Line %d is not JSON: %q", i, line)
+		}
+
+		_, err = time.Parse(time.RFC3339Nano, e.Time)
+		if err != nil {
+			t.Errorf("Line %d has invalid time '%s'", i, e.Time)
+		}
+
+		e.Time = ""
+		if e != expected[i] {
+			t.Errorf("Line %d is %+v, expected %+v", i, e, expected[i])
+		}
+	}
+}
+
+func TestJSONDebugDisabled(t *testing.T) {
+	out := capture(t, FormatJSON, func() {
+		Green("quiet", "not shown")
+	})
+
+	if out != "" {
+		t.Fatalf("Debug output was written for disabled subsystem: %q", out)
+	}
+}
+
+func TestSetFormat(t *testing.T) {
+	if SetFormat("xml") != ErrUnknownFormat {
+		t.Fatalf("SetFormat() accepted an unknown format")
+	}
+}
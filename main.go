@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,32 +20,63 @@ import (
 	"github.com/abrander/agento/logger"
 	"github.com/abrander/agento/monitor"
 	"github.com/abrander/agento/plugins"
+	_ "github.com/abrander/agento/plugins/agents/cgroup"
 	_ "github.com/abrander/agento/plugins/agents/cpustats"
 	_ "github.com/abrander/agento/plugins/agents/diskstats"
 	_ "github.com/abrander/agento/plugins/agents/diskusage"
+	_ "github.com/abrander/agento/plugins/agents/dnscheck"
 	_ "github.com/abrander/agento/plugins/agents/dnsresponsetime"
+	_ "github.com/abrander/agento/plugins/agents/elasticsearch"
 	_ "github.com/abrander/agento/plugins/agents/entropy"
-	_ "github.com/abrander/agento/plugins/agents/hostname"
+	_ "github.com/abrander/agento/plugins/agents/firewall"
+	_ "github.com/abrander/agento/plugins/agents/haproxy"
+	"github.com/abrander/agento/plugins/agents/hostname"
 	_ "github.com/abrander/agento/plugins/agents/http"
+	_ "github.com/abrander/agento/plugins/agents/httpcheck"
+	_ "github.com/abrander/agento/plugins/agents/jsonhttp"
 	_ "github.com/abrander/agento/plugins/agents/linuxhost"
+	_ "github.com/abrander/agento/plugins/agents/listenqueue"
 	_ "github.com/abrander/agento/plugins/agents/loadstats"
+	_ "github.com/abrander/agento/plugins/agents/memcached"
 	_ "github.com/abrander/agento/plugins/agents/memorystats"
+	_ "github.com/abrander/agento/plugins/agents/mongodb"
+	_ "github.com/abrander/agento/plugins/agents/mounts"
 	_ "github.com/abrander/agento/plugins/agents/muninpluginrunner"
 	_ "github.com/abrander/agento/plugins/agents/mysql"
 	_ "github.com/abrander/agento/plugins/agents/mysqlslave"
 	_ "github.com/abrander/agento/plugins/agents/mysqltables"
 	_ "github.com/abrander/agento/plugins/agents/netfilter"
 	_ "github.com/abrander/agento/plugins/agents/netstat"
+	_ "github.com/abrander/agento/plugins/agents/nfs"
 	_ "github.com/abrander/agento/plugins/agents/nginx"
 	_ "github.com/abrander/agento/plugins/agents/null"
 	_ "github.com/abrander/agento/plugins/agents/openfiles"
+	_ "github.com/abrander/agento/plugins/agents/osrelease"
 	_ "github.com/abrander/agento/plugins/agents/phpfpm"
 	_ "github.com/abrander/agento/plugins/agents/ping"
+	_ "github.com/abrander/agento/plugins/agents/postgres"
+	_ "github.com/abrander/agento/plugins/agents/pressure"
+	_ "github.com/abrander/agento/plugins/agents/processes"
+	_ "github.com/abrander/agento/plugins/agents/rabbitmq"
+	_ "github.com/abrander/agento/plugins/agents/redis"
+	_ "github.com/abrander/agento/plugins/agents/smtpcheck"
 	_ "github.com/abrander/agento/plugins/agents/snmpstats"
 	_ "github.com/abrander/agento/plugins/agents/socketstats"
+	_ "github.com/abrander/agento/plugins/agents/softnet"
+	_ "github.com/abrander/agento/plugins/agents/systemd"
+	_ "github.com/abrander/agento/plugins/agents/tcpcheck"
+	_ "github.com/abrander/agento/plugins/agents/tcpconn"
 	_ "github.com/abrander/agento/plugins/agents/tcpport"
+	_ "github.com/abrander/agento/plugins/agents/tcpstat"
+	_ "github.com/abrander/agento/plugins/agents/temperature"
+	_ "github.com/abrander/agento/plugins/agents/tlscert"
+	_ "github.com/abrander/agento/plugins/agents/topprocesses"
+	_ "github.com/abrander/agento/plugins/agents/uptime"
+	_ "github.com/abrander/agento/plugins/agents/vmstat"
+	_ "github.com/abrander/agento/plugins/transports/docker"
 	_ "github.com/abrander/agento/plugins/transports/local"
 	_ "github.com/abrander/agento/plugins/transports/ssh"
+	_ "github.com/abrander/agento/plugins/transports/winrm"
 	"github.com/abrander/agento/server"
 	"github.com/abrander/agento/timeseries"
 	"github.com/abrander/agento/userdb"
@@ -115,6 +149,14 @@ func loadConfig() {
 		logger.Red("agento", "Configuration error: %s", err.Error())
 		os.Exit(1)
 	}
+
+	err = logger.SetFormat(config.Main.LogFormat)
+	if err != nil {
+		logger.Red("agento", "Configuration error: %s '%s'", err.Error(), config.Main.LogFormat)
+		os.Exit(1)
+	}
+
+	hostname.SetOverride(config.Client.Hostname)
 }
 
 func getStore(broadcaster core.Broadcaster) core.Store {
@@ -140,59 +182,111 @@ func getStore(broadcaster core.Broadcaster) core.Store {
 	return store
 }
 
+// getEmitter will return an emitter sharing changes with other instances
+// using Redis if configured.
+func getEmitter() (core.Emitter, core.Broadcaster) {
+	if config.Redis.URL == "" {
+		emitter := core.NewSimpleEmitter()
+
+		return emitter, emitter
+	}
+
+	emitter, err := monitor.NewRedisEmitter(config.Redis)
+	if err != nil {
+		logger.Red("agento", "Redis error: %s", err.Error())
+		os.Exit(1)
+	}
+
+	return emitter, emitter
+}
+
 func run(_ *cobra.Command, _ []string) {
 	var err error
+
+	// wg keeps track of the loops stopping when ctx is cancelled. The
+	// listeners run until we exit.
 	wg := sync.WaitGroup{}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	loadConfig()
 
 	db := userdb.NewSingleUser(config.Server.Secret)
 	engine := gin.New()
 
-	emitter := core.NewSimpleEmitter()
+	emitter, broadcaster := getEmitter()
 
-	store := getStore(emitter)
+	store := getStore(broadcaster)
 
-	scheduler := monitor.NewScheduler(store, db)
+	scheduler := monitor.NewScheduler(store, emitter, broadcaster, db)
+	scheduler.SetMaxConcurrentChecks(config.Server.MaxConcurrentChecks)
+	scheduler.SetSpreadFactor(config.Server.SpreadFactor)
+	scheduler.SetTickResolution(time.Duration(config.Server.TickResolution) * time.Millisecond)
+	core.SetMinInterval(time.Duration(config.Server.MinInterval * float64(time.Second)))
 
-	serv, err := server.NewServer(engine, config.Server, db, store)
+	timeseries.DeclareFieldTypes(plugins.FieldTypes())
+
+	tsdb, err := timeseries.NewDatabase(&config.Server)
 	if err != nil {
-		logger.Red("agento", "Server error: %s", err.Error())
+		logger.Red("agento", "Timeseries database error: %s", err.Error())
 		os.Exit(1)
 	}
 
-	tsdb, err := timeseries.NewInfluxDb(&config.Server.Influxdb)
+	serv, err := server.NewServer(engine, config.Server, db, store, tsdb)
 	if err != nil {
-		logger.Red("agento", "InfluxDB error: %s", err.Error())
+		logger.Red("agento", "Server error: %s", err.Error())
 		os.Exit(1)
 	}
 
 	if config.Server.HTTP.Enabled {
-		wg.Add(1)
 		go serv.ListenAndServe(engine)
 	}
 
 	if config.Server.HTTPS.Enabled {
-		wg.Add(1)
 		go serv.ListenAndServeTLS(engine)
 	}
 
 	if config.Server.UDP.Enabled {
-		wg.Add(1)
 		go serv.ListenAndServeUDP()
 	}
 
 	if config.Client.Enabled {
-		wg.Add(1)
 		go client.GatherAndReport(config.Client)
 	}
 
+	if config.Redis.URL != "" && config.Redis.Election {
+		elector := monitor.NewRedisElector(config.Redis)
+		scheduler.SetElector(elector)
+
+		wg.Add(1)
+		go elector.Loop(ctx, &wg)
+	}
+
 	wg.Add(1)
-	go scheduler.Loop(&wg, tsdb)
+	go scheduler.Loop(ctx, &wg, tsdb)
+
+	if config.Notifier.WebhookURL != "" {
+		notifier := monitor.NewNotifier(config.Notifier, emitter, store, db)
+
+		wg.Add(1)
+		go notifier.Loop(ctx, &wg)
+	}
+
+	go api.Init(engine.Group("/api"), store, emitter, scheduler, db, config.API)
 
-	go api.Init(engine.Group("/api"), store, emitter, db)
+	<-ctx.Done()
+	logger.Yellow("agento", "Shutting down")
 
 	wg.Wait()
+
+	// Flush buffered points before exiting.
+	if closer, ok := tsdb.(timeseries.Closer); ok {
+		err = closer.Close()
+		if err != nil {
+			logger.Red("agento", "Error closing timeseries database: %s", err.Error())
+		}
+	}
 }
 
 func runOnce(_ *cobra.Command, _ []string) {
@@ -216,12 +310,22 @@ func runOnce(_ *cobra.Command, _ []string) {
 			continue
 		}
 
-		err = agent.Gather(host.Transport())
+		transport, err := host.Transport()
 		if err != nil {
 			logger.Red("agento", "Error gathering %s: %s", probe.ID, err.Error())
 			continue
 		}
 
+		err = agent.Gather(transport)
+		if err != nil {
+			logger.Red("agento", "Error gathering %s: %s", probe.ID, err.Error())
+			continue
+		}
+
+		for _, warning := range plugins.GetWarnings(agent) {
+			logger.Yellow("agento", "Warning gathering %s: %s", probe.ID, warning)
+		}
+
 		for _, point := range agent.GetPoints() {
 			// Tag all points with hostname and arbitrary tags.
 			point.Tags["hostname"] = host.Name
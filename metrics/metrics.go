@@ -0,0 +1,130 @@
+// Package metrics holds counters describing the inner workings of Agento
+// itself. They are exposed in Prometheus text format by the server.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// ReportsReceived counts reports received from clients.
+	ReportsReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "agento_reports_received_total",
+		Help: "Number of reports received from clients.",
+	})
+
+	// InfluxWriteFailures counts failed writes to InfluxDB.
+	InfluxWriteFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "agento_influxdb_write_failures_total",
+		Help: "Number of failed writes to InfluxDB.",
+	})
+
+	// ProbesInFlight is the number of probes currently running.
+	ProbesInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "agento_scheduler_probes_in_flight",
+		Help: "Number of probes currently running.",
+	})
+
+	// ProbeRuns counts probe runs by result, "success" or "failure".
+	ProbeRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "agento_probe_runs_total",
+		Help: "Number of probe runs by result.",
+	}, []string{"result"})
+
+	// RequestDuration tracks HTTP request latency.
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "agento_http_request_duration_seconds",
+		Help:    "HTTP request latency.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "path", "status"})
+)
+
+var (
+	// pointStatsLock protects pointStats.
+	pointStatsLock sync.RWMutex
+
+	// pointStats returns the points written and dropped by the timeseries
+	// backend.
+	pointStats func() (written, dropped uint64)
+)
+
+func init() {
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "agento_points_written_total",
+		Help: "Number of points written to the timeseries backend.",
+	}, func() float64 {
+		written, _ := getPointStats()
+
+		return float64(written)
+	})
+
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "agento_points_dropped_total",
+		Help: "Number of points dropped because writing to the timeseries backend failed.",
+	}, func() float64 {
+		_, dropped := getPointStats()
+
+		return float64(dropped)
+	})
+}
+
+// SetPointStats will set the function used to read the number of points
+// written and dropped by the timeseries backend.
+func SetPointStats(f func() (written, dropped uint64)) {
+	pointStatsLock.Lock()
+	pointStats = f
+	pointStatsLock.Unlock()
+}
+
+// getPointStats will return the points written and dropped, or zeros if
+// SetPointStats() has not been called.
+func getPointStats() (written, dropped uint64) {
+	pointStatsLock.RLock()
+	f := pointStats
+	pointStatsLock.RUnlock()
+
+	if f == nil {
+		return 0, 0
+	}
+
+	return f()
+}
+
+// ProbeRun will count a probe run. A nil err counts as a success.
+func ProbeRun(err error) {
+	if err != nil {
+		ProbeRuns.WithLabelValues("failure").Inc()
+	} else {
+		ProbeRuns.WithLabelValues("success").Inc()
+	}
+}
+
+// Handler will serve all metrics in Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware will observe the latency of all requests handled by gin. The
+// route pattern is used as path to avoid a label per URL.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		path := c.FullPath()
+		if path == "" {
+			path = "unknown"
+		}
+
+		RequestDuration.WithLabelValues(c.Request.Method, path, strconv.Itoa(c.Writer.Status())).Observe(time.Since(start).Seconds())
+	}
+}
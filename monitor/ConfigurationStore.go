@@ -130,18 +130,45 @@ func (s *ConfigurationStore) GetHostByName(_ userdb.Subject, name string) (*core
 	return nil, fmt.Errorf("Host '%s' not found", name)
 }
 
-// DeleteHost will remove a host from memory, but not from configuration file.
+// GetHostsByTag will return all hosts tagged with key set to value.
+func (s *ConfigurationStore) GetHostsByTag(_ userdb.Subject, _ string, key string, value string) ([]core.Host, error) {
+	var hosts []core.Host
+
+	s.hostsLock.RLock()
+	for _, host := range s.hosts {
+		if host.HasTag(key, value) {
+			hosts = append(hosts, host)
+		}
+	}
+	s.hostsLock.RUnlock()
+
+	return hosts, nil
+}
+
+// DeleteHost will remove a host and its probes from memory, but not from
+// configuration file.
 func (s *ConfigurationStore) DeleteHost(_ userdb.Subject, id string) error {
 	s.hostsLock.Lock()
+	s.probesLock.Lock()
+
 	host, found := s.hosts[id]
 	if !found {
+		s.probesLock.Unlock()
+		s.hostsLock.Unlock()
+
 		return core.ErrHostNotFound
 	}
 
 	delete(s.hosts, id)
+	probes := s.deleteProbesByHost(id)
 
+	s.probesLock.Unlock()
 	s.hostsLock.Unlock()
 
+	for i := range probes {
+		s.changes.Broadcast("probedelete", &probes[i])
+	}
+
 	s.changes.Broadcast("hostdelete", &host)
 
 	return nil
@@ -201,6 +228,35 @@ func (s *ConfigurationStore) UpdateProbe(_ userdb.Subject, probe *core.Probe) er
 	return nil
 }
 
+// DeleteProbesByHost will delete all probes for the host identified by
+// hostID from memory but not from file.
+func (s *ConfigurationStore) DeleteProbesByHost(_ userdb.Subject, hostID string) error {
+	s.probesLock.Lock()
+	probes := s.deleteProbesByHost(hostID)
+	s.probesLock.Unlock()
+
+	for i := range probes {
+		s.changes.Broadcast("probedelete", &probes[i])
+	}
+
+	return nil
+}
+
+// deleteProbesByHost will delete all probes for hostID and return them.
+// probesLock must be held by the caller.
+func (s *ConfigurationStore) deleteProbesByHost(hostID string) []core.Probe {
+	var deleted []core.Probe
+
+	for id, probe := range s.probes {
+		if probe.HostID == hostID {
+			deleted = append(deleted, probe)
+			delete(s.probes, id)
+		}
+	}
+
+	return deleted
+}
+
 // DeleteProbe does delete the probe from memory but not from file.
 func (s *ConfigurationStore) DeleteProbe(_ userdb.Subject, id string) error {
 	s.probesLock.Lock()
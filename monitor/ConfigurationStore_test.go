@@ -0,0 +1,230 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/core"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/userdb"
+)
+
+type (
+	// requiredAgent has a required configuration field.
+	requiredAgent struct {
+		slowAgent
+
+		Command string `json:"command" description:"Command to run" required:"true"`
+	}
+)
+
+func init() {
+	plugins.Register("requiredagent", func() interface{} { return new(requiredAgent) })
+}
+
+// TestUpdateProbeInvalid makes sure the scheduler can save probes that no
+// longer validate. Validation is done by the API.
+func TestUpdateProbeInvalid(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	probe := &core.Probe{
+		AgentID:     "requiredagent",
+		AgentConfig: map[string]interface{}{"command": "/bin/true"},
+		Interval:    time.Hour,
+	}
+
+	err := store.AddProbe(userdb.God, probe)
+	if err != nil {
+		t.Fatalf("AddProbe() failed: %s", err.Error())
+	}
+
+	probe.AgentConfig = map[string]interface{}{}
+	probe.ConsecutiveFailures = 1
+	err = store.UpdateProbe(userdb.God, probe)
+	if err != nil {
+		t.Fatalf("UpdateProbe() failed: %s", err.Error())
+	}
+
+	stored, _ := store.GetProbe(userdb.God, probe.ID)
+	if stored.ConsecutiveFailures != 1 {
+		t.Fatalf("Update was not stored")
+	}
+}
+
+func TestGetHostsByTag(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	hosts := []*core.Host{
+		{Name: "web1", Tags: map[string]string{"role": "web", "dc": "east"}},
+		{Name: "web2", Tags: map[string]string{"role": "web", "dc": "west"}},
+		{Name: "db1", Tags: map[string]string{"role": "db", "dc": "east"}},
+		{Name: "untagged"},
+	}
+
+	for _, host := range hosts {
+		store.AddHost(userdb.God, host)
+	}
+
+	cases := []struct {
+		key      string
+		value    string
+		expected int
+	}{
+		{"role", "web", 2},
+		{"role", "db", 1},
+		{"dc", "east", 2},
+		{"role", "cache", 0},
+		{"unknown", "web", 0},
+	}
+
+	for _, c := range cases {
+		found, err := store.GetHostsByTag(userdb.God, "", c.key, c.value)
+		if err != nil {
+			t.Fatalf("GetHostsByTag() failed: %s", err.Error())
+		}
+
+		if len(found) != c.expected {
+			t.Errorf("Got %d hosts for %s=%s, expected %d", len(found), c.key, c.value, c.expected)
+		}
+
+		for _, host := range found {
+			if host.Tags[c.key] != c.value {
+				t.Errorf("Host %s returned for %s=%s", host.Name, c.key, c.value)
+			}
+		}
+	}
+}
+
+func TestDeleteHostCascade(t *testing.T) {
+	store, emitter := newTestStore(t)
+
+	doomed := &core.Host{Name: "doomed", TransportID: "localtransport"}
+	store.AddHost(userdb.God, doomed)
+
+	survivor := &core.Host{Name: "survivor", TransportID: "localtransport"}
+	store.AddHost(userdb.God, survivor)
+
+	for _, hostID := range []string{doomed.ID, doomed.ID, survivor.ID} {
+		probe := &core.Probe{
+			HostID:   hostID,
+			AgentID:  "slowagent",
+			Interval: time.Hour,
+		}
+
+		err := store.AddProbe(userdb.God, probe)
+		if err != nil {
+			t.Fatalf("AddProbe() failed: %s", err.Error())
+		}
+	}
+
+	changes := emitter.Subscribe(userdb.God)
+	defer emitter.Unsubscribe(changes)
+
+	events := make(chan string, 10)
+	go func() {
+		for change := range changes {
+			events <- change.Type
+		}
+	}()
+
+	err := store.DeleteHost(userdb.God, doomed.ID)
+	if err != nil {
+		t.Fatalf("DeleteHost() failed: %s", err.Error())
+	}
+
+	probes, _ := store.GetAllProbes(userdb.God, "")
+	if len(probes) != 1 || probes[0].HostID != survivor.ID {
+		t.Fatalf("Got %d probes left after deleting host, expected 1 for the surviving host", len(probes))
+	}
+
+	// Two probes and the host must be announced as deleted, probes first.
+	expected := []string{"probedelete", "probedelete", "hostdelete"}
+	for _, e := range expected {
+		select {
+		case event := <-events:
+			if event != e {
+				t.Fatalf("Got event %s, expected %s", event, e)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Event %s not broadcasted", e)
+		}
+	}
+
+	err = store.DeleteHost(userdb.God, doomed.ID)
+	if err != core.ErrHostNotFound {
+		t.Fatalf("Deleting a deleted host returned %v, expected ErrHostNotFound", err)
+	}
+
+	err = store.DeleteProbesByHost(userdb.God, survivor.ID)
+	if err != nil {
+		t.Fatalf("DeleteProbesByHost() failed: %s", err.Error())
+	}
+
+	probes, _ = store.GetAllProbes(userdb.God, "")
+	if len(probes) != 0 {
+		t.Fatalf("Got %d probes after DeleteProbesByHost(), expected 0", len(probes))
+	}
+
+	host, _ := store.GetHost(userdb.God, survivor.ID)
+	if host == nil {
+		t.Fatalf("DeleteProbesByHost() deleted the host")
+	}
+}
+
+func TestUpsertProbe(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	probe := func(command string, interval time.Duration) *core.Probe {
+		return &core.Probe{
+			HostID:      "000000000000000000000000",
+			AgentID:     "requiredagent",
+			AgentConfig: map[string]interface{}{"command": command},
+			Interval:    interval,
+		}
+	}
+
+	first := probe("/bin/true", time.Minute)
+	err := core.UpsertProbe(userdb.God, store, first)
+	if err != nil {
+		t.Fatalf("UpsertProbe() failed: %s", err.Error())
+	}
+
+	// Simulate a run to make sure state survives an upsert.
+	stored, _ := store.GetProbe(userdb.God, first.ID)
+	stored.ConsecutiveFailures = 2
+	store.UpdateProbe(userdb.God, stored)
+
+	second := probe("/bin/true", time.Hour)
+	err = core.UpsertProbe(userdb.God, store, second)
+	if err != nil {
+		t.Fatalf("UpsertProbe() failed: %s", err.Error())
+	}
+
+	if second.ID != first.ID {
+		t.Errorf("Identical probe got new id %s, expected %s", second.ID, first.ID)
+	}
+
+	probes, _ := store.GetAllProbes(userdb.God, "")
+	if len(probes) != 1 {
+		t.Fatalf("Got %d probes after identical upsert, expected 1", len(probes))
+	}
+
+	if probes[0].Interval != time.Hour {
+		t.Errorf("Interval not updated, got %s", probes[0].Interval)
+	}
+
+	if probes[0].ConsecutiveFailures != 2 {
+		t.Errorf("Probe state was not kept")
+	}
+
+	third := probe("/bin/false", time.Minute)
+	err = core.UpsertProbe(userdb.God, store, third)
+	if err != nil {
+		t.Fatalf("UpsertProbe() failed: %s", err.Error())
+	}
+
+	probes, _ = store.GetAllProbes(userdb.God, "")
+	if len(probes) != 2 || third.ID == first.ID {
+		t.Errorf("Probe with different configuration was not added")
+	}
+}
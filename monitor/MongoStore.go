@@ -50,6 +50,10 @@ func NewMongoStore(config configuration.MongoConfiguration, changes core.Broadca
 func (s *MongoStore) GetAllProbes(subject userdb.Subject, accountID string) ([]core.Probe, error) {
 	var probes []core.Probe
 
+	if !bson.IsObjectIdHex(accountID) {
+		return nil, userdb.ErrorInvalidAccountId
+	}
+
 	err := subject.CanAccess(userdb.ObjectProxy(accountID))
 	if err != nil {
 		return nil, err
@@ -72,6 +76,10 @@ func (s *MongoStore) GetProbe(subject userdb.Subject, id string) (*core.Probe, e
 	}
 
 	err := s.probeCollection.FindId(bson.ObjectIdHex(id)).One(&probe)
+	if err == mgo.ErrNotFound {
+		return nil, core.ErrProbeNotFound
+	}
+
 	if err != nil {
 		logger.Red("mongostore", "Error getting probe %s from Mongo: %s", id, err.Error())
 		return nil, err
@@ -128,6 +136,39 @@ func (s *MongoStore) DeleteProbe(subject userdb.Subject, id string) error {
 	return s.probeCollection.RemoveId(bson.ObjectIdHex(id))
 }
 
+// DeleteProbesByHost will delete all probes for the host identified by
+// hostID.
+func (s *MongoStore) DeleteProbesByHost(subject userdb.Subject, hostID string) error {
+	host, err := s.GetHost(subject, hostID)
+	if err != nil {
+		return err
+	}
+
+	return s.deleteProbesByHost(host)
+}
+
+// deleteProbesByHost will delete all probes for host. Access must be checked
+// by the caller.
+func (s *MongoStore) deleteProbesByHost(host *core.Host) error {
+	var probes []core.Probe
+
+	err := s.probeCollection.Find(bson.M{"hostid": host.ID}).All(&probes)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.probeCollection.RemoveAll(bson.M{"hostid": host.ID})
+	if err != nil {
+		return err
+	}
+
+	for i := range probes {
+		s.changes.Broadcast("probedelete", &probes[i])
+	}
+
+	return nil
+}
+
 // GetAllHosts will return all hosts accessible by subject.
 func (s *MongoStore) GetAllHosts(subject userdb.Subject, accountID string) ([]core.Host, error) {
 	var hosts []core.Host
@@ -162,6 +203,29 @@ func (s *MongoStore) GetHostByName(subject userdb.Subject, name string) (*core.H
 	return &host, nil
 }
 
+// GetHostsByTag will return all hosts belonging to accountID tagged with key
+// set to value.
+func (s *MongoStore) GetHostsByTag(subject userdb.Subject, accountID string, key string, value string) ([]core.Host, error) {
+	var hosts []core.Host
+
+	err := subject.CanAccess(userdb.ObjectProxy(accountID))
+	if err != nil {
+		return nil, err
+	}
+
+	query := bson.M{
+		"accountID":   bson.ObjectIdHex(accountID),
+		"tags." + key: value,
+	}
+
+	err = s.hostCollection.Find(query).All(&hosts)
+	if err != nil {
+		return nil, err
+	}
+
+	return hosts, nil
+}
+
 // GetHost returns a host matching id.
 func (s *MongoStore) GetHost(subject userdb.Subject, id string) (*core.Host, error) {
 	var host core.Host
@@ -171,6 +235,10 @@ func (s *MongoStore) GetHost(subject userdb.Subject, id string) (*core.Host, err
 	}
 
 	err := s.hostCollection.FindId(bson.ObjectIdHex(id)).One(&host)
+	if err == mgo.ErrNotFound {
+		return nil, core.ErrHostNotFound
+	}
+
 	if err != nil {
 		logger.Red("mongostore", "Error getting host from Mongo: %s", err.Error())
 		return nil, err
@@ -201,7 +269,7 @@ func (s *MongoStore) AddHost(subject userdb.Subject, host *core.Host) error {
 	return s.hostCollection.Insert(host)
 }
 
-// DeleteHost will delete a host matching id.
+// DeleteHost will delete a host matching id and all probes for the host.
 func (s *MongoStore) DeleteHost(subject userdb.Subject, id string) error {
 	if !bson.IsObjectIdHex(id) {
 		return core.ErrHostNotFound
@@ -217,6 +285,13 @@ func (s *MongoStore) DeleteHost(subject userdb.Subject, id string) error {
 		return err
 	}
 
+	// Remove probes first, we would rather leave a host without probes
+	// than orphaned probes.
+	err = s.deleteProbesByHost(host)
+	if err != nil {
+		return err
+	}
+
 	s.changes.Broadcast("hostdelete", host)
 
 	return s.hostCollection.RemoveId(bson.ObjectIdHex(id))
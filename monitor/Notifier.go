@@ -0,0 +1,202 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/core"
+	"github.com/abrander/agento/logger"
+	"github.com/abrander/agento/userdb"
+)
+
+type (
+	// Notifier will POST probe state changes to a webhook.
+	Notifier struct {
+		emitter core.Emitter
+		store   core.HostStore
+		subject userdb.Subject
+		url     string
+		auth    string
+		retries int
+
+		// retryDelay is the delay before the first retry. It's doubled for
+		// each retry.
+		retryDelay time.Duration
+
+		client *http.Client
+		queue  chan Notification
+	}
+
+	// Notification is the payload sent to the webhook.
+	Notification struct {
+		Probe     string    `json:"probe"`
+		Host      string    `json:"host"`
+		State     string    `json:"state"`
+		Time      time.Time `json:"time"`
+		LastError string    `json:"lastError"`
+	}
+)
+
+const (
+	// notifierQueueSize is the number of notifications that can wait for
+	// delivery. If the queue is full, notifications are dropped.
+	notifierQueueSize = 100
+)
+
+var (
+	// states maps events to the state reported to the webhook.
+	states = map[string]string{
+		"probeup":   "up",
+		"probedown": "down",
+	}
+)
+
+// NewNotifier will instantiate a new notifier following changes from emitter.
+// Host names are looked up in store.
+func NewNotifier(cfg configuration.NotifierConfiguration, emitter core.Emitter, store core.HostStore, subject userdb.Subject) *Notifier {
+	return &Notifier{
+		emitter:    emitter,
+		store:      store,
+		subject:    subject,
+		url:        cfg.WebhookURL,
+		auth:       cfg.Authorization,
+		retries:    cfg.Retries,
+		retryDelay: time.Second,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		queue:      make(chan Notification, notifierQueueSize),
+	}
+}
+
+// Loop will send notifications until ctx is cancelled.
+func (n *Notifier) Loop(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	changes := n.emitter.Subscribe(n.subject)
+
+	// Delivery may take a while, we must not block the emitter while
+	// retrying.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for notification := range n.queue {
+			err := n.send(notification)
+			if err != nil {
+				logger.Red("notifier", "Failed to notify about %s: %s", notification.Probe, err.Error())
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// A probe may be broadcasting to us while holding the emitter
+			// lock, we must keep draining changes until unsubscribed.
+			stop := make(chan struct{})
+			go func() {
+				for {
+					select {
+					case <-changes:
+					case <-stop:
+						return
+					}
+				}
+			}()
+
+			n.emitter.Unsubscribe(changes)
+			close(stop)
+			close(n.queue)
+			<-done
+
+			return
+		case change := <-changes:
+			state, found := states[change.Type]
+			if !found {
+				continue
+			}
+
+			probe, ok := change.Payload.(*core.Probe)
+			if !ok {
+				continue
+			}
+
+			select {
+			case n.queue <- n.notification(probe, state):
+			default:
+				logger.Red("notifier", "Queue full, dropping notification about %s", probe.ID)
+			}
+		}
+	}
+}
+
+// notification will build a notification for probe.
+func (n *Notifier) notification(probe *core.Probe, state string) Notification {
+	hostname := probe.HostID
+
+	host, err := n.store.GetHost(n.subject, probe.HostID)
+	if err == nil {
+		hostname = host.Name
+	}
+
+	return Notification{
+		Probe:     probe.ID,
+		Host:      hostname,
+		State:     state,
+		Time:      probe.LastCheck,
+		LastError: probe.LastError,
+	}
+}
+
+// send will POST notification to the webhook. If the request fails or a
+// non-2xx status is returned, the request is retried.
+func (n *Notifier) send(notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	delay := n.retryDelay
+
+	for attempt := 0; ; attempt++ {
+		err = n.post(body)
+		if err == nil || attempt >= n.retries {
+			return err
+		}
+
+		logger.Yellow("notifier", "Error posting to webhook: %s, retry %d/%d", err.Error(), attempt+1, n.retries)
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// post will do a single POST of body to the webhook.
+func (n *Notifier) post(body []byte) error {
+	req, err := http.NewRequest("POST", n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if n.auth != "" {
+		req.Header.Set("Authorization", n.auth)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/core"
+	"github.com/abrander/agento/userdb"
+)
+
+func TestNotifier(t *testing.T) {
+	var lock sync.Mutex
+	var requests int
+	received := make(chan Notification, 1)
+
+	// Fail the first two requests.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		requests++
+		attempt := requests
+		lock.Unlock()
+
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Wrong Authorization header '%s'", r.Header.Get("Authorization"))
+		}
+
+		if attempt < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		var notification Notification
+		err := json.NewDecoder(r.Body).Decode(&notification)
+		if err != nil {
+			t.Errorf("Failed to decode payload: %s", err.Error())
+		}
+
+		received <- notification
+	}))
+	defer server.Close()
+
+	store, emitter := newTestStore(t)
+	core.AddLocalhost(userdb.God, store)
+
+	cfg := configuration.NotifierConfiguration{
+		WebhookURL:    server.URL,
+		Authorization: "Bearer secret",
+		Retries:       3,
+	}
+
+	n := NewNotifier(cfg, emitter, store, userdb.God)
+	n.retryDelay = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go n.Loop(ctx, &wg)
+
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	probe := &core.Probe{
+		ID:        "probe1",
+		HostID:    "000000000000000000000000",
+		LastCheck: now,
+		LastError: "connection refused",
+	}
+
+	// Wait for the notifier to subscribe.
+	time.Sleep(50 * time.Millisecond)
+
+	// Other events must be ignored.
+	emitter.Broadcast("probechange", probe)
+	emitter.Broadcast("probedown", probe)
+
+	select {
+	case notification := <-received:
+		hostname, _ := os.Hostname()
+		expected := Notification{
+			Probe:     "probe1",
+			Host:      hostname,
+			State:     "down",
+			Time:      now,
+			LastError: "connection refused",
+		}
+
+		if notification != expected {
+			t.Errorf("Got %+v, expected %+v", notification, expected)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Notification not received")
+	}
+
+	cancel()
+	wg.Wait()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if requests != 3 {
+		t.Fatalf("Got %d requests, expected 3", requests)
+	}
+}
+
+func TestNotifierShutdownBroadcasting(t *testing.T) {
+	store, emitter := newTestStore(t)
+
+	// The first listener blocks broadcasts until read from.
+	blocker := emitter.Subscribe(userdb.God)
+
+	n := NewNotifier(configuration.NotifierConfiguration{}, emitter, store, userdb.God)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go n.Loop(ctx, &wg)
+
+	// Wait for the notifier to subscribe.
+	time.Sleep(50 * time.Millisecond)
+
+	probe := &core.Probe{ID: "probe1", HostID: "000000000000000000000000"}
+	go emitter.Broadcast("probechange", probe)
+
+	// Let the notifier stop while the broadcast is in progress.
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+
+	<-blocker
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Notifier did not stop while a broadcast was in progress")
+	}
+}
+
+func TestNotifierGiveUp(t *testing.T) {
+	var lock sync.Mutex
+	var requests int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		requests++
+		lock.Unlock()
+
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	store, emitter := newTestStore(t)
+
+	n := NewNotifier(configuration.NotifierConfiguration{WebhookURL: server.URL, Retries: 2}, emitter, store, userdb.God)
+	n.retryDelay = time.Millisecond
+
+	err := n.send(Notification{Probe: "probe1", State: "up"})
+	if err == nil {
+		t.Fatalf("send() did not fail")
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if requests != 3 {
+		t.Fatalf("Got %d requests, expected 3", requests)
+	}
+}
@@ -0,0 +1,99 @@
+package monitor
+
+import (
+	"container/heap"
+	"time"
+
+	"github.com/abrander/agento/core"
+)
+
+type (
+	// probeQueue is a min-heap of probes ordered by NextCheck. It is not
+	// safe for concurrent use.
+	probeQueue struct {
+		items []*queueItem
+		index map[string]*queueItem
+	}
+
+	queueItem struct {
+		probe core.Probe
+		index int
+	}
+)
+
+func newProbeQueue() *probeQueue {
+	return &probeQueue{
+		index: make(map[string]*queueItem),
+	}
+}
+
+// Len implements heap.Interface.
+func (q *probeQueue) Len() int {
+	return len(q.items)
+}
+
+// Less implements heap.Interface.
+func (q *probeQueue) Less(i, j int) bool {
+	return q.items[i].probe.NextCheck.Before(q.items[j].probe.NextCheck)
+}
+
+// Swap implements heap.Interface.
+func (q *probeQueue) Swap(i, j int) {
+	q.items[i], q.items[j] = q.items[j], q.items[i]
+	q.items[i].index = i
+	q.items[j].index = j
+}
+
+// Push implements heap.Interface. Use schedule() instead.
+func (q *probeQueue) Push(x interface{}) {
+	item := x.(*queueItem)
+	item.index = len(q.items)
+	q.items = append(q.items, item)
+	q.index[item.probe.ID] = item
+}
+
+// Pop implements heap.Interface. Use due() instead.
+func (q *probeQueue) Pop() interface{} {
+	n := len(q.items)
+	item := q.items[n-1]
+	q.items[n-1] = nil
+	q.items = q.items[:n-1]
+	delete(q.index, item.probe.ID)
+
+	return item
+}
+
+// schedule will add probe to the queue or update it if already present.
+func (q *probeQueue) schedule(probe core.Probe) {
+	item, found := q.index[probe.ID]
+	if found {
+		item.probe = probe
+		heap.Fix(q, item.index)
+
+		return
+	}
+
+	heap.Push(q, &queueItem{probe: probe})
+}
+
+// remove will remove the probe identified by id from the queue.
+func (q *probeQueue) remove(id string) {
+	item, found := q.index[id]
+	if !found {
+		return
+	}
+
+	heap.Remove(q, item.index)
+}
+
+// due will remove and return all probes scheduled at or before t.
+func (q *probeQueue) due(t time.Time) []core.Probe {
+	var probes []core.Probe
+
+	for len(q.items) > 0 && !q.items[0].probe.NextCheck.After(t) {
+		item := heap.Pop(q).(*queueItem)
+		probes = append(probes, item.probe)
+	}
+
+	return probes
+}
@@ -0,0 +1,42 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/core"
+)
+
+func TestProbeQueue(t *testing.T) {
+	now := time.Now()
+	q := newProbeQueue()
+
+	q.schedule(core.Probe{ID: "a", NextCheck: now.Add(3 * time.Second)})
+	q.schedule(core.Probe{ID: "b", NextCheck: now.Add(1 * time.Second)})
+	q.schedule(core.Probe{ID: "c", NextCheck: now.Add(2 * time.Second)})
+	q.schedule(core.Probe{ID: "d", NextCheck: now.Add(4 * time.Second)})
+
+	// Reschedule a to be first, and remove d.
+	q.schedule(core.Probe{ID: "a", NextCheck: now})
+	q.remove("d")
+	q.remove("unknown")
+
+	if q.Len() != 3 {
+		t.Fatalf("Wrong length, got %d, expected 3", q.Len())
+	}
+
+	due := q.due(now.Add(2 * time.Second))
+	if len(due) != 3 {
+		t.Fatalf("Got %d due probes, expected 3", len(due))
+	}
+
+	for i, id := range []string{"a", "b", "c"} {
+		if due[i].ID != id {
+			t.Errorf("Probe %d is '%s', expected '%s'", i, due[i].ID, id)
+		}
+	}
+
+	if len(q.due(now.Add(time.Hour))) != 0 {
+		t.Errorf("Queue not empty")
+	}
+}
@@ -0,0 +1,187 @@
+package monitor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/core"
+	"github.com/abrander/agento/logger"
+)
+
+type (
+	// RedisElector elects a single leader among Agento instances using a
+	// lease in Redis. The leader renews the lease, if it dies another
+	// instance will take over when the lease expires.
+	RedisElector struct {
+		pool     *redis.Pool
+		key      string
+		instance string
+		ttl      time.Duration
+
+		// expires is when the lease held by this instance runs out
+		// unless renewed. A stalled Redis call must not keep us leading
+		// after another instance may have taken over.
+		lock    sync.Mutex
+		expires time.Time
+	}
+)
+
+const (
+	// DefaultLeaseTTL is the lifetime of the lease unless configured.
+	DefaultLeaseTTL = 10 * time.Second
+)
+
+var (
+	// campaignScript will take the lease if it's free or renew it if it's
+	// held by ARGV[1]. 1 is returned if ARGV[1] holds the lease.
+	campaignScript = redis.NewScript(1, `
+		local holder = redis.call("GET", KEYS[1])
+		if holder == ARGV[1] then
+			redis.call("PEXPIRE", KEYS[1], ARGV[2])
+			return 1
+		end
+
+		if not holder then
+			redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+			return 1
+		end
+
+		return 0
+	`)
+
+	// resignScript will delete the lease if it's held by ARGV[1].
+	resignScript = redis.NewScript(1, `
+		if redis.call("GET", KEYS[1]) == ARGV[1] then
+			return redis.call("DEL", KEYS[1])
+		end
+
+		return 0
+	`)
+)
+
+// NewRedisElector will return an elector using the Redis server at cfg.URL.
+// The lease is stored next to the pub/sub channel used by RedisEmitter.
+func NewRedisElector(cfg configuration.RedisConfiguration) *RedisElector {
+	channel := cfg.Channel
+	if channel == "" {
+		channel = DefaultRedisChannel
+	}
+
+	ttl := time.Duration(cfg.LeaseTTL) * time.Second
+	if ttl <= 0 {
+		ttl = DefaultLeaseTTL
+	}
+
+	url := cfg.URL
+
+	// A call must fail well before the lease expires.
+	timeout := ttl / 3
+
+	return &RedisElector{
+		pool: &redis.Pool{
+			MaxIdle:     1,
+			IdleTimeout: time.Minute,
+			Dial: func() (redis.Conn, error) {
+				return redis.DialURL(url,
+					redis.DialConnectTimeout(timeout),
+					redis.DialReadTimeout(timeout),
+					redis.DialWriteTimeout(timeout),
+				)
+			},
+		},
+		key:      channel + ":leader",
+		instance: core.RandomString(16),
+		ttl:      ttl,
+	}
+}
+
+// IsLeader will return true while this instance holds the lease. The lease
+// is considered lost when not renewed within its lifetime, even if Redis
+// never told us.
+func (e *RedisElector) IsLeader() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return time.Now().Before(e.expires)
+}
+
+// campaign will try to take or renew the lease. Leadership is given up if
+// Redis can't be reached, as another instance may take over when the lease
+// expires.
+func (e *RedisElector) campaign() bool {
+	// The lease is renewed from the time we ask, not when Redis answers.
+	start := time.Now()
+
+	conn := e.pool.Get()
+	defer conn.Close()
+
+	held, err := redis.Int(campaignScript.Do(conn, e.key, e.instance, e.ttl.Nanoseconds()/int64(time.Millisecond)))
+	if err != nil {
+		logger.Red("elector", "Can't campaign for %s: %s", e.key, err.Error())
+	}
+
+	leader := err == nil && held == 1
+	wasLeader := e.IsLeader()
+
+	switch {
+	case leader && !wasLeader:
+		logger.Green("elector", "Elected leader")
+	case !leader && wasLeader:
+		logger.Yellow("elector", "Lost leadership")
+	}
+
+	e.lock.Lock()
+	if leader {
+		e.expires = start.Add(e.ttl)
+	} else {
+		e.expires = time.Time{}
+	}
+	e.lock.Unlock()
+
+	return leader
+}
+
+// resign will give up the lease, letting another instance take over at once.
+func (e *RedisElector) resign() {
+	e.lock.Lock()
+	e.expires = time.Time{}
+	e.lock.Unlock()
+
+	conn := e.pool.Get()
+	defer conn.Close()
+
+	_, err := resignScript.Do(conn, e.key, e.instance)
+	if err != nil {
+		logger.Red("elector", "Can't resign %s: %s", e.key, err.Error())
+	}
+}
+
+// Loop will campaign for the lease three times per lease lifetime until ctx
+// is cancelled. The lease is given up when Loop returns.
+func (e *RedisElector) Loop(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer e.pool.Close()
+
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	e.campaign()
+
+	for {
+		select {
+		case <-ctx.Done():
+			e.resign()
+
+			return
+		case <-ticker.C:
+			e.campaign()
+		}
+	}
+}
+
+// Ensure compliance.
+var _ Elector = (*RedisElector)(nil)
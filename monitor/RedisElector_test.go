@@ -0,0 +1,112 @@
+package monitor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/abrander/agento/configuration"
+)
+
+// waitForLeader will wait up to a second for e to become leader.
+func waitForLeader(e *RedisElector) bool {
+	deadline := time.Now().Add(time.Second)
+
+	for time.Now().Before(deadline) {
+		if e.IsLeader() {
+			return true
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	return false
+}
+
+func TestRedisElectorFailover(t *testing.T) {
+	m, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis.Run() failed: %s", err.Error())
+	}
+	defer m.Close()
+
+	cfg := configuration.RedisConfiguration{URL: "redis://" + m.Addr(), LeaseTTL: 1}
+
+	// a takes the lease once and dies without giving it up.
+	a := NewRedisElector(cfg)
+	if !a.campaign() || !a.IsLeader() {
+		t.Fatalf("First elector did not become leader")
+	}
+
+	// Leadership must run out locally if the lease isn't renewed, even if
+	// Redis never answers.
+	a.lock.Lock()
+	expires := a.expires
+	a.expires = time.Now().Add(-time.Millisecond)
+	a.lock.Unlock()
+
+	if a.IsLeader() {
+		t.Errorf("Elector is leader after the lease expired")
+	}
+
+	a.lock.Lock()
+	a.expires = expires
+	a.lock.Unlock()
+
+	b := NewRedisElector(cfg)
+
+	wg := sync.WaitGroup{}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	wg.Add(1)
+	go b.Loop(ctx, &wg)
+
+	if waitForLeader(b) {
+		t.Fatalf("Follower became leader while the lease was held")
+	}
+
+	// The lease expires as a no longer renews it.
+	m.FastForward(time.Second)
+
+	if !waitForLeader(b) {
+		t.Fatalf("Follower did not take over after the lease expired")
+	}
+
+	if a.campaign() {
+		t.Errorf("Old leader got the lease back")
+	}
+
+	// Giving up the lease lets another instance take over at once.
+	cancel()
+	wg.Wait()
+
+	if b.IsLeader() {
+		t.Errorf("Elector is leader after Loop returned")
+	}
+
+	if !a.campaign() {
+		t.Errorf("Lease was not given up")
+	}
+}
+
+func TestRedisElectorUnavailable(t *testing.T) {
+	m, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis.Run() failed: %s", err.Error())
+	}
+
+	e := NewRedisElector(configuration.RedisConfiguration{URL: "redis://" + m.Addr()})
+	if !e.campaign() {
+		t.Fatalf("Elector did not become leader")
+	}
+
+	// A leader unable to renew the lease must step down.
+	m.Close()
+
+	if e.campaign() || e.IsLeader() {
+		t.Errorf("Elector is still leader without Redis")
+	}
+}
@@ -0,0 +1,282 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/core"
+	"github.com/abrander/agento/logger"
+	"github.com/abrander/agento/userdb"
+)
+
+type (
+	// RedisEmitter is an Emitter and Broadcaster sharing changes between
+	// Agento instances using Redis pub/sub. Changes are delivered to local
+	// listeners at once and published to other instances.
+	RedisEmitter struct {
+		*core.SimpleEmitter
+
+		instance string
+		channel  string
+		url      string
+		pool     *redis.Pool
+
+		lock   sync.Mutex
+		conn   redis.PubSubConn
+		closed bool
+		done   chan struct{}
+	}
+
+	// redisMessage is a change published to Redis.
+	redisMessage struct {
+		Instance  string          `json:"instance"`
+		Type      string          `json:"type"`
+		AccountID string          `json:"accountId"`
+		Payload   json.RawMessage `json:"payload"`
+	}
+)
+
+var (
+	// ErrEmitterClosed is returned when subscribing after Close().
+	ErrEmitterClosed = errors.New("emitter is closed")
+)
+
+const (
+	// DefaultRedisChannel is used if no channel is configured.
+	DefaultRedisChannel = "agento"
+
+	// redisReconnectDelay is the delay before resubscribing after losing
+	// the connection to Redis.
+	redisReconnectDelay = time.Second
+)
+
+// NewRedisEmitter will return an emitter publishing changes to the Redis
+// server at cfg.URL. The subscription is active when NewRedisEmitter
+// returns.
+func NewRedisEmitter(cfg configuration.RedisConfiguration) (*RedisEmitter, error) {
+	channel := cfg.Channel
+	if channel == "" {
+		channel = DefaultRedisChannel
+	}
+
+	url := cfg.URL
+	r := &RedisEmitter{
+		SimpleEmitter: core.NewSimpleEmitter(),
+		instance:      core.RandomString(16),
+		channel:       channel,
+		url:           url,
+		pool: &redis.Pool{
+			MaxIdle:     2,
+			IdleTimeout: time.Minute,
+			Dial: func() (redis.Conn, error) {
+				return redis.DialURL(url)
+			},
+		},
+		done: make(chan struct{}),
+	}
+
+	err := r.subscribe()
+	if err != nil {
+		r.pool.Close()
+
+		return nil, err
+	}
+
+	go r.receive()
+
+	return r, nil
+}
+
+// subscribe will connect to Redis and wait for the subscription to be
+// confirmed.
+func (r *RedisEmitter) subscribe() error {
+	c, err := redis.DialURL(r.url)
+	if err != nil {
+		return err
+	}
+
+	conn := redis.PubSubConn{Conn: c}
+
+	err = conn.Subscribe(r.channel)
+	if err != nil {
+		c.Close()
+
+		return err
+	}
+
+	switch v := conn.Receive().(type) {
+	case redis.Subscription:
+	case error:
+		c.Close()
+
+		return v
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.closed {
+		c.Close()
+
+		return ErrEmitterClosed
+	}
+
+	r.conn = conn
+
+	return nil
+}
+
+// receive will deliver changes published by other instances to local
+// listeners until Close() is called. The subscription is renewed if the
+// connection to Redis is lost.
+func (r *RedisEmitter) receive() {
+	for {
+		r.lock.Lock()
+		conn := r.conn
+		r.lock.Unlock()
+
+		err := r.deliver(conn)
+
+		select {
+		case <-r.done:
+			return
+		default:
+		}
+
+		logger.Red("redisemitter", "Lost subscription to %s: %s", r.channel, err.Error())
+
+		for {
+			select {
+			case <-r.done:
+				return
+			case <-time.After(redisReconnectDelay):
+			}
+
+			err = r.subscribe()
+			if err == nil {
+				break
+			}
+
+			logger.Red("redisemitter", "Can't subscribe to %s: %s", r.channel, err.Error())
+		}
+	}
+}
+
+// deliver will read messages from conn until an error occurs.
+func (r *RedisEmitter) deliver(conn redis.PubSubConn) error {
+	for {
+		switch v := conn.Receive().(type) {
+		case redis.Message:
+			typ, payload, err := r.decode(v.Data)
+			if err != nil {
+				logger.Yellow("redisemitter", "Ignoring message: %s", err.Error())
+				continue
+			}
+
+			if payload != nil {
+				r.SimpleEmitter.Broadcast(typ, payload)
+			}
+		case error:
+			return v
+		}
+	}
+}
+
+// decode will decode a message published by Broadcast(). A nil payload is
+// returned for messages published by this instance, as they're already
+// delivered.
+func (r *RedisEmitter) decode(data []byte) (string, userdb.Object, error) {
+	var message redisMessage
+	err := json.Unmarshal(data, &message)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if message.Instance == r.instance {
+		return "", nil, nil
+	}
+
+	var payload userdb.Object
+	switch {
+	case strings.HasPrefix(message.Type, "probe"):
+		payload = &core.Probe{}
+	case strings.HasPrefix(message.Type, "host"):
+		payload = &core.Host{}
+	default:
+		return message.Type, userdb.ObjectProxy(message.AccountID), nil
+	}
+
+	err = json.Unmarshal(message.Payload, payload)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return message.Type, payload, nil
+}
+
+// Broadcast implements core.Broadcaster. The change is delivered to local
+// listeners and published to other instances.
+func (r *RedisEmitter) Broadcast(typ string, payload userdb.Object) {
+	r.SimpleEmitter.Broadcast(typ, payload)
+
+	err := r.publish(typ, payload)
+	if err != nil {
+		logger.Red("redisemitter", "Can't publish %s: %s", typ, err.Error())
+	}
+}
+
+// publish will publish a change to Redis.
+func (r *RedisEmitter) publish(typ string, payload userdb.Object) error {
+	p, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(redisMessage{
+		Instance:  r.instance,
+		Type:      typ,
+		AccountID: payload.GetAccountId(),
+		Payload:   p,
+	})
+	if err != nil {
+		return err
+	}
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	_, err = conn.Do("PUBLISH", r.channel, data)
+
+	return err
+}
+
+// Close will stop receiving changes from other instances.
+func (r *RedisEmitter) Close() error {
+	r.lock.Lock()
+	if r.closed {
+		r.lock.Unlock()
+
+		return nil
+	}
+
+	r.closed = true
+	close(r.done)
+	conn := r.conn
+	r.lock.Unlock()
+
+	conn.Close()
+
+	return r.pool.Close()
+}
+
+// Ensure compliance.
+var (
+	_ core.Emitter     = (*RedisEmitter)(nil)
+	_ core.Broadcaster = (*RedisEmitter)(nil)
+)
@@ -0,0 +1,114 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/core"
+	"github.com/abrander/agento/userdb"
+)
+
+// newTestRedisEmitter will return an emitter using the miniredis server m.
+func newTestRedisEmitter(t *testing.T, m *miniredis.Miniredis) *RedisEmitter {
+	r, err := NewRedisEmitter(configuration.RedisConfiguration{URL: "redis://" + m.Addr()})
+	if err != nil {
+		t.Fatalf("NewRedisEmitter() failed: %s", err.Error())
+	}
+
+	return r
+}
+
+// receive will wait up to a second for a change on ch.
+func receive(t *testing.T, ch chan core.Change) core.Change {
+	select {
+	case change := <-ch:
+		return change
+	case <-time.After(time.Second):
+		t.Fatalf("No change received")
+	}
+
+	return core.Change{}
+}
+
+func TestRedisEmitter(t *testing.T) {
+	m, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis.Run() failed: %s", err.Error())
+	}
+	defer m.Close()
+
+	a := newTestRedisEmitter(t, m)
+	defer a.Close()
+
+	b := newTestRedisEmitter(t, m)
+	defer b.Close()
+
+	changesA := a.Subscribe(userdb.God)
+	changesB := b.Subscribe(userdb.God)
+
+	probe := &core.Probe{
+		ID:        "000000000000000000000042",
+		AccountID: userdb.God.GetAccountId(),
+		AgentID:   "entropy",
+		Interval:  time.Minute,
+	}
+
+	go a.Broadcast("probeadd", probe)
+
+	// The local listener gets the probe itself.
+	change := receive(t, changesA)
+	if change.Type != "probeadd" || change.Payload != probe {
+		t.Errorf("Got %s %+v locally, expected probeadd %+v", change.Type, change.Payload, probe)
+	}
+
+	// The other instance gets a copy.
+	change = receive(t, changesB)
+	if change.Type != "probeadd" {
+		t.Fatalf("Got %s on the other instance, expected probeadd", change.Type)
+	}
+
+	received, ok := change.Payload.(*core.Probe)
+	if !ok {
+		t.Fatalf("Got payload %T, expected *core.Probe", change.Payload)
+	}
+
+	if received.ID != probe.ID || received.AgentID != probe.AgentID || received.Interval != probe.Interval {
+		t.Errorf("Got %+v, expected %+v", received, probe)
+	}
+
+	go b.Broadcast("hostdelete", &core.Host{ID: "000000000000000000000007", AccountID: userdb.God.GetAccountId(), Name: "web1"})
+
+	receive(t, changesB)
+
+	change = receive(t, changesA)
+	host, ok := change.Payload.(*core.Host)
+	if change.Type != "hostdelete" || !ok || host.Name != "web1" {
+		t.Errorf("Got %s %+v, expected hostdelete for web1", change.Type, change.Payload)
+	}
+
+	// Instances must not receive their own changes twice.
+	select {
+	case change := <-changesA:
+		t.Errorf("Got unexpected change %s", change.Type)
+	case change := <-changesB:
+		t.Errorf("Got unexpected change %s", change.Type)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestRedisEmitterUnavailable(t *testing.T) {
+	m, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis.Run() failed: %s", err.Error())
+	}
+	addr := m.Addr()
+	m.Close()
+
+	_, err = NewRedisEmitter(configuration.RedisConfiguration{URL: "redis://" + addr})
+	if err == nil {
+		t.Fatalf("NewRedisEmitter() did not fail without Redis")
+	}
+}
@@ -1,12 +1,16 @@
 package monitor
 
 import (
+	"context"
+	"errors"
 	"math/rand"
 	"sync"
 	"time"
 
 	"github.com/abrander/agento/core"
 	"github.com/abrander/agento/logger"
+	"github.com/abrander/agento/metrics"
+	"github.com/abrander/agento/plugins"
 	"github.com/abrander/agento/timeseries"
 	"github.com/abrander/agento/userdb"
 )
@@ -14,145 +18,626 @@ import (
 type (
 	// Scheduler is a scheduler executing probes.
 	Scheduler struct {
-		store   core.Store
-		subject userdb.Subject
+		store       core.Store
+		emitter     core.Emitter
+		broadcaster core.Broadcaster
+		subject     userdb.Subject
+
+		// queue holds all probes not currently running ordered by NextCheck.
+		queueLock sync.Mutex
+		queue     *probeQueue
+
+		// inFlight is a list of probes id's currently running
+		inFlightLock sync.RWMutex
+		inFlight     map[string]bool
+
+		// running keeps track of probe go routines, we wait for them to
+		// finish before returning from Loop.
+		running sync.WaitGroup
+
+		// slots limits the number of probes running at once. If nil, there
+		// is no limit.
+		slots chan struct{}
+
+		// spreadFactor is the part of the interval used to jitter runs of
+		// probes without their own spread factor.
+		spreadFactor float64
+
+		// resolution is the time between ticks. Probes can't be run more
+		// precisely than this.
+		resolution time.Duration
+
+		// elector decides if this instance runs probes. If nil, probes are
+		// always run.
+		elector Elector
+
+		// leader is true if probes were run at the last tick.
+		leader bool
+
+		// rates keeps the previous sample of each probe, allowing agents
+		// to report counters as rates.
+		rates *plugins.Rates
+	}
+
+	// Elector elects a single instance to run probes when running more
+	// than one instance of Agento.
+	Elector interface {
+		IsLeader() bool
 	}
 )
 
+const (
+	// maxBackoff is the maximum number of intervals a failing probe will be
+	// delayed.
+	maxBackoff = 10
+
+	// DefaultTickResolution is the time between ticks unless changed by
+	// SetTickResolution().
+	DefaultTickResolution = 100 * time.Millisecond
+)
+
+var (
+	// ErrTimeout will be recorded for probes not done within their timeout.
+	ErrTimeout = errors.New("Probe timed out")
+)
+
 // NewScheduler will instantiate a new scheduler. The scheduler needs a Store to
-// read/write checks. If the system is not a multiuser system, userdb.God can be
-// used as subject.
-func NewScheduler(store core.Store, subject userdb.Subject) *Scheduler {
+// read/write checks, an Emitter to follow changes to probes and a Broadcaster
+// for announcing state changes. If the system is not a multiuser system,
+// userdb.God can be used as subject.
+func NewScheduler(store core.Store, emitter core.Emitter, broadcaster core.Broadcaster, subject userdb.Subject) *Scheduler {
 	return &Scheduler{
-		store:   store,
-		subject: subject,
+		store:       store,
+		emitter:     emitter,
+		broadcaster: broadcaster,
+		subject:     subject,
+		queue:       newProbeQueue(),
+		inFlight:    make(map[string]bool),
+		resolution:  DefaultTickResolution,
+		rates:       plugins.NewRates(),
 	}
 }
 
-// Loop will simply loop through all probes and emit changes and execute jobs.
-func (s *Scheduler) Loop(wg *sync.WaitGroup, serv timeseries.Database) {
+// SetMaxConcurrentChecks will limit the number of probes running at once to
+// n. Probes due while n probes are running will wait for their turn. Zero or
+// less means no limit. Must be called before Loop.
+func (s *Scheduler) SetMaxConcurrentChecks(n int) {
+	if n <= 0 {
+		s.slots = nil
+		return
+	}
+
+	s.slots = make(chan struct{}, n)
+}
+
+// SetSpreadFactor will set the part of the interval used to jitter probe runs
+// for probes without their own spread factor. f is clamped to 0.0-1.0. Zero
+// disables jitter. Must be called before Loop.
+func (s *Scheduler) SetSpreadFactor(f float64) {
+	switch {
+	case f < 0.0:
+		f = 0.0
+	case f > 1.0:
+		f = 1.0
+	}
+
+	s.spreadFactor = f
+}
+
+// SetTickResolution will set the time between checking for due probes. A
+// coarser resolution means fewer wakeups, a finer more precise scheduling.
+// Zero or less means DefaultTickResolution. Must be called before Loop.
+func (s *Scheduler) SetTickResolution(d time.Duration) {
+	if d <= 0 {
+		d = DefaultTickResolution
+	}
+
+	s.resolution = d
+}
+
+// SetElector will make the scheduler run probes only while e says this
+// instance is the leader. Followers keep the queue up to date and reload it
+// from the store when elected. Must be called before Loop.
+func (s *Scheduler) SetElector(e Elector) {
+	s.elector = e
+}
+
+// Loop will load all probes once and execute them when due. Changes to probes
+// are picked up from the emitter, the store is not queried again.
+// Loop will return when ctx is cancelled, after all running probes are done.
+func (s *Scheduler) Loop(ctx context.Context, wg *sync.WaitGroup, serv timeseries.Database) {
+	// Make sure we have the magic localhost. Maybe we should move this somewhere else.
 	err := core.AddLocalhost(s.subject, s.store)
 	if err != nil {
-		logger.Red("Failed to add localhost: %s", err.Error())
+		logger.Red("scheduler", "Failed to add localhost: %s", err.Error())
 		wg.Done()
 		return
 	}
-	// Make sure we have the magic localhost. Maybe we should move this somewhere else.
 
-	// We tick ten times a second, this should be enough for now.
-	ticker := time.Tick(time.Millisecond * 100)
+	// Subscribe before loading to make sure we don't miss any changes.
+	changes := s.emitter.Subscribe(s.subject)
+	stop := make(chan struct{})
+	go s.follow(changes, stop)
+
+	err = s.load()
+	if err != nil {
+		logger.Red("scheduler", "Error getting probes from store: %s", err.Error())
+		s.emitter.Unsubscribe(changes)
+		close(stop)
+		wg.Done()
+		return
+	}
+
+	ticker := time.NewTicker(s.resolution)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.running.Wait()
+
+			// Running probes may broadcast changes, we must keep following
+			// until they're done.
+			s.emitter.Unsubscribe(changes)
+			close(stop)
+
+			wg.Done()
+			return
+		case t := <-ticker.C:
+			if s.leading() {
+				s.tick(ctx, t, serv)
+			}
+		}
+	}
+}
+
+// leading will return true if this instance should run probes. The queue is
+// reloaded when elected, as the previous leader may have saved probes we
+// didn't hear about.
+func (s *Scheduler) leading() bool {
+	if s.elector == nil {
+		return true
+	}
 
-	// inFlight is a list of probes id's currently running
-	inFlight := make(map[string]bool)
-	inFlightLock := sync.RWMutex{}
-	for t := range ticker {
-		// We start by extracting a list of all probes. If this gets too
-		// expensive at some point, we can do it less frequent.
+	leader := s.elector.IsLeader()
 
-		probes, err := s.store.GetAllProbes(s.subject, userdb.God.GetAccountId())
+	switch {
+	case leader && !s.leader:
+		logger.Green("scheduler", "Elected leader, running probes")
 
+		err := s.load()
 		if err != nil {
 			logger.Red("scheduler", "Error getting probes from store: %s", err.Error())
-			continue
 		}
+	case !leader && s.leader:
+		logger.Yellow("scheduler", "No longer leader, not running probes")
+	}
+
+	s.leader = leader
 
-		// We iterate the list of probes, to see if anything needs to be done.
-		for _, probe := range probes {
-			// Calculate the age of the last check, if the age is positive, it's
-			// in the past.
-			age := t.Sub(probe.LastCheck)
+	return leader
+}
 
-			// Calculate how much we should wait before executing the job. If
-			// the value is positive, it's in the future.
-			wait := probe.NextCheck.Sub(t)
+// load will read all probes from the store and add them to the queue.
+// Running probes are left out, they will be rescheduled when they're done.
+func (s *Scheduler) load() error {
+	probes, err := s.store.GetAllProbes(s.subject, userdb.God.GetAccountId())
+	if err != nil {
+		return err
+	}
 
-			// Check if the probe is already executing.
-			inFlightLock.RLock()
-			_, found := inFlight[probe.ID]
-			inFlightLock.RUnlock()
+	s.queueLock.Lock()
+	s.inFlightLock.RLock()
+	for _, probe := range probes {
+		if !s.inFlight[probe.ID] {
+			s.queue.schedule(probe)
+		}
+	}
+	s.inFlightLock.RUnlock()
+	s.queueLock.Unlock()
 
-			if found {
+	return nil
+}
+
+// follow will keep the queue in sync with changes until stop is closed.
+func (s *Scheduler) follow(changes chan core.Change, stop chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case change := <-changes:
+			probe, ok := change.Payload.(*core.Probe)
+			if !ok {
 				continue
 			}
 
-			// If the check is older than two intervals, we treat it as new.
-			if age > probe.Interval*2 && wait < -probe.Interval {
-				checkIn := time.Duration(rand.Int63n(int64(probe.Interval)))
-				probe.NextCheck = t.Add(checkIn)
-				agent := probe.Agent()
+			s.queueLock.Lock()
+			switch change.Type {
+			case "probeadd", "probechange":
+				// Running probes will be rescheduled when they're done.
+				s.inFlightLock.RLock()
+				_, found := s.inFlight[probe.ID]
+				s.inFlightLock.RUnlock()
+
+				if !found {
+					s.queue.schedule(*probe)
+				}
+			case "probedelete":
+				s.queue.remove(probe.ID)
+				s.rates.Forget(probe.ID)
+			}
+			s.queueLock.Unlock()
+		}
+	}
+}
+
+// save will write probe back to the store. The store will broadcast the change
+// which will reschedule the probe. If the store fails for any other reason
+// than the probe being gone, we schedule it ourself to avoid losing it.
+func (s *Scheduler) save(probe *core.Probe) error {
+	err := s.store.UpdateProbe(s.subject, probe)
+	if err != nil && err != core.ErrProbeNotFound {
+		s.queueLock.Lock()
+		s.queue.schedule(*probe)
+		s.queueLock.Unlock()
+	}
+
+	return err
+}
+
+// popDue will remove the probes due at t from the queue and mark them in
+// flight. This is done before releasing the queue, a change to a probe must
+// never find it neither queued nor in flight, or it would run twice.
+func (s *Scheduler) popDue(t time.Time) []core.Probe {
+	s.queueLock.Lock()
+	defer s.queueLock.Unlock()
+
+	probes := s.queue.due(t)
+
+	s.inFlightLock.Lock()
+	for _, probe := range probes {
+		s.inFlight[probe.ID] = true
+	}
+	s.inFlightLock.Unlock()
+
+	return probes
+}
+
+// tick will execute all probes due at t. Probes still waiting for a slot
+// when ctx is cancelled are put back in the queue without running.
+func (s *Scheduler) tick(ctx context.Context, t time.Time, serv timeseries.Database) {
+	probes := s.popDue(t)
+
+	for _, probe := range probes {
+		// Calculate the age of the last check, if the age is positive, it's
+		// in the past.
+		age := t.Sub(probe.LastCheck)
+
+		// Calculate how much we should wait before executing the job. If
+		// the value is positive, it's in the future.
+		wait := probe.NextCheck.Sub(t)
+
+		// If the check is older than two intervals, we treat it as new and
+		// delay it by up to spread factor intervals.
+		if age > probe.Interval*2 && wait < -probe.Interval {
+			var checkIn time.Duration
+			spread := int64(float64(probe.Interval) * probe.GetSpreadFactor(s.spreadFactor))
+			if spread > 0 {
+				checkIn = time.Duration(rand.Int63n(spread)).Truncate(s.resolution)
+			}
+			probe.NextCheck = t.Add(checkIn)
+			agent := probe.Agent()
+
+			logger.Yellow("scheduler", "[%s] %T:(%+v): start delayed by %s", probe.ID, agent, agent, checkIn)
+
+			// The probe is not running, saving it must reschedule it.
+			s.inFlightLock.Lock()
+			delete(s.inFlight, probe.ID)
+			s.inFlightLock.Unlock()
+
+			err := s.save(&probe)
+			if err != nil {
+				logger.Red("scheduler", "Error updating: %v", err.Error())
+			}
+
+			continue
+		}
+
+		metrics.ProbesInFlight.Inc()
+
+		s.running.Add(1)
+
+		// Execute the probe in its own go routine.
+		go func(probe core.Probe) {
+			defer s.running.Done()
+
+			// Wait for a free slot if concurrency is limited.
+			if s.slots != nil {
+				select {
+				case s.slots <- struct{}{}:
+				case <-ctx.Done():
+					s.inFlightLock.Lock()
+					delete(s.inFlight, probe.ID)
+					s.inFlightLock.Unlock()
+					metrics.ProbesInFlight.Dec()
+
+					s.queueLock.Lock()
+					s.queue.schedule(probe)
+					s.queueLock.Unlock()
+
+					return
+				}
+				defer func() { <-s.slots }()
+			}
+
+			// Save the check time and schedule next check.
+			spread := probe.GetSpreadFactor(s.spreadFactor)
+			probe.LastCheck = t
+			probe.NextCheck = t.Add(probe.Interval + jitter(probe.Interval, spread, s.resolution))
+
+			agent := probe.Agent()
+			host, err := s.store.GetHost(userdb.God, probe.HostID)
+			if err != nil {
+				logger.Red("scheduler", "[%s] Could not get host '%s': %s", probe.ID, probe.HostID, err.Error())
+
+				s.inFlightLock.Lock()
+				delete(s.inFlight, probe.ID)
+				s.inFlightLock.Unlock()
+				metrics.ProbesInFlight.Dec()
+
+				s.queueLock.Lock()
+				s.queue.schedule(probe)
+				s.queueLock.Unlock()
+
+				return
+			}
+
+			// Skip the run while the host is in maintenance. Nothing is
+			// gathered, so no state changes will be broadcasted.
+			if host.InMaintenance(t) {
+				logger.Yellow("scheduler", "[%s] Host '%s' in maintenance, skipping", probe.ID, host.Name)
 
-				logger.Yellow("scheduler", "[%s] %T:(%+v): start delayed by %s", probe.ID, agent, agent, checkIn)
+				s.inFlightLock.Lock()
+				delete(s.inFlight, probe.ID)
+				s.inFlightLock.Unlock()
+				metrics.ProbesInFlight.Dec()
 
-				err = s.store.UpdateProbe(s.subject, &probe)
+				err = s.save(&probe)
 				if err != nil {
-					logger.Red("Error updating: %v", err.Error())
+					logger.Red("scheduler", "[%s] %T(%+v) UpdateProbe(): %s", probe.ID, agent, agent, err.Error())
 				}
-			} else if wait < 0 {
-				// If we arrive here, wait is sub-zero, which means that we
-				// should execute now.
-				inFlightLock.Lock()
-				inFlight[probe.ID] = true
-				inFlightLock.Unlock()
-
-				// Execute the probe in its own go routine.
-				go func(probe core.Probe) {
-					agent := probe.Agent()
-					host, err := s.store.GetHost(userdb.God, probe.HostID)
-					if err != nil {
-						logger.Red("scheduler", "[%s] Could not get host '%s': %s", probe.ID, probe.HostID, err.Error())
-						return
-					}
 
-					// Run the job.
-					start := time.Now()
+				return
+			}
 
-					transport := host.Transport()
-					err = agent.Gather(transport)
-					if err != nil {
-						logger.Red("scheduler", "[%s] %T(%+v) failed in %s: %s", probe.ID, probe.Agent, probe.Agent, time.Now().Sub(start), err.Error())
-					} else {
-						logger.Green("scheduler", "[%s] %T(%+v) ran in %s", probe.ID, probe.Agent, probe.Agent, time.Now().Sub(start))
-
-						points := agent.GetPoints()
-
-						if len(points) > 0 {
-							// Tag all points with hostname and arbitrary tags.
-							for _, point := range points {
-								point.Tags["hostname"] = host.Name
-
-								for key, value := range probe.Tags {
-									point.Tags[key] = value
-								}
-							}
-
-							// Write results to TSDB.
-							err = serv.WritePoints(points)
-							if err != nil {
-								logger.Red("scheduler", "[%s] %T(%+v) WritePoints(): %s", probe.ID, probe.Agent, probe.Agent, err.Error())
-							}
-						}
-
-						// Save the result
-						probe.LastPoints = points
-					}
+			// Run the job.
+			start := time.Now()
 
-					// Save the check time and schedule next check.
-					probe.LastCheck = t
-					probe.NextCheck = t.Add(probe.Interval)
+			// A host referencing a transport plugin no longer available
+			// will fail the probe like any other error.
+			transport, err := host.Transport()
+			if err == nil {
+				err = gather(agent, transport, probe.GetTimeout())
+			}
 
-					// Save everything back to store.
-					err = s.store.UpdateProbe(s.subject, &probe)
+			var warnings []string
+			if err == nil {
+				warnings = plugins.GetWarnings(agent)
+			}
+
+			probe.AddRun(t, err, warnings...)
+			metrics.ProbeRun(err)
+
+			if err != nil {
+				logger.Red("scheduler", "[%s] %T(%+v) failed in %s: %s", probe.ID, agent, agent, time.Now().Sub(start), err.Error())
+
+				probe.LastError = err.Error()
+				probe.LastWarnings = nil
+				probe.ConsecutiveFailures++
+			} else {
+				logger.Green("scheduler", "[%s] %T(%+v) ran in %s", probe.ID, agent, agent, time.Now().Sub(start))
+
+				for _, warning := range warnings {
+					logger.Yellow("scheduler", "[%s] %T(%+v) warning: %s", probe.ID, agent, agent, warning)
+				}
+
+				// Agents reporting rates have no points before the
+				// second run.
+				var points []*timeseries.Point
+				rated, ok := s.rates.Apply(probe.ID, agent)
+				if ok {
+					points = rated.GetPoints()
+				}
+
+				if len(points) > 0 {
+					stampPoints(points, t)
+					tagPoints(points, host, &probe)
+
+					// Write results to TSDB.
+					err = serv.WritePoints(points)
 					if err != nil {
-						logger.Red("scheduler", "[%s] %T(%+v) UpdateProbe(): %s", probe.ID, probe.Agent, probe.Agent, err.Error())
+						logger.Red("scheduler", "[%s] %T(%+v) WritePoints(): %s", probe.ID, agent, agent, err.Error())
 					}
-					// Remove the probe from inFlight map.
-					inFlightLock.Lock()
-					delete(inFlight, probe.ID)
-					inFlightLock.Unlock()
-				}(probe)
+				}
+
+				// Save the result
+				probe.LastPoints = points
+				probe.LastError = ""
+				probe.LastWarnings = warnings
+				probe.ConsecutiveFailures = 0
+			}
+
+			// Back off if the probe keeps failing.
+			probe.NextCheck = t.Add(backoff(probe.Interval, probe.ConsecutiveFailures) + jitter(probe.Interval, spread, s.resolution))
+
+			events := stateEvents(&probe, t)
+
+			// Remove the probe from inFlight map, allowing the change to
+			// reschedule it.
+			s.inFlightLock.Lock()
+			delete(s.inFlight, probe.ID)
+			s.inFlightLock.Unlock()
+			metrics.ProbesInFlight.Dec()
+
+			// Save everything back to store.
+			err = s.save(&probe)
+			if err != nil {
+				logger.Red("scheduler", "[%s] %T(%+v) UpdateProbe(): %s", probe.ID, agent, agent, err.Error())
 			}
+
+			for _, event := range events {
+				s.broadcaster.Broadcast(event, &probe)
+			}
+		}(probe)
+	}
+}
+
+// RunNow will run the probe identified by id once and return the points
+// gathered. Nothing is written to the timeseries database and the probe is
+// not saved, LastCheck, NextCheck and the history are left untouched. Rates
+// are calculated from the last scheduled run, agents reporting rates will
+// return no points if the probe has not run yet. This is useful for testing a
+// probe while configuring it.
+func (s *Scheduler) RunNow(subject userdb.Subject, id string) ([]*timeseries.Point, error) {
+	probe, err := s.store.GetProbe(subject, id)
+	if err != nil {
+		return nil, err
+	}
+
+	host, err := s.store.GetHost(subject, probe.HostID)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := host.Transport()
+	if err != nil {
+		return nil, err
+	}
+
+	agent := probe.Agent()
+
+	err = gather(agent, transport, probe.GetTimeout())
+	if err != nil {
+		return nil, err
+	}
+
+	rated, ok := plugins.Sub(agent, s.rates.Previous(probe.ID))
+	if !ok {
+		return []*timeseries.Point{}, nil
+	}
+
+	points := rated.GetPoints()
+	tagPoints(points, host, probe)
+
+	return points, nil
+}
+
+// tagPoints will tag all points with the hostname, the account and the tags
+// of probe.
+func tagPoints(points []*timeseries.Point, host *core.Host, probe *core.Probe) {
+	for _, point := range points {
+		point.Tags["hostname"] = host.Name
+
+		timeseries.TagAccount(point, probe.AccountID)
+
+		for key, value := range probe.Tags {
+			point.Tags[key] = value
+		}
+	}
+}
+
+// stampPoints will set the time of points without a time to t, the time the
+// probe was checked. Agents knowing their sample time set it themselves, this
+// is only a fallback. Points are written long after they were gathered when
+// buffered or spooled, the database must not stamp them at receive time.
+func stampPoints(points []*timeseries.Point, t time.Time) {
+	for _, point := range points {
+		if point.Time.IsZero() {
+			point.Time = t
 		}
 	}
+}
+
+// jitter will return a random duration within +/- half of factor intervals.
+// This keeps probes with identical intervals from running in lockstep. The
+// duration is truncated to a multiple of resolution, as probes can't be
+// scheduled more precisely than the tick anyway.
+func jitter(interval time.Duration, factor float64, resolution time.Duration) time.Duration {
+	spread := int64(float64(interval) * factor)
+	if spread <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(spread) - spread/2).Truncate(resolution)
+}
+
+// gather will run agent.Gather() and wait at most timeout for it to return. If
+// the timeout is reached, the gathering is abandoned and ErrTimeout returned.
+func gather(agent plugins.Agent, transport plugins.Transport, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := plugins.Gather(ctx, agent, transport)
+	if err == plugins.ErrGatherTimeout {
+		return ErrTimeout
+	}
+
+	return err
+}
+
+// stateEvents will update the flapping state of probe after a run at t and
+// return the events to broadcast. "probeup" and "probedown" are only returned
+// when the state changes, and never while the probe is flapping. When a probe
+// starts flapping "probeflap" is returned, when it settles "probestable" is
+// returned followed by the current state. A probe without history is
+// considered up.
+func stateEvents(probe *core.Probe, t time.Time) []string {
+	n := len(probe.History)
+	if n == 0 {
+		return nil
+	}
+
+	up := probe.History[n-1].Success
+
+	wasUp := true
+	if n > 1 {
+		wasUp = probe.History[n-2].Success
+	}
+
+	state := "probedown"
+	if up {
+		state = "probeup"
+	}
+
+	flapping := probe.StateChanges(t.Add(-probe.GetFlapWindow())) > probe.GetFlapThreshold()
+
+	var events []string
+	switch {
+	case flapping && !probe.Flapping:
+		events = append(events, "probeflap")
+	case !flapping && probe.Flapping:
+		events = append(events, "probestable", state)
+	case !flapping && up != wasUp:
+		events = append(events, state)
+	}
+
+	probe.Flapping = flapping
+
+	return events
+}
+
+// backoff will return the time to wait before running a probe again after
+// failures consecutive failures. The wait doubles for each failure, but is
+// capped at maxBackoff intervals.
+func backoff(interval time.Duration, failures int) time.Duration {
+	factor := 1
+	for i := 0; i < failures && factor < maxBackoff; i++ {
+		factor *= 2
+	}
+
+	if factor > maxBackoff {
+		factor = maxBackoff
+	}
 
-	wg.Done()
+	return interval * time.Duration(factor)
 }
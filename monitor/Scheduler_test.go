@@ -0,0 +1,1321 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/core"
+	"github.com/abrander/agento/plugins"
+	_ "github.com/abrander/agento/plugins/transports/local"
+	"github.com/abrander/agento/timeseries"
+	"github.com/abrander/agento/userdb"
+)
+
+type (
+	// failingStore will fail adding and getting hosts.
+	failingStore struct {
+		core.Store
+	}
+
+	// slowAgent will take some time to gather and count calls.
+	slowAgent struct{}
+
+	// readingAgent will read a file using the transport.
+	readingAgent struct {
+		slowAgent
+	}
+
+	// failingAgent will fail when fail is non-zero.
+	failingAgent struct {
+		slowAgent
+	}
+
+	// blockingTransport will block reads until unblock is closed.
+	blockingTransport struct {
+		plugins.Transport
+	}
+
+	// concurrentAgent will block until release is closed and keep track of
+	// how many gathers run at once.
+	concurrentAgent struct {
+		slowAgent
+	}
+
+	// warningAgent will gather successfully but report a warning.
+	warningAgent struct {
+		slowAgent
+	}
+
+	// pointAgent will return a single point.
+	pointAgent struct {
+		slowAgent
+	}
+
+	// switchElector is an Elector controlled by the test.
+	switchElector struct {
+		leader int32
+	}
+
+	// countingStore will count calls to GetAllProbes.
+	countingStore struct {
+		core.Store
+		queries int
+	}
+)
+
+var (
+	slowStarted  int32
+	slowFinished int32
+
+	unblock = make(chan struct{})
+
+	fail int32
+
+	concurrentLock    sync.Mutex
+	concurrentCurrent int
+	concurrentMax     int
+	concurrentRelease = make(chan struct{})
+)
+
+func init() {
+	plugins.Register("slowagent", func() interface{} { return new(slowAgent) })
+	plugins.Register("readingagent", func() interface{} { return new(readingAgent) })
+	plugins.Register("failingagent", func() interface{} { return new(failingAgent) })
+	plugins.Register("concurrentagent", func() interface{} { return new(concurrentAgent) })
+	plugins.Register("warningagent", func() interface{} { return new(warningAgent) })
+	plugins.Register("pointagent", func() interface{} { return new(pointAgent) })
+	plugins.Register("blockingtransport", func() interface{} { return new(blockingTransport) })
+}
+
+func (a *readingAgent) Gather(transport plugins.Transport) error {
+	_, err := transport.ReadFile("/dev/null")
+
+	return err
+}
+
+func (a *failingAgent) Gather(_ plugins.Transport) error {
+	if atomic.LoadInt32(&fail) != 0 {
+		return errors.New("failing")
+	}
+
+	return nil
+}
+
+func (a *concurrentAgent) Gather(_ plugins.Transport) error {
+	concurrentLock.Lock()
+	concurrentCurrent++
+	if concurrentCurrent > concurrentMax {
+		concurrentMax = concurrentCurrent
+	}
+	concurrentLock.Unlock()
+
+	<-concurrentRelease
+
+	concurrentLock.Lock()
+	concurrentCurrent--
+	concurrentLock.Unlock()
+
+	return nil
+}
+
+func (a *warningAgent) Gather(_ plugins.Transport) error {
+	return nil
+}
+
+func (a *warningAgent) Warnings() []string {
+	return []string{"line 2: invalid syntax"}
+}
+
+func (e *switchElector) IsLeader() bool {
+	return atomic.LoadInt32(&e.leader) == 1
+}
+
+func (a *pointAgent) Gather(_ plugins.Transport) error {
+	return nil
+}
+
+func (a *pointAgent) GetPoints() []*timeseries.Point {
+	return []*timeseries.Point{plugins.SimplePoint("test.Value", int64(1))}
+}
+
+func (t *blockingTransport) ReadFile(_ string) ([]byte, error) {
+	<-unblock
+
+	return nil, nil
+}
+
+func (a *slowAgent) Gather(_ plugins.Transport) error {
+	atomic.AddInt32(&slowStarted, 1)
+	time.Sleep(300 * time.Millisecond)
+	atomic.AddInt32(&slowFinished, 1)
+
+	return nil
+}
+
+func (a *slowAgent) GetPoints() []*timeseries.Point {
+	return nil
+}
+
+func (a *slowAgent) GetDoc() *plugins.Doc {
+	return plugins.NewDoc("Slow test agent")
+}
+
+func (s *failingStore) AddHost(_ userdb.Subject, _ *core.Host) error {
+	return errors.New("failing")
+}
+
+func (s *failingStore) GetHost(_ userdb.Subject, _ string) (*core.Host, error) {
+	return nil, core.ErrHostNotFound
+}
+
+func (s *countingStore) GetAllProbes(subject userdb.Subject, accountID string) ([]core.Probe, error) {
+	s.queries++
+
+	return s.Store.GetAllProbes(subject, accountID)
+}
+
+func newTestStore(t testing.TB) (*ConfigurationStore, *core.SimpleEmitter) {
+	emitter := core.NewSimpleEmitter()
+
+	store, err := NewConfigurationStore(&configuration.Configuration{}, emitter)
+	if err != nil {
+		t.Fatalf("NewConfigurationStore() failed: %s", err.Error())
+	}
+
+	return store, emitter
+}
+
+// newBenchmarkStore will return a store with n probes not due for an hour.
+func newBenchmarkStore(b *testing.B, n int) (*countingStore, *core.SimpleEmitter) {
+	store, emitter := newTestStore(b)
+
+	next := time.Now().Add(time.Hour)
+	for i := 0; i < n; i++ {
+		probe := &core.Probe{
+			HostID:    "000000000000000000000000",
+			AgentID:   "slowagent",
+			Interval:  time.Hour,
+			LastCheck: next.Add(-time.Hour),
+			NextCheck: next,
+		}
+
+		err := store.AddProbe(userdb.God, probe)
+		if err != nil {
+			b.Fatalf("AddProbe() failed: %s", err.Error())
+		}
+	}
+
+	return &countingStore{Store: store}, emitter
+}
+
+// waitTimeout will return true if wg is done before timeout.
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func TestLoopWaitGroup(t *testing.T) {
+	wg := sync.WaitGroup{}
+	store, emitter := newTestStore(t)
+	s := NewScheduler(&failingStore{store}, emitter, emitter, userdb.God)
+
+	wg.Add(1)
+	go s.Loop(context.Background(), &wg, nil)
+
+	if !waitTimeout(&wg, time.Second) {
+		t.Fatalf("Loop() did not mark the WaitGroup done")
+	}
+}
+
+func TestLoopCancel(t *testing.T) {
+	wg := sync.WaitGroup{}
+	store, emitter := newTestStore(t)
+	s := NewScheduler(store, emitter, emitter, userdb.God)
+
+	now := time.Now()
+	probe := &core.Probe{
+		ID:        "slow",
+		HostID:    "000000000000000000000000",
+		AgentID:   "slowagent",
+		Interval:  time.Hour,
+		LastCheck: now,
+		NextCheck: now,
+	}
+
+	err := store.AddProbe(userdb.God, probe)
+	if err != nil {
+		t.Fatalf("AddProbe() failed: %s", err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	wg.Add(1)
+	go s.Loop(ctx, &wg, nil)
+
+	// Wait for the probe to start before cancelling.
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&slowStarted) == 0 {
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatalf("Probe never started")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+
+	if !waitTimeout(&wg, 2*time.Second) {
+		t.Fatalf("Loop() did not return after cancel")
+	}
+
+	started := atomic.LoadInt32(&slowStarted)
+	finished := atomic.LoadInt32(&slowFinished)
+	if started != finished {
+		t.Fatalf("Loop() returned with %d of %d probes unfinished", started-finished, started)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	store, emitter := newTestStore(t)
+	s := NewScheduler(store, emitter, emitter, userdb.God)
+
+	changes := emitter.Subscribe(userdb.God)
+	stop := make(chan struct{})
+	go s.follow(changes, stop)
+
+	probe := &core.Probe{
+		AgentID:   "slowagent",
+		Interval:  time.Hour,
+		NextCheck: time.Now(),
+	}
+
+	store.AddProbe(userdb.God, probe)
+	store.DeleteProbe(userdb.God, probe.ID)
+
+	// follow could still be handling the delete, give it a moment.
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.queueLock.Lock()
+		l := s.queue.Len()
+		s.queueLock.Unlock()
+
+		if l == 0 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Queue holds %d probes after delete", l)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	emitter.Unsubscribe(changes)
+	close(stop)
+}
+
+// BenchmarkTickScan is the scheduler as it used to be, querying the store on
+// every tick.
+func BenchmarkTickScan(b *testing.B) {
+	store, _ := newBenchmarkStore(b, 10000)
+	now := time.Now()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		probes, _ := store.GetAllProbes(userdb.God, userdb.God.GetAccountId())
+		for _, probe := range probes {
+			if probe.NextCheck.Sub(now) < 0 {
+				b.Fatalf("Probe should not be due")
+			}
+		}
+	}
+
+	b.ReportMetric(float64(store.queries)/float64(b.N), "queries/op")
+}
+
+func BenchmarkTickQueue(b *testing.B) {
+	store, emitter := newBenchmarkStore(b, 10000)
+	s := NewScheduler(store, emitter, emitter, userdb.God)
+	s.load()
+	now := time.Now()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.tick(context.Background(), now, nil)
+	}
+
+	// The initial load is not counted.
+	b.ReportMetric(float64(store.queries-1)/float64(b.N), "queries/op")
+}
+
+func TestTimeout(t *testing.T) {
+	defer close(unblock)
+
+	store, emitter := newTestStore(t)
+	s := NewScheduler(store, emitter, emitter, userdb.God)
+
+	host := &core.Host{
+		Name:        "dead",
+		TransportID: "blockingtransport",
+	}
+	store.AddHost(userdb.God, host)
+
+	now := time.Now()
+	probe := &core.Probe{
+		HostID:    host.ID,
+		AgentID:   "readingagent",
+		Interval:  time.Hour,
+		Timeout:   100 * time.Millisecond,
+		LastCheck: now,
+		NextCheck: now,
+	}
+	store.AddProbe(userdb.God, probe)
+	s.load()
+
+	s.tick(context.Background(), now, nil)
+
+	if !waitTimeout(&s.running, time.Second) {
+		t.Fatalf("Probe was not abandoned after timeout")
+	}
+
+	s.inFlightLock.RLock()
+	_, found := s.inFlight[probe.ID]
+	s.inFlightLock.RUnlock()
+
+	if found {
+		t.Fatalf("Probe still in flight after timeout")
+	}
+
+	p, _ := store.GetProbe(userdb.God, probe.ID)
+	if p.LastError != ErrTimeout.Error() {
+		t.Fatalf("Timeout not recorded, got '%s'", p.LastError)
+	}
+
+	// A timeout counts as a failure and will be backed off.
+	if !p.NextCheck.Equal(now.Add(2 * time.Hour)) {
+		t.Fatalf("Probe not rescheduled, next check at %s", p.NextCheck)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cases := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{2, 4 * time.Second},
+		{3, 8 * time.Second},
+		{4, 10 * time.Second},
+		{100, 10 * time.Second},
+	}
+
+	for _, c := range cases {
+		got := backoff(time.Second, c.failures)
+		if got != c.expected {
+			t.Errorf("backoff() with %d failures returned %s, expected %s", c.failures, got, c.expected)
+		}
+	}
+}
+
+func TestJitter(t *testing.T) {
+	if jitter(time.Minute, 0.0, DefaultTickResolution) != 0 {
+		t.Fatalf("jitter() returned non-zero for a zero factor")
+	}
+
+	for i := 0; i < 1000; i++ {
+		j := jitter(time.Minute, 0.5, DefaultTickResolution)
+		if j < -15*time.Second || j >= 15*time.Second {
+			t.Fatalf("jitter() returned %s, expected within +/- 15s", j)
+		}
+
+		if j%DefaultTickResolution != 0 {
+			t.Fatalf("jitter() returned %s, expected a multiple of %s", j, DefaultTickResolution)
+		}
+	}
+
+	// Jitter finer than the resolution is pointless.
+	for i := 0; i < 1000; i++ {
+		j := jitter(time.Second, 0.1, time.Second)
+		if j != 0 {
+			t.Fatalf("jitter() returned %s below the resolution", j)
+		}
+	}
+}
+
+func TestTickResolution(t *testing.T) {
+	s := NewScheduler(nil, nil, nil, userdb.God)
+	if s.resolution != DefaultTickResolution {
+		t.Fatalf("Default resolution is %s, expected %s", s.resolution, DefaultTickResolution)
+	}
+
+	s.SetTickResolution(0)
+	if s.resolution != DefaultTickResolution {
+		t.Fatalf("SetTickResolution(0) gave %s, expected %s", s.resolution, DefaultTickResolution)
+	}
+
+	// The first run of a due probe happens on the first tick, the delay
+	// must scale with the resolution.
+	cases := []struct {
+		resolution time.Duration
+		min        time.Duration
+		max        time.Duration
+	}{
+		{20 * time.Millisecond, 0, 250 * time.Millisecond},
+		{600 * time.Millisecond, 550 * time.Millisecond, 2 * time.Second},
+	}
+
+	for _, c := range cases {
+		wg := sync.WaitGroup{}
+		store, emitter := newTestStore(t)
+		s := NewScheduler(store, emitter, emitter, userdb.God)
+		s.SetTickResolution(c.resolution)
+
+		start := time.Now()
+		probe := &core.Probe{
+			HostID:    "000000000000000000000000",
+			AgentID:   "warningagent",
+			Interval:  time.Hour,
+			LastCheck: start,
+			NextCheck: start,
+		}
+		store.AddProbe(userdb.God, probe)
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		wg.Add(1)
+		go s.Loop(ctx, &wg, nil)
+
+		var lastCheck time.Time
+		deadline := time.Now().Add(3 * time.Second)
+		for time.Now().Before(deadline) {
+			stored, _ := store.GetProbe(userdb.God, probe.ID)
+			if stored.LastCheck.After(start) {
+				lastCheck = stored.LastCheck
+				break
+			}
+
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		cancel()
+		wg.Wait()
+
+		if lastCheck.IsZero() {
+			t.Fatalf("Probe never ran with resolution %s", c.resolution)
+		}
+
+		delay := lastCheck.Sub(start)
+		if delay < c.min || delay > c.max {
+			t.Errorf("Probe ran after %s with resolution %s, expected %s-%s", delay, c.resolution, c.min, c.max)
+		}
+	}
+}
+
+func TestSpreadFactor(t *testing.T) {
+	store, emitter := newTestStore(t)
+	s := NewScheduler(store, emitter, emitter, userdb.God)
+	s.SetSpreadFactor(0.5)
+
+	core.AddLocalhost(userdb.God, store)
+
+	// All probes are stale and have identical intervals.
+	now := time.Now()
+	for i := 0; i < 50; i++ {
+		probe := &core.Probe{
+			HostID:    "000000000000000000000000",
+			AgentID:   "failingagent",
+			Interval:  time.Minute,
+			LastCheck: now.Add(-time.Hour),
+			NextCheck: now.Add(-time.Hour),
+		}
+		store.AddProbe(userdb.God, probe)
+	}
+
+	// distinct will check that all NextCheck values are within from and to
+	// and return the number of distinct values.
+	distinct := func(from time.Time, to time.Time) int {
+		probes, _ := store.GetAllProbes(userdb.God, userdb.God.GetAccountId())
+
+		seen := make(map[time.Time]bool)
+		for _, p := range probes {
+			if p.NextCheck.Before(from) || !p.NextCheck.Before(to) {
+				t.Fatalf("NextCheck %s not within %s and %s", p.NextCheck, from, to)
+			}
+
+			seen[p.NextCheck] = true
+		}
+
+		return len(seen)
+	}
+
+	atomic.StoreInt32(&fail, 0)
+
+	// The first run must be delayed by up to half an interval.
+	s.load()
+	s.tick(context.Background(), now, nil)
+
+	if n := distinct(now, now.Add(30*time.Second)); n < 40 {
+		t.Fatalf("First runs are aligned, only %d distinct times for 50 probes", n)
+	}
+
+	// Run everything at once, the next runs must be spread too.
+	run := now.Add(30 * time.Second)
+	s.load()
+	s.tick(context.Background(), run, nil)
+
+	if !waitTimeout(&s.running, time.Second) {
+		t.Fatalf("Probes did not finish")
+	}
+
+	if n := distinct(run.Add(45*time.Second), run.Add(75*time.Second)); n < 40 {
+		t.Fatalf("Scheduled runs are aligned, only %d distinct times for 50 probes", n)
+	}
+}
+
+func TestFailingBackoff(t *testing.T) {
+	store, emitter := newTestStore(t)
+	s := NewScheduler(store, emitter, emitter, userdb.God)
+
+	core.AddLocalhost(userdb.God, store)
+
+	now := time.Now()
+	probe := &core.Probe{
+		HostID:    "000000000000000000000000",
+		AgentID:   "failingagent",
+		Interval:  time.Minute,
+		LastCheck: now,
+		NextCheck: now,
+	}
+	store.AddProbe(userdb.God, probe)
+
+	// run will run the probe once and return how long until next check.
+	run := func() (time.Duration, *core.Probe) {
+		s.load()
+
+		p, _ := store.GetProbe(userdb.God, probe.ID)
+		s.tick(context.Background(), p.NextCheck, nil)
+
+		if !waitTimeout(&s.running, time.Second) {
+			t.Fatalf("Probe did not finish")
+		}
+
+		next, _ := store.GetProbe(userdb.God, probe.ID)
+
+		return next.NextCheck.Sub(p.NextCheck), next
+	}
+
+	atomic.StoreInt32(&fail, 1)
+	for _, expected := range []time.Duration{2, 4, 8, 10, 10} {
+		wait, p := run()
+		if wait != expected*time.Minute {
+			t.Fatalf("Wrong backoff after %d failures, got %s, expected %s", p.ConsecutiveFailures, wait, expected*time.Minute)
+		}
+	}
+
+	atomic.StoreInt32(&fail, 0)
+	wait, p := run()
+	if wait != time.Minute {
+		t.Fatalf("Backoff not reset after success, got %s", wait)
+	}
+
+	if p.ConsecutiveFailures != 0 {
+		t.Fatalf("ConsecutiveFailures not reset, got %d", p.ConsecutiveFailures)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	store, emitter := newTestStore(t)
+	s := NewScheduler(store, emitter, emitter, userdb.God)
+
+	core.AddLocalhost(userdb.God, store)
+
+	now := time.Now()
+	probe := &core.Probe{
+		HostID:    "000000000000000000000000",
+		AgentID:   "failingagent",
+		Interval:  time.Minute,
+		LastCheck: now,
+		NextCheck: now,
+	}
+	store.AddProbe(userdb.God, probe)
+
+	pattern := []bool{true, false, false, true, true}
+	for _, success := range pattern {
+		if success {
+			atomic.StoreInt32(&fail, 0)
+		} else {
+			atomic.StoreInt32(&fail, 1)
+		}
+
+		s.load()
+
+		p, _ := store.GetProbe(userdb.God, probe.ID)
+		s.tick(context.Background(), p.NextCheck, nil)
+
+		if !waitTimeout(&s.running, time.Second) {
+			t.Fatalf("Probe did not finish")
+		}
+	}
+	atomic.StoreInt32(&fail, 0)
+
+	p, _ := store.GetProbe(userdb.God, probe.ID)
+	if len(p.History) != len(pattern) {
+		t.Fatalf("Got %d history entries, expected %d", len(p.History), len(pattern))
+	}
+
+	for i, run := range p.History {
+		if run.Success != pattern[i] {
+			t.Errorf("Run %d has success=%v, expected %v", i, run.Success, pattern[i])
+		}
+
+		if !run.Success && run.Error != "failing" {
+			t.Errorf("Run %d has error '%s', expected 'failing'", i, run.Error)
+		}
+
+		if i > 0 && !run.Time.After(p.History[i-1].Time) {
+			t.Errorf("Run %d at %s is not after run %d at %s", i, run.Time, i-1, p.History[i-1].Time)
+		}
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	store, emitter := newTestStore(t)
+	s := NewScheduler(store, emitter, emitter, userdb.God)
+
+	core.AddLocalhost(userdb.God, store)
+
+	now := time.Now()
+	probe := &core.Probe{
+		HostID:    "000000000000000000000000",
+		AgentID:   "warningagent",
+		Interval:  time.Minute,
+		LastCheck: now,
+		NextCheck: now,
+	}
+	store.AddProbe(userdb.God, probe)
+
+	s.load()
+	s.tick(context.Background(), now, nil)
+
+	if !waitTimeout(&s.running, time.Second) {
+		t.Fatalf("Probe did not finish")
+	}
+
+	p, _ := store.GetProbe(userdb.God, probe.ID)
+	if p.LastError != "" || p.ConsecutiveFailures != 0 {
+		t.Fatalf("Warnings were treated as failure: '%s', %d failures", p.LastError, p.ConsecutiveFailures)
+	}
+
+	if len(p.LastWarnings) != 1 || p.LastWarnings[0] != "line 2: invalid syntax" {
+		t.Fatalf("Got LastWarnings %v, expected one warning", p.LastWarnings)
+	}
+
+	if len(p.History) != 1 || !p.History[0].Success || len(p.History[0].Warnings) != 1 {
+		t.Fatalf("Warnings not recorded in history: %+v", p.History)
+	}
+}
+
+func TestStateEvents(t *testing.T) {
+	now := time.Now()
+
+	probe := &core.Probe{
+		Interval:      time.Minute,
+		FlapThreshold: 3,
+	}
+
+	// run will record a run and return the events.
+	i := 0
+	run := func(success bool) []string {
+		var err error
+		if !success {
+			err = errors.New("failing")
+		}
+
+		i++
+		at := now.Add(time.Duration(i) * time.Minute)
+		probe.AddRun(at, err)
+
+		return stateEvents(probe, at)
+	}
+
+	expect := func(got []string, expected ...string) {
+		if strings.Join(got, ",") != strings.Join(expected, ",") {
+			t.Fatalf("Run %d: Got events %v, expected %v", i, got, expected)
+		}
+	}
+
+	expect(run(true))
+	expect(run(true))
+	expect(run(false), "probedown")
+	expect(run(false))
+	expect(run(true), "probeup")
+
+	// Alternating results will make the probe flap after more than three
+	// changes within ten intervals. No up or down events while flapping.
+	expect(run(false), "probedown")
+	expect(run(true), "probeflap")
+	expect(run(false))
+	expect(run(true))
+
+	if !probe.Flapping {
+		t.Fatalf("Probe not flapping")
+	}
+
+	// Ten stable runs will push the changes out of the window.
+	for j := 0; j < 10; j++ {
+		events := run(true)
+		if !probe.Flapping {
+			expect(events, "probestable", "probeup")
+			break
+		}
+
+		expect(events)
+	}
+
+	if probe.Flapping {
+		t.Fatalf("Probe still flapping")
+	}
+}
+
+func TestFlapBroadcast(t *testing.T) {
+	store, emitter := newTestStore(t)
+	s := NewScheduler(store, emitter, emitter, userdb.God)
+
+	core.AddLocalhost(userdb.God, store)
+
+	now := time.Now()
+	probe := &core.Probe{
+		HostID:        "000000000000000000000000",
+		AgentID:       "failingagent",
+		Interval:      time.Minute,
+		FlapThreshold: 2,
+		LastCheck:     now,
+		NextCheck:     now,
+	}
+	store.AddProbe(userdb.God, probe)
+
+	changes := emitter.Subscribe(userdb.God)
+	defer emitter.Unsubscribe(changes)
+
+	var eventsLock sync.Mutex
+	var events []string
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case change := <-changes:
+				if change.Type == "probechange" {
+					continue
+				}
+
+				eventsLock.Lock()
+				events = append(events, change.Type)
+				eventsLock.Unlock()
+			}
+		}
+	}()
+
+	for i := 0; i < 6; i++ {
+		atomic.StoreInt32(&fail, int32(i%2))
+
+		s.load()
+
+		p, _ := store.GetProbe(userdb.God, probe.ID)
+		s.tick(context.Background(), p.NextCheck, nil)
+
+		if !waitTimeout(&s.running, time.Second) {
+			t.Fatalf("Probe did not finish")
+		}
+	}
+	atomic.StoreInt32(&fail, 0)
+
+	eventsLock.Lock()
+	defer eventsLock.Unlock()
+
+	expected := "probedown,probeup,probeflap"
+	if strings.Join(events, ",") != expected {
+		t.Fatalf("Got events %v, expected %s", events, expected)
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	store, emitter := newTestStore(t)
+	s := NewScheduler(store, emitter, emitter, userdb.God)
+
+	now := time.Now()
+	host := &core.Host{
+		Name:        "maintained",
+		TransportID: "localtransport",
+		MaintenanceWindows: []core.MaintenanceWindow{
+			{Start: now, End: now.Add(3 * time.Minute)},
+		},
+	}
+	store.AddHost(userdb.God, host)
+
+	probe := &core.Probe{
+		HostID:    host.ID,
+		AgentID:   "failingagent",
+		Interval:  time.Minute,
+		LastCheck: now,
+		NextCheck: now,
+	}
+	store.AddProbe(userdb.God, probe)
+
+	changes := emitter.Subscribe(userdb.God)
+	defer emitter.Unsubscribe(changes)
+
+	events := make(chan string, 100)
+	go func() {
+		for change := range changes {
+			events <- change.Type
+		}
+	}()
+
+	// The probe fails, but that must go unnoticed inside the window.
+	atomic.StoreInt32(&fail, 1)
+	defer atomic.StoreInt32(&fail, 0)
+
+	// run will run the probe once and return it.
+	run := func() *core.Probe {
+		s.load()
+
+		p, _ := store.GetProbe(userdb.God, probe.ID)
+		s.tick(context.Background(), p.NextCheck, nil)
+
+		if !waitTimeout(&s.running, time.Second) {
+			t.Fatalf("Probe did not finish")
+		}
+
+		p, _ = store.GetProbe(userdb.God, probe.ID)
+
+		return p
+	}
+
+	for i := 0; i < 3; i++ {
+		p := run()
+
+		if len(p.History) != 0 {
+			t.Fatalf("Probe ran inside maintenance window")
+		}
+
+		expected := now.Add(time.Duration(i+1) * time.Minute)
+		if !p.NextCheck.Equal(expected) {
+			t.Fatalf("NextCheck not advanced, got %s, expected %s", p.NextCheck, expected)
+		}
+	}
+
+	// The window is over, the probe must run again.
+	p := run()
+	if len(p.History) != 1 {
+		t.Fatalf("Probe did not resume after maintenance window")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	var downs int
+	for len(events) > 0 {
+		if <-events == "probedown" {
+			downs++
+		}
+	}
+
+	if downs != 1 {
+		t.Fatalf("Got %d probedown events, expected 1 after the window", downs)
+	}
+}
+
+func TestMaxConcurrentChecks(t *testing.T) {
+	store, emitter := newTestStore(t)
+	s := NewScheduler(store, emitter, emitter, userdb.God)
+	s.SetMaxConcurrentChecks(2)
+
+	core.AddLocalhost(userdb.God, store)
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		probe := &core.Probe{
+			HostID:    "000000000000000000000000",
+			AgentID:   "concurrentagent",
+			Interval:  time.Minute,
+			Timeout:   time.Minute,
+			LastCheck: now,
+			NextCheck: now,
+		}
+		store.AddProbe(userdb.God, probe)
+	}
+
+	s.load()
+	s.tick(context.Background(), now, nil)
+
+	// Give all probes a chance to start.
+	time.Sleep(100 * time.Millisecond)
+
+	concurrentLock.Lock()
+	current := concurrentCurrent
+	concurrentLock.Unlock()
+
+	if current != 2 {
+		t.Fatalf("Got %d probes running, expected 2", current)
+	}
+
+	close(concurrentRelease)
+
+	if !waitTimeout(&s.running, time.Second) {
+		t.Fatalf("Probes did not finish")
+	}
+
+	p, _ := store.GetAllProbes(userdb.God, userdb.God.GetAccountId())
+	for _, probe := range p {
+		if len(probe.History) != 1 {
+			t.Fatalf("Probe %s ran %d times, expected 1", probe.ID, len(probe.History))
+		}
+	}
+
+	if concurrentMax != 2 {
+		t.Fatalf("Got at most %d probes running at once, expected 2", concurrentMax)
+	}
+}
+
+func TestTickCancelWaiting(t *testing.T) {
+	store, emitter := newTestStore(t)
+	s := NewScheduler(store, emitter, emitter, userdb.God)
+	s.SetMaxConcurrentChecks(1)
+
+	core.AddLocalhost(userdb.God, store)
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		probe := &core.Probe{
+			HostID:    "000000000000000000000000",
+			AgentID:   "slowagent",
+			Interval:  time.Hour,
+			LastCheck: now,
+			NextCheck: now,
+		}
+		store.AddProbe(userdb.God, probe)
+	}
+
+	s.load()
+
+	started := atomic.LoadInt32(&slowStarted)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.tick(ctx, now, nil)
+
+	// Wait for the first probe to take the slot.
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&slowStarted) == started {
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatalf("Probe never started")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+
+	if !waitTimeout(&s.running, 2*time.Second) {
+		t.Fatalf("Probes did not finish")
+	}
+
+	// Probes waiting for a slot must not run after cancel.
+	if ran := atomic.LoadInt32(&slowStarted) - started; ran != 1 {
+		t.Fatalf("%d probes ran, expected only the one already running", ran)
+	}
+
+	s.inFlightLock.RLock()
+	inFlight := len(s.inFlight)
+	s.inFlightLock.RUnlock()
+
+	if inFlight != 0 {
+		t.Errorf("Got %d probes in flight after cancel, expected 0", inFlight)
+	}
+
+	s.queueLock.Lock()
+	queued := s.queue.Len()
+	s.queueLock.Unlock()
+
+	if queued != 2 {
+		t.Errorf("Got %d probes queued after cancel, expected the 2 never run", queued)
+	}
+}
+
+func TestUnknownTransport(t *testing.T) {
+	store, emitter := newTestStore(t)
+	s := NewScheduler(store, emitter, emitter, userdb.God)
+
+	host := &core.Host{
+		Name:        "orphan",
+		TransportID: "nonexistingtransport",
+	}
+	store.AddHost(userdb.God, host)
+
+	now := time.Now()
+	probe := &core.Probe{
+		HostID:    host.ID,
+		AgentID:   "warningagent",
+		Interval:  time.Minute,
+		LastCheck: now,
+		NextCheck: now,
+	}
+	store.AddProbe(userdb.God, probe)
+
+	s.load()
+	s.tick(context.Background(), now, nil)
+
+	if !waitTimeout(&s.running, time.Second) {
+		t.Fatalf("Probe did not finish")
+	}
+
+	p, _ := store.GetProbe(userdb.God, probe.ID)
+	if p.LastError != "unknown transport 'nonexistingtransport'" {
+		t.Errorf("Got LastError '%s', expected unknown transport", p.LastError)
+	}
+
+	if p.ConsecutiveFailures != 1 || len(p.History) != 1 || p.History[0].Success {
+		t.Errorf("Probe not marked as failed: %d failures, history %+v", p.ConsecutiveFailures, p.History)
+	}
+}
+
+func TestRunNow(t *testing.T) {
+	store, emitter := newTestStore(t)
+	s := NewScheduler(store, emitter, emitter, userdb.God)
+
+	core.AddLocalhost(userdb.God, store)
+
+	next := time.Now().Add(time.Hour)
+	probe := &core.Probe{
+		HostID:    "000000000000000000000000",
+		AgentID:   "pointagent",
+		Interval:  time.Hour,
+		NextCheck: next,
+		Tags:      map[string]string{"env": "test"},
+	}
+	store.AddProbe(userdb.God, probe)
+
+	points, err := s.RunNow(userdb.God, probe.ID)
+	if err != nil {
+		t.Fatalf("RunNow() failed: %s", err.Error())
+	}
+
+	if len(points) != 1 {
+		t.Fatalf("Got %d points, expected 1", len(points))
+	}
+
+	if points[0].Tags["hostname"] == "" || points[0].Tags["env"] != "test" {
+		t.Errorf("Points were not tagged: %+v", points[0].Tags)
+	}
+
+	stored, _ := store.GetProbe(userdb.God, probe.ID)
+	if !stored.LastCheck.IsZero() || !stored.NextCheck.Equal(next) || len(stored.History) != 0 || len(stored.LastPoints) != 0 {
+		t.Errorf("RunNow() changed the scheduling state: %+v", stored)
+	}
+
+	_, err = s.RunNow(userdb.God, "nonexisting")
+	if err != core.ErrProbeNotFound {
+		t.Errorf("RunNow() returned %v for unknown probe, expected %v", err, core.ErrProbeNotFound)
+	}
+}
+
+func TestStampPoints(t *testing.T) {
+	sampletime := time.Now().Add(-time.Hour)
+	checktime := time.Now()
+
+	points := []*timeseries.Point{
+		plugins.SimplePoint("a", 1),
+		timeseries.NewPoint("b", nil, map[string]interface{}{"value": 2}, sampletime),
+	}
+
+	stampPoints(points, checktime)
+
+	if !points[0].Time.Equal(checktime) {
+		t.Errorf("Point without time got %s, expected %s", points[0].Time, checktime)
+	}
+
+	if !points[1].Time.Equal(sampletime) {
+		t.Errorf("Point with sample time got %s, expected %s", points[1].Time, sampletime)
+	}
+}
+
+func TestTagPoints(t *testing.T) {
+	host := &core.Host{Name: "web1"}
+	probe := &core.Probe{
+		AccountID: "000000000000000000000001",
+		Tags:      map[string]string{"role": "web"},
+	}
+
+	points := []*timeseries.Point{plugins.SimplePoint("a", 1)}
+
+	tagPoints(points, host, probe)
+
+	expected := map[string]string{
+		"hostname":            "web1",
+		timeseries.AccountTag: "000000000000000000000001",
+		"role":                "web",
+	}
+
+	for key, value := range expected {
+		if points[0].Tags[key] != value {
+			t.Errorf("Tag %s is '%s', expected '%s'", key, points[0].Tags[key], value)
+		}
+	}
+
+	// Probes from the configuration belong to the single user account.
+	// Like reports from clients, their points must not be tagged.
+	probe = &core.Probe{AccountID: userdb.God.GetAccountId()}
+
+	points = []*timeseries.Point{plugins.SimplePoint("a", 1)}
+	tagPoints(points, host, probe)
+
+	if id, found := points[0].Tags[timeseries.AccountTag]; found {
+		t.Errorf("Point of the single user account was tagged with '%s'", id)
+	}
+}
+
+func TestSchedulerElector(t *testing.T) {
+	wg := sync.WaitGroup{}
+	store, emitter := newTestStore(t)
+
+	elector := &switchElector{}
+	s := NewScheduler(store, emitter, emitter, userdb.God)
+	s.SetElector(elector)
+	s.SetTickResolution(10 * time.Millisecond)
+
+	start := time.Now()
+	probe := &core.Probe{
+		HostID:    "000000000000000000000000",
+		AgentID:   "warningagent",
+		Interval:  time.Hour,
+		LastCheck: start,
+		NextCheck: start,
+	}
+	store.AddProbe(userdb.God, probe)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	wg.Add(1)
+	go s.Loop(ctx, &wg, nil)
+
+	ran := func() bool {
+		stored, _ := store.GetProbe(userdb.God, probe.ID)
+
+		return stored.LastCheck.After(start)
+	}
+
+	// A follower must not run probes.
+	time.Sleep(100 * time.Millisecond)
+	if ran() {
+		t.Fatalf("Probe ran while not leader")
+	}
+
+	// The leader died, this instance takes over.
+	atomic.StoreInt32(&elector.leader, 1)
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) && !ran() {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if !ran() {
+		t.Fatalf("Probe did not run after becoming leader")
+	}
+}
+
+func TestLoadInFlight(t *testing.T) {
+	store, emitter := newTestStore(t)
+	s := NewScheduler(store, emitter, emitter, userdb.God)
+
+	running := &core.Probe{AgentID: "warningagent", Interval: time.Hour}
+	idle := &core.Probe{AgentID: "warningagent", Interval: time.Hour}
+	store.AddProbe(userdb.God, running)
+	store.AddProbe(userdb.God, idle)
+
+	s.inFlight[running.ID] = true
+
+	err := s.load()
+	if err != nil {
+		t.Fatalf("load() failed: %s", err.Error())
+	}
+
+	// A running probe queued again would run twice at once.
+	if s.queue.Len() != 1 {
+		t.Fatalf("Got %d probes in the queue, expected 1", s.queue.Len())
+	}
+
+	probes := s.queue.due(time.Now().Add(24 * time.Hour))
+	if len(probes) != 1 || probes[0].ID != idle.ID {
+		t.Errorf("Running probe was queued by load()")
+	}
+}
+
+func TestPopDueChange(t *testing.T) {
+	store, emitter := newTestStore(t)
+	s := NewScheduler(store, emitter, emitter, userdb.God)
+
+	now := time.Now()
+	probe := &core.Probe{
+		HostID:    "000000000000000000000000",
+		AgentID:   "warningagent",
+		Interval:  time.Hour,
+		LastCheck: now,
+		NextCheck: now,
+	}
+	store.AddProbe(userdb.God, probe)
+	s.load()
+
+	changes := emitter.Subscribe(userdb.God)
+	stop := make(chan struct{})
+	go s.follow(changes, stop)
+	defer func() {
+		emitter.Unsubscribe(changes)
+		close(stop)
+	}()
+
+	probes := s.popDue(now)
+	if len(probes) != 1 {
+		t.Fatalf("Got %d probes due, expected 1", len(probes))
+	}
+
+	// A change between popping the probe and running it must not queue
+	// it again. The second broadcast waits for follow() to handle the
+	// first.
+	emitter.Broadcast("probechange", probe)
+	emitter.Broadcast("probechange", probe)
+
+	s.queueLock.Lock()
+	queued := s.queue.Len()
+	s.queueLock.Unlock()
+
+	if queued != 0 {
+		t.Fatalf("Probe about to run was queued again")
+	}
+}
@@ -1,6 +1,7 @@
 package plugins
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -14,8 +15,44 @@ type (
 		Gather(transport Transport) error
 		GetPoints() []*timeseries.Point
 	}
+
+	// Warner can be implemented by agents able to gather most of their
+	// points even when something fails. Warnings are problems from the last
+	// call to Gather() not serious enough to fail it.
+	Warner interface {
+		Warnings() []string
+	}
 )
 
+var (
+	// ErrGatherTimeout will be returned by Gather() if the agent did not
+	// return before the deadline.
+	ErrGatherTimeout = errors.New("gather timed out")
+)
+
+// Gather will run agent.Gather() and wait for it to return or ctx to be done.
+// If the deadline is exceeded, the gathering is abandoned and
+// ErrGatherTimeout returned. An abandoned agent must not be used again.
+func Gather(ctx context.Context, agent Agent, transport Transport) error {
+	// Buffered to allow an abandoned gather to finish.
+	done := make(chan error, 1)
+
+	go func() {
+		done <- agent.Gather(transport)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return ErrGatherTimeout
+		}
+
+		return ctx.Err()
+	}
+}
+
 // GetAgent will return an agent of type id or nil plus an error if the
 // agent was not found.
 func GetAgent(id string) (Agent, error) {
@@ -37,6 +74,17 @@ func GetAgent(id string) (Agent, error) {
 	return agent, nil
 }
 
+// GetWarnings will return the warnings from the last Gather() if agent
+// implements Warner.
+func GetWarnings(agent Agent) []string {
+	warner, ok := agent.(Warner)
+	if !ok {
+		return nil
+	}
+
+	return warner.Warnings()
+}
+
 // GenericAgentTest can be called from local agent _test files to test agents
 // for conformance.
 func GenericAgentTest(t *testing.T, i interface{}) {
@@ -55,7 +103,13 @@ func GenericAgentTest(t *testing.T, i interface{}) {
 	doc := plugin.GetDoc()
 	points := agent.GetPoints()
 
-	for _, point := range points {
+	for n, point := range points {
+		// Preallocated slices can leave nil points behind.
+		if point == nil {
+			t.Errorf("Point %d of %d from %T is nil", n, len(points), i)
+			continue
+		}
+
 		// Check measurement documentation.
 		_, found := doc.Measurements[point.Name]
 		if !found {
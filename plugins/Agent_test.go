@@ -0,0 +1,65 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/timeseries"
+)
+
+type (
+	// sleepingAgent will sleep for delay in Gather() and return err.
+	sleepingAgent struct {
+		delay time.Duration
+		err   error
+	}
+)
+
+func (a *sleepingAgent) Gather(_ Transport) error {
+	time.Sleep(a.delay)
+
+	return a.err
+}
+
+func (a *sleepingAgent) GetPoints() []*timeseries.Point {
+	return nil
+}
+
+func TestGather(t *testing.T) {
+	failing := errors.New("failing")
+
+	cases := []struct {
+		agent    *sleepingAgent
+		expected error
+	}{
+		{&sleepingAgent{}, nil},
+		{&sleepingAgent{err: failing}, failing},
+		{&sleepingAgent{delay: time.Second}, ErrGatherTimeout},
+	}
+
+	for i, c := range cases {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+
+		start := time.Now()
+		err := Gather(ctx, c.agent, nil)
+		cancel()
+
+		if err != c.expected {
+			t.Errorf("%d: Gather() returned %v, expected %v", i, err, c.expected)
+		}
+
+		if time.Since(start) > 500*time.Millisecond {
+			t.Errorf("%d: Gather() did not return at the deadline", i)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Gather(ctx, &sleepingAgent{delay: time.Second}, nil)
+	if err != context.Canceled {
+		t.Errorf("Gather() returned %v for a canceled context, expected %v", err, context.Canceled)
+	}
+}
@@ -2,18 +2,58 @@ package plugins
 
 import (
 	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/abrander/agento/timeseries"
 )
 
-// Doc represents end user documentation for a plugin.
-type Doc struct {
-	Info struct {
-		Name        string `json:"name"`
+type (
+	// Doc represents end user documentation for a plugin.
+	Doc struct {
+		Info struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+		} `json:"info"`
+		Parameters   []Parameter       `json:"parameters"`
+		Tags         map[string]string `json:"-"`
+		Measurements map[string]string `json:"-"`
+
+		// units holds the unit of each measurement.
+		units map[string]string
+
+		// descriptions holds measurement descriptions without the unit.
+		descriptions map[string]string
+
+		// fields holds the declared type of fields per measurement.
+		fields map[string]map[string]timeseries.FieldType
+	}
+
+	// CatalogEntry is the documentation for a single plugin as returned by
+	// Catalog().
+	CatalogEntry struct {
+		Plugin       string               `json:"plugin"`
+		Description  string               `json:"description"`
+		Measurements []CatalogMeasurement `json:"measurements"`
+		Tags         []CatalogTag         `json:"tags"`
+	}
+
+	// CatalogMeasurement documents a single measurement.
+	CatalogMeasurement struct {
+		Key         string `json:"key"`
 		Description string `json:"description"`
-	} `json:"info"`
-	Parameters   []Parameter       `json:"parameters"`
-	Tags         map[string]string `json:"-"`
-	Measurements map[string]string `json:"-"`
-}
+		Unit        string `json:"unit"`
+
+		// Fields maps field names to their InfluxDB type if declared.
+		Fields map[string]string `json:"fields,omitempty"`
+	}
+
+	// CatalogTag documents a single tag.
+	CatalogTag struct {
+		Key         string `json:"key"`
+		Description string `json:"description"`
+	}
+)
 
 // NewDoc will instantiate a new Doc. Can be used from plugins to build GetDoc().
 func NewDoc(description string) *Doc {
@@ -22,13 +62,58 @@ func NewDoc(description string) *Doc {
 	doc.Info.Description = description
 	doc.Measurements = make(map[string]string)
 	doc.Tags = make(map[string]string)
+	doc.units = make(map[string]string)
+	doc.descriptions = make(map[string]string)
+	doc.fields = make(map[string]map[string]timeseries.FieldType)
 
 	return &doc
 }
 
-// AddMeasurement will add documentation for a measurement.
+// AddMeasurement will add documentation for a measurement. Rates and
+// percentages are always floats, the value field of measurements in a unit
+// per second or percent is declared as float.
 func (d *Doc) AddMeasurement(key string, description string, unit string) {
 	d.Measurements[key] = description + " (" + unit + ")"
+	d.units[key] = unit
+	d.descriptions[key] = description
+
+	if strings.HasSuffix(unit, "/s") || unit == "%" {
+		d.AddField(key, "value", timeseries.FieldFloat)
+	}
+}
+
+// AddField will declare the type of a field of the measurement key. Points
+// are converted to the declared type before being written, keeping InfluxDB
+// from rejecting points if an agent changes the type of a field.
+func (d *Doc) AddField(key string, field string, typ timeseries.FieldType) {
+	if d.fields == nil {
+		d.fields = make(map[string]map[string]timeseries.FieldType)
+	}
+
+	if d.fields[key] == nil {
+		d.fields[key] = make(map[string]timeseries.FieldType)
+	}
+
+	d.fields[key][field] = typ
+}
+
+// FieldTypes will return the declared field types of all registered plugins
+// keyed by measurement and field name separated by a dot. The result is
+// suitable for timeseries.DeclareFieldTypes().
+func FieldTypes() map[string]timeseries.FieldType {
+	types := make(map[string]timeseries.FieldType)
+
+	for _, p := range plugins {
+		doc := p.GetDoc()
+
+		for key, fields := range doc.fields {
+			for field, typ := range fields {
+				types[key+"."+field] = typ
+			}
+		}
+	}
+
+	return types
 }
 
 // AddTag will add documentation for a tag.
@@ -50,6 +135,69 @@ func GetDoc() map[string]*Doc {
 	return docs
 }
 
+// Catalog will return documentation for all registered plugins sorted by
+// name. Measurements and tags are sorted by key. The result is suitable for
+// JSON encoding.
+func Catalog() []CatalogEntry {
+	names := make([]string, 0, len(plugins))
+	for shortName := range plugins {
+		names = append(names, shortName)
+	}
+	sort.Strings(names)
+
+	catalog := make([]CatalogEntry, 0, len(names))
+	for _, name := range names {
+		doc := plugins[name].GetDoc()
+
+		entry := CatalogEntry{
+			Plugin:       name,
+			Description:  doc.Info.Description,
+			Measurements: []CatalogMeasurement{},
+			Tags:         []CatalogTag{},
+		}
+
+		for key, description := range doc.Measurements {
+			m := CatalogMeasurement{
+				Key:         key,
+				Description: description,
+			}
+
+			// Measurements added using AddMeasurement() will have the unit
+			// split out.
+			unit, found := doc.units[key]
+			if found {
+				m.Description = doc.descriptions[key]
+				m.Unit = unit
+			}
+
+			if len(doc.fields[key]) > 0 {
+				m.Fields = make(map[string]string)
+				for field, typ := range doc.fields[key] {
+					m.Fields[field] = typ.String()
+				}
+			}
+
+			entry.Measurements = append(entry.Measurements, m)
+		}
+
+		sort.Slice(entry.Measurements, func(i, j int) bool {
+			return entry.Measurements[i].Key < entry.Measurements[j].Key
+		})
+
+		for key, description := range doc.Tags {
+			entry.Tags = append(entry.Tags, CatalogTag{Key: key, Description: description})
+		}
+
+		sort.Slice(entry.Tags, func(i, j int) bool {
+			return entry.Tags[i].Key < entry.Tags[j].Key
+		})
+
+		catalog = append(catalog, entry)
+	}
+
+	return catalog
+}
+
 // GetDocAgents behaves like GetDoc(), but will only return documentaiton for
 // agents.
 func GetDocAgents() map[string]*Doc {
@@ -0,0 +1,79 @@
+package plugins_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/abrander/agento/plugins"
+	_ "github.com/abrander/agento/plugins/agents/cpustats"
+	"github.com/abrander/agento/timeseries"
+)
+
+func TestCatalog(t *testing.T) {
+	catalog := plugins.Catalog()
+
+	for _, entry := range catalog {
+		if entry.Plugin != "cpustats" {
+			continue
+		}
+
+		for _, m := range entry.Measurements {
+			if m.Key != "cpu.User" {
+				continue
+			}
+
+			if m.Unit != "ticks/s" {
+				t.Errorf("cpu.User has unit '%s', expected 'ticks/s'", m.Unit)
+			}
+
+			if m.Fields["value"] != "float" {
+				t.Errorf("cpu.User value is declared as '%s', expected 'float'", m.Fields["value"])
+			}
+
+			if m.Description != "Time spend in user mode" {
+				t.Errorf("cpu.User has wrong description '%s'", m.Description)
+			}
+
+			found := false
+			for _, tag := range entry.Tags {
+				if tag.Key == "core" {
+					found = true
+				}
+			}
+
+			if !found {
+				t.Errorf("Tag 'core' missing from cpustats")
+			}
+
+			_, err := json.Marshal(catalog)
+			if err != nil {
+				t.Errorf("Failed to encode catalog: %s", err.Error())
+			}
+
+			return
+		}
+
+		t.Fatalf("cpu.User not found in cpustats")
+	}
+
+	t.Fatalf("cpustats not found in catalog")
+}
+
+func TestFieldTypes(t *testing.T) {
+	types := plugins.FieldTypes()
+
+	expected := map[string]timeseries.FieldType{
+		"cpu.User.value":        timeseries.FieldFloat,
+		"cpu.UserPercent.value": timeseries.FieldFloat,
+	}
+
+	for key, typ := range expected {
+		if types[key] != typ {
+			t.Errorf("%s is declared as %s, expected %s", key, types[key], typ)
+		}
+	}
+
+	if _, found := types["misc.RunningProcesses.value"]; found {
+		t.Errorf("Measurement without rate unit was declared")
+	}
+}
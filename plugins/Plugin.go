@@ -1,6 +1,7 @@
 package plugins
 
 import (
+	"fmt"
 	"log"
 	"reflect"
 	"strings"
@@ -18,6 +19,7 @@ type Parameter struct {
 	Type        string   `json:"type"`
 	Description string   `json:"description"`
 	EnumValues  []string `json:"enumValues"`
+	Required    bool     `json:"required"`
 }
 
 // PluginConstructor is the type for a function that will instantiate a plugin.
@@ -95,6 +97,7 @@ func getParams(elem reflect.Type) []Parameter {
 			p.Name = jsonName
 			p.Type = f.Type.String()
 			p.Description = description
+			p.Required = f.Tag.Get("required") == "true"
 			enum := f.Tag.Get("enum")
 			if enum != "" {
 				p.EnumValues = strings.Split(enum, ",")
@@ -109,3 +112,58 @@ func getParams(elem reflect.Type) []Parameter {
 
 	return parameters
 }
+
+// ValidateConfig will check that all fields tagged with required:"true" on
+// plugin are set. If any are missing, an error listing them is returned.
+func ValidateConfig(plugin interface{}) error {
+	value := reflect.ValueOf(plugin)
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+
+	missing := missingFields(value)
+	if len(missing) > 0 {
+		return fmt.Errorf("%T is missing required configuration: %s", plugin, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// missingFields will return the names of required fields in value left at
+// their zero value.
+func missingFields(value reflect.Value) []string {
+	missing := []string{}
+
+	if value.Kind() != reflect.Struct {
+		return missing
+	}
+
+	elem := value.Type()
+	l := elem.NumField()
+
+	for i := 0; i < l; i++ {
+		f := elem.Field(i)
+
+		if f.Anonymous {
+			missing = append(missing, missingFields(value.Field(i))...)
+			continue
+		}
+
+		if f.Tag.Get("required") != "true" || !value.Field(i).IsZero() {
+			continue
+		}
+
+		name := f.Tag.Get("toml")
+		if name == "" {
+			name = f.Tag.Get("json")
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		missing = append(missing, name)
+	}
+
+	return missing
+}
@@ -0,0 +1,84 @@
+package plugins
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type (
+	embeddedConfig struct {
+		Path string `toml:"path" json:"path" description:"Path" required:"true"`
+	}
+
+	requiredConfig struct {
+		embeddedConfig
+
+		Command string `toml:"command" json:"command" description:"Command to run" required:"true"`
+		Port    int    `json:"port" description:"Port" required:"true"`
+		Name    string `required:"true"`
+		Prefix  string `toml:"prefix" json:"prefix" description:"Prefix"`
+	}
+)
+
+func TestValidateConfigMissing(t *testing.T) {
+	err := ValidateConfig(&requiredConfig{})
+	if err == nil {
+		t.Fatalf("ValidateConfig() accepted empty required fields")
+	}
+
+	for _, name := range []string{"path", "command", "port", "Name"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Error '%s' does not mention '%s'", err.Error(), name)
+		}
+	}
+
+	if strings.Contains(err.Error(), "prefix") {
+		t.Errorf("Error '%s' mentions optional field", err.Error())
+	}
+}
+
+func TestValidateConfigPartial(t *testing.T) {
+	c := &requiredConfig{
+		Command: "/bin/true",
+		Port:    80,
+		Name:    "test",
+	}
+
+	err := ValidateConfig(c)
+	if err == nil || !strings.HasSuffix(err.Error(), ": path") {
+		t.Fatalf("ValidateConfig() returned '%v', expected only path missing", err)
+	}
+}
+
+func TestValidateConfigOK(t *testing.T) {
+	c := &requiredConfig{
+		embeddedConfig: embeddedConfig{Path: "/"},
+		Command:        "/bin/true",
+		Port:           80,
+		Name:           "test",
+	}
+
+	err := ValidateConfig(c)
+	if err != nil {
+		t.Fatalf("ValidateConfig() failed: %s", err.Error())
+	}
+
+	err = ValidateConfig(struct{}{})
+	if err != nil {
+		t.Fatalf("ValidateConfig() failed for struct without required fields: %s", err.Error())
+	}
+}
+
+func TestGetParamsRequired(t *testing.T) {
+	params := getParams(reflect.TypeOf(requiredConfig{}))
+
+	required := map[string]bool{}
+	for _, p := range params {
+		required[p.Name] = p.Required
+	}
+
+	if !required["path"] || !required["command"] || !required["port"] || required["prefix"] {
+		t.Fatalf("Wrong required flags: %v", required)
+	}
+}
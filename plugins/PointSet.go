@@ -0,0 +1,61 @@
+package plugins
+
+import (
+	"time"
+
+	"github.com/abrander/agento/timeseries"
+)
+
+type (
+	// PointSet will collect points for GetPoints(). Points are appended,
+	// there's no index arithmetic to get wrong when adding or removing
+	// measurements.
+	PointSet struct {
+		points []*timeseries.Point
+		time   time.Time
+	}
+)
+
+// NewPointSet will return a new PointSet. capacity is only a hint, the set
+// will grow as needed.
+func NewPointSet(capacity int) *PointSet {
+	if capacity < 0 {
+		capacity = 0
+	}
+
+	return &PointSet{
+		points: make([]*timeseries.Point, 0, capacity),
+	}
+}
+
+// Add will add a point without tags like SimplePoint().
+func (s *PointSet) Add(key string, value interface{}) {
+	s.points = append(s.points, SimplePoint(key, value))
+}
+
+// AddTagged will add a point with a single tag like PointWithTag().
+func (s *PointSet) AddTagged(key string, value interface{}, tagKey string, tagValue string) {
+	s.points = append(s.points, PointWithTag(key, value, tagKey, tagValue))
+}
+
+// AddWithTags will add a point with tags like PointWithTags().
+func (s *PointSet) AddWithTags(key string, value interface{}, tags map[string]string) {
+	s.points = append(s.points, PointWithTags(key, value, tags))
+}
+
+// SetTime will set the time of all points to t, usually the time the sample
+// was taken. Points without a time are stamped when written.
+func (s *PointSet) SetTime(t time.Time) {
+	s.time = t
+}
+
+// Points will return all points added.
+func (s *PointSet) Points() []*timeseries.Point {
+	if !s.time.IsZero() {
+		for _, point := range s.points {
+			point.Time = s.time
+		}
+	}
+
+	return s.points
+}
@@ -0,0 +1,60 @@
+package plugins
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPointSet(t *testing.T) {
+	// A too small capacity must not leave nil points or drop any.
+	set := NewPointSet(1)
+
+	set.Add("a", 1)
+	set.AddTagged("b", 2, "core", "0")
+	set.AddWithTags("c", 3, map[string]string{"x": "y"})
+
+	points := set.Points()
+	if len(points) != 3 {
+		t.Fatalf("Got %d points, expected 3", len(points))
+	}
+
+	for i, point := range points {
+		if point == nil {
+			t.Fatalf("Point %d is nil", i)
+		}
+	}
+
+	if points[1].Tags["core"] != "0" || points[2].Tags["x"] != "y" {
+		t.Errorf("Tags were not set: %+v %+v", points[1].Tags, points[2].Tags)
+	}
+
+	// Too large capacity must not add trailing points.
+	set = NewPointSet(10)
+	set.Add("a", 1)
+	if len(set.Points()) != 1 {
+		t.Errorf("Got %d points, expected 1", len(set.Points()))
+	}
+
+	if len(NewPointSet(-1).Points()) != 0 {
+		t.Errorf("Empty set returned points")
+	}
+}
+
+func TestPointSetTime(t *testing.T) {
+	set := NewPointSet(2)
+	set.Add("a", 1)
+
+	if !set.Points()[0].Time.IsZero() {
+		t.Errorf("Point got a time without SetTime()")
+	}
+
+	sampletime := time.Now().Add(-time.Minute)
+	set.SetTime(sampletime)
+	set.Add("b", 2)
+
+	for i, point := range set.Points() {
+		if !point.Time.Equal(sampletime) {
+			t.Errorf("Point %d has time %s, expected %s", i, point.Time, sampletime)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package plugins
+
+import (
+	"reflect"
+	"sync"
+)
+
+type (
+	// Rates keeps the previous sample of agents between runs, allowing
+	// agents reporting counters to report per-second rates instead.
+	Rates struct {
+		sync.Mutex
+		previous map[string]Agent
+	}
+)
+
+// NewRates will instantiate a new empty Rates.
+func NewRates() *Rates {
+	return &Rates{
+		previous: make(map[string]Agent),
+	}
+}
+
+// Apply will return the rates of agent since the previous sample kept for
+// key. agent is kept as the previous sample for the next call. If the
+// previous sample is missing, false is returned as no rates can be
+// calculated yet.
+func (r *Rates) Apply(key string, agent Agent) (Agent, bool) {
+	r.Lock()
+	previous := r.previous[key]
+	r.previous[key] = agent
+	r.Unlock()
+
+	return Sub(agent, previous)
+}
+
+// Previous will return the previous sample kept for key or nil.
+func (r *Rates) Previous(key string) Agent {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.previous[key]
+}
+
+// Forget will remove the previous sample kept for key.
+func (r *Rates) Forget(key string) {
+	r.Lock()
+	delete(r.previous, key)
+	r.Unlock()
+}
+
+// Sub will return the rates between previous and current if current
+// implements a Sub() method taking and returning its own type. Agents without
+// Sub() are returned as is. If previous is nil or of another type, false is
+// returned as no rates can be calculated.
+func Sub(current Agent, previous Agent) (Agent, bool) {
+	typ := reflect.TypeOf(current)
+
+	method, found := typ.MethodByName("Sub")
+	if !found {
+		return current, true
+	}
+
+	// The receiver is the first argument.
+	if method.Type.NumIn() != 2 || method.Type.In(1) != typ ||
+		method.Type.NumOut() != 1 || method.Type.Out(0) != typ {
+		return current, true
+	}
+
+	if previous == nil || reflect.TypeOf(previous) != typ {
+		return nil, false
+	}
+
+	out := method.Func.Call([]reflect.Value{reflect.ValueOf(current), reflect.ValueOf(previous)})
+
+	diff, ok := out[0].Interface().(Agent)
+	if !ok {
+		return nil, false
+	}
+
+	return diff, true
+}
@@ -0,0 +1,111 @@
+package plugins
+
+import (
+	"testing"
+
+	"github.com/abrander/agento/timeseries"
+)
+
+type (
+	// counterAgent reports the difference between samples of Counter.
+	counterAgent struct {
+		Counter float64
+	}
+
+	// otherCounterAgent is a counter of another type.
+	otherCounterAgent struct {
+		counterAgent
+	}
+
+	// gaugeAgent has no Sub() and is reported as is.
+	gaugeAgent struct {
+		Gauge float64
+	}
+)
+
+func (a *counterAgent) Gather(_ Transport) error {
+	a.Counter += 10.0
+
+	return nil
+}
+
+func (a *counterAgent) GetPoints() []*timeseries.Point {
+	return []*timeseries.Point{SimplePoint("counter", a.Counter)}
+}
+
+func (a *counterAgent) Sub(previous *counterAgent) *counterAgent {
+	return &counterAgent{Counter: a.Counter - previous.Counter}
+}
+
+func (a *gaugeAgent) Gather(_ Transport) error {
+	return nil
+}
+
+func (a *gaugeAgent) GetPoints() []*timeseries.Point {
+	return []*timeseries.Point{SimplePoint("gauge", a.Gauge)}
+}
+
+func TestRatesApply(t *testing.T) {
+	rates := NewRates()
+
+	first := &counterAgent{Counter: 100.0}
+	_, ok := rates.Apply("a", first)
+	if ok {
+		t.Errorf("Apply() returned rates without a previous sample")
+	}
+
+	second := &counterAgent{Counter: 130.0}
+	diff, ok := rates.Apply("a", second)
+	if !ok {
+		t.Fatalf("Apply() returned no rates")
+	}
+
+	if diff.(*counterAgent).Counter != 30.0 {
+		t.Errorf("Got %f, expected 30", diff.(*counterAgent).Counter)
+	}
+
+	if rates.Previous("a") != second {
+		t.Errorf("Apply() did not keep the latest sample")
+	}
+
+	// Samples are kept per key.
+	_, ok = rates.Apply("b", &counterAgent{})
+	if ok {
+		t.Errorf("Apply() used the previous sample of another key")
+	}
+
+	rates.Forget("a")
+	if rates.Previous("a") != nil {
+		t.Errorf("Forget() did not remove the sample")
+	}
+
+	// A probe changing agent must not be diffed against the old type.
+	rates.Apply("c", &counterAgent{})
+	_, ok = rates.Apply("c", &otherCounterAgent{})
+	if ok {
+		t.Errorf("Apply() diffed agents of different types")
+	}
+}
+
+func (a *otherCounterAgent) Sub(previous *otherCounterAgent) *otherCounterAgent {
+	return a
+}
+
+func TestSub(t *testing.T) {
+	gauge := &gaugeAgent{Gauge: 42.0}
+
+	agent, ok := Sub(gauge, nil)
+	if !ok || agent != gauge {
+		t.Errorf("Sub() did not return an agent without Sub() as is")
+	}
+
+	_, ok = Sub(&counterAgent{}, nil)
+	if ok {
+		t.Errorf("Sub() returned rates without a previous sample")
+	}
+
+	_, ok = Sub(&counterAgent{}, gauge)
+	if ok {
+		t.Errorf("Sub() returned rates from a sample of another type")
+	}
+}
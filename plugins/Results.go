@@ -2,6 +2,7 @@ package plugins
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"github.com/abrander/agento/logger"
 	"github.com/abrander/agento/timeseries"
@@ -23,6 +24,9 @@ func (r Results) GetPoints() []*timeseries.Point {
 	return points
 }
 
+// UnmarshalJSON will decode each key into the concrete type registered for
+// the plugin by that name. Unknown keys are ignored to allow forward
+// compatibility.
 func (r *Results) UnmarshalJSON(b []byte) error {
 	var tmp = map[string]json.RawMessage{}
 
@@ -30,22 +34,29 @@ func (r *Results) UnmarshalJSON(b []byte) error {
 	if err != nil {
 		return err
 	}
+
+	if *r == nil {
+		*r = Results{}
+	}
+
 	for t, v := range tmp {
 		constructor, ok := pluginConstructors[t]
-		if ok {
-			res := constructor()
+		if !ok {
+			// Fail silently if we don't know the type to allow forward
+			// compatibility.
+			logger.Yellow("plugins", "Trying to unmarshal unknown type: %s", t)
 
-			err = json.Unmarshal(v, &res)
-			if err != nil {
-				return err
-			}
+			continue
+		}
 
-			(*r)[t] = res
-		} else {
-			logger.Yellow("plugins", "Trying to unmarshal unknown type: %s", t)
+		res := constructor()
 
+		err = json.Unmarshal(v, res)
+		if err != nil {
+			return fmt.Errorf("%s: %s", t, err.Error())
 		}
-		// Fail silently if we don't know the type to allow forward compatibility
+
+		(*r)[t] = res
 	}
 
 	return nil
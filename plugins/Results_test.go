@@ -0,0 +1,74 @@
+package plugins_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/agents/cpustats"
+	"github.com/abrander/agento/plugins/agents/hostname"
+)
+
+func TestResultsUnmarshalJSON(t *testing.T) {
+	payload := `{
+		"cpustats": {"cpu": {"0": [1, 2, 3, 4, 5, 6, 7, 8, 9, 10]}, "in": 100, "ct": 200},
+		"hostname": "web1",
+		"fluxcapacitor": {"gigawatts": 1.21}
+	}`
+
+	var results plugins.Results
+	err := json.Unmarshal([]byte(payload), &results)
+	if err != nil {
+		t.Fatalf("Unmarshal() failed: %s", err.Error())
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("Got %d results, expected 2", len(results))
+	}
+
+	if _, found := results["fluxcapacitor"]; found {
+		t.Errorf("Unknown key was decoded")
+	}
+
+	cpu, ok := results["cpustats"].(*cpustats.CpuStats)
+	if !ok {
+		t.Fatalf("cpustats decoded as %T", results["cpustats"])
+	}
+
+	if cpu.Interrupts != 100 || cpu.ContextSwitches != 200 {
+		t.Errorf("Wrong counters decoded: %+v", cpu)
+	}
+
+	if cpu.Cpu["0"] == nil || cpu.Cpu["0"].User != 1 || cpu.Cpu["0"].GuestNice != 10 {
+		t.Errorf("Wrong core decoded: %+v", cpu.Cpu["0"])
+	}
+
+	h, ok := results["hostname"].(*hostname.Hostname)
+	if !ok {
+		t.Fatalf("hostname decoded as %T", results["hostname"])
+	}
+
+	if *h != "web1" {
+		t.Errorf("Got hostname '%s', expected 'web1'", *h)
+	}
+
+	// All decoded results must be usable.
+	if len(results.GetPoints()) == 0 {
+		t.Errorf("No points from decoded results")
+	}
+}
+
+func TestResultsUnmarshalJSONInvalid(t *testing.T) {
+	payloads := []string{
+		`not json`,
+		`{"hostname": {"not": "a string"}}`,
+	}
+
+	for _, payload := range payloads {
+		var results plugins.Results
+		err := json.Unmarshal([]byte(payload), &results)
+		if err == nil {
+			t.Errorf("Unmarshal() accepted '%s'", payload)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package plugins
 
 import (
+	"bufio"
 	"errors"
 	"io"
 	"net"
@@ -51,3 +52,35 @@ func HTTPClient(transport Transport) *http.Client {
 		},
 	}
 }
+
+// ReadDir will return the names of the entries in the directory path. If the
+// transport returns something capable of listing directories from Open(), it
+// will be used, otherwise we fall back to executing ls.
+func ReadDir(transport Transport, path string) ([]string, error) {
+	f, err := transport.Open(path)
+	if err == nil {
+		defer f.Close()
+
+		dir, ok := f.(interface {
+			Readdirnames(n int) ([]string, error)
+		})
+		if ok {
+			return dir.Readdirnames(-1)
+		}
+	}
+
+	stdout, _, err := transport.Exec("ls", "-1", path)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if scanner.Text() != "" {
+			names = append(names, scanner.Text())
+		}
+	}
+
+	return names, scanner.Err()
+}
@@ -0,0 +1,222 @@
+package cgroup
+
+import (
+	"bufio"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("cgroup", NewCgroup)
+}
+
+// Cgroup will read memory and cpu usage for a single control group. Both the
+// unified v2 hierarchy and the v1 memory, cpu and cpuacct controllers are
+// supported. CPU usage and throttling are cumulative and will be converted
+// to microseconds per second by Sub().
+type Cgroup struct {
+	Path string `toml:"path" json:"path" description:"Path of the cgroup relative to /sys/fs/cgroup (e.g. system.slice/docker.service)"`
+
+	sampletime time.Time
+
+	Version       int     `json:"v"`
+	MemoryUsed    int64   `json:"mu"`
+	MemoryLimit   int64   `json:"ml"`
+	CpuUsageUsec  float64 `json:"cu"`
+	ThrottledUsec float64 `json:"tu"`
+}
+
+// unlimited is the threshold above which a v1 memory limit is considered
+// unset. The kernel reports the page aligned maximum of an int64.
+const unlimited = 1 << 62
+
+// NewCgroup will return a new Cgroup.
+func NewCgroup() interface{} {
+	return new(Cgroup)
+}
+
+// Gather will detect the cgroup layout and read usage for Path.
+func (c *Cgroup) Gather(transport plugins.Transport) error {
+	c.sampletime = time.Now()
+
+	root := filepath.Join(configuration.SysfsPath, "fs", "cgroup")
+
+	// cgroup.controllers only exists in the unified hierarchy.
+	_, err := transport.ReadFile(filepath.Join(root, "cgroup.controllers"))
+	if err == nil {
+		c.Version = 2
+		return c.gatherV2(transport, filepath.Join(root, c.Path))
+	}
+
+	c.Version = 1
+	return c.gatherV1(transport, root)
+}
+
+// gatherV2 will read memory.current, memory.max and cpu.stat from dir.
+func (c *Cgroup) gatherV2(transport plugins.Transport, dir string) error {
+	var err error
+
+	c.MemoryUsed, err = readInt(transport, filepath.Join(dir, "memory.current"))
+	if err != nil {
+		return err
+	}
+
+	c.MemoryLimit, err = readInt(transport, filepath.Join(dir, "memory.max"))
+	if err != nil {
+		return err
+	}
+
+	stat, err := readStat(transport, filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return err
+	}
+
+	c.CpuUsageUsec = float64(stat["usage_usec"])
+	c.ThrottledUsec = float64(stat["throttled_usec"])
+
+	return nil
+}
+
+// gatherV1 will read from the memory, cpuacct and cpu controllers. Times
+// are reported in nanoseconds by v1 and converted to microseconds.
+func (c *Cgroup) gatherV1(transport plugins.Transport, root string) error {
+	var err error
+
+	memory := filepath.Join(root, "memory", c.Path)
+
+	c.MemoryUsed, err = readInt(transport, filepath.Join(memory, "memory.usage_in_bytes"))
+	if err != nil {
+		return err
+	}
+
+	c.MemoryLimit, err = readInt(transport, filepath.Join(memory, "memory.limit_in_bytes"))
+	if err != nil {
+		return err
+	}
+
+	if c.MemoryLimit >= unlimited {
+		c.MemoryLimit = -1
+	}
+
+	usage, err := readInt(transport, filepath.Join(root, "cpuacct", c.Path, "cpuacct.usage"))
+	if err != nil {
+		return err
+	}
+
+	stat, err := readStat(transport, filepath.Join(root, "cpu", c.Path, "cpu.stat"))
+	if err != nil {
+		return err
+	}
+
+	c.CpuUsageUsec = float64(usage) / 1000.0
+	c.ThrottledUsec = float64(stat["throttled_time"]) / 1000.0
+
+	return nil
+}
+
+// readInt will read a single integer from path. "max" is returned as -1.
+func readInt(transport plugins.Transport, path string) (int64, error) {
+	contents, err := transport.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	trimmed := strings.TrimSpace(string(contents))
+	if trimmed == "max" {
+		return -1, nil
+	}
+
+	return strconv.ParseInt(trimmed, 10, 64)
+}
+
+// readStat will read a flat keyed file like cpu.stat. Lines not parsing
+// are ignored.
+func readStat(transport plugins.Transport, path string) (map[string]int64, error) {
+	contents, err := transport.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	stat := make(map[string]int64)
+
+	scanner := bufio.NewScanner(strings.NewReader(string(contents)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+
+		value, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		stat[fields[0]] = value
+	}
+
+	return stat, nil
+}
+
+// Sub will calculate CPU usage and throttling per second between previous
+// and c. Memory is copied as is. An empty Cgroup is returned if previous is
+// nil or no time has passed.
+func (c *Cgroup) Sub(previous *Cgroup) *Cgroup {
+	diff := &Cgroup{
+		Path: c.Path,
+	}
+
+	if previous == nil {
+		return diff
+	}
+
+	duration := c.sampletime.Sub(previous.sampletime)
+	if duration <= 0 {
+		return diff
+	}
+
+	factor := duration.Seconds()
+
+	diff.sampletime = c.sampletime
+	diff.Version = c.Version
+	diff.MemoryUsed = c.MemoryUsed
+	diff.MemoryLimit = c.MemoryLimit
+	diff.CpuUsageUsec = plugins.CounterRate(c.CpuUsageUsec, previous.CpuUsageUsec, factor)
+	diff.ThrottledUsec = plugins.CounterRate(c.ThrottledUsec, previous.ThrottledUsec, factor)
+
+	return diff
+}
+
+// GetPoints will return usage for the cgroup.
+func (c *Cgroup) GetPoints() []*timeseries.Point {
+	tags := map[string]string{"cgroup": "/" + strings.Trim(c.Path, "/")}
+
+	return []*timeseries.Point{
+		plugins.PointWithTags("cgroup.MemoryUsed", c.MemoryUsed, tags),
+		plugins.PointWithTags("cgroup.MemoryLimit", c.MemoryLimit, tags),
+		plugins.PointWithTags("cgroup.CpuUsageUsec", c.CpuUsageUsec, tags),
+		plugins.PointWithTags("cgroup.ThrottledUsec", c.ThrottledUsec, tags),
+	}
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (c *Cgroup) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("Control group memory and cpu usage")
+
+	doc.AddMeasurement("cgroup.MemoryUsed", "Memory used by the cgroup", "b")
+	doc.AddMeasurement("cgroup.MemoryLimit", "Memory limit of the cgroup (or -1 if unlimited)", "b")
+	doc.AddMeasurement("cgroup.CpuUsageUsec", "CPU time used by the cgroup", "µs/s")
+	doc.AddMeasurement("cgroup.ThrottledUsec", "Time the cgroup was throttled by its CPU quota", "µs/s")
+
+	doc.AddTag("cgroup", "The cgroup path")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*Cgroup)(nil)
@@ -0,0 +1,111 @@
+package cgroup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+)
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewCgroup())
+}
+
+// gather will read path using root as the sysfs path.
+func gather(t *testing.T, root string, path string) *Cgroup {
+	sysfsPath := configuration.SysfsPath
+	configuration.SysfsPath = root
+	defer func() { configuration.SysfsPath = sysfsPath }()
+
+	c := NewCgroup().(*Cgroup)
+	c.Path = path
+
+	err := c.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	return c
+}
+
+func TestGatherV2(t *testing.T) {
+	c := gather(t, "testdata/v2", "system.slice/app.service")
+
+	if c.Version != 2 {
+		t.Errorf("Detected cgroup v%d, expected v2", c.Version)
+	}
+
+	if c.MemoryUsed != 104857600 || c.MemoryLimit != -1 {
+		t.Errorf("Wrong memory: %+v", *c)
+	}
+
+	if c.CpuUsageUsec != 2500000 || c.ThrottledUsec != 30000 {
+		t.Errorf("Wrong cpu: %+v", *c)
+	}
+}
+
+func TestGatherV1(t *testing.T) {
+	c := gather(t, "testdata/v1", "/docker/abc")
+
+	if c.Version != 1 {
+		t.Errorf("Detected cgroup v%d, expected v1", c.Version)
+	}
+
+	if c.MemoryUsed != 52428800 || c.MemoryLimit != 268435456 {
+		t.Errorf("Wrong memory: %+v", *c)
+	}
+
+	if c.CpuUsageUsec != 1500000 || c.ThrottledUsec != 20000 {
+		t.Errorf("Wrong cpu: %+v", *c)
+	}
+}
+
+func TestGatherMissing(t *testing.T) {
+	sysfsPath := configuration.SysfsPath
+	configuration.SysfsPath = "testdata/v2"
+	defer func() { configuration.SysfsPath = sysfsPath }()
+
+	c := NewCgroup().(*Cgroup)
+	c.Path = "nonexisting.slice"
+
+	err := c.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err == nil {
+		t.Errorf("Gather() did not fail for a missing cgroup")
+	}
+}
+
+func TestSub(t *testing.T) {
+	previous := &Cgroup{
+		sampletime:    time.Now(),
+		CpuUsageUsec:  1000000,
+		ThrottledUsec: 5000,
+	}
+
+	current := &Cgroup{
+		sampletime:    previous.sampletime.Add(10 * time.Second),
+		MemoryUsed:    1024,
+		MemoryLimit:   4096,
+		CpuUsageUsec:  6000000,
+		ThrottledUsec: 15000,
+	}
+
+	diff := current.Sub(previous)
+	if diff.CpuUsageUsec != 500000 || diff.ThrottledUsec != 1000 {
+		t.Errorf("Wrong rates: %+v", *diff)
+	}
+
+	if diff.MemoryUsed != 1024 || diff.MemoryLimit != 4096 {
+		t.Errorf("Memory not copied: %+v", *diff)
+	}
+
+	current.sampletime = previous.sampletime
+	if current.Sub(previous).CpuUsageUsec != 0.0 {
+		t.Errorf("Sub() returned rates for a zero duration")
+	}
+
+	if current.Sub(nil).MemoryUsed != 0 {
+		t.Errorf("Sub() returned values without a previous sample")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/abrander/agento/configuration"
 	"github.com/abrander/agento/plugins"
@@ -16,6 +17,7 @@ func init() {
 }
 
 type CpuStats struct {
+	sampletime       time.Time                 `json:"-"`
 	Cpu              map[string]*SingleCpuStat `json:"cpu"`
 	Interrupts       float64                   `json:"in"`
 	ContextSwitches  float64                   `json:"ct"`
@@ -38,6 +40,7 @@ func (stat *CpuStats) Gather(transport plugins.Transport) error {
 	}
 	defer file.Close()
 
+	stat.sampletime = time.Now()
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		text := scanner.Text()
@@ -78,32 +81,80 @@ func (stat *CpuStats) Gather(transport plugins.Transport) error {
 	return nil
 }
 
+// Sub will calculate the per-second rates between previous and c. If previous
+// is nil or no time has passed between the two samples (or the clock went
+// backwards), an empty CpuStats is returned to avoid emitting bogus values.
+// Counters that went backwards (after a reboot or a core coming back online)
+// will be reported as zero for the interval.
+func (c *CpuStats) Sub(previous *CpuStats) *CpuStats {
+	diff := &CpuStats{
+		Cpu: make(map[string]*SingleCpuStat),
+	}
+
+	if previous == nil {
+		return diff
+	}
+
+	duration := c.sampletime.Sub(previous.sampletime)
+	if duration <= 0 {
+		return diff
+	}
+
+	factor := duration.Seconds()
+
+	for key, value := range c.Cpu {
+		prev, found := previous.Cpu[key]
+		if found {
+			diff.Cpu[key] = value.Sub(prev, factor)
+		}
+	}
+
+	diff.sampletime = c.sampletime
+	diff.Interrupts = plugins.CounterRate(c.Interrupts, previous.Interrupts, factor)
+	diff.ContextSwitches = plugins.CounterRate(c.ContextSwitches, previous.ContextSwitches, factor)
+	diff.Forks = plugins.CounterRate(c.Forks, previous.Forks, factor)
+	diff.RunningProcesses = c.RunningProcesses
+	diff.BlockedProcesses = c.BlockedProcesses
+
+	return diff
+}
+
 func (c *CpuStats) GetPoints() []*timeseries.Point {
-	points := make([]*timeseries.Point, 5+len(c.Cpu)*10)
+	points := plugins.NewPointSet(5 + len(c.Cpu)*20)
+	points.SetTime(c.sampletime)
 
-	points[0] = plugins.SimplePoint("misc.Interrupts", c.Interrupts)
-	points[1] = plugins.SimplePoint("misc.ContextSwitches", c.ContextSwitches)
-	points[2] = plugins.SimplePoint("misc.Forks", c.Forks)
-	points[3] = plugins.SimplePoint("misc.RunningProcesses", c.RunningProcesses)
-	points[4] = plugins.SimplePoint("misc.BlockedProcesses", c.BlockedProcesses)
+	points.Add("misc.Interrupts", c.Interrupts)
+	points.Add("misc.ContextSwitches", c.ContextSwitches)
+	points.Add("misc.Forks", c.Forks)
+	points.Add("misc.RunningProcesses", c.RunningProcesses)
+	points.Add("misc.BlockedProcesses", c.BlockedProcesses)
 
-	i := 5
 	for key, value := range c.Cpu {
-		points[i+0] = plugins.PointWithTag("cpu.User", value.User, "core", key)
-		points[i+1] = plugins.PointWithTag("cpu.Nice", value.Nice, "core", key)
-		points[i+2] = plugins.PointWithTag("cpu.System", value.System, "core", key)
-		points[i+3] = plugins.PointWithTag("cpu.Idle", value.Idle, "core", key)
-		points[i+4] = plugins.PointWithTag("cpu.IoWait", value.IoWait, "core", key)
-		points[i+5] = plugins.PointWithTag("cpu.Irq", value.Irq, "core", key)
-		points[i+6] = plugins.PointWithTag("cpu.SoftIrq", value.SoftIrq, "core", key)
-		points[i+7] = plugins.PointWithTag("cpu.Steal", value.Steal, "core", key)
-		points[i+8] = plugins.PointWithTag("cpu.Guest", value.Guest, "core", key)
-		points[i+9] = plugins.PointWithTag("cpu.GuestNice", value.GuestNice, "core", key)
-
-		i = i + 10
+		points.AddTagged("cpu.User", value.User, "core", key)
+		points.AddTagged("cpu.Nice", value.Nice, "core", key)
+		points.AddTagged("cpu.System", value.System, "core", key)
+		points.AddTagged("cpu.Idle", value.Idle, "core", key)
+		points.AddTagged("cpu.IoWait", value.IoWait, "core", key)
+		points.AddTagged("cpu.Irq", value.Irq, "core", key)
+		points.AddTagged("cpu.SoftIrq", value.SoftIrq, "core", key)
+		points.AddTagged("cpu.Steal", value.Steal, "core", key)
+		points.AddTagged("cpu.Guest", value.Guest, "core", key)
+		points.AddTagged("cpu.GuestNice", value.GuestNice, "core", key)
+
+		percent := value.Percent()
+		points.AddTagged("cpu.UserPercent", percent.User, "core", key)
+		points.AddTagged("cpu.NicePercent", percent.Nice, "core", key)
+		points.AddTagged("cpu.SystemPercent", percent.System, "core", key)
+		points.AddTagged("cpu.IdlePercent", percent.Idle, "core", key)
+		points.AddTagged("cpu.IoWaitPercent", percent.IoWait, "core", key)
+		points.AddTagged("cpu.IrqPercent", percent.Irq, "core", key)
+		points.AddTagged("cpu.SoftIrqPercent", percent.SoftIrq, "core", key)
+		points.AddTagged("cpu.StealPercent", percent.Steal, "core", key)
+		points.AddTagged("cpu.GuestPercent", percent.Guest, "core", key)
+		points.AddTagged("cpu.GuestNicePercent", percent.GuestNice, "core", key)
 	}
 
-	return points
+	return points.Points()
 }
 
 func (c *CpuStats) GetDoc() *plugins.Doc {
@@ -128,6 +179,17 @@ func (c *CpuStats) GetDoc() *plugins.Doc {
 	doc.AddMeasurement("cpu.Guest", "Time spend on running guests", "ticks/s")
 	doc.AddMeasurement("cpu.GuestNice", "Time spend on running nice guests", "ticks/s")
 
+	doc.AddMeasurement("cpu.UserPercent", "Share of time spend in user mode", "%")
+	doc.AddMeasurement("cpu.NicePercent", "Share of time spend in user mode with low priority", "%")
+	doc.AddMeasurement("cpu.SystemPercent", "Share of time spend in kernel mode", "%")
+	doc.AddMeasurement("cpu.IdlePercent", "Share of time spend idle", "%")
+	doc.AddMeasurement("cpu.IoWaitPercent", "Share of time spend waiting for IO", "%")
+	doc.AddMeasurement("cpu.IrqPercent", "Share of time spend processing interrupts", "%")
+	doc.AddMeasurement("cpu.SoftIrqPercent", "Share of time spend processing soft interrupts", "%")
+	doc.AddMeasurement("cpu.StealPercent", "Share of time spend waiting for the *physical* CPU on a guest", "%")
+	doc.AddMeasurement("cpu.GuestPercent", "Share of time spend on running guests (included in user)", "%")
+	doc.AddMeasurement("cpu.GuestNicePercent", "Share of time spend on running nice guests (included in nice)", "%")
+
 	return doc
 }
 
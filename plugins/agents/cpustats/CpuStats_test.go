@@ -1,9 +1,14 @@
 package cpustats
 
 import (
+	"bytes"
+	"math"
 	"testing"
+	"time"
 
+	"github.com/abrander/agento/configuration"
 	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
 	"github.com/abrander/agento/plugins/transports/mock"
 )
 
@@ -54,3 +59,230 @@ func TestGather(t *testing.T) {
 func TestAgent(t *testing.T) {
 	plugins.GenericAgentTest(t, NewCpuStats())
 }
+
+func TestSubSameSampletime(t *testing.T) {
+	transport := mocktransport.NewMock()
+	mock := transport.(*mocktransport.Mock)
+	mock.SetFile("/proc/stat", testData)
+
+	previous := NewCpuStats().(*CpuStats)
+	current := NewCpuStats().(*CpuStats)
+
+	previous.Gather(mock)
+	current.Gather(mock)
+
+	cases := map[string]time.Duration{
+		"same sampletime": 0,
+		"clock backwards": -time.Second,
+	}
+
+	for name, offset := range cases {
+		current.sampletime = previous.sampletime.Add(offset)
+
+		diff := current.Sub(previous)
+
+		for _, point := range diff.GetPoints() {
+			value, ok := point.Fields["value"].(float64)
+			if ok && (math.IsInf(value, 0) || math.IsNaN(value)) {
+				t.Errorf("%s: %s is not finite: %f", name, point.Name, value)
+			}
+		}
+	}
+}
+
+func TestSubCounterReset(t *testing.T) {
+	previous := &CpuStats{
+		Cpu: map[string]*SingleCpuStat{
+			"0": &SingleCpuStat{User: 1000, System: 1000, Idle: 1000},
+		},
+		Interrupts:      305606156,
+		ContextSwitches: 882885801,
+		Forks:           98481,
+	}
+
+	current := &CpuStats{
+		sampletime: previous.sampletime.Add(time.Second),
+		Cpu: map[string]*SingleCpuStat{
+			"0": &SingleCpuStat{User: 10, System: 10, Idle: 2000},
+		},
+		Interrupts:      100,
+		ContextSwitches: 200,
+		Forks:           10,
+	}
+
+	diff := current.Sub(previous)
+
+	for _, point := range diff.GetPoints() {
+		value, ok := point.Fields["value"].(float64)
+		if ok && value < 0.0 {
+			t.Errorf("%s is negative after counter reset: %f", point.Name, value)
+		}
+	}
+
+	if diff.Cpu["0"].Idle != 1000.0 {
+		t.Errorf("Idle rate is %f, should be 1000", diff.Cpu["0"].Idle)
+	}
+}
+
+func TestSubPercent(t *testing.T) {
+	previous := &CpuStats{
+		Cpu: map[string]*SingleCpuStat{
+			"0": &SingleCpuStat{User: 1000, Nice: 100, System: 500, Idle: 8000, IoWait: 200, Irq: 10, SoftIrq: 20, Steal: 0, Guest: 50},
+		},
+	}
+
+	current := &CpuStats{
+		sampletime: previous.sampletime.Add(2 * time.Second),
+		Cpu: map[string]*SingleCpuStat{
+			"0": &SingleCpuStat{User: 1050, Nice: 100, System: 520, Idle: 8120, IoWait: 206, Irq: 11, SoftIrq: 23, Steal: 0, Guest: 60},
+		},
+	}
+
+	diff := current.Sub(previous)
+
+	// The deltas sum to 200 ticks, 100 ticks/s.
+	percent := diff.Cpu["0"].Percent()
+
+	expected := map[string]float64{
+		"User":    25.0,
+		"System":  10.0,
+		"Idle":    60.0,
+		"IoWait":  3.0,
+		"Irq":     0.5,
+		"SoftIrq": 1.5,
+		"Guest":   5.0,
+	}
+
+	got := map[string]float64{
+		"User":    percent.User,
+		"System":  percent.System,
+		"Idle":    percent.Idle,
+		"IoWait":  percent.IoWait,
+		"Irq":     percent.Irq,
+		"SoftIrq": percent.SoftIrq,
+		"Guest":   percent.Guest,
+	}
+
+	for state, value := range expected {
+		if math.Abs(got[state]-value) > 0.0001 {
+			t.Errorf("%s is %f%%, expected %f%%", state, got[state], value)
+		}
+	}
+
+	sum := percent.User + percent.Nice + percent.System + percent.Idle + percent.IoWait + percent.Irq + percent.SoftIrq + percent.Steal
+	if math.Abs(sum-100.0) > 0.0001 {
+		t.Errorf("Percentages sum to %f, expected 100", sum)
+	}
+
+	// The old rates must still be there.
+	if diff.Cpu["0"].User != 25.0 {
+		t.Errorf("User rate is %f, expected 25", diff.Cpu["0"].User)
+	}
+}
+
+func TestPercentEmpty(t *testing.T) {
+	percent := (&SingleCpuStat{}).Percent()
+
+	if percent.Idle != 0.0 || percent.User != 0.0 {
+		t.Errorf("Percent() of an empty sample is not zero: %+v", percent)
+	}
+}
+
+func TestGatherFixture(t *testing.T) {
+	procPath := configuration.ProcPath
+	configuration.ProcPath = "testdata/proc"
+	defer func() { configuration.ProcPath = procPath }()
+
+	stat := NewCpuStats().(*CpuStats)
+	err := stat.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if len(stat.Cpu) != 3 {
+		t.Fatalf("Got %d cpus, expected 3 (all, 0 and 1)", len(stat.Cpu))
+	}
+
+	expected := SingleCpuStat{User: 1598806, Nice: 23329, System: 388310, Idle: 59296242, IoWait: 41929, Irq: 6, SoftIrq: 179}
+	if *stat.Cpu["1"] != expected {
+		t.Errorf("Got %+v for cpu1, expected %+v", *stat.Cpu["1"], expected)
+	}
+
+	if stat.Interrupts != 305606156 || stat.ContextSwitches != 882885801 || stat.Forks != 98481 {
+		t.Errorf("Wrong counters: %+v", stat)
+	}
+
+	if stat.RunningProcesses != 2 || stat.BlockedProcesses != 10 {
+		t.Errorf("Got %d running and %d blocked, expected 2 and 10", stat.RunningProcesses, stat.BlockedProcesses)
+	}
+}
+
+func TestGetPoints(t *testing.T) {
+	procPath := configuration.ProcPath
+	configuration.ProcPath = "testdata/proc"
+	defer func() { configuration.ProcPath = procPath }()
+
+	stat := NewCpuStats().(*CpuStats)
+	stat.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+
+	points := stat.GetPoints()
+
+	// 5 misc points and 20 per cpu.
+	if len(points) != 5+3*20 {
+		t.Fatalf("Got %d points, expected %d", len(points), 5+3*20)
+	}
+
+	for i, point := range points {
+		if point == nil {
+			t.Fatalf("Point %d is nil", i)
+		}
+
+		if !point.Time.Equal(stat.sampletime) {
+			t.Errorf("Point %d has time %s, expected the sample time %s", i, point.Time, stat.sampletime)
+		}
+	}
+
+	plugins.GenericAgentTest(t, stat)
+}
+
+func TestRates(t *testing.T) {
+	mock := mocktransport.NewMock().(*mocktransport.Mock)
+	rates := plugins.NewRates()
+
+	mock.SetFile("/proc/stat", testData)
+	previous := NewCpuStats().(*CpuStats)
+	previous.Gather(mock)
+
+	_, ok := rates.Apply("cpustats", previous)
+	if ok {
+		t.Fatalf("Got rates from a single sample")
+	}
+
+	// Pretend the first sample was taken two seconds ago.
+	previous.sampletime = previous.sampletime.Add(-2 * time.Second)
+
+	mock.SetFile("/proc/stat", bytes.Replace(testData, []byte("ctxt 882885801"), []byte("ctxt 882887801"), 1))
+	current := NewCpuStats().(*CpuStats)
+	current.Gather(mock)
+
+	agent, ok := rates.Apply("cpustats", current)
+	if !ok {
+		t.Fatalf("Got no rates from two samples")
+	}
+
+	for _, point := range agent.GetPoints() {
+		if point.Name != "misc.ContextSwitches" {
+			continue
+		}
+
+		// The samples are a few microseconds more than two seconds apart.
+		value := point.Fields["value"].(float64)
+		if value > 1000.0 || value < 999.0 {
+			t.Errorf("ContextSwitches is %f, should be 1000", value)
+		}
+
+		return
+	}
+
+	t.Errorf("misc.ContextSwitches not found")
+}
@@ -94,19 +94,61 @@ func (s *SingleCpuStat) UnmarshalJSON(b []byte) error {
 	return err
 }
 
+// Sub returns the rates in ticks per second between previous and s. The
+// percentage of the interval spent in each state can be read using Percent()
+// on the result.
 func (s *SingleCpuStat) Sub(previous *SingleCpuStat, factor float64) *SingleCpuStat {
 	diff := SingleCpuStat{}
 
-	diff.User = (s.User - previous.User) / factor
-	diff.Nice = (s.Nice - previous.Nice) / factor
-	diff.System = (s.System - previous.System) / factor
-	diff.Idle = (s.Idle - previous.Idle) / factor
-	diff.IoWait = (s.IoWait - previous.IoWait) / factor
-	diff.Irq = (s.Irq - previous.Irq) / factor
-	diff.SoftIrq = (s.SoftIrq - previous.SoftIrq) / factor
-	diff.Steal = (s.Steal - previous.Steal) / factor
-	diff.Guest = (s.Guest - previous.Guest) / factor
-	diff.GuestNice = (s.GuestNice - previous.GuestNice) / factor
+	// Dividing by zero (or a negative duration) would give us +Inf or NaN.
+	if factor <= 0 {
+		return &diff
+	}
+
+	diff.User = plugins.CounterRate(s.User, previous.User, factor)
+	diff.Nice = plugins.CounterRate(s.Nice, previous.Nice, factor)
+	diff.System = plugins.CounterRate(s.System, previous.System, factor)
+	diff.Idle = plugins.CounterRate(s.Idle, previous.Idle, factor)
+	diff.IoWait = plugins.CounterRate(s.IoWait, previous.IoWait, factor)
+	diff.Irq = plugins.CounterRate(s.Irq, previous.Irq, factor)
+	diff.SoftIrq = plugins.CounterRate(s.SoftIrq, previous.SoftIrq, factor)
+	diff.Steal = plugins.CounterRate(s.Steal, previous.Steal, factor)
+	diff.Guest = plugins.CounterRate(s.Guest, previous.Guest, factor)
+	diff.GuestNice = plugins.CounterRate(s.GuestNice, previous.GuestNice, factor)
 
 	return &diff
 }
+
+// Total returns the sum of all states. Guest and GuestNice are not included,
+// the kernel already accounts them in User and Nice.
+func (s *SingleCpuStat) Total() float64 {
+	return s.User + s.Nice + s.System + s.Idle + s.IoWait + s.Irq + s.SoftIrq + s.Steal
+}
+
+// Percent returns the share of Total() each state consumed in percent. Used
+// on the rates returned by Sub(), this is the percentage of the interval
+// spent in each state. Excluding Guest and GuestNice, the percentages sum to
+// 100.
+func (s *SingleCpuStat) Percent() *SingleCpuStat {
+	percent := SingleCpuStat{}
+
+	total := s.Total()
+	if total <= 0 {
+		return &percent
+	}
+
+	factor := 100.0 / total
+
+	percent.User = s.User * factor
+	percent.Nice = s.Nice * factor
+	percent.System = s.System * factor
+	percent.Idle = s.Idle * factor
+	percent.IoWait = s.IoWait * factor
+	percent.Irq = s.Irq * factor
+	percent.SoftIrq = s.SoftIrq * factor
+	percent.Steal = s.Steal * factor
+	percent.Guest = s.Guest * factor
+	percent.GuestNice = s.GuestNice * factor
+
+	return &percent
+}
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/abrander/agento/configuration"
 	"github.com/abrander/agento/plugins"
@@ -20,7 +21,42 @@ func NewDiskStats() interface{} {
 }
 
 type DiskStats struct {
-	Disks map[string]*SingleDiskStats `json:"disks"`
+	sampletime time.Time                   `json:"-"`
+	Disks      map[string]*SingleDiskStats `json:"disks"`
+
+	ExcludePartitions bool `toml:"excludePartitions" json:"excludePartitions" description:"Exclude partitions, only report whole devices"`
+	ExcludeVirtual    bool `toml:"excludeVirtual" json:"excludeVirtual" description:"Exclude loop and ram devices"`
+}
+
+// virtualPrefixes lists device name prefixes of virtual devices.
+var virtualPrefixes = []string{"loop", "ram"}
+
+// isVirtual will return true if name is a loop or ram device.
+func isVirtual(name string) bool {
+	for _, prefix := range virtualPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isPartition will return true if name is a partition of one of the devices
+// in devices. sda1 is a partition of sda and nvme0n1p1 of nvme0n1.
+func isPartition(name string, devices map[string]*SingleDiskStats) bool {
+	for device := range devices {
+		if device == name || !strings.HasPrefix(name, device) {
+			continue
+		}
+
+		suffix := strings.TrimPrefix(strings.TrimPrefix(name, device), "p")
+		if _, err := strconv.Atoi(suffix); err == nil {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (stat *DiskStats) Gather(transport plugins.Transport) error {
@@ -33,12 +69,19 @@ func (stat *DiskStats) Gather(transport plugins.Transport) error {
 	}
 	defer file.Close()
 
+	stat.sampletime = time.Now()
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		text := scanner.Text()
 
+		// Newer kernels add discard and flush fields, we only use the first
+		// 14.
 		data := strings.Fields(strings.Trim(text, " "))
-		if len(data) != 14 {
+		if len(data) < 14 {
+			continue
+		}
+
+		if stat.ExcludeVirtual && isVirtual(data[2]) {
 			continue
 		}
 
@@ -51,11 +94,52 @@ func (stat *DiskStats) Gather(transport plugins.Transport) error {
 		}
 	}
 
+	if stat.ExcludePartitions {
+		for name := range stat.Disks {
+			if isPartition(name, stat.Disks) {
+				delete(stat.Disks, name)
+			}
+		}
+	}
+
 	return nil
 }
 
+// Sub will calculate per-second rates between previous and d. Like cpustats,
+// an empty DiskStats is returned if previous is nil or no time has passed.
+// Devices not present in both samples are left out.
+func (d *DiskStats) Sub(previous *DiskStats) *DiskStats {
+	diff := &DiskStats{
+		ExcludePartitions: d.ExcludePartitions,
+		ExcludeVirtual:    d.ExcludeVirtual,
+		Disks:             make(map[string]*SingleDiskStats),
+	}
+
+	if previous == nil {
+		return diff
+	}
+
+	duration := d.sampletime.Sub(previous.sampletime)
+	if duration <= 0 {
+		return diff
+	}
+
+	factor := duration.Seconds()
+
+	for key, value := range d.Disks {
+		prev, found := previous.Disks[key]
+		if found {
+			diff.Disks[key] = value.Sub(prev, factor)
+		}
+	}
+
+	diff.sampletime = d.sampletime
+
+	return diff
+}
+
 func (d *DiskStats) GetPoints() []*timeseries.Point {
-	points := make([]*timeseries.Point, len(d.Disks)*11)
+	points := make([]*timeseries.Point, len(d.Disks)*13)
 
 	i := 0
 	for key, value := range d.Disks {
@@ -70,8 +154,10 @@ func (d *DiskStats) GetPoints() []*timeseries.Point {
 		points[i+8] = plugins.PointWithTag("io.IoInProgress", value.IoInProgress, "device", key)
 		points[i+9] = plugins.PointWithTag("io.IoTime", value.IoTime, "device", key)
 		points[i+10] = plugins.PointWithTag("io.IoWeightedTime", value.IoWeightedTime, "device", key)
+		points[i+11] = plugins.PointWithTag("io.ReadBytes", value.ReadSectors*sectorSize, "device", key)
+		points[i+12] = plugins.PointWithTag("io.WriteBytes", value.WriteSectors*sectorSize, "device", key)
 
-		i = i + 11
+		i = i + 13
 	}
 
 	return points
@@ -93,6 +179,8 @@ func (c *DiskStats) GetDoc() *plugins.Doc {
 	doc.AddMeasurement("io.IoInProgress", "The current queue size of IO operation", "(n")
 	doc.AddMeasurement("io.IoTime", "Time spend on IO", "ms/s")
 	doc.AddMeasurement("io.IoWeightedTime", "Time spend on IO times the IO queue. Please see https://www.kernel.org/doc/Documentation/iostats.txt", "ms/s")
+	doc.AddMeasurement("io.ReadBytes", "Bytes read", "b/s")
+	doc.AddMeasurement("io.WriteBytes", "Bytes written", "b/s")
 
 	return doc
 }
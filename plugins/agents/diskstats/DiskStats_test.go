@@ -2,10 +2,161 @@ package diskstats
 
 import (
 	"testing"
+	"time"
 
 	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/mock"
+)
+
+var (
+	testData1 = []byte(`   7       0 loop0 100 0 2000 10 0 0 0 0 0 20 10 0 0 0 0
+   1       0 ram0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0
+   8       0 sda 1000 10 20000 500 2000 20 40000 1000 1 1200 1500 0 0 0 0 10 5
+   8       1 sda1 900 10 18000 450 1900 20 38000 950 0 1100 1400 0 0 0 0
+ 259       0 nvme0n1 5000 0 100000 700 6000 0 200000 900 2 1500 1600
+ 259       1 nvme0n1p1 4000 0 80000 600 5000 0 160000 800 0 1300 1400
+`)
+
+	testData2 = []byte(`   7       0 loop0 100 0 2000 10 0 0 0 0 0 20 10 0 0 0 0
+   1       0 ram0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0
+   8       0 sda 1100 10 22000 520 2200 20 44000 1040 3 1300 1700 0 0 0 0 10 5
+   8       1 sda1 1000 10 20000 470 2100 20 42000 990 0 1200 1600 0 0 0 0
+ 259       0 nvme0n1 5000 0 100000 700 6000 0 200000 900 2 1500 1600
+ 259       1 nvme0n1p1 4000 0 80000 600 5000 0 160000 800 0 1300 1400
+`)
 )
 
 func TestAgent(t *testing.T) {
 	plugins.GenericAgentTest(t, NewDiskStats())
 }
+
+func gather(t *testing.T, data []byte, stats *DiskStats) {
+	mock := mocktransport.NewMock().(*mocktransport.Mock)
+	mock.SetFile("/proc/diskstats", data)
+
+	err := stats.Gather(mock)
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+}
+
+func TestGatherFilter(t *testing.T) {
+	cases := []struct {
+		excludePartitions bool
+		excludeVirtual    bool
+		expected          []string
+	}{
+		{false, false, []string{"loop0", "sda", "sda1", "nvme0n1", "nvme0n1p1"}},
+		{true, false, []string{"loop0", "sda", "nvme0n1"}},
+		{false, true, []string{"sda", "sda1", "nvme0n1", "nvme0n1p1"}},
+		{true, true, []string{"sda", "nvme0n1"}},
+	}
+
+	for i, c := range cases {
+		stats := &DiskStats{
+			ExcludePartitions: c.excludePartitions,
+			ExcludeVirtual:    c.excludeVirtual,
+		}
+		gather(t, testData1, stats)
+
+		if len(stats.Disks) != len(c.expected) {
+			t.Errorf("%d: Got %d devices, expected %d", i, len(stats.Disks), len(c.expected))
+		}
+
+		for _, device := range c.expected {
+			if _, found := stats.Disks[device]; !found {
+				t.Errorf("%d: %s not found", i, device)
+			}
+		}
+	}
+}
+
+func TestSub(t *testing.T) {
+	previous := &DiskStats{}
+	gather(t, testData1, previous)
+
+	current := &DiskStats{}
+	gather(t, testData2, current)
+	current.sampletime = previous.sampletime.Add(2 * time.Second)
+
+	diff := current.Sub(previous)
+	sda, found := diff.Disks["sda"]
+	if !found {
+		t.Fatalf("sda not found in diff")
+	}
+
+	if sda.ReadsCompleted != 50.0 {
+		t.Errorf("ReadsCompleted is %f, should be 50", sda.ReadsCompleted)
+	}
+
+	if sda.WriteSectors != 2000.0 {
+		t.Errorf("WriteSectors is %f, should be 2000", sda.WriteSectors)
+	}
+
+	if sda.IoInProgress != 3 {
+		t.Errorf("IoInProgress is %d, should be 3", sda.IoInProgress)
+	}
+
+	for _, point := range diff.GetPoints() {
+		if point.Name == "io.WriteBytes" && point.Tags["device"] == "sda" && point.Fields["value"] != 2000.0*512 {
+			t.Errorf("io.WriteBytes is %v, should be %d", point.Fields["value"], 2000*512)
+		}
+	}
+
+	// Swapping the samples simulates a counter reset.
+	previous.sampletime = current.sampletime.Add(time.Second)
+	diff = previous.Sub(current)
+	if diff.Disks["sda"].ReadsCompleted != 0.0 {
+		t.Errorf("Counter reset resulted in ReadsCompleted %f", diff.Disks["sda"].ReadsCompleted)
+	}
+
+	// No time has passed.
+	current.sampletime = previous.sampletime
+	diff = current.Sub(previous)
+	if len(diff.Disks) != 0 {
+		t.Errorf("Sub() returned devices for a zero duration")
+	}
+
+	if len(current.Sub(nil).Disks) != 0 {
+		t.Errorf("Sub(nil) returned devices")
+	}
+}
+
+func TestRates(t *testing.T) {
+	rates := plugins.NewRates()
+
+	previous := &DiskStats{}
+	gather(t, testData1, previous)
+
+	_, ok := rates.Apply("diskio", previous)
+	if ok {
+		t.Fatalf("Got rates from a single sample")
+	}
+
+	// Pretend the first sample was taken two seconds ago.
+	previous.sampletime = previous.sampletime.Add(-2 * time.Second)
+
+	current := &DiskStats{}
+	gather(t, testData2, current)
+
+	agent, ok := rates.Apply("diskio", current)
+	if !ok {
+		t.Fatalf("Got no rates from two samples")
+	}
+
+	for _, point := range agent.GetPoints() {
+		if point.Name != "io.ReadsCompleted" || point.Tags["device"] != "sda" {
+			continue
+		}
+
+		// The samples are a few microseconds more than two seconds apart.
+		value := point.Fields["value"].(float64)
+		if value > 50.0 || value < 49.9 {
+			t.Errorf("ReadsCompleted is %f, should be 50", value)
+		}
+
+		return
+	}
+
+	t.Errorf("io.ReadsCompleted for sda not found")
+}
@@ -7,6 +7,10 @@ import (
 	"github.com/abrander/agento/plugins"
 )
 
+// sectorSize is the size of a sector as used in /proc/diskstats. This is
+// always 512 regardless of the actual device.
+const sectorSize = 512
+
 type SingleDiskStats struct {
 	ReadsCompleted  float64
 	ReadsMerged     float64
@@ -69,6 +73,30 @@ func (s *SingleDiskStats) ReadArray(data []string) {
 	}
 }
 
+// Sub will calculate per-second rates between previous and s. IoInProgress is
+// not a counter and will be copied as is.
+func (s *SingleDiskStats) Sub(previous *SingleDiskStats, factor float64) *SingleDiskStats {
+	diff := SingleDiskStats{}
+
+	if factor <= 0 {
+		return &diff
+	}
+
+	diff.ReadsCompleted = plugins.CounterRate(s.ReadsCompleted, previous.ReadsCompleted, factor)
+	diff.ReadsMerged = plugins.CounterRate(s.ReadsMerged, previous.ReadsMerged, factor)
+	diff.ReadSectors = plugins.CounterRate(s.ReadSectors, previous.ReadSectors, factor)
+	diff.ReadTime = plugins.CounterRate(s.ReadTime, previous.ReadTime, factor)
+	diff.WritesCompleted = plugins.CounterRate(s.WritesCompleted, previous.WritesCompleted, factor)
+	diff.WritesMerged = plugins.CounterRate(s.WritesMerged, previous.WritesMerged, factor)
+	diff.WriteSectors = plugins.CounterRate(s.WriteSectors, previous.WriteSectors, factor)
+	diff.WriteTime = plugins.CounterRate(s.WriteTime, previous.WriteTime, factor)
+	diff.IoInProgress = s.IoInProgress
+	diff.IoTime = plugins.CounterRate(s.IoTime, previous.IoTime, factor)
+	diff.IoWeightedTime = plugins.CounterRate(s.IoWeightedTime, previous.IoWeightedTime, factor)
+
+	return &diff
+}
+
 func (s SingleDiskStats) MarshalJSON() ([]byte, error) {
 	var a [11]float64
 
@@ -1,9 +1,10 @@
 package diskusage
 
 import (
-	"io/ioutil"
+	"path/filepath"
 	"strings"
 
+	"github.com/abrander/agento/configuration"
 	"github.com/abrander/agento/plugins"
 	"github.com/abrander/agento/timeseries"
 )
@@ -17,13 +18,57 @@ func NewDiskUsageStats() interface{} {
 }
 
 type DiskUsageStats struct {
+	Mountpoints   []string `toml:"mountpoints" json:"mountpoints" description:"Mountpoints to report (all real filesystems if empty)"`
+	IncludePseudo bool     `toml:"includePseudo" json:"includePseudo" description:"Include pseudo filesystems like proc, sysfs and tmpfs when discovering mountpoints"`
+
 	Disks map[string]*SingleDiskUsageStats `json:"disks"`
 }
 
-func GetMountPoints() []string {
-	var mountPoints []string
+// MountPoint is a single line from /proc/mounts.
+type MountPoint struct {
+	Device string
+	Path   string
+	FsType string
+}
+
+// pseudoFsTypes lists filesystems that doesn't represent real storage. They
+// will be skipped unless IncludePseudo is set.
+var pseudoFsTypes = map[string]bool{
+	"autofs":      true,
+	"binfmt_misc": true,
+	"bpf":         true,
+	"cgroup":      true,
+	"cgroup2":     true,
+	"configfs":    true,
+	"debugfs":     true,
+	"devpts":      true,
+	"devtmpfs":    true,
+	"fusectl":     true,
+	"hugetlbfs":   true,
+	"mqueue":      true,
+	"nsfs":        true,
+	"proc":        true,
+	"pstore":      true,
+	"securityfs":  true,
+	"sysfs":       true,
+	"tmpfs":       true,
+	"tracefs":     true,
+}
+
+// unescape will decode the octal escapes used for whitespace in /proc/mounts.
+var unescape = strings.NewReplacer(
+	`\040`, " ",
+	`\011`, "\t",
+	`\012`, "\n",
+	`\134`, `\`,
+)
+
+// GetMountPoints will parse /proc/mounts using transport.
+func GetMountPoints(transport plugins.Transport) []MountPoint {
+	var mountPoints []MountPoint
 
-	data, err := ioutil.ReadFile("/etc/mtab")
+	path := filepath.Join(configuration.ProcPath, "/mounts")
+	data, err := transport.ReadFile(path)
 	if err != nil {
 		return mountPoints
 	}
@@ -36,10 +81,11 @@ func GetMountPoints() []string {
 			continue
 		}
 
-		dev := fields[0]
-		if strings.HasPrefix(dev, "/") {
-			mountPoints = append(mountPoints, fields[1])
-		}
+		mountPoints = append(mountPoints, MountPoint{
+			Device: fields[0],
+			Path:   unescape.Replace(fields[1]),
+			FsType: fields[2],
+		})
 	}
 
 	return mountPoints
@@ -48,27 +94,63 @@ func GetMountPoints() []string {
 func (du *DiskUsageStats) Gather(transport plugins.Transport) error {
 	du.Disks = make(map[string]*SingleDiskUsageStats)
 
-	mountPoints := GetMountPoints()
+	mountPoints := GetMountPoints(transport)
+	includePseudo := du.IncludePseudo
+
+	// If the user asked for specific mountpoints, we use them as-is, but we
+	// still try to find the filesystem type from /proc/mounts.
+	if len(du.Mountpoints) > 0 {
+		includePseudo = true
 
-	for _, path := range mountPoints {
-		du.Disks[path] = ReadSingleDiskUsageStats(transport, path)
+		fsTypes := make(map[string]string)
+		for _, mountPoint := range mountPoints {
+			fsTypes[mountPoint.Path] = mountPoint.FsType
+		}
+
+		mountPoints = make([]MountPoint, 0, len(du.Mountpoints))
+		for _, path := range du.Mountpoints {
+			mountPoints = append(mountPoints, MountPoint{
+				Path:   path,
+				FsType: fsTypes[path],
+			})
+		}
+	}
+
+	for _, mountPoint := range mountPoints {
+		if !includePseudo && pseudoFsTypes[mountPoint.FsType] {
+			continue
+		}
+
+		stats := ReadSingleDiskUsageStats(transport, mountPoint.Path)
+		if stats == nil {
+			continue
+		}
+
+		stats.FsType = mountPoint.FsType
+		du.Disks[mountPoint.Path] = stats
 	}
 
 	return nil
 }
 
 func (d *DiskUsageStats) GetPoints() []*timeseries.Point {
-	points := make([]*timeseries.Point, len(d.Disks)*5)
+	points := make([]*timeseries.Point, len(d.Disks)*6)
 
 	i := 0
 	for key, value := range d.Disks {
-		points[i+0] = plugins.PointWithTag("du.Used", value.Used, "mountpoint", key)
-		points[i+1] = plugins.PointWithTag("du.Reserved", value.Reserved, "mountpoint", key)
-		points[i+2] = plugins.PointWithTag("du.Free", value.Free, "mountpoint", key)
-		points[i+3] = plugins.PointWithTag("du.UsedNodes", value.UsedNodes, "mountpoint", key)
-		points[i+4] = plugins.PointWithTag("du.FreeNodes", value.FreeNodes, "mountpoint", key)
+		tags := map[string]string{
+			"mountpoint": key,
+			"fstype":     value.FsType,
+		}
 
-		i = i + 5
+		points[i+0] = plugins.PointWithTags("du.Used", value.Used, tags)
+		points[i+1] = plugins.PointWithTags("du.Reserved", value.Reserved, tags)
+		points[i+2] = plugins.PointWithTags("du.Free", value.Free, tags)
+		points[i+3] = plugins.PointWithTags("du.Total", value.Total, tags)
+		points[i+4] = plugins.PointWithTags("du.UsedNodes", value.UsedNodes, tags)
+		points[i+5] = plugins.PointWithTags("du.FreeNodes", value.FreeNodes, tags)
+
+		i = i + 6
 	}
 
 	return points
@@ -78,12 +160,17 @@ func (c *DiskUsageStats) GetDoc() *plugins.Doc {
 	doc := plugins.NewDoc("Disk Usage")
 
 	doc.AddTag("mountpoint", "The mount point of the volume")
+	doc.AddTag("fstype", "The filesystem type of the volume")
 
 	doc.AddMeasurement("du.Used", "Used space", "b")
 	doc.AddMeasurement("du.Reserved", "Space reserved for uid 0", "b")
 	doc.AddMeasurement("du.Free", "Free space", "b")
+	doc.AddMeasurement("du.Total", "Total size of the filesystem", "b")
 	doc.AddMeasurement("du.UsedNodes", "Used inodes", "n")
 	doc.AddMeasurement("du.FreeNodes", "Free inodes", "n")
 
 	return doc
 }
+
+// Ensure compliance
+var _ plugins.Agent = (*DiskUsageStats)(nil)
@@ -1,11 +1,73 @@
 package diskusage
 
 import (
+	"io/ioutil"
+	"os"
+	"syscall"
 	"testing"
 
 	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+	"github.com/abrander/agento/plugins/transports/mock"
 )
 
 func TestAgent(t *testing.T) {
 	plugins.GenericAgentTest(t, NewDiskUsageStats())
 }
+
+func TestGetMountPoints(t *testing.T) {
+	transport := mocktransport.NewMock().(*mocktransport.Mock)
+	transport.SetFile("/proc/mounts", []byte(`sysfs /sys sysfs rw,nosuid,nodev,noexec,relatime 0 0
+proc /proc proc rw,nosuid,nodev,noexec,relatime 0 0
+/dev/sda1 / ext4 rw,relatime,errors=remount-ro 0 0
+/dev/sdb1 /mnt/my\040disk xfs rw,relatime 0 0
+`))
+
+	mountPoints := GetMountPoints(transport)
+	if len(mountPoints) != 4 {
+		t.Fatalf("Got %d mountpoints, expected 4", len(mountPoints))
+	}
+
+	if mountPoints[3].Path != "/mnt/my disk" {
+		t.Errorf("Path is '%s', expected '/mnt/my disk'", mountPoints[3].Path)
+	}
+
+	if mountPoints[2].FsType != "ext4" {
+		t.Errorf("FsType is '%s', expected 'ext4'", mountPoints[2].FsType)
+	}
+}
+
+func TestGatherTempDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskusage")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	du := NewDiskUsageStats().(*DiskUsageStats)
+	du.Mountpoints = []string{dir}
+
+	err = du.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	stats, found := du.Disks[dir]
+	if !found {
+		t.Fatalf("%s not found in result", dir)
+	}
+
+	var stat syscall.Statfs_t
+	syscall.Statfs(dir, &stat)
+
+	total := int64(stat.Bsize) * int64(stat.Blocks)
+	if stats.Total != total {
+		t.Errorf("Total is %d, expected %d", stats.Total, total)
+	}
+
+	if stats.Used+stats.Reserved+stats.Free != stats.Total {
+		t.Errorf("Used, reserved and free doesn't add up to total")
+	}
+
+	plugins.GenericAgentTest(t, du)
+}
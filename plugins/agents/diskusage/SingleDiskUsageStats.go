@@ -7,11 +7,13 @@ import (
 )
 
 type SingleDiskUsageStats struct {
-	Used      int64 `json:"u"`
-	Reserved  int64 `json:"r"`
-	Free      int64 `json:"f"`
-	UsedNodes int64 `json:"un"`
-	FreeNodes int64 `json:"fn"`
+	FsType    string `json:"t"`
+	Used      int64  `json:"u"`
+	Reserved  int64  `json:"r"`
+	Free      int64  `json:"f"`
+	Total     int64  `json:"T"`
+	UsedNodes int64  `json:"un"`
+	FreeNodes int64  `json:"fn"`
 }
 
 func ReadSingleDiskUsageStats(transport plugins.Transport, path string) *SingleDiskUsageStats {
@@ -27,6 +29,7 @@ func ReadSingleDiskUsageStats(transport plugins.Transport, path string) *SingleD
 	stats.Used = bSize * int64(stat.Blocks-stat.Bfree)
 	stats.Reserved = bSize * int64(stat.Bfree-stat.Bavail)
 	stats.Free = bSize * int64(stat.Bavail)
+	stats.Total = bSize * int64(stat.Blocks)
 
 	stats.UsedNodes = int64(stat.Files - stat.Ffree)
 	stats.FreeNodes = int64(stat.Ffree)
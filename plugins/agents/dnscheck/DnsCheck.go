@@ -0,0 +1,156 @@
+package dnscheck
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("dnscheck", NewDnsCheck)
+}
+
+// DnsCheck will resolve a name using a specific DNS server.
+type DnsCheck struct {
+	Server  string `toml:"server" json:"server" description:"The DNS server to query (host or host:port)" required:"true"`
+	Name    string `toml:"name" json:"name" description:"The name to resolve" required:"true"`
+	Type    string `toml:"type" json:"type" description:"The record type to query" enum:"A,AAAA,MX,TXT"`
+	Timeout int    `toml:"timeout" json:"timeout" description:"Query timeout in seconds (default 5)"`
+
+	Up          bool          `json:"u"`
+	ResolveTime time.Duration `json:"t"`
+	RecordCount int           `json:"c"`
+	Rcode       string        `json:"r"`
+}
+
+var (
+	// ErrMissingConfig will be returned if server or name is not configured.
+	ErrMissingConfig = errors.New("server and name must be set")
+
+	// ErrUnknownType will be returned for unsupported record types.
+	ErrUnknownType = errors.New("unknown record type")
+
+	types = map[string]uint16{
+		"A":    dns.TypeA,
+		"AAAA": dns.TypeAAAA,
+		"MX":   dns.TypeMX,
+		"TXT":  dns.TypeTXT,
+	}
+)
+
+// NewDnsCheck will return a new DnsCheck.
+func NewDnsCheck() interface{} {
+	return new(DnsCheck)
+}
+
+// Gather will query the server. A failed query is not an error, it will be
+// reported as down with the reason in Rcode.
+func (d *DnsCheck) Gather(_ plugins.Transport) error {
+	d.Up = false
+	d.ResolveTime = 0
+	d.RecordCount = 0
+	d.Rcode = ""
+
+	if d.Server == "" || d.Name == "" {
+		return ErrMissingConfig
+	}
+
+	typ := "A"
+	if d.Type != "" {
+		typ = strings.ToUpper(d.Type)
+	}
+
+	qtype, found := types[typ]
+	if !found {
+		return ErrUnknownType
+	}
+
+	timeout := 5 * time.Second
+	if d.Timeout > 0 {
+		timeout = time.Duration(d.Timeout) * time.Second
+	}
+
+	server := d.Server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	c := dns.Client{Timeout: timeout}
+	m := dns.Msg{}
+	m.SetQuestion(dns.Fqdn(d.Name), qtype)
+
+	r, rtt, err := c.Exchange(&m, server)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			d.Rcode = "TIMEOUT"
+		} else {
+			d.Rcode = "ERROR"
+		}
+
+		return nil
+	}
+
+	d.ResolveTime = rtt
+	d.Rcode = dns.RcodeToString[r.Rcode]
+	d.Up = r.Rcode == dns.RcodeSuccess
+
+	for _, rr := range r.Answer {
+		if rr.Header().Rrtype == qtype {
+			d.RecordCount++
+		}
+	}
+
+	return nil
+}
+
+// GetPoints will return the result of the query. If no answer was received,
+// only dns.Up is returned.
+func (d *DnsCheck) GetPoints() []*timeseries.Point {
+	up := 0
+	if d.Up {
+		up = 1
+	}
+
+	tags := map[string]string{
+		"server": d.Server,
+		"name":   d.Name,
+		"rcode":  d.Rcode,
+	}
+
+	points := []*timeseries.Point{
+		plugins.PointWithTags("dns.Up", up, tags),
+	}
+
+	if d.Rcode == "TIMEOUT" || d.Rcode == "ERROR" {
+		return points
+	}
+
+	return append(points,
+		plugins.PointWithTags("dns.ResolveTime", d.ResolveTime.Seconds()*1000.0, tags),
+		plugins.PointWithTags("dns.RecordCount", d.RecordCount, tags),
+	)
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (d *DnsCheck) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("DNS resolution check")
+
+	doc.AddTag("server", "The DNS server queried")
+	doc.AddTag("name", "The name resolved")
+	doc.AddTag("rcode", "The response code (NOERROR, NXDOMAIN, SERVFAIL, ...) or TIMEOUT or ERROR")
+	doc.AddMeasurement("dns.Up", "1 if the server answered with NOERROR, 0 otherwise", "n")
+	doc.AddMeasurement("dns.ResolveTime", "The time it took to get an answer", "ms")
+	doc.AddMeasurement("dns.RecordCount", "The number of records of the requested type returned", "n")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*DnsCheck)(nil)
@@ -0,0 +1,108 @@
+package dnscheck
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+
+	"github.com/abrander/agento/plugins"
+)
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewDnsCheck())
+}
+
+// stub will answer queries for a few names in the .test domain.
+func stub(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+
+	switch r.Question[0].Name {
+	case "two.test.":
+		a1, _ := dns.NewRR("two.test. 60 IN A 192.0.2.1")
+		a2, _ := dns.NewRR("two.test. 60 IN A 192.0.2.2")
+		m.Answer = []dns.RR{a1, a2}
+	case "mx.test.":
+		mx, _ := dns.NewRR("mx.test. 60 IN MX 10 mail.mx.test.")
+		m.Answer = []dns.RR{mx}
+	case "fail.test.":
+		m.Rcode = dns.RcodeServerFailure
+	default:
+		m.Rcode = dns.RcodeNameError
+	}
+
+	w.WriteMsg(m)
+}
+
+func TestGather(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() failed: %s", err.Error())
+	}
+
+	server := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(stub)}
+	go server.ActivateAndServe()
+	defer server.Shutdown()
+
+	// silent will never answer.
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() failed: %s", err.Error())
+	}
+	defer silent.Close()
+
+	addr := pc.LocalAddr().String()
+
+	cases := []struct {
+		check  DnsCheck
+		up     bool
+		count  int
+		rcode  string
+		points int
+	}{
+		{DnsCheck{Server: addr, Name: "two.test"}, true, 2, "NOERROR", 3},
+		{DnsCheck{Server: addr, Name: "mx.test", Type: "mx"}, true, 1, "NOERROR", 3},
+		{DnsCheck{Server: addr, Name: "mx.test", Type: "A"}, true, 0, "NOERROR", 3},
+		{DnsCheck{Server: addr, Name: "missing.test"}, false, 0, "NXDOMAIN", 3},
+		{DnsCheck{Server: addr, Name: "fail.test"}, false, 0, "SERVFAIL", 3},
+		{DnsCheck{Server: silent.LocalAddr().String(), Name: "two.test", Timeout: 1}, false, 0, "TIMEOUT", 1},
+	}
+
+	for i, c := range cases {
+		check := c.check
+
+		err := check.Gather(nil)
+		if err != nil {
+			t.Fatalf("%d: Gather() failed: %s", i, err.Error())
+		}
+
+		if check.Up != c.up {
+			t.Errorf("%d: Got up %v, expected %v", i, check.Up, c.up)
+		}
+
+		if check.RecordCount != c.count {
+			t.Errorf("%d: Got %d records, expected %d", i, check.RecordCount, c.count)
+		}
+
+		if check.Rcode != c.rcode {
+			t.Errorf("%d: Got rcode %s, expected %s", i, check.Rcode, c.rcode)
+		}
+
+		points := check.GetPoints()
+		if len(points) != c.points {
+			t.Errorf("%d: Got %d points, expected %d", i, len(points), c.points)
+		}
+
+		plugins.GenericAgentTest(t, &check)
+	}
+}
+
+func TestGatherUnknownType(t *testing.T) {
+	check := &DnsCheck{Server: "127.0.0.1", Name: "test", Type: "SRV"}
+
+	err := check.Gather(nil)
+	if err != ErrUnknownType {
+		t.Fatalf("Gather() did not reject unknown type")
+	}
+}
@@ -0,0 +1,190 @@
+package elasticsearch
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("elasticsearch", NewElasticsearch)
+}
+
+type (
+	// Elasticsearch will read cluster health and JVM heap usage from an
+	// Elasticsearch cluster.
+	Elasticsearch struct {
+		URL                string `toml:"url" json:"url" description:"Base URL of the cluster (like http://localhost:9200)" required:"true"`
+		Username           string `toml:"username" json:"username" description:"Username for basic authentication"`
+		Password           string `toml:"password" json:"password" description:"Password for basic authentication"`
+		Timeout            int    `toml:"timeout" json:"timeout" description:"Request timeout in seconds (default 10)"`
+		InsecureSkipVerify bool   `toml:"insecureSkipVerify" json:"insecureSkipVerify" description:"Do not verify TLS certificates"`
+
+		Status           int                `json:"s"`
+		NumberOfNodes    int64              `json:"n"`
+		ActiveShards     int64              `json:"a"`
+		UnassignedShards int64              `json:"u"`
+		RelocatingShards int64              `json:"r"`
+		HeapUsedPercent  map[string]float64 `json:"h"`
+	}
+
+	// health is the parts of _cluster/health we use.
+	health struct {
+		Status           string `json:"status"`
+		NumberOfNodes    int64  `json:"number_of_nodes"`
+		ActiveShards     int64  `json:"active_shards"`
+		UnassignedShards int64  `json:"unassigned_shards"`
+		RelocatingShards int64  `json:"relocating_shards"`
+	}
+
+	// nodeStats is the parts of _nodes/stats/jvm we use.
+	nodeStats struct {
+		Nodes map[string]struct {
+			Name string `json:"name"`
+			JVM  struct {
+				Mem struct {
+					HeapUsedPercent float64 `json:"heap_used_percent"`
+				} `json:"mem"`
+			} `json:"jvm"`
+		} `json:"nodes"`
+	}
+)
+
+var (
+	// ErrMissingURL will be returned if no URL is configured.
+	ErrMissingURL = errors.New("url must be set")
+
+	// statusValues maps cluster status to the value of es.Status.
+	statusValues = map[string]int{
+		"green":  2,
+		"yellow": 1,
+		"red":    0,
+	}
+)
+
+// NewElasticsearch will return a new Elasticsearch.
+func NewElasticsearch() interface{} {
+	return new(Elasticsearch)
+}
+
+// Gather will request _cluster/health and _nodes/stats/jvm.
+func (e *Elasticsearch) Gather(transport plugins.Transport) error {
+	if e.URL == "" {
+		return ErrMissingURL
+	}
+
+	client := plugins.HTTPClient(transport)
+	client.Transport.(*http.Transport).TLSClientConfig = &tls.Config{
+		InsecureSkipVerify: e.InsecureSkipVerify,
+	}
+
+	client.Timeout = 10 * time.Second
+	if e.Timeout > 0 {
+		client.Timeout = time.Duration(e.Timeout) * time.Second
+	}
+
+	var h health
+	err := e.get(client, "/_cluster/health", &h)
+	if err != nil {
+		return err
+	}
+
+	status, found := statusValues[h.Status]
+	if !found {
+		return fmt.Errorf("unknown cluster status '%s'", h.Status)
+	}
+
+	var stats nodeStats
+	err = e.get(client, "/_nodes/stats/jvm", &stats)
+	if err != nil {
+		return err
+	}
+
+	e.Status = status
+	e.NumberOfNodes = h.NumberOfNodes
+	e.ActiveShards = h.ActiveShards
+	e.UnassignedShards = h.UnassignedShards
+	e.RelocatingShards = h.RelocatingShards
+	e.HeapUsedPercent = make(map[string]float64)
+
+	for id, node := range stats.Nodes {
+		name := node.Name
+		if name == "" {
+			name = id
+		}
+
+		e.HeapUsedPercent[name] = node.JVM.Mem.HeapUsedPercent
+	}
+
+	return nil
+}
+
+// get will request path relative to the base URL and decode the JSON
+// response into v.
+func (e *Elasticsearch) get(client *http.Client, path string, v interface{}) error {
+	url := strings.TrimRight(e.URL, "/") + path
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	if e.Username != "" {
+		req.SetBasicAuth(e.Username, e.Password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// GetPoints will return the cluster health and heap usage per node.
+func (e *Elasticsearch) GetPoints() []*timeseries.Point {
+	points := make([]*timeseries.Point, 5, 5+len(e.HeapUsedPercent))
+
+	points[0] = plugins.SimplePoint("es.Status", e.Status)
+	points[1] = plugins.SimplePoint("es.NumberOfNodes", e.NumberOfNodes)
+	points[2] = plugins.SimplePoint("es.ActiveShards", e.ActiveShards)
+	points[3] = plugins.SimplePoint("es.UnassignedShards", e.UnassignedShards)
+	points[4] = plugins.SimplePoint("es.RelocatingShards", e.RelocatingShards)
+
+	for node, percent := range e.HeapUsedPercent {
+		points = append(points, plugins.PointWithTag("es.HeapUsedPercent", percent, "node", node))
+	}
+
+	return points
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (e *Elasticsearch) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("Elasticsearch cluster health")
+
+	doc.AddTag("node", "The node name (es.HeapUsedPercent only)")
+
+	doc.AddMeasurement("es.Status", "Cluster status, 2 for green, 1 for yellow and 0 for red", "n")
+	doc.AddMeasurement("es.NumberOfNodes", "Nodes in the cluster", "n")
+	doc.AddMeasurement("es.ActiveShards", "Active primary and replica shards", "n")
+	doc.AddMeasurement("es.UnassignedShards", "Shards not allocated to any node", "n")
+	doc.AddMeasurement("es.RelocatingShards", "Shards being moved between nodes", "n")
+	doc.AddMeasurement("es.HeapUsedPercent", "JVM heap in use", "%")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*Elasticsearch)(nil)
@@ -0,0 +1,141 @@
+package elasticsearch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+)
+
+const (
+	healthJSON = `{
+  "cluster_name": "logs",
+  "status": "yellow",
+  "timed_out": false,
+  "number_of_nodes": 2,
+  "number_of_data_nodes": 2,
+  "active_primary_shards": 10,
+  "active_shards": 18,
+  "relocating_shards": 1,
+  "initializing_shards": 0,
+  "unassigned_shards": 2
+}`
+
+	statsJSON = `{
+  "_nodes": {"total": 2, "successful": 2, "failed": 0},
+  "cluster_name": "logs",
+  "nodes": {
+    "Xc9F0mZqRdGmEj4kO0pDpQ": {
+      "name": "es1",
+      "jvm": {"mem": {"heap_used_in_bytes": 536870912, "heap_used_percent": 25}}
+    },
+    "p0Gq2JtGSdWbVnJ6S5bYwA": {
+      "name": "es2",
+      "jvm": {"mem": {"heap_used_in_bytes": 1288490188, "heap_used_percent": 60}}
+    }
+  }
+}`
+)
+
+// handler will serve health with the status JSON given, requiring basic
+// authentication.
+func handler(t *testing.T, status string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "elastic" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/_cluster/health":
+			w.Write([]byte(status))
+		case "/_nodes/stats/jvm":
+			w.Write([]byte(statsJSON))
+		default:
+			t.Errorf("Unexpected request for %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewElasticsearch())
+}
+
+func TestGather(t *testing.T) {
+	server := httptest.NewServer(handler(t, healthJSON))
+	defer server.Close()
+
+	e := NewElasticsearch().(*Elasticsearch)
+	e.URL = server.URL + "/"
+	e.Username = "elastic"
+	e.Password = "secret"
+
+	err := e.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if e.Status != 1 || e.NumberOfNodes != 2 || e.ActiveShards != 18 || e.UnassignedShards != 2 || e.RelocatingShards != 1 {
+		t.Errorf("Wrong health: %+v", *e)
+	}
+
+	if e.HeapUsedPercent["es1"] != 25 || e.HeapUsedPercent["es2"] != 60 {
+		t.Errorf("Wrong heap usage: %v", e.HeapUsedPercent)
+	}
+
+	if len(e.GetPoints()) != 7 {
+		t.Errorf("Got %d points, expected 7", len(e.GetPoints()))
+	}
+}
+
+func TestGatherErrors(t *testing.T) {
+	transport := localtransport.NewLocalTransport().(plugins.Transport)
+
+	e := NewElasticsearch().(*Elasticsearch)
+	if e.Gather(transport) != ErrMissingURL {
+		t.Errorf("Gather() did not return ErrMissingURL")
+	}
+
+	server := httptest.NewServer(handler(t, `{"status": "purple"}`))
+	defer server.Close()
+
+	e.URL = server.URL
+	err := e.Gather(transport)
+	if err == nil {
+		t.Errorf("Gather() did not fail without credentials")
+	}
+
+	e.Username = "elastic"
+	e.Password = "secret"
+	err = e.Gather(transport)
+	if err == nil {
+		t.Errorf("Gather() accepted an unknown status")
+	}
+}
+
+func TestInsecureSkipVerify(t *testing.T) {
+	server := httptest.NewTLSServer(handler(t, healthJSON))
+	defer server.Close()
+
+	transport := localtransport.NewLocalTransport().(plugins.Transport)
+
+	e := NewElasticsearch().(*Elasticsearch)
+	e.URL = server.URL
+	e.Username = "elastic"
+	e.Password = "secret"
+
+	err := e.Gather(transport)
+	if err == nil {
+		t.Fatalf("Gather() accepted a self-signed certificate")
+	}
+
+	e.InsecureSkipVerify = true
+	err = e.Gather(transport)
+	if err != nil {
+		t.Fatalf("Gather() failed with insecureSkipVerify: %s", err.Error())
+	}
+}
@@ -3,9 +3,55 @@ package entropy
 import (
 	"testing"
 
+	"github.com/abrander/agento/configuration"
 	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
 )
 
 func TestAgent(t *testing.T) {
 	plugins.GenericAgentTest(t, NewEntropy())
 }
+
+func TestGatherFixture(t *testing.T) {
+	procPath := configuration.ProcPath
+	defer func() { configuration.ProcPath = procPath }()
+
+	transport := localtransport.NewLocalTransport().(plugins.Transport)
+
+	configuration.ProcPath = "testdata/proc"
+
+	e := NewEntropy().(*Entropy)
+	err := e.Gather(transport)
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if *e != 3754 {
+		t.Fatalf("Got %d, expected 3754", *e)
+	}
+
+	configuration.ProcPath = "testdata/nonexisting"
+
+	err = e.Gather(transport)
+	if err == nil {
+		t.Fatalf("Gather() did not fail for missing file")
+	}
+}
+
+func TestGetPointsFieldType(t *testing.T) {
+	e := Entropy(3754)
+
+	points := e.GetPoints()
+	if len(points) != 1 {
+		t.Fatalf("Got %d points, expected 1", len(points))
+	}
+
+	value, ok := points[0].Fields["value"].(int64)
+	if !ok {
+		t.Fatalf("Got field of type %T, expected int64", points[0].Fields["value"])
+	}
+
+	if value != 3754 {
+		t.Fatalf("Got %d, expected 3754", value)
+	}
+}
@@ -40,7 +40,7 @@ func (e *Entropy) Gather(transport plugins.Transport) error {
 func (h Entropy) GetPoints() []*timeseries.Point {
 	points := make([]*timeseries.Point, 1)
 
-	points[0] = plugins.SimplePoint("misc.AvailableEntropy", int(h))
+	points[0] = plugins.SimplePoint("misc.AvailableEntropy", int64(h))
 
 	return points
 }
@@ -0,0 +1,248 @@
+package firewall
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("firewall", NewFirewall)
+}
+
+type (
+	// Firewall will read packet and byte counters for all chains by running
+	// iptables. Counters are cumulative and will be converted to per-second
+	// rates by Sub().
+	Firewall struct {
+		Command string   `toml:"command" json:"command" description:"iptables binary to run (default iptables, ip6tables for IPv6)"`
+		Tables  []string `toml:"tables" json:"tables" description:"Tables to read (default filter)"`
+
+		sampletime time.Time
+
+		Chains map[string]*Chain `json:"c"`
+	}
+
+	// Chain holds the counters of a single chain. Packets and bytes are the
+	// sum of all rules in the chain and the chain policy.
+	Chain struct {
+		Table   string  `json:"t"`
+		Name    string  `json:"n"`
+		Packets float64 `json:"p"`
+		Bytes   float64 `json:"b"`
+	}
+)
+
+var (
+	// ErrNotInstalled will be returned if the iptables binary is not present
+	// on the host.
+	ErrNotInstalled = errors.New("iptables is not installed")
+)
+
+// NewFirewall will return a new Firewall.
+func NewFirewall() interface{} {
+	return new(Firewall)
+}
+
+// command will return the binary to run.
+func (f *Firewall) command() string {
+	if f.Command == "" {
+		return "iptables"
+	}
+
+	return f.Command
+}
+
+// tables will return the tables to read.
+func (f *Firewall) tables() []string {
+	if len(f.Tables) == 0 {
+		return []string{"filter"}
+	}
+
+	return f.Tables
+}
+
+// Gather will run iptables -L for each table.
+func (f *Firewall) Gather(transport plugins.Transport) error {
+	f.sampletime = time.Now()
+	f.Chains = make(map[string]*Chain)
+
+	for _, table := range f.tables() {
+		stdout, _, err := transport.Exec(f.command(), "-t", table, "-L", "-v", "-n", "-x")
+		if notInstalled(err) {
+			return ErrNotInstalled
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = f.parse(table, stdout)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// notInstalled will return true if err indicates a missing binary. Remote
+// shells report this as exit status 127.
+func notInstalled(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, exec.ErrNotFound) {
+		return true
+	}
+
+	return strings.HasSuffix(err.Error(), "status 127")
+}
+
+// parse will parse the output of iptables -L -v -n -x for table.
+func (f *Firewall) parse(table string, r io.Reader) error {
+	var chain *Chain
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if fields[0] == "Chain" && len(fields) >= 2 {
+			chain = &Chain{Table: table, Name: fields[1]}
+			f.Chains[table+"/"+chain.Name] = chain
+
+			// Built-in chains have a policy with counters:
+			// Chain INPUT (policy ACCEPT 1234 packets, 567890 bytes)
+			if len(fields) >= 7 && fields[2] == "(policy" {
+				packets, err := strconv.ParseFloat(fields[4], 64)
+				if err != nil {
+					return err
+				}
+
+				bytes, err := strconv.ParseFloat(fields[6], 64)
+				if err != nil {
+					return err
+				}
+
+				chain.Packets += packets
+				chain.Bytes += bytes
+			}
+
+			continue
+		}
+
+		// Skip the column header and anything before the first chain.
+		if chain == nil || fields[0] == "pkts" || len(fields) < 2 {
+			continue
+		}
+
+		packets, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+
+		bytes, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+
+		chain.Packets += packets
+		chain.Bytes += bytes
+	}
+
+	return scanner.Err()
+}
+
+// Sub will calculate per-second rates for all chains present in both
+// previous and f. An empty Firewall is returned if previous is nil or no
+// time has passed.
+func (f *Firewall) Sub(previous *Firewall) *Firewall {
+	diff := &Firewall{
+		Command: f.Command,
+		Tables:  f.Tables,
+		Chains:  make(map[string]*Chain),
+	}
+
+	if previous == nil {
+		return diff
+	}
+
+	duration := f.sampletime.Sub(previous.sampletime)
+	if duration <= 0 {
+		return diff
+	}
+
+	factor := duration.Seconds()
+
+	diff.sampletime = f.sampletime
+
+	for key, chain := range f.Chains {
+		prev, found := previous.Chains[key]
+		if !found {
+			continue
+		}
+
+		diff.Chains[key] = &Chain{
+			Table:   chain.Table,
+			Name:    chain.Name,
+			Packets: plugins.CounterRate(chain.Packets, prev.Packets, factor),
+			Bytes:   plugins.CounterRate(chain.Bytes, prev.Bytes, factor),
+		}
+	}
+
+	return diff
+}
+
+// GetPoints will return packets and bytes per chain.
+func (f *Firewall) GetPoints() []*timeseries.Point {
+	keys := make([]string, 0, len(f.Chains))
+	for key := range f.Chains {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	points := make([]*timeseries.Point, 0, len(keys)*2)
+
+	for _, key := range keys {
+		chain := f.Chains[key]
+		tags := map[string]string{
+			"table": chain.Table,
+			"chain": chain.Name,
+		}
+
+		points = append(points,
+			plugins.PointWithTags("fw.Packets", chain.Packets, tags),
+			plugins.PointWithTags("fw.Bytes", chain.Bytes, tags),
+		)
+	}
+
+	return points
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (f *Firewall) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("iptables packet counters")
+
+	doc.AddMeasurement("fw.Packets", "Packets matched by rules in the chain or its policy", "/s")
+	doc.AddMeasurement("fw.Bytes", "Bytes matched by rules in the chain or its policy", "b/s")
+
+	doc.AddTag("table", "The iptables table (filter, nat, mangle, ...)")
+	doc.AddTag("chain", "The chain name")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*Firewall)(nil)
@@ -0,0 +1,123 @@
+package firewall
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+	"github.com/abrander/agento/plugins/transports/mock"
+)
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewFirewall())
+}
+
+func TestParse(t *testing.T) {
+	file, err := os.Open("testdata/filter.txt")
+	if err != nil {
+		t.Fatalf("Open() failed: %s", err.Error())
+	}
+	defer file.Close()
+
+	f := NewFirewall().(*Firewall)
+	f.Chains = make(map[string]*Chain)
+
+	err = f.parse("filter", file)
+	if err != nil {
+		t.Fatalf("parse() failed: %s", err.Error())
+	}
+
+	expected := map[string]Chain{
+		"filter/INPUT":       {"filter", "INPUT", 1650420, 1200025200},
+		"filter/FORWARD":     {"filter", "FORWARD", 0, 0},
+		"filter/OUTPUT":      {"filter", "OUTPUT", 1600000, 1100000000},
+		"filter/DOCKER-USER": {"filter", "DOCKER-USER", 10, 840},
+	}
+
+	if len(f.Chains) != len(expected) {
+		t.Fatalf("Got %d chains, expected %d", len(f.Chains), len(expected))
+	}
+
+	for key, e := range expected {
+		chain, found := f.Chains[key]
+		if !found {
+			t.Errorf("Chain %s not found", key)
+			continue
+		}
+
+		if *chain != e {
+			t.Errorf("Chain %s is %+v, expected %+v", key, *chain, e)
+		}
+	}
+}
+
+func TestGather(t *testing.T) {
+	contents, _ := ioutil.ReadFile("testdata/filter.txt")
+
+	mock := mocktransport.NewMock().(*mocktransport.Mock)
+	mock.SetExec("iptables", contents)
+
+	f := NewFirewall().(*Firewall)
+	err := f.Gather(mock)
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if len(f.Chains) != 4 {
+		t.Errorf("Got %d chains, expected 4", len(f.Chains))
+	}
+}
+
+func TestGatherNotInstalled(t *testing.T) {
+	f := NewFirewall().(*Firewall)
+	f.Command = "agento-nonexisting-iptables"
+
+	err := f.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != ErrNotInstalled {
+		t.Fatalf("Gather() returned %v, expected %v", err, ErrNotInstalled)
+	}
+}
+
+func TestSub(t *testing.T) {
+	previous := &Firewall{
+		sampletime: time.Now(),
+		Chains: map[string]*Chain{
+			"filter/INPUT": {"filter", "INPUT", 1000, 64000},
+		},
+	}
+
+	current := &Firewall{
+		sampletime: previous.sampletime.Add(10 * time.Second),
+		Chains: map[string]*Chain{
+			"filter/INPUT":  {"filter", "INPUT", 1500, 96000},
+			"filter/OUTPUT": {"filter", "OUTPUT", 100, 6400},
+		},
+	}
+
+	diff := current.Sub(previous)
+	if len(diff.Chains) != 1 {
+		t.Fatalf("Got %d chains, expected only chains present in both samples", len(diff.Chains))
+	}
+
+	input := diff.Chains["filter/INPUT"]
+	if input.Packets != 50 || input.Bytes != 3200 {
+		t.Errorf("Wrong rates: %+v", *input)
+	}
+
+	points := diff.GetPoints()
+	if len(points) != 2 || points[0].Tags["chain"] != "INPUT" || points[0].Tags["table"] != "filter" {
+		t.Errorf("Wrong points: %+v", points)
+	}
+
+	current.sampletime = previous.sampletime
+	if len(current.Sub(previous).Chains) != 0 {
+		t.Errorf("Sub() returned rates for a zero duration")
+	}
+
+	if len(current.Sub(nil).Chains) != 0 {
+		t.Errorf("Sub() returned values without a previous sample")
+	}
+}
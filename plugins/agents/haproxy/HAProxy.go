@@ -0,0 +1,299 @@
+package haproxy
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("haproxy", NewHAProxy)
+}
+
+type (
+	// HAProxy will read frontend, backend and server statistics from the
+	// HAProxy stats page or the admin socket. Bytes and errors are
+	// cumulative and will be converted to per-second rates by Sub().
+	HAProxy struct {
+		URL      string `toml:"url" json:"url" description:"Stats page URL, ';csv' is appended if missing"`
+		Socket   string `toml:"socket" json:"socket" description:"Path to the admin socket, used if url is not set"`
+		Username string `toml:"username" json:"username" description:"Username for the stats page"`
+		Password string `toml:"password" json:"password" description:"Password for the stats page"`
+		Timeout  int    `toml:"timeout" json:"timeout" description:"Request timeout in seconds (default 10)"`
+
+		sampletime time.Time
+		Proxies    map[string]*Proxy `json:"p"`
+	}
+
+	// Proxy is the statistics for a single frontend, backend or server.
+	Proxy struct {
+		Pxname          string  `json:"px"`
+		Svname          string  `json:"sv"`
+		SessionsCurrent int64   `json:"sc"`
+		SessionRate     int64   `json:"sr"`
+		Status          int     `json:"st"`
+		BytesIn         float64 `json:"bi"`
+		BytesOut        float64 `json:"bo"`
+		Errors          float64 `json:"e"`
+	}
+)
+
+var (
+	// ErrMissingSource will be returned if neither url nor socket is
+	// configured.
+	ErrMissingSource = errors.New("url or socket must be set")
+
+	// ErrMissingHeader will be returned if the CSV lacks the header line.
+	ErrMissingHeader = errors.New("stats header not found")
+)
+
+// NewHAProxy will return a new HAProxy.
+func NewHAProxy() interface{} {
+	return new(HAProxy)
+}
+
+// Gather will read the stats CSV from the stats page or the admin socket.
+func (h *HAProxy) Gather(transport plugins.Transport) error {
+	timeout := 10 * time.Second
+	if h.Timeout > 0 {
+		timeout = time.Duration(h.Timeout) * time.Second
+	}
+
+	switch {
+	case h.URL != "":
+		return h.gatherURL(transport, timeout)
+	case h.Socket != "":
+		return h.gatherSocket(transport, timeout)
+	}
+
+	return ErrMissingSource
+}
+
+// gatherURL will request the stats page in CSV format.
+func (h *HAProxy) gatherURL(transport plugins.Transport, timeout time.Duration) error {
+	url := h.URL
+	if !strings.HasSuffix(url, ";csv") {
+		url += ";csv"
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	if h.Username != "" {
+		req.SetBasicAuth(h.Username, h.Password)
+	}
+
+	client := plugins.HTTPClient(transport)
+	client.Timeout = timeout
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+
+	h.sampletime = time.Now()
+
+	return h.parse(resp.Body)
+}
+
+// gatherSocket will issue "show stat" on the admin socket.
+func (h *HAProxy) gatherSocket(transport plugins.Transport, timeout time.Duration) error {
+	conn, err := transport.Dial("unix", h.Socket)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	_, err = conn.Write([]byte("show stat\n"))
+	if err != nil {
+		return err
+	}
+
+	h.sampletime = time.Now()
+
+	return h.parse(conn)
+}
+
+// parse will parse the stats CSV. The first line is a header starting with
+// "# ", columns are looked up by name to support different versions.
+func (h *HAProxy) parse(r io.Reader) error {
+	h.Proxies = make(map[string]*Proxy)
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF || (err == nil && !strings.HasPrefix(header[0], "# ")) {
+		return ErrMissingHeader
+	}
+
+	if err != nil {
+		return err
+	}
+
+	header[0] = strings.TrimPrefix(header[0], "# ")
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[name] = i
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		// The admin socket ends the output with an empty line.
+		if len(record) == 1 && record[0] == "" {
+			continue
+		}
+
+		field := func(name string) string {
+			i, found := columns[name]
+			if !found || i >= len(record) {
+				return ""
+			}
+
+			return record[i]
+		}
+
+		p := &Proxy{
+			Pxname:          field("pxname"),
+			Svname:          field("svname"),
+			SessionsCurrent: parseInt(field("scur")),
+			SessionRate:     parseInt(field("rate")),
+			Status:          status(field("status")),
+			BytesIn:         float64(parseInt(field("bin"))),
+			BytesOut:        float64(parseInt(field("bout"))),
+			Errors:          float64(parseInt(field("ereq")) + parseInt(field("econ")) + parseInt(field("eresp"))),
+		}
+
+		h.Proxies[p.Pxname+"/"+p.Svname] = p
+	}
+
+	return nil
+}
+
+// parseInt will parse value as an integer. HAProxy leaves fields not
+// applicable to the proxy type empty, these are returned as 0.
+func parseInt(value string) int64 {
+	i, _ := strconv.ParseInt(value, 10, 64)
+
+	return i
+}
+
+// status will return 1 if status is UP (including transitions like
+// "UP 1/3"), OPEN or "no check". Anything else is 0.
+func status(status string) int {
+	if strings.HasPrefix(status, "UP") || status == "OPEN" || status == "no check" {
+		return 1
+	}
+
+	return 0
+}
+
+// Sub will calculate per-second rates for bytes and errors between previous
+// and h. Gauges are copied as is. An empty HAProxy is returned if previous
+// is nil or no time has passed. Proxies not present in both samples are left
+// out.
+func (h *HAProxy) Sub(previous *HAProxy) *HAProxy {
+	diff := &HAProxy{
+		Proxies: make(map[string]*Proxy),
+	}
+
+	if previous == nil {
+		return diff
+	}
+
+	duration := h.sampletime.Sub(previous.sampletime)
+	if duration <= 0 {
+		return diff
+	}
+
+	factor := duration.Seconds()
+
+	for key, value := range h.Proxies {
+		prev, found := previous.Proxies[key]
+		if found {
+			diff.Proxies[key] = &Proxy{
+				Pxname:          value.Pxname,
+				Svname:          value.Svname,
+				SessionsCurrent: value.SessionsCurrent,
+				SessionRate:     value.SessionRate,
+				Status:          value.Status,
+				BytesIn:         plugins.CounterRate(value.BytesIn, prev.BytesIn, factor),
+				BytesOut:        plugins.CounterRate(value.BytesOut, prev.BytesOut, factor),
+				Errors:          plugins.CounterRate(value.Errors, prev.Errors, factor),
+			}
+		}
+	}
+
+	diff.sampletime = h.sampletime
+
+	return diff
+}
+
+// GetPoints will return six points per frontend, backend and server.
+func (h *HAProxy) GetPoints() []*timeseries.Point {
+	points := make([]*timeseries.Point, 0, len(h.Proxies)*6)
+
+	for _, p := range h.Proxies {
+		tags := map[string]string{
+			"pxname": p.Pxname,
+			"svname": p.Svname,
+		}
+
+		points = append(points,
+			plugins.PointWithTags("haproxy.SessionsCurrent", p.SessionsCurrent, tags),
+			plugins.PointWithTags("haproxy.SessionRate", p.SessionRate, tags),
+			plugins.PointWithTags("haproxy.Status", p.Status, tags),
+			plugins.PointWithTags("haproxy.BytesIn", p.BytesIn, tags),
+			plugins.PointWithTags("haproxy.BytesOut", p.BytesOut, tags),
+			plugins.PointWithTags("haproxy.Errors", p.Errors, tags),
+		)
+	}
+
+	return points
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (h *HAProxy) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("HAProxy statistics")
+
+	doc.AddTag("pxname", "The frontend or backend name")
+	doc.AddTag("svname", "The server name, FRONTEND or BACKEND")
+
+	doc.AddMeasurement("haproxy.SessionsCurrent", "Current sessions", "n")
+	doc.AddMeasurement("haproxy.SessionRate", "Sessions during the last second", "/s")
+	doc.AddMeasurement("haproxy.Status", "1 if UP or OPEN, 0 otherwise", "n")
+	doc.AddMeasurement("haproxy.BytesIn", "Bytes received", "b/s")
+	doc.AddMeasurement("haproxy.BytesOut", "Bytes sent", "b/s")
+	doc.AddMeasurement("haproxy.Errors", "Request, connection and response errors", "/s")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*HAProxy)(nil)
@@ -0,0 +1,182 @@
+package haproxy
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %s", err.Error())
+	}
+
+	return b
+}
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewHAProxy())
+}
+
+func TestParse(t *testing.T) {
+	h := NewHAProxy().(*HAProxy)
+
+	err := h.parse(strings.NewReader(string(readFixture(t, "stats1.csv"))))
+	if err != nil {
+		t.Fatalf("parse() failed: %s", err.Error())
+	}
+
+	expected := map[string]Proxy{
+		"http-in/FRONTEND": {Pxname: "http-in", Svname: "FRONTEND", SessionsCurrent: 12, SessionRate: 5, Status: 1, BytesIn: 1048576, BytesOut: 8388608, Errors: 3},
+		"web/web1":         {Pxname: "web", Svname: "web1", SessionsCurrent: 5, SessionRate: 2, Status: 1, BytesIn: 524288, BytesOut: 4194304, Errors: 1},
+		"web/web2":         {Pxname: "web", Svname: "web2", SessionsCurrent: 0, SessionRate: 0, Status: 0, BytesIn: 524288, BytesOut: 4194304, Errors: 6},
+		"web/BACKEND":      {Pxname: "web", Svname: "BACKEND", SessionsCurrent: 5, SessionRate: 2, Status: 1, BytesIn: 1048576, BytesOut: 8388608, Errors: 7},
+	}
+
+	if len(h.Proxies) != len(expected) {
+		t.Fatalf("Got %d proxies, expected %d", len(h.Proxies), len(expected))
+	}
+
+	for key, e := range expected {
+		if *h.Proxies[key] != e {
+			t.Errorf("%s is %+v, expected %+v", key, *h.Proxies[key], e)
+		}
+	}
+
+	if len(h.GetPoints()) != 24 {
+		t.Errorf("Got %d points, expected 24", len(h.GetPoints()))
+	}
+
+	err = h.parse(strings.NewReader("<html>Not found</html>\n"))
+	if err != ErrMissingHeader {
+		t.Errorf("parse() accepted input without header")
+	}
+}
+
+func TestSub(t *testing.T) {
+	previous := NewHAProxy().(*HAProxy)
+	previous.parse(strings.NewReader(string(readFixture(t, "stats1.csv"))))
+	previous.sampletime = time.Now()
+
+	current := NewHAProxy().(*HAProxy)
+	current.parse(strings.NewReader(string(readFixture(t, "stats2.csv"))))
+	current.sampletime = previous.sampletime.Add(10 * time.Second)
+
+	diff := current.Sub(previous)
+
+	// web2 is gone from the second sample.
+	if len(diff.Proxies) != 3 {
+		t.Fatalf("Got %d proxies, expected 3", len(diff.Proxies))
+	}
+
+	frontend := diff.Proxies["http-in/FRONTEND"]
+	if frontend.BytesIn != 1024 || frontend.BytesOut != 4096 || frontend.Errors != 1 {
+		t.Errorf("Wrong rates: %+v", *frontend)
+	}
+
+	if frontend.SessionsCurrent != 10 || frontend.SessionRate != 4 {
+		t.Errorf("Gauges not copied: %+v", *frontend)
+	}
+
+	if diff.Proxies["web/web1"].Status != 1 {
+		t.Errorf("UP 1/3 was not treated as up")
+	}
+
+	current.sampletime = previous.sampletime
+	if len(current.Sub(previous).Proxies) != 0 {
+		t.Errorf("Sub() returned rates for a zero duration")
+	}
+
+	if len(current.Sub(nil).Proxies) != 0 {
+		t.Errorf("Sub() returned rates without a previous sample")
+	}
+}
+
+func TestGatherURL(t *testing.T) {
+	stats := readFixture(t, "stats1.csv")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RawQuery != ";csv" && !strings.HasSuffix(r.URL.Path, ";csv") {
+			t.Errorf("CSV format not requested: %s", r.URL.String())
+		}
+
+		w.Write(stats)
+	}))
+	defer server.Close()
+
+	h := NewHAProxy().(*HAProxy)
+	h.URL = server.URL + "/stats"
+
+	err := h.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if len(h.Proxies) != 4 {
+		t.Fatalf("Got %d proxies, expected 4", len(h.Proxies))
+	}
+}
+
+func TestGatherSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "haproxy")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "admin.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Listen() failed: %s", err.Error())
+	}
+	defer listener.Close()
+
+	stats := readFixture(t, "stats2.csv")
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		buf := make([]byte, 64)
+		n, _ := conn.Read(buf)
+		if string(buf[:n]) != "show stat\n" {
+			t.Errorf("Got command '%s', expected 'show stat'", string(buf[:n]))
+		}
+
+		conn.Write(stats)
+	}()
+
+	h := NewHAProxy().(*HAProxy)
+	h.Socket = path
+
+	err = h.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if len(h.Proxies) != 3 {
+		t.Fatalf("Got %d proxies, expected 3", len(h.Proxies))
+	}
+}
+
+func TestGatherMissingSource(t *testing.T) {
+	h := NewHAProxy().(*HAProxy)
+
+	err := h.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != ErrMissingSource {
+		t.Fatalf("Gather() returned %v, expected ErrMissingSource", err)
+	}
+}
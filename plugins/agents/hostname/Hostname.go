@@ -17,11 +17,27 @@ func init() {
 
 type Hostname string
 
+// override is used instead of the system hostname if set.
+var override string
+
+// SetOverride will make the agent report name instead of the system
+// hostname. An empty name restores the system hostname.
+func SetOverride(name string) {
+	override = strings.TrimSpace(name)
+}
+
 func NewHostname() interface{} {
 	return new(Hostname)
 }
 
 func (h *Hostname) Gather(transport plugins.Transport) error {
+	// A configured override wins over everything else.
+	if override != "" {
+		*h = Hostname(override)
+
+		return nil
+	}
+
 	hostname := os.Getenv("AGENTO_HOSTNAME")
 	hostnamePath := os.Getenv("AGENTO_HOSTNAME_PATH")
 
@@ -1,11 +1,61 @@
 package hostname
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/abrander/agento/configuration"
 	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
 )
 
 func TestAgent(t *testing.T) {
 	plugins.GenericAgentTest(t, NewHostname())
 }
+
+func TestOverride(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hostname")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sys", "kernel", "hostname")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	ioutil.WriteFile(path, []byte("kernelname\n"), 0644)
+
+	procPath := configuration.ProcPath
+	configuration.ProcPath = dir
+	defer func() { configuration.ProcPath = procPath }()
+
+	t.Setenv("AGENTO_HOSTNAME", "")
+	t.Setenv("AGENTO_HOSTNAME_PATH", "")
+	defer SetOverride("")
+
+	transport := localtransport.NewLocalTransport().(plugins.Transport)
+
+	cases := []struct {
+		override string
+		expected Hostname
+	}{
+		{"", "kernelname"},
+		{"external.example.com", "external.example.com"},
+		{"  ", "kernelname"},
+	}
+
+	for _, c := range cases {
+		SetOverride(c.override)
+
+		h := NewHostname().(*Hostname)
+		err = h.Gather(transport)
+		if err != nil {
+			t.Fatalf("Gather() failed: %s", err.Error())
+		}
+
+		if *h != c.expected {
+			t.Errorf("Got hostname '%s' with override '%s', expected '%s'", *h, c.override, c.expected)
+		}
+	}
+}
@@ -19,7 +19,7 @@ func NewHttp() interface{} {
 
 type (
 	Http struct {
-		Url             string `json:"url" description:"The URL to request"`
+		Url             string `json:"url" description:"The URL to request" required:"true"`
 		Status          int
 		Time            time.Duration
 		ConnectDuration time.Duration
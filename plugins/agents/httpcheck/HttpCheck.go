@@ -0,0 +1,160 @@
+package httpcheck
+
+import (
+	"crypto/tls"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("httpcheck", NewHttpCheck)
+}
+
+// HttpCheck will request an URL and check the response. Unlike the http agent
+// a failed request is not an error, it will be reported as down.
+type HttpCheck struct {
+	URL                string `toml:"url" json:"url" description:"The URL to request" required:"true"`
+	Method             string `toml:"method" json:"method" description:"The HTTP method to use (default GET)"`
+	ExpectedStatus     int    `toml:"expectedStatus" json:"expectedStatus" description:"The expected status code (default 200)"`
+	Timeout            int    `toml:"timeout" json:"timeout" description:"Request timeout in seconds (default 10)"`
+	BodyMustContain    string `toml:"bodyMustContain" json:"bodyMustContain" description:"A string the body must contain"`
+	InsecureSkipVerify bool   `toml:"insecureSkipVerify" json:"insecureSkipVerify" description:"Do not verify TLS certificates"`
+
+	Up            bool          `json:"u"`
+	ResponseTime  time.Duration `json:"r"`
+	StatusCode    int           `json:"s"`
+	ContentLength int64         `json:"l"`
+	BodyMatch     bool          `json:"b"`
+}
+
+var (
+	// ErrMissingURL will be returned if no URL is configured.
+	ErrMissingURL = errors.New("url must be set")
+)
+
+// NewHttpCheck will return a new HttpCheck.
+func NewHttpCheck() interface{} {
+	return new(HttpCheck)
+}
+
+// Gather will request the URL using the dialer from transport.
+func (h *HttpCheck) Gather(transport plugins.Transport) error {
+	h.Up = false
+	h.ResponseTime = 0
+	h.StatusCode = 0
+	h.ContentLength = 0
+	h.BodyMatch = false
+
+	if h.URL == "" {
+		return ErrMissingURL
+	}
+
+	method := "GET"
+	if h.Method != "" {
+		method = strings.ToUpper(h.Method)
+	}
+
+	expected := http.StatusOK
+	if h.ExpectedStatus > 0 {
+		expected = h.ExpectedStatus
+	}
+
+	timeout := 10 * time.Second
+	if h.Timeout > 0 {
+		timeout = time.Duration(h.Timeout) * time.Second
+	}
+
+	req, err := http.NewRequest(method, h.URL, nil)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Dial:              transport.Dial,
+			DisableKeepAlives: true,
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: h.InsecureSkipVerify,
+			},
+		},
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		// The service is down, that's not an error.
+		return nil
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	h.ResponseTime = time.Now().Sub(start)
+	if err != nil {
+		return nil
+	}
+
+	h.StatusCode = resp.StatusCode
+	h.ContentLength = int64(len(body))
+	h.BodyMatch = strings.Contains(string(body), h.BodyMustContain)
+	h.Up = h.StatusCode == expected && h.BodyMatch
+
+	return nil
+}
+
+// GetPoints will return the state of the URL. If the request failed, only
+// http.Up will be returned.
+func (h *HttpCheck) GetPoints() []*timeseries.Point {
+	up := 0
+	if h.Up {
+		up = 1
+	}
+
+	points := []*timeseries.Point{
+		plugins.PointWithTag("http.Up", up, "url", h.URL),
+	}
+
+	if h.StatusCode == 0 {
+		return points
+	}
+
+	points = append(points,
+		plugins.PointWithTag("http.ResponseTime", h.ResponseTime.Seconds()*1000.0, "url", h.URL),
+		plugins.PointWithTag("http.StatusCode", h.StatusCode, "url", h.URL),
+		plugins.PointWithTag("http.ContentLength", h.ContentLength, "url", h.URL),
+	)
+
+	if h.BodyMustContain != "" {
+		match := 0
+		if h.BodyMatch {
+			match = 1
+		}
+
+		points = append(points, plugins.PointWithTag("http.BodyMatch", match, "url", h.URL))
+	}
+
+	return points
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (h *HttpCheck) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("HTTP endpoint check")
+
+	doc.AddTag("url", "The requested URL")
+	doc.AddMeasurement("http.Up", "1 if the expected status was returned (and the body matched), 0 otherwise", "n")
+	doc.AddMeasurement("http.ResponseTime", "The time it took to receive the complete response", "ms")
+	doc.AddMeasurement("http.StatusCode", "The status code returned", "n")
+	doc.AddMeasurement("http.ContentLength", "The size of the response body", "b")
+	doc.AddMeasurement("http.BodyMatch", "1 if the body contained bodyMustContain, 0 otherwise", "n")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*HttpCheck)(nil)
@@ -0,0 +1,103 @@
+package httpcheck
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+)
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewHttpCheck())
+}
+
+func newServer() *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("all is good"))
+	})
+
+	mux.HandleFunc("/created", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestGather(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	cases := []struct {
+		check  HttpCheck
+		up     bool
+		status int
+		points int
+	}{
+		{HttpCheck{URL: server.URL + "/ok"}, true, 200, 4},
+		{HttpCheck{URL: server.URL + "/ok", BodyMustContain: "good"}, true, 200, 5},
+		{HttpCheck{URL: server.URL + "/ok", BodyMustContain: "bad"}, false, 200, 5},
+		{HttpCheck{URL: server.URL + "/created", Method: "post", ExpectedStatus: 201}, true, 201, 4},
+		{HttpCheck{URL: server.URL + "/created"}, false, 405, 4},
+		{HttpCheck{URL: server.URL + "/error"}, false, 500, 4},
+		{HttpCheck{URL: server.URL + "/missing"}, false, 404, 4},
+		{HttpCheck{URL: "http://127.0.0.1:1/"}, false, 0, 1},
+	}
+
+	transport := localtransport.NewLocalTransport().(plugins.Transport)
+
+	for i, c := range cases {
+		check := c.check
+
+		err := check.Gather(transport)
+		if err != nil {
+			t.Fatalf("%d: Gather() failed: %s", i, err.Error())
+		}
+
+		if check.Up != c.up {
+			t.Errorf("%d: Got up %v, expected %v", i, check.Up, c.up)
+		}
+
+		if check.StatusCode != c.status {
+			t.Errorf("%d: Got status %d, expected %d", i, check.StatusCode, c.status)
+		}
+
+		points := check.GetPoints()
+		if len(points) != c.points {
+			t.Errorf("%d: Got %d points, expected %d", i, len(points), c.points)
+		}
+
+		plugins.GenericAgentTest(t, &check)
+	}
+}
+
+func TestGatherInsecure(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	transport := localtransport.NewLocalTransport().(plugins.Transport)
+
+	check := &HttpCheck{URL: server.URL}
+	check.Gather(transport)
+	if check.Up {
+		t.Errorf("Self-signed certificate was accepted")
+	}
+
+	check.InsecureSkipVerify = true
+	check.Gather(transport)
+	if !check.Up {
+		t.Errorf("Self-signed certificate was rejected with InsecureSkipVerify")
+	}
+}
@@ -0,0 +1,170 @@
+package jsonhttp
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("jsonhttp", NewJsonHttp)
+}
+
+type (
+	// JsonHttp will fetch a JSON document from a URL and extract numeric
+	// values using JSONPath expressions. This allows scraping arbitrary
+	// /stats endpoints without writing a plugin.
+	JsonHttp struct {
+		URL                string       `toml:"url" json:"url" description:"URL returning a JSON document" required:"true"`
+		Username           string       `toml:"username" json:"username" description:"Username for basic authentication"`
+		Password           string       `toml:"password" json:"password" description:"Password for basic authentication"`
+		Timeout            int          `toml:"timeout" json:"timeout" description:"Request timeout in seconds (default 10)"`
+		InsecureSkipVerify bool         `toml:"insecureSkipVerify" json:"insecureSkipVerify" description:"Do not verify TLS certificates"`
+		Extract            []Extraction `toml:"extract" json:"extract" description:"Values to extract as {path, metric} (like {path = \"$.db.connections\", metric = \"app.Connections\"})" required:"true"`
+
+		Values   []Value `json:"v"`
+		warnings []string
+	}
+
+	// Extraction maps a JSONPath expression to a measurement.
+	Extraction struct {
+		Path   string `toml:"path" json:"path"`
+		Metric string `toml:"metric" json:"metric"`
+	}
+
+	// Value is a single extracted value.
+	Value struct {
+		Metric string  `json:"m"`
+		Value  float64 `json:"v"`
+	}
+)
+
+var (
+	// ErrMissingURL will be returned if no URL is configured.
+	ErrMissingURL = errors.New("url must be set")
+)
+
+// NewJsonHttp will return a new JsonHttp.
+func NewJsonHttp() interface{} {
+	return new(JsonHttp)
+}
+
+// Gather will fetch URL and extract all configured values. Invalid paths and
+// non-numeric values are skipped and reported by Warnings().
+func (j *JsonHttp) Gather(transport plugins.Transport) error {
+	j.Values = nil
+	j.warnings = nil
+
+	if j.URL == "" {
+		return ErrMissingURL
+	}
+
+	client := plugins.HTTPClient(transport)
+	client.Transport.(*http.Transport).TLSClientConfig = &tls.Config{
+		InsecureSkipVerify: j.InsecureSkipVerify,
+	}
+
+	client.Timeout = 10 * time.Second
+	if j.Timeout > 0 {
+		client.Timeout = time.Duration(j.Timeout) * time.Second
+	}
+
+	req, err := http.NewRequest("GET", j.URL, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if j.Username != "" {
+		req.SetBasicAuth(j.Username, j.Password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", j.URL, resp.StatusCode)
+	}
+
+	return j.extract(resp.Body)
+}
+
+// extract will decode the document in r and extract all configured values.
+func (j *JsonHttp) extract(r io.Reader) error {
+	var document interface{}
+
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	err := decoder.Decode(&document)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range j.Extract {
+		steps, err := parsePath(e.Path)
+		if err != nil {
+			j.warnings = append(j.warnings, err.Error())
+			continue
+		}
+
+		raw, err := lookup(document, steps)
+		if err != nil {
+			j.warnings = append(j.warnings, fmt.Sprintf("%s: %s", e.Path, err.Error()))
+			continue
+		}
+
+		number, ok := raw.(json.Number)
+		if !ok {
+			j.warnings = append(j.warnings, fmt.Sprintf("%s: value is not a number", e.Path))
+			continue
+		}
+
+		value, err := number.Float64()
+		if err != nil {
+			j.warnings = append(j.warnings, fmt.Sprintf("%s: %s", e.Path, err.Error()))
+			continue
+		}
+
+		j.Values = append(j.Values, Value{Metric: e.Metric, Value: value})
+	}
+
+	return nil
+}
+
+// Warnings will return the extractions skipped by the last Gather().
+func (j *JsonHttp) Warnings() []string {
+	return j.warnings
+}
+
+// GetPoints will return a point per extracted value.
+func (j *JsonHttp) GetPoints() []*timeseries.Point {
+	points := make([]*timeseries.Point, len(j.Values))
+
+	for i, v := range j.Values {
+		points[i] = plugins.SimplePoint(v.Metric, v.Value)
+	}
+
+	return points
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (j *JsonHttp) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("JSON over HTTP doesn't have any fixed measurements, but will use the configured metric names.")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*JsonHttp)(nil)
+var _ plugins.Warner = (*JsonHttp)(nil)
@@ -0,0 +1,138 @@
+package jsonhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+)
+
+const stats = `{
+	"uptime": 3600,
+	"db": {"connections": {"active": 12, "idle": 3}, "pool.size": 20},
+	"queues": [{"name": "mail", "length": 5}, {"name": "jobs", "length": 42}],
+	"version": "1.2.3",
+	"latency": 0.25
+}`
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewJsonHttp())
+}
+
+func TestParsePath(t *testing.T) {
+	cases := []struct {
+		path     string
+		expected []step
+		valid    bool
+	}{
+		{"$.a.b", []step{{name: "a"}, {name: "b"}}, true},
+		{"a.b", []step{{name: "a"}, {name: "b"}}, true},
+		{"$['a.b'][2]", []step{{name: "a.b"}, {index: 2, isIndex: true}}, true},
+		{`$.a["b"][-1].c`, []step{{name: "a"}, {name: "b"}, {index: -1, isIndex: true}, {name: "c"}}, true},
+		{"$", nil, false},
+		{"$.a..b", nil, false},
+		{"$.a[x]", nil, false},
+		{"$.a[1", nil, false},
+	}
+
+	for _, c := range cases {
+		steps, err := parsePath(c.path)
+		if c.valid != (err == nil) {
+			t.Errorf("parsePath(%s) returned error %v", c.path, err)
+			continue
+		}
+
+		if c.valid && !reflect.DeepEqual(steps, c.expected) {
+			t.Errorf("parsePath(%s) returned %+v, expected %+v", c.path, steps, c.expected)
+		}
+	}
+}
+
+func TestGather(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/stats" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(stats))
+	}))
+	defer server.Close()
+
+	j := NewJsonHttp().(*JsonHttp)
+	j.URL = server.URL + "/stats"
+	j.Extract = []Extraction{
+		{"$.uptime", "app.Uptime"},
+		{"$.db.connections.active", "app.ActiveConnections"},
+		{"db['pool.size']", "app.PoolSize"},
+		{"$.queues[1].length", "app.JobsQueue"},
+		{"$.queues[-1].length", "app.LastQueue"},
+		{"$.latency", "app.Latency"},
+		{"$.version", "app.Version"},
+		{"$.db.missing", "app.Missing"},
+		{"$.queues[7].length", "app.OutOfRange"},
+		{"$.db[", "app.Invalid"},
+	}
+
+	err := j.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	expected := []Value{
+		{"app.Uptime", 3600},
+		{"app.ActiveConnections", 12},
+		{"app.PoolSize", 20},
+		{"app.JobsQueue", 42},
+		{"app.LastQueue", 42},
+		{"app.Latency", 0.25},
+	}
+
+	if !reflect.DeepEqual(j.Values, expected) {
+		t.Errorf("Got values %+v, expected %+v", j.Values, expected)
+	}
+
+	if len(j.Warnings()) != 4 {
+		t.Errorf("Got warnings %v, expected 4", j.Warnings())
+	}
+
+	points := j.GetPoints()
+	if len(points) != len(expected) || points[1].Name != "app.ActiveConnections" {
+		t.Errorf("Wrong points: %+v", points)
+	}
+}
+
+func TestGatherError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.Write([]byte(`{"uptime": `))
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	transport := localtransport.NewLocalTransport().(plugins.Transport)
+
+	j := NewJsonHttp().(*JsonHttp)
+	j.Extract = []Extraction{{"$.uptime", "app.Uptime"}}
+
+	if j.Gather(transport) != ErrMissingURL {
+		t.Errorf("Gather() did not return ErrMissingURL")
+	}
+
+	j.URL = server.URL
+	if j.Gather(transport) == nil {
+		t.Errorf("Gather() did not fail for status 500")
+	}
+
+	j.URL = server.URL + "/broken"
+	if j.Gather(transport) == nil {
+		t.Errorf("Gather() did not fail for invalid JSON")
+	}
+}
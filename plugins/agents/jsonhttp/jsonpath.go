@@ -0,0 +1,116 @@
+package jsonhttp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// step is a single step in a path, either a member name or an array index.
+type step struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+// parsePath will parse a simple JSONPath expression. Supported are member
+// access by dot (a.b) or bracket (['a.b']) and array indices ([0], [-1] for
+// the last element). The leading $ is optional.
+func parsePath(path string) ([]step, error) {
+	var steps []step
+
+	rest := strings.TrimPrefix(strings.TrimSpace(path), "$")
+	if rest == "" {
+		return nil, fmt.Errorf("empty path '%s'", path)
+	}
+
+	// Allow "a.b" as well as "$.a.b".
+	if rest[0] != '.' && rest[0] != '[' {
+		rest = "." + rest
+	}
+
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+
+			if end == 0 {
+				return nil, fmt.Errorf("empty member name in '%s'", path)
+			}
+
+			steps = append(steps, step{name: rest[:end]})
+			rest = rest[end:]
+
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("missing ] in '%s'", path)
+			}
+
+			inner := rest[1:end]
+			rest = rest[end+1:]
+
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				steps = append(steps, step{name: inner[1 : len(inner)-1]})
+				continue
+			}
+
+			index, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid index '%s' in '%s'", inner, path)
+			}
+
+			steps = append(steps, step{index: index, isIndex: true})
+
+		default:
+			return nil, fmt.Errorf("unexpected '%c' in '%s'", rest[0], path)
+		}
+	}
+
+	return steps, nil
+}
+
+// lookup will follow steps in a document decoded by encoding/json. An error
+// is returned if any step cannot be followed.
+func lookup(document interface{}, steps []step) (interface{}, error) {
+	current := document
+
+	for _, s := range steps {
+		if s.isIndex {
+			array, ok := current.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("[%d] applied to non-array", s.index)
+			}
+
+			index := s.index
+			if index < 0 {
+				index += len(array)
+			}
+
+			if index < 0 || index >= len(array) {
+				return nil, fmt.Errorf("index %d out of range", s.index)
+			}
+
+			current = array[index]
+
+			continue
+		}
+
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("member '%s' applied to non-object", s.name)
+		}
+
+		current, ok = object[s.name]
+		if !ok {
+			return nil, fmt.Errorf("member '%s' not found", s.name)
+		}
+	}
+
+	return current, nil
+}
@@ -1,6 +1,10 @@
 package linuxhost
 
 import (
+	"context"
+	"time"
+
+	"github.com/abrander/agento/logger"
 	"github.com/abrander/agento/plugins"
 	"github.com/abrander/agento/timeseries"
 )
@@ -24,6 +28,10 @@ var agentIds = []string{
 	"sockets",
 }
 
+// gatherTimeout is the maximum time a single agent may use. Agents timing out
+// are left out of the sample.
+var gatherTimeout = 10 * time.Second
+
 func init() {
 	plugins.Register("linuxhost", NewLinuxHost)
 }
@@ -39,18 +47,54 @@ func (l *LinuxHost) Gather(transport plugins.Transport) error {
 	for _, agentId := range agentIds {
 		agent, found := agents[agentId]
 
-		if found {
-			l.Agents[agentId] = agent().(plugins.Agent)
-			err := l.Agents[agentId].Gather(transport)
-			if err != nil {
-				return err
-			}
+		if !found {
+			continue
+		}
+
+		a := agent().(plugins.Agent)
+
+		ctx, cancel := context.WithTimeout(context.Background(), gatherTimeout)
+		err := plugins.Gather(ctx, a, transport)
+		cancel()
+
+		if err == plugins.ErrGatherTimeout {
+			logger.Yellow("linuxhost", "%s did not finish in %s, skipping", agentId, gatherTimeout)
+			continue
+		}
+
+		if err != nil {
+			return err
 		}
+
+		l.Agents[agentId] = a
 	}
 
 	return nil
 }
 
+// Sub will return the rates of all agents between previous and l. Agents
+// reporting rates are left out if previous has no sample for them, as happens
+// on the first run.
+func (l *LinuxHost) Sub(previous *LinuxHost) *LinuxHost {
+	diff := &LinuxHost{
+		Agents: make(map[string]plugins.Agent),
+	}
+
+	for agentId, agent := range l.Agents {
+		var prev plugins.Agent
+		if previous != nil {
+			prev = previous.Agents[agentId]
+		}
+
+		a, ok := plugins.Sub(agent, prev)
+		if ok {
+			diff.Agents[agentId] = a
+		}
+	}
+
+	return diff
+}
+
 func (l *LinuxHost) GetPoints() []*timeseries.Point {
 	points := make([]*timeseries.Point, 0, 300)
 
@@ -2,10 +2,99 @@ package linuxhost
 
 import (
 	"testing"
+	"time"
 
 	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/agents/hostname"
+	"github.com/abrander/agento/plugins/agents/netstat"
+	"github.com/abrander/agento/plugins/transports/local"
+	"github.com/abrander/agento/timeseries"
 )
 
+type (
+	// slowAgent will block in Gather() until the test is done.
+	slowAgent struct{}
+)
+
+var release = make(chan struct{})
+
+func init() {
+	plugins.Register("slowagent", func() interface{} { return new(slowAgent) })
+}
+
+func (a *slowAgent) Gather(_ plugins.Transport) error {
+	<-release
+
+	return nil
+}
+
+func (a *slowAgent) GetPoints() []*timeseries.Point {
+	return nil
+}
+
+func (a *slowAgent) GetDoc() *plugins.Doc {
+	return plugins.NewDoc("Slow agent")
+}
+
 func TestAgent(t *testing.T) {
 	plugins.GenericAgentTest(t, NewLinuxHost())
 }
+
+func TestGatherTimeout(t *testing.T) {
+	defer close(release)
+
+	ids := agentIds
+	timeout := gatherTimeout
+	defer func() {
+		agentIds = ids
+		gatherTimeout = timeout
+	}()
+
+	agentIds = []string{"slowagent", "hostname"}
+	gatherTimeout = 50 * time.Millisecond
+
+	l := NewLinuxHost().(*LinuxHost)
+
+	err := l.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if _, found := l.Agents["slowagent"]; found {
+		t.Errorf("Timed out agent included in sample")
+	}
+
+	if _, ok := l.Agents["hostname"].(*hostname.Hostname); !ok {
+		t.Errorf("hostname missing from sample after timeout: %+v", l.Agents)
+	}
+}
+
+func TestSub(t *testing.T) {
+	first := &LinuxHost{
+		Agents: map[string]plugins.Agent{
+			"hostname": hostname.NewHostname().(plugins.Agent),
+			"netio":    &netstat.NetStats{},
+		},
+	}
+
+	diff := first.Sub(nil)
+	if _, found := diff.Agents["hostname"]; !found {
+		t.Errorf("hostname missing without a previous sample")
+	}
+
+	if _, found := diff.Agents["netio"]; found {
+		t.Errorf("netio included without a previous sample")
+	}
+
+	second := &LinuxHost{
+		Agents: map[string]plugins.Agent{
+			"hostname": hostname.NewHostname().(plugins.Agent),
+			"netio":    &netstat.NetStats{},
+		},
+	}
+
+	diff = second.Sub(first)
+	if _, found := diff.Agents["netio"]; !found {
+		t.Errorf("netio missing with a previous sample")
+	}
+}
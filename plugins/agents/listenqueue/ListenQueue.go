@@ -0,0 +1,279 @@
+package listenqueue
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("listenqueue", NewListenQueue)
+}
+
+type (
+	// ListenQueue reports the accept queue of listening TCP sockets per
+	// port by reading /proc/net/tcp and /proc/net/tcp6, and listen drops
+	// from /proc/net/netstat. Listen drops are cumulative and will be
+	// converted to a per-second rate by Sub().
+	// https://www.kernel.org/doc/Documentation/networking/proc_net_tcp.txt
+	ListenQueue struct {
+		sampletime time.Time
+
+		Ports       map[int]*Port `json:"p"`
+		ListenDrops float64       `json:"d"`
+	}
+
+	// Port holds the accept queue of all sockets listening on a port.
+	Port struct {
+		// Backlog is the number of connections waiting to be accepted.
+		Backlog int64 `json:"b"`
+
+		// MaxBacklog is the maximum length of the accept queue as given
+		// to listen(2), capped by net.core.somaxconn.
+		MaxBacklog int64 `json:"m"`
+
+		// Overflow is 1 if the accept queue of any socket on the port
+		// is full.
+		Overflow int64 `json:"o"`
+	}
+)
+
+// stateListen is TCP_LISTEN in the "st" column.
+const stateListen = "0A"
+
+// NewListenQueue will return a new ListenQueue.
+func NewListenQueue() interface{} {
+	return new(ListenQueue)
+}
+
+// Gather will read /proc/net/tcp, /proc/net/tcp6 and /proc/net/netstat. A
+// missing tcp6 or netstat is not an error, IPv6 could be disabled.
+func (l *ListenQueue) Gather(transport plugins.Transport) error {
+	*l = ListenQueue{
+		Ports: make(map[int]*Port),
+	}
+
+	err := l.read(transport, filepath.Join(configuration.ProcPath, "/net/tcp"))
+	if err != nil {
+		return err
+	}
+
+	err = l.read(transport, filepath.Join(configuration.ProcPath, "/net/tcp6"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	file, err := transport.Open(filepath.Join(configuration.ProcPath, "/net/netstat"))
+	if err == nil {
+		defer file.Close()
+
+		l.ListenDrops, err = parseListenDrops(file)
+	}
+
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	l.sampletime = time.Now()
+
+	return nil
+}
+
+// read will open the tcp table at path and parse it.
+func (l *ListenQueue) read(transport plugins.Transport, path string) error {
+	file, err := transport.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return l.parse(file)
+}
+
+// parse will add the listening sockets from a tcp table to l.Ports.
+func (l *ListenQueue) parse(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+
+	// Skip the header.
+	scanner.Scan()
+
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[3] != stateListen {
+			continue
+		}
+
+		port, err := parsePort(fields[1])
+		if err != nil {
+			return err
+		}
+
+		// For listening sockets rx_queue is the current length of the
+		// accept queue and tx_queue is the maximum length.
+		max, backlog, err := parseQueues(fields[4])
+		if err != nil {
+			return err
+		}
+
+		p, found := l.Ports[port]
+		if !found {
+			p = &Port{}
+			l.Ports[port] = p
+		}
+
+		p.Backlog += backlog
+		p.MaxBacklog += max
+
+		if max > 0 && backlog >= max {
+			p.Overflow = 1
+		}
+	}
+
+	return scanner.Err()
+}
+
+// parsePort will return the port from a local address like "0100007F:1F90".
+func parsePort(address string) (int, error) {
+	i := strings.LastIndex(address, ":")
+	if i < 0 {
+		return 0, fmt.Errorf("malformed address '%s'", address)
+	}
+
+	port, err := strconv.ParseUint(address[i+1:], 16, 16)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(port), nil
+}
+
+// parseQueues will parse the tx_queue:rx_queue column.
+func parseQueues(queues string) (int64, int64, error) {
+	parts := strings.Split(queues, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("malformed queues '%s'", queues)
+	}
+
+	tx, err := strconv.ParseInt(parts[0], 16, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	rx, err := strconv.ParseInt(parts[1], 16, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return tx, rx, nil
+}
+
+// parseListenDrops will return TcpExt.ListenDrops from the contents of
+// /proc/net/netstat. Lines come in pairs, a header line with names followed
+// by a line with values.
+func parseListenDrops(r io.Reader) (float64, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		names := strings.Fields(scanner.Text())
+		if !scanner.Scan() {
+			break
+		}
+
+		if len(names) == 0 || names[0] != "TcpExt:" {
+			continue
+		}
+
+		values := strings.Fields(scanner.Text())
+		if len(values) != len(names) {
+			return 0, fmt.Errorf("malformed table near '%s'", scanner.Text())
+		}
+
+		for i, name := range names {
+			if name == "ListenDrops" {
+				return strconv.ParseFloat(values[i], 64)
+			}
+		}
+	}
+
+	return 0, scanner.Err()
+}
+
+// Sub will calculate the rate of listen drops between previous and l. The
+// accept queues are gauges and are copied from l. An empty ListenQueue is
+// returned if previous is nil or no time has passed.
+func (l *ListenQueue) Sub(previous *ListenQueue) *ListenQueue {
+	diff := &ListenQueue{
+		Ports: make(map[int]*Port),
+	}
+
+	if previous == nil {
+		return diff
+	}
+
+	duration := l.sampletime.Sub(previous.sampletime)
+	if duration <= 0 {
+		return diff
+	}
+
+	diff.sampletime = l.sampletime
+	diff.ListenDrops = plugins.CounterRate(l.ListenDrops, previous.ListenDrops, duration.Seconds())
+
+	for port, p := range l.Ports {
+		copied := *p
+		diff.Ports[port] = &copied
+	}
+
+	return diff
+}
+
+// GetPoints will return the accept queue per listening port and the rate of
+// listen drops.
+func (l *ListenQueue) GetPoints() []*timeseries.Point {
+	ports := make([]int, 0, len(l.Ports))
+	for port := range l.Ports {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+
+	points := plugins.NewPointSet(1 + len(ports)*3)
+
+	points.Add("listen.ListenDrops", l.ListenDrops)
+
+	for _, port := range ports {
+		p := l.Ports[port]
+		tag := strconv.Itoa(port)
+
+		points.AddTagged("listen.Backlog", p.Backlog, "port", tag)
+		points.AddTagged("listen.MaxBacklog", p.MaxBacklog, "port", tag)
+		points.AddTagged("listen.AcceptQueueOverflow", p.Overflow, "port", tag)
+	}
+
+	return points.Points()
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (l *ListenQueue) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("TCP listen queues")
+
+	doc.AddMeasurement("listen.Backlog", "Connections waiting to be accepted. A growing queue means the application can't keep up", "n")
+	doc.AddMeasurement("listen.MaxBacklog", "Maximum length of the accept queue", "n")
+	doc.AddMeasurement("listen.AcceptQueueOverflow", "1 if the accept queue is full and new connections are dropped, 0 otherwise", "")
+	doc.AddMeasurement("listen.ListenDrops", "Connections dropped while listening, on all ports", "/s")
+
+	doc.AddTag("port", "The listening port (not on listen.ListenDrops)")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*ListenQueue)(nil)
@@ -0,0 +1,118 @@
+package listenqueue
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+)
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewListenQueue())
+}
+
+// gather will gather from the fixture in testdata/dir.
+func gather(t *testing.T, dir string) *ListenQueue {
+	procPath := configuration.ProcPath
+	configuration.ProcPath = "testdata/" + dir
+	defer func() { configuration.ProcPath = procPath }()
+
+	l := NewListenQueue().(*ListenQueue)
+	err := l.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	return l
+}
+
+func TestGather(t *testing.T) {
+	l := gather(t, "proc1")
+
+	expected := map[int]Port{
+		22:   {Backlog: 1, MaxBacklog: 256},
+		3306: {Backlog: 3, MaxBacklog: 70},
+		8080: {Backlog: 128, MaxBacklog: 128, Overflow: 1},
+	}
+
+	if len(l.Ports) != len(expected) {
+		t.Fatalf("Got %d ports, expected %d", len(l.Ports), len(expected))
+	}
+
+	for port, p := range expected {
+		got, found := l.Ports[port]
+		if !found {
+			t.Errorf("Port %d not found", port)
+			continue
+		}
+
+		if *got != p {
+			t.Errorf("Got %+v for port %d, expected %+v", *got, port, p)
+		}
+	}
+
+	if l.ListenDrops != 12 {
+		t.Errorf("Got %f listen drops, expected 12", l.ListenDrops)
+	}
+
+	points := l.GetPoints()
+	if len(points) != 1+3*len(expected) {
+		t.Fatalf("Got %d points, expected %d", len(points), 1+3*len(expected))
+	}
+
+	if points[1].Name != "listen.Backlog" || points[1].Tags["port"] != "22" {
+		t.Errorf("Got %s tagged %v, expected listen.Backlog for port 22", points[1].Name, points[1].Tags)
+	}
+}
+
+func TestSub(t *testing.T) {
+	previous := gather(t, "proc1")
+	current := gather(t, "proc2")
+	current.sampletime = previous.sampletime.Add(10 * time.Second)
+
+	diff := current.Sub(previous)
+
+	if diff.ListenDrops != 4 {
+		t.Errorf("Got %f listen drops/s, expected 4", diff.ListenDrops)
+	}
+
+	// proc2 has no tcp6, port 22 is only listening on IPv4.
+	if diff.Ports[22].Backlog != 0 || diff.Ports[22].MaxBacklog != 128 {
+		t.Errorf("Got %+v for port 22", *diff.Ports[22])
+	}
+
+	if len(current.Sub(nil).Ports) != 0 {
+		t.Errorf("Sub(nil) returned ports")
+	}
+
+	plugins.GenericAgentTest(t, diff)
+}
+
+func TestParseErrors(t *testing.T) {
+	header := "  sl  local_address rem_address   st tx_queue rx_queue\n"
+
+	cases := []string{
+		header + "   0: 00000000 00000000:0000 0A 00000080:00000000\n",
+		header + "   0: 00000000:XYZ 00000000:0000 0A 00000080:00000000\n",
+		header + "   0: 00000000:0016 00000000:0000 0A 00000080\n",
+		header + "   0: 00000000:0016 00000000:0000 0A 00000080:zz\n",
+	}
+
+	for _, contents := range cases {
+		l := NewListenQueue().(*ListenQueue)
+		l.Ports = make(map[int]*Port)
+
+		err := l.parse(strings.NewReader(contents))
+		if err == nil {
+			t.Errorf("parse() accepted '%s'", contents)
+		}
+	}
+
+	_, err := parseListenDrops(strings.NewReader("TcpExt: ListenOverflows ListenDrops\nTcpExt: 1\n"))
+	if err == nil {
+		t.Errorf("parseListenDrops() accepted a malformed table")
+	}
+}
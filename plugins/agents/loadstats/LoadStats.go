@@ -38,28 +38,32 @@ func (stat *LoadStats) Gather(transport plugins.Transport) error {
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		text := scanner.Text()
+		stat.parse(scanner.Text())
+	}
 
-		data := strings.Fields(strings.Trim(text, " "))
-		if len(data) != 5 {
-			continue
-		}
+	return nil
+}
 
-		stat.Load1, _ = strconv.ParseFloat(data[0], 64)
-		stat.Load5, _ = strconv.ParseFloat(data[1], 64)
-		stat.Load15, _ = strconv.ParseFloat(data[2], 64)
+// parse will parse a single line from /proc/loadavg. Lines not matching the
+// expected format will be ignored.
+func (stat *LoadStats) parse(text string) {
+	data := strings.Fields(strings.Trim(text, " "))
+	if len(data) != 5 {
+		return
+	}
 
-		sep := strings.Index(data[3], "/")
-		if sep > 0 {
-			stat.ActiveTasks, _ = strconv.ParseInt(data[3][0:sep], 10, 64)
-			stat.Tasks, _ = strconv.ParseInt(data[3][sep+1:], 10, 64)
+	stat.Load1, _ = strconv.ParseFloat(data[0], 64)
+	stat.Load5, _ = strconv.ParseFloat(data[1], 64)
+	stat.Load15, _ = strconv.ParseFloat(data[2], 64)
 
-			// We don't want yo count ourself as active. We're sneeky.
-			stat.ActiveTasks -= 1
-		}
-	}
+	sep := strings.Index(data[3], "/")
+	if sep > 0 {
+		stat.ActiveTasks, _ = strconv.ParseInt(data[3][0:sep], 10, 64)
+		stat.Tasks, _ = strconv.ParseInt(data[3][sep+1:], 10, 64)
 
-	return nil
+		// We don't want yo count ourself as active. We're sneeky.
+		stat.ActiveTasks -= 1
+	}
 }
 
 func (l *LoadStats) GetPoints() []*timeseries.Point {
@@ -9,3 +9,32 @@ import (
 func TestAgent(t *testing.T) {
 	plugins.GenericAgentTest(t, NewLoadStats())
 }
+
+func TestParse(t *testing.T) {
+	cases := map[string]LoadStats{
+		"0.52 0.58 0.59 3/1034 12345": LoadStats{
+			Load1:       0.52,
+			Load5:       0.58,
+			Load15:      0.59,
+			ActiveTasks: 2,
+			Tasks:       1034,
+		},
+		"12.00 8.50 4.25 17/256 1": LoadStats{
+			Load1:       12.0,
+			Load5:       8.5,
+			Load15:      4.25,
+			ActiveTasks: 16,
+			Tasks:       256,
+		},
+		"garbage": LoadStats{},
+	}
+
+	for line, correct := range cases {
+		stat := LoadStats{}
+		stat.parse(line)
+
+		if stat != correct {
+			t.Errorf("Parsing '%s' resulted in %+v, expected %+v", line, stat, correct)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package memcached
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("memcached", NewMemcached)
+}
+
+// Memcached will read server statistics using the stats command. Hits,
+// misses and evictions are cumulative and will be converted to per-second
+// rates by Sub().
+type Memcached struct {
+	Addr    string `toml:"addr" json:"addr" description:"Memcached server address (host:port)" required:"true"`
+	Timeout int    `toml:"timeout" json:"timeout" description:"Connect and read timeout in seconds (default 5)"`
+
+	sampletime time.Time
+
+	CurrItems       int64   `json:"ci"`
+	Bytes           int64   `json:"b"`
+	CurrConnections int64   `json:"cc"`
+	GetHits         float64 `json:"gh"`
+	GetMisses       float64 `json:"gm"`
+	Evictions       float64 `json:"e"`
+}
+
+var (
+	// ErrMissingAddr will be returned if no address is configured.
+	ErrMissingAddr = errors.New("addr must be set")
+)
+
+// NewMemcached will return a new Memcached.
+func NewMemcached() interface{} {
+	return new(Memcached)
+}
+
+// Gather will connect to the server and issue stats.
+func (m *Memcached) Gather(transport plugins.Transport) error {
+	if m.Addr == "" {
+		return ErrMissingAddr
+	}
+
+	timeout := 5 * time.Second
+	if m.Timeout > 0 {
+		timeout = time.Duration(m.Timeout) * time.Second
+	}
+
+	conn, err := transport.Dial("tcp", m.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	_, err = conn.Write([]byte("stats\r\n"))
+	if err != nil {
+		return err
+	}
+
+	m.sampletime = time.Now()
+
+	return m.parse(conn)
+}
+
+// parse will parse the reply to stats. Each line is "STAT <name> <value>",
+// the reply ends with "END".
+func (m *Memcached) parse(r io.Reader) error {
+	m.CurrItems = 0
+	m.Bytes = 0
+	m.CurrConnections = 0
+	m.GetHits = 0.0
+	m.GetMisses = 0.0
+	m.Evictions = 0.0
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "END" {
+			return nil
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[0] != "STAT" {
+			return fmt.Errorf("unexpected reply '%s'", line)
+		}
+
+		key, value := fields[1], fields[2]
+
+		var err error
+		switch key {
+		case "curr_items":
+			m.CurrItems, err = strconv.ParseInt(value, 10, 64)
+		case "bytes":
+			m.Bytes, err = strconv.ParseInt(value, 10, 64)
+		case "curr_connections":
+			m.CurrConnections, err = strconv.ParseInt(value, 10, 64)
+		case "get_hits":
+			m.GetHits, err = strconv.ParseFloat(value, 64)
+		case "get_misses":
+			m.GetMisses, err = strconv.ParseFloat(value, 64)
+		case "evictions":
+			m.Evictions, err = strconv.ParseFloat(value, 64)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return err
+	}
+
+	return io.ErrUnexpectedEOF
+}
+
+// Sub will calculate per-second rates for hits, misses and evictions between
+// previous and m. Gauges are copied as is. An empty Memcached is returned if
+// previous is nil or no time has passed.
+func (m *Memcached) Sub(previous *Memcached) *Memcached {
+	diff := &Memcached{
+		Addr:    m.Addr,
+		Timeout: m.Timeout,
+	}
+
+	if previous == nil {
+		return diff
+	}
+
+	duration := m.sampletime.Sub(previous.sampletime)
+	if duration <= 0 {
+		return diff
+	}
+
+	factor := duration.Seconds()
+
+	diff.sampletime = m.sampletime
+	diff.CurrItems = m.CurrItems
+	diff.Bytes = m.Bytes
+	diff.CurrConnections = m.CurrConnections
+	diff.GetHits = plugins.CounterRate(m.GetHits, previous.GetHits, factor)
+	diff.GetMisses = plugins.CounterRate(m.GetMisses, previous.GetMisses, factor)
+	diff.Evictions = plugins.CounterRate(m.Evictions, previous.Evictions, factor)
+
+	return diff
+}
+
+// GetPoints will return server statistics.
+func (m *Memcached) GetPoints() []*timeseries.Point {
+	return []*timeseries.Point{
+		plugins.SimplePoint("memcached.CurrItems", m.CurrItems),
+		plugins.SimplePoint("memcached.Bytes", m.Bytes),
+		plugins.SimplePoint("memcached.CurrConnections", m.CurrConnections),
+		plugins.SimplePoint("memcached.GetHits", m.GetHits),
+		plugins.SimplePoint("memcached.GetMisses", m.GetMisses),
+		plugins.SimplePoint("memcached.Evictions", m.Evictions),
+	}
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (m *Memcached) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("Memcached server statistics")
+
+	doc.AddMeasurement("memcached.CurrItems", "Items currently stored", "n")
+	doc.AddMeasurement("memcached.Bytes", "Bytes used to store items", "b")
+	doc.AddMeasurement("memcached.CurrConnections", "Open client connections", "n")
+	doc.AddMeasurement("memcached.GetHits", "Keys requested and found", "/s")
+	doc.AddMeasurement("memcached.GetMisses", "Keys requested but not found", "/s")
+	doc.AddMeasurement("memcached.Evictions", "Valid items removed to free memory", "/s")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*Memcached)(nil)
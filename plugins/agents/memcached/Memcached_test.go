@@ -0,0 +1,139 @@
+package memcached
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+)
+
+const (
+	stats1 = "STAT pid 1234\r\n" +
+		"STAT uptime 86400\r\n" +
+		"STAT version 1.6.21\r\n" +
+		"STAT curr_connections 10\r\n" +
+		"STAT get_hits 1000\r\n" +
+		"STAT get_misses 200\r\n" +
+		"STAT bytes 1048576\r\n" +
+		"STAT curr_items 4242\r\n" +
+		"STAT evictions 5\r\n" +
+		"END\r\n"
+
+	stats2 = "STAT curr_connections 12\r\n" +
+		"STAT get_hits 1500\r\n" +
+		"STAT get_misses 220\r\n" +
+		"STAT bytes 2097152\r\n" +
+		"STAT curr_items 5000\r\n" +
+		"STAT evictions 5\r\n" +
+		"END\r\n"
+)
+
+// serve will start a fake memcached answering stats with reply. The first
+// command received is sent to commands.
+func serve(t *testing.T, reply string, commands chan<- string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() failed: %s", err.Error())
+	}
+
+	go func() {
+		defer l.Close()
+
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		command, _ := bufio.NewReader(conn).ReadString('\n')
+		commands <- strings.TrimSpace(command)
+
+		conn.Write([]byte(reply))
+	}()
+
+	return l.Addr().String()
+}
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewMemcached())
+}
+
+func TestGather(t *testing.T) {
+	commands := make(chan string, 1)
+
+	m := NewMemcached().(*Memcached)
+	m.Addr = serve(t, stats1, commands)
+
+	err := m.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if command := <-commands; command != "stats" {
+		t.Errorf("Got command '%s', expected 'stats'", command)
+	}
+
+	if m.CurrItems != 4242 || m.Bytes != 1048576 || m.CurrConnections != 10 || m.GetHits != 1000 || m.GetMisses != 200 || m.Evictions != 5 {
+		t.Errorf("Wrong result: %+v", *m)
+	}
+
+	if len(m.GetPoints()) != 6 {
+		t.Errorf("Got %d points, expected 6", len(m.GetPoints()))
+	}
+}
+
+func TestGatherErrors(t *testing.T) {
+	m := NewMemcached().(*Memcached)
+
+	err := m.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != ErrMissingAddr {
+		t.Errorf("Gather() returned %v, expected ErrMissingAddr", err)
+	}
+
+	m.Addr = serve(t, "ERROR\r\n", make(chan string, 1))
+
+	err = m.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err == nil {
+		t.Errorf("Gather() accepted an error reply")
+	}
+
+	// The reply must end with END.
+	m.Addr = serve(t, "STAT curr_items 1\r\n", make(chan string, 1))
+
+	err = m.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err == nil {
+		t.Errorf("Gather() accepted a truncated reply")
+	}
+}
+
+func TestSub(t *testing.T) {
+	previous := NewMemcached().(*Memcached)
+	previous.parse(strings.NewReader(stats1))
+	previous.sampletime = time.Now()
+
+	current := NewMemcached().(*Memcached)
+	current.parse(strings.NewReader(stats2))
+	current.sampletime = previous.sampletime.Add(10 * time.Second)
+
+	diff := current.Sub(previous)
+	if diff.GetHits != 50 || diff.GetMisses != 2 || diff.Evictions != 0 {
+		t.Errorf("Wrong rates: %+v", *diff)
+	}
+
+	if diff.CurrItems != 5000 || diff.Bytes != 2097152 || diff.CurrConnections != 12 {
+		t.Errorf("Gauges not copied: %+v", *diff)
+	}
+
+	current.sampletime = previous.sampletime
+	if current.Sub(previous).GetHits != 0.0 {
+		t.Errorf("Sub() returned rates for a zero duration")
+	}
+
+	if current.Sub(nil).CurrItems != 0 {
+		t.Errorf("Sub() returned values without a previous sample")
+	}
+}
@@ -20,13 +20,14 @@ func NewMemoryStats() interface{} {
 }
 
 type MemoryStats struct {
-	Used     int64 `json:"u"`
-	Free     int64 `json:"f"`
-	Shared   int64 `json:"s"`
-	Buffers  int64 `json:"b"`
-	Cached   int64 `json:"c"`
-	SwapUsed int64 `json:"su"`
-	SwapFree int64 `json:"sf"`
+	Used      int64 `json:"u"`
+	Free      int64 `json:"f"`
+	Available int64 `json:"a"`
+	Shared    int64 `json:"s"`
+	Buffers   int64 `json:"b"`
+	Cached    int64 `json:"c"`
+	SwapUsed  int64 `json:"su"`
+	SwapFree  int64 `json:"sf"`
 }
 
 func getMemInfo(transport plugins.Transport) *map[string]int64 {
@@ -74,7 +75,17 @@ func getMemInfo(transport plugins.Transport) *map[string]int64 {
 func (stat *MemoryStats) Gather(transport plugins.Transport) error {
 	meminfo := getMemInfo(transport)
 
-	stat.Used = (*meminfo)["MemTotal"] - (*meminfo)["MemFree"] - (*meminfo)["Buffers"] - (*meminfo)["Cached"]
+	// MemAvailable is the kernel's own estimate (since 3.14). If it's not
+	// present we fall back to our own - somewhat naive - heuristic.
+	available, found := (*meminfo)["MemAvailable"]
+	if found {
+		stat.Available = available
+		stat.Used = (*meminfo)["MemTotal"] - available
+	} else {
+		stat.Available = (*meminfo)["MemFree"] + (*meminfo)["Buffers"] + (*meminfo)["Cached"]
+		stat.Used = (*meminfo)["MemTotal"] - (*meminfo)["MemFree"] - (*meminfo)["Buffers"] - (*meminfo)["Cached"]
+	}
+
 	stat.Free = (*meminfo)["MemFree"]
 	stat.Shared = (*meminfo)["Shmem"]
 	stat.Buffers = (*meminfo)["Buffers"]
@@ -87,17 +98,18 @@ func (stat *MemoryStats) Gather(transport plugins.Transport) error {
 }
 
 func (s *MemoryStats) GetPoints() []*timeseries.Point {
-	points := make([]*timeseries.Point, 7)
-
-	points[0] = plugins.SimplePoint("mem.Used", s.Used)
-	points[1] = plugins.SimplePoint("mem.Free", s.Free)
-	points[2] = plugins.SimplePoint("mem.Shared", s.Shared)
-	points[3] = plugins.SimplePoint("mem.Buffers", s.Buffers)
-	points[4] = plugins.SimplePoint("mem.Cached", s.Cached)
-	points[5] = plugins.SimplePoint("swap.Used", s.SwapUsed)
-	points[6] = plugins.SimplePoint("swap.Free", s.SwapFree)
-
-	return points
+	points := plugins.NewPointSet(8)
+
+	points.Add("mem.Used", s.Used)
+	points.Add("mem.Free", s.Free)
+	points.Add("mem.Available", s.Available)
+	points.Add("mem.Shared", s.Shared)
+	points.Add("mem.Buffers", s.Buffers)
+	points.Add("mem.Cached", s.Cached)
+	points.Add("swap.Used", s.SwapUsed)
+	points.Add("swap.Free", s.SwapFree)
+
+	return points.Points()
 }
 
 func (s *MemoryStats) GetDoc() *plugins.Doc {
@@ -105,6 +117,7 @@ func (s *MemoryStats) GetDoc() *plugins.Doc {
 
 	doc.AddMeasurement("mem.Used", "Memory used", "b")
 	doc.AddMeasurement("mem.Free", "Free memory", "b")
+	doc.AddMeasurement("mem.Available", "Memory available for starting new applications without swapping", "b")
 	doc.AddMeasurement("mem.Shared", "Memory shared among multiple processes", "b")
 	doc.AddMeasurement("mem.Buffers", "Memory used for buffers", "b")
 	doc.AddMeasurement("mem.Cached", "Memory used for cache", "b")
@@ -3,9 +3,89 @@ package memorystats
 import (
 	"testing"
 
+	"github.com/abrander/agento/configuration"
 	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+	"github.com/abrander/agento/plugins/transports/mock"
 )
 
 func TestAgent(t *testing.T) {
 	plugins.GenericAgentTest(t, NewMemoryStats())
 }
+
+func TestGatherAvailable(t *testing.T) {
+	cases := map[string]int64{
+		`MemTotal:       16303488 kB
+MemFree:          612340 kB
+MemAvailable:    9876543 kB
+Buffers:          345612 kB
+Cached:          7012344 kB
+SwapTotal:       2097148 kB
+SwapFree:        2097148 kB
+`: 9876543,
+		`MemTotal:       16303488 kB
+MemFree:          612340 kB
+Buffers:          345612 kB
+Cached:          7012344 kB
+SwapTotal:       2097148 kB
+SwapFree:        2097148 kB
+`: 612340 + 345612 + 7012344,
+	}
+
+	for meminfo, available := range cases {
+		mock := mocktransport.NewMock().(*mocktransport.Mock)
+		mock.SetFile("/proc/meminfo", []byte(meminfo))
+
+		stat := NewMemoryStats().(*MemoryStats)
+		stat.Gather(mock)
+
+		if stat.Available != available {
+			t.Errorf("Available is %d, should be %d", stat.Available, available)
+		}
+
+		if stat.Used != 16303488-available {
+			t.Errorf("Used is %d, should be %d", stat.Used, 16303488-available)
+		}
+	}
+}
+
+func TestGatherFixture(t *testing.T) {
+	procPath := configuration.ProcPath
+	configuration.ProcPath = "testdata/proc"
+	defer func() { configuration.ProcPath = procPath }()
+
+	stat := NewMemoryStats().(*MemoryStats)
+	err := stat.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	expected := MemoryStats{
+		Used:      16303488 - 9876543,
+		Free:      612340,
+		Available: 9876543,
+		Shared:    421100,
+		Buffers:   345612,
+		Cached:    7012344,
+		SwapUsed:  2097148 - 1048574,
+		SwapFree:  1048574,
+	}
+
+	if *stat != expected {
+		t.Fatalf("Got %+v, expected %+v", *stat, expected)
+	}
+}
+
+func TestGetPoints(t *testing.T) {
+	points := NewMemoryStats().(*MemoryStats).GetPoints()
+
+	if len(points) != 8 {
+		t.Fatalf("Got %d points, expected 8", len(points))
+	}
+
+	for i, point := range points {
+		if point == nil {
+			t.Fatalf("Point %d is nil", i)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package mongodb
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("mongodb", NewMongoDB)
+}
+
+type (
+	// MongoDB will read server statistics using serverStatus. Opcounters
+	// are cumulative and will be converted to per-second rates by Sub().
+	// Replication lag is only reported for replica set members.
+	MongoDB struct {
+		URL     string `toml:"url" json:"url" description:"MongoDB URL (like mongodb://localhost:27017)" required:"true"`
+		Timeout int    `toml:"timeout" json:"timeout" description:"Connect and query timeout in seconds (default 5)"`
+
+		sampletime time.Time
+
+		Connections      int64   `json:"c"`
+		ResidentMemMB    int64   `json:"r"`
+		OpcountersInsert float64 `json:"oi"`
+		OpcountersQuery  float64 `json:"oq"`
+		OpcountersUpdate float64 `json:"ou"`
+		OpcountersDelete float64 `json:"od"`
+		ReplicaSet       bool    `json:"rs"`
+		ReplicationLag   float64 `json:"l"`
+	}
+
+	// serverStatus is the parts of serverStatus we use.
+	serverStatus struct {
+		Connections struct {
+			Current int64 `bson:"current"`
+		} `bson:"connections"`
+		Mem struct {
+			Resident int64 `bson:"resident"`
+		} `bson:"mem"`
+		Opcounters struct {
+			Insert float64 `bson:"insert"`
+			Query  float64 `bson:"query"`
+			Update float64 `bson:"update"`
+			Delete float64 `bson:"delete"`
+		} `bson:"opcounters"`
+		Repl struct {
+			SetName string `bson:"setName"`
+		} `bson:"repl"`
+	}
+
+	// replSetStatus is the parts of replSetGetStatus we use.
+	replSetStatus struct {
+		Members []struct {
+			StateStr   string    `bson:"stateStr"`
+			OptimeDate time.Time `bson:"optimeDate"`
+			Self       bool      `bson:"self"`
+		} `bson:"members"`
+	}
+
+	// session is the subset of *mgo.Session used by MongoDB.
+	session interface {
+		Run(cmd interface{}, result interface{}) error
+		Close()
+	}
+)
+
+var (
+	// ErrMissingURL will be returned if no URL is configured.
+	ErrMissingURL = errors.New("url must be set")
+
+	// dial will connect to MongoDB. Replaced when testing.
+	dial = func(info *mgo.DialInfo) (session, error) {
+		sess, err := mgo.DialWithInfo(info)
+		if err != nil {
+			return nil, err
+		}
+
+		// We want statistics from the server configured, even if it's a
+		// secondary.
+		sess.SetMode(mgo.Monotonic, true)
+
+		return sess, nil
+	}
+)
+
+// NewMongoDB will return a new MongoDB.
+func NewMongoDB() interface{} {
+	return new(MongoDB)
+}
+
+// Gather will connect to the server and run serverStatus. If the server is
+// a replica set member, replSetGetStatus is used to find the lag.
+func (m *MongoDB) Gather(transport plugins.Transport) error {
+	if m.URL == "" {
+		return ErrMissingURL
+	}
+
+	info, err := mgo.ParseURL(m.URL)
+	if err != nil {
+		return err
+	}
+
+	info.Direct = true
+	info.Timeout = 5 * time.Second
+	if m.Timeout > 0 {
+		info.Timeout = time.Duration(m.Timeout) * time.Second
+	}
+
+	info.DialServer = func(addr *mgo.ServerAddr) (net.Conn, error) {
+		return transport.Dial("tcp", addr.String())
+	}
+
+	sess, err := dial(info)
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	var status serverStatus
+	err = sess.Run(bson.D{{Name: "serverStatus", Value: 1}}, &status)
+	if err != nil {
+		return err
+	}
+
+	m.sampletime = time.Now()
+	m.Connections = status.Connections.Current
+	m.ResidentMemMB = status.Mem.Resident
+	m.OpcountersInsert = status.Opcounters.Insert
+	m.OpcountersQuery = status.Opcounters.Query
+	m.OpcountersUpdate = status.Opcounters.Update
+	m.OpcountersDelete = status.Opcounters.Delete
+	m.ReplicaSet = status.Repl.SetName != ""
+	m.ReplicationLag = 0.0
+
+	if !m.ReplicaSet {
+		return nil
+	}
+
+	var repl replSetStatus
+	err = sess.Run(bson.D{{Name: "replSetGetStatus", Value: 1}}, &repl)
+	if err != nil {
+		return err
+	}
+
+	m.ReplicationLag = repl.lag()
+
+	return nil
+}
+
+// lag will return how many seconds this member is behind the primary. 0 is
+// returned for the primary itself, or if no primary is found.
+func (r *replSetStatus) lag() float64 {
+	var primary, self time.Time
+
+	for _, member := range r.Members {
+		if member.StateStr == "PRIMARY" {
+			primary = member.OptimeDate
+		}
+
+		if member.Self {
+			self = member.OptimeDate
+		}
+	}
+
+	if primary.IsZero() || self.IsZero() || !primary.After(self) {
+		return 0.0
+	}
+
+	return primary.Sub(self).Seconds()
+}
+
+// Sub will calculate per-second rates for the opcounters between previous
+// and m. Gauges are copied as is. An empty MongoDB is returned if previous
+// is nil or no time has passed.
+func (m *MongoDB) Sub(previous *MongoDB) *MongoDB {
+	diff := &MongoDB{
+		URL:     m.URL,
+		Timeout: m.Timeout,
+	}
+
+	if previous == nil {
+		return diff
+	}
+
+	duration := m.sampletime.Sub(previous.sampletime)
+	if duration <= 0 {
+		return diff
+	}
+
+	factor := duration.Seconds()
+
+	diff.sampletime = m.sampletime
+	diff.Connections = m.Connections
+	diff.ResidentMemMB = m.ResidentMemMB
+	diff.OpcountersInsert = plugins.CounterRate(m.OpcountersInsert, previous.OpcountersInsert, factor)
+	diff.OpcountersQuery = plugins.CounterRate(m.OpcountersQuery, previous.OpcountersQuery, factor)
+	diff.OpcountersUpdate = plugins.CounterRate(m.OpcountersUpdate, previous.OpcountersUpdate, factor)
+	diff.OpcountersDelete = plugins.CounterRate(m.OpcountersDelete, previous.OpcountersDelete, factor)
+	diff.ReplicaSet = m.ReplicaSet
+	diff.ReplicationLag = m.ReplicationLag
+
+	return diff
+}
+
+// GetPoints will return server statistics. mongo.ReplicationLag is only
+// returned for replica set members.
+func (m *MongoDB) GetPoints() []*timeseries.Point {
+	points := []*timeseries.Point{
+		plugins.SimplePoint("mongo.Connections", m.Connections),
+		plugins.SimplePoint("mongo.ResidentMemMB", m.ResidentMemMB),
+		plugins.SimplePoint("mongo.OpcountersInsert", m.OpcountersInsert),
+		plugins.SimplePoint("mongo.OpcountersQuery", m.OpcountersQuery),
+		plugins.SimplePoint("mongo.OpcountersUpdate", m.OpcountersUpdate),
+		plugins.SimplePoint("mongo.OpcountersDelete", m.OpcountersDelete),
+	}
+
+	if m.ReplicaSet {
+		points = append(points, plugins.SimplePoint("mongo.ReplicationLag", m.ReplicationLag))
+	}
+
+	return points
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (m *MongoDB) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("MongoDB server status")
+
+	doc.AddMeasurement("mongo.Connections", "Open client connections", "n")
+	doc.AddMeasurement("mongo.ResidentMemMB", "Resident memory used by mongod", "MiB")
+	doc.AddMeasurement("mongo.OpcountersInsert", "Insert operations", "/s")
+	doc.AddMeasurement("mongo.OpcountersQuery", "Query operations", "/s")
+	doc.AddMeasurement("mongo.OpcountersUpdate", "Update operations", "/s")
+	doc.AddMeasurement("mongo.OpcountersDelete", "Delete operations", "/s")
+	doc.AddMeasurement("mongo.ReplicationLag", "How far this member is behind the primary (replica sets only)", "s")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*MongoDB)(nil)
@@ -0,0 +1,206 @@
+package mongodb
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+)
+
+type (
+	// fakeSession will answer commands with the documents in results.
+	fakeSession struct {
+		results map[string]bson.M
+		info    *mgo.DialInfo
+		closed  bool
+	}
+)
+
+func (s *fakeSession) Run(cmd interface{}, result interface{}) error {
+	name := cmd.(bson.D)[0].Name
+
+	doc, found := s.results[name]
+	if !found {
+		return errors.New("no such command: '" + name + "'")
+	}
+
+	// A round trip through BSON will give us the same types as mgo.
+	b, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	return bson.Unmarshal(b, result)
+}
+
+func (s *fakeSession) Close() {
+	s.closed = true
+}
+
+// useSession will make Gather() use s.
+func useSession(t *testing.T, s *fakeSession) {
+	original := dial
+	dial = func(info *mgo.DialInfo) (session, error) {
+		s.info = info
+
+		return s, nil
+	}
+
+	t.Cleanup(func() { dial = original })
+}
+
+func status(setName string) bson.M {
+	status := bson.M{
+		"host":        "mongo1:27017",
+		"version":     "4.4.29",
+		"connections": bson.M{"current": 17, "available": 51183},
+		"mem":         bson.M{"bits": 64, "resident": 412, "virtual": 1650},
+		"opcounters": bson.M{
+			"insert":  int64(1200),
+			"query":   int64(5400),
+			"update":  int64(300),
+			"delete":  int64(12),
+			"getmore": int64(40),
+			"command": int64(9000),
+		},
+	}
+
+	if setName != "" {
+		status["repl"] = bson.M{"setName": setName, "ismaster": false, "secondary": true}
+	}
+
+	return status
+}
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewMongoDB())
+}
+
+func TestGather(t *testing.T) {
+	s := &fakeSession{
+		results: map[string]bson.M{"serverStatus": status("")},
+	}
+	useSession(t, s)
+
+	m := NewMongoDB().(*MongoDB)
+	m.URL = "mongodb://localhost:27017"
+
+	err := m.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if !s.closed {
+		t.Errorf("Session was not closed")
+	}
+
+	if !s.info.Direct || s.info.DialServer == nil {
+		t.Errorf("Gather() did not connect directly using the transport")
+	}
+
+	if m.Connections != 17 || m.ResidentMemMB != 412 || m.OpcountersInsert != 1200 || m.OpcountersQuery != 5400 || m.OpcountersUpdate != 300 || m.OpcountersDelete != 12 {
+		t.Errorf("Wrong result: %+v", *m)
+	}
+
+	if m.ReplicaSet || len(m.GetPoints()) != 6 {
+		t.Errorf("Got replication lag from a standalone server")
+	}
+}
+
+func TestGatherReplicaSet(t *testing.T) {
+	optime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	s := &fakeSession{
+		results: map[string]bson.M{
+			"serverStatus": status("rs0"),
+			"replSetGetStatus": {
+				"set": "rs0",
+				"members": []bson.M{
+					{"name": "mongo0:27017", "stateStr": "PRIMARY", "optimeDate": optime},
+					{"name": "mongo1:27017", "stateStr": "SECONDARY", "optimeDate": optime.Add(-4 * time.Second), "self": true},
+					{"name": "mongo2:27017", "stateStr": "SECONDARY", "optimeDate": optime.Add(-30 * time.Second)},
+				},
+			},
+		},
+	}
+	useSession(t, s)
+
+	m := NewMongoDB().(*MongoDB)
+	m.URL = "mongodb://mongo1:27017"
+
+	err := m.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if !m.ReplicaSet || m.ReplicationLag != 4.0 {
+		t.Errorf("Got lag %f (replica set: %v), expected 4s", m.ReplicationLag, m.ReplicaSet)
+	}
+
+	if len(m.GetPoints()) != 7 {
+		t.Errorf("Got %d points, expected 7", len(m.GetPoints()))
+	}
+}
+
+func TestGatherErrors(t *testing.T) {
+	transport := localtransport.NewLocalTransport().(plugins.Transport)
+
+	m := NewMongoDB().(*MongoDB)
+	if m.Gather(transport) != ErrMissingURL {
+		t.Errorf("Gather() did not return ErrMissingURL")
+	}
+
+	useSession(t, &fakeSession{})
+
+	m.URL = "mongodb://localhost:27017"
+	if m.Gather(transport) == nil {
+		t.Errorf("Gather() did not fail when serverStatus failed")
+	}
+}
+
+func TestSub(t *testing.T) {
+	now := time.Now()
+
+	previous := &MongoDB{
+		sampletime:       now,
+		OpcountersInsert: 1000,
+		OpcountersQuery:  5000,
+		OpcountersUpdate: 300,
+		OpcountersDelete: 10,
+	}
+
+	current := &MongoDB{
+		sampletime:       now.Add(10 * time.Second),
+		Connections:      20,
+		ResidentMemMB:    400,
+		OpcountersInsert: 1100,
+		OpcountersQuery:  5500,
+		OpcountersUpdate: 310,
+		OpcountersDelete: 10,
+		ReplicaSet:       true,
+		ReplicationLag:   2,
+	}
+
+	diff := current.Sub(previous)
+	if diff.OpcountersInsert != 10 || diff.OpcountersQuery != 50 || diff.OpcountersUpdate != 1 || diff.OpcountersDelete != 0 {
+		t.Errorf("Wrong rates: %+v", *diff)
+	}
+
+	if diff.Connections != 20 || diff.ResidentMemMB != 400 || !diff.ReplicaSet || diff.ReplicationLag != 2 {
+		t.Errorf("Gauges not copied: %+v", *diff)
+	}
+
+	current.sampletime = previous.sampletime
+	if current.Sub(previous).OpcountersQuery != 0.0 {
+		t.Errorf("Sub() returned rates for a zero duration")
+	}
+
+	if current.Sub(nil).Connections != 0 {
+		t.Errorf("Sub() returned values without a previous sample")
+	}
+}
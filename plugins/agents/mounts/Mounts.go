@@ -0,0 +1,132 @@
+package mounts
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("mounts", NewMounts)
+}
+
+type (
+	// Mounts will count mounted filesystems by reading /proc/mounts.
+	Mounts struct {
+		ReadOnlyIgnore []string `toml:"readOnlyIgnore" json:"readOnlyIgnore" description:"Filesystem types always mounted read-only, not counted in mounts.ReadOnly (default squashfs, iso9660 and udf)"`
+
+		Total    int64            `json:"t"`
+		ReadOnly int64            `json:"r"`
+		FsTypes  map[string]int64 `json:"f"`
+	}
+)
+
+// defaultReadOnlyIgnore lists filesystem types that can only be mounted
+// read-only.
+var defaultReadOnlyIgnore = []string{"squashfs", "iso9660", "udf"}
+
+// NewMounts will return a new Mounts.
+func NewMounts() interface{} {
+	return new(Mounts)
+}
+
+// readOnlyIgnore will return the filesystem types to leave out of the
+// read-only count.
+func (m *Mounts) readOnlyIgnore() []string {
+	if len(m.ReadOnlyIgnore) == 0 {
+		return defaultReadOnlyIgnore
+	}
+
+	return m.ReadOnlyIgnore
+}
+
+// Gather will read and parse /proc/mounts.
+func (m *Mounts) Gather(transport plugins.Transport) error {
+	contents, err := transport.ReadFile(filepath.Join(configuration.ProcPath, "/mounts"))
+	if err != nil {
+		return err
+	}
+
+	m.Total = 0
+	m.ReadOnly = 0
+	m.FsTypes = make(map[string]int64)
+
+	ignore := make(map[string]bool)
+	for _, fstype := range m.readOnlyIgnore() {
+		ignore[fstype] = true
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		// device mountpoint fstype options dump pass
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+
+		fstype := fields[2]
+
+		m.Total++
+		m.FsTypes[fstype]++
+
+		if !ignore[fstype] && readOnly(fields[3]) {
+			m.ReadOnly++
+		}
+	}
+
+	return scanner.Err()
+}
+
+// readOnly will return true if the mount options include "ro".
+func readOnly(options string) bool {
+	for _, option := range strings.Split(options, ",") {
+		if option == "ro" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetPoints will return the total number of mounts, the number of read-only
+// mounts and the number of mounts per filesystem type.
+func (m *Mounts) GetPoints() []*timeseries.Point {
+	fstypes := make([]string, 0, len(m.FsTypes))
+	for fstype := range m.FsTypes {
+		fstypes = append(fstypes, fstype)
+	}
+	sort.Strings(fstypes)
+
+	points := make([]*timeseries.Point, 2, 2+len(fstypes))
+
+	points[0] = plugins.SimplePoint("mounts.Total", m.Total)
+	points[1] = plugins.SimplePoint("mounts.ReadOnly", m.ReadOnly)
+
+	for _, fstype := range fstypes {
+		points = append(points, plugins.PointWithTag("mounts.Count", m.FsTypes[fstype], "fstype", fstype))
+	}
+
+	return points
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (m *Mounts) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("Mounted filesystems")
+
+	doc.AddMeasurement("mounts.Total", "Number of mounted filesystems", "n")
+	doc.AddMeasurement("mounts.ReadOnly", "Number of filesystems mounted read-only. A filesystem can be remounted read-only by the kernel after errors", "n")
+	doc.AddMeasurement("mounts.Count", "Number of mounted filesystems of a type", "n")
+
+	doc.AddTag("fstype", "The filesystem type (only on mounts.Count)")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*Mounts)(nil)
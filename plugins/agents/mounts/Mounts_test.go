@@ -0,0 +1,68 @@
+package mounts
+
+import (
+	"testing"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+)
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewMounts())
+}
+
+func TestGather(t *testing.T) {
+	procPath := configuration.ProcPath
+	configuration.ProcPath = "testdata"
+	defer func() { configuration.ProcPath = procPath }()
+
+	transport := localtransport.NewLocalTransport().(plugins.Transport)
+
+	m := NewMounts().(*Mounts)
+	err := m.Gather(transport)
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if m.Total != 8 {
+		t.Errorf("Got %d mounts, expected 8", m.Total)
+	}
+
+	// /var and /home, the squashfs mount is ignored.
+	if m.ReadOnly != 2 {
+		t.Errorf("Got %d read-only mounts, expected 2", m.ReadOnly)
+	}
+
+	expected := map[string]int64{
+		"sysfs":    1,
+		"proc":     1,
+		"ext4":     3,
+		"xfs":      1,
+		"nfs4":     1,
+		"squashfs": 1,
+	}
+
+	if len(m.FsTypes) != len(expected) {
+		t.Errorf("Got %d filesystem types, expected %d", len(m.FsTypes), len(expected))
+	}
+
+	for fstype, count := range expected {
+		if m.FsTypes[fstype] != count {
+			t.Errorf("Got %d %s mounts, expected %d", m.FsTypes[fstype], fstype, count)
+		}
+	}
+
+	if len(m.GetPoints()) != 2+len(expected) {
+		t.Errorf("Got %d points, expected %d", len(m.GetPoints()), 2+len(expected))
+	}
+
+	// /var and the squashfs mount now the defaults are replaced.
+	m.ReadOnlyIgnore = []string{"nfs4"}
+	m.Gather(transport)
+	if m.ReadOnly != 2 {
+		t.Errorf("Got %d read-only mounts with nfs4 ignored, expected 2", m.ReadOnly)
+	}
+
+	plugins.GenericAgentTest(t, m)
+}
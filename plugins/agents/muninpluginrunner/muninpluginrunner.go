@@ -9,13 +9,16 @@ import (
 	"github.com/abrander/agento/timeseries"
 )
 
+// valueRE matches a single "field.value 1234" line from a munin plugin.
+var valueRE = regexp.MustCompile(`^(\S+)\.value (-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?)$`)
+
 func init() {
 	plugins.Register("muninpluginrunner", newMuninPluginRunner)
 }
 
 // MuninPluginRunner will retrieve stub status.
 type MuninPluginRunner struct {
-	Command   string `toml:"command" json:"command" description:"Command to run"`
+	Command   string `toml:"command" json:"command" description:"Command to run" required:"true"`
 	Arguments string `toml:"arguments" json:"arguments" description:"Arguments to command"`
 	Prefix    string `toml:"prefix" json:"prefix" description:"Prefix to output variables"`
 
@@ -42,8 +45,7 @@ func (m *MuninPluginRunner) Gather(transport plugins.Transport) error {
 	}
 	scanner := bufio.NewScanner(stdout)
 	for scanner.Scan() {
-		re := regexp.MustCompile("^(.*).value ([0-9]+(\\.([0-9])*)?)$")
-		matches := re.FindAllStringSubmatch(scanner.Text(), -1)
+		matches := valueRE.FindAllStringSubmatch(scanner.Text(), -1)
 
 		if len(matches) == 1 {
 			value, err := strconv.ParseFloat(matches[0][2], 64)
@@ -0,0 +1,51 @@
+package muninpluginrunner
+
+import (
+	"testing"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/mock"
+)
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, newMuninPluginRunner())
+}
+
+func TestGather(t *testing.T) {
+	mock := mocktransport.NewMock().(*mocktransport.Mock)
+	mock.SetExec("/usr/share/munin/plugins/test", []byte(`load.value 0.52
+users.value 3
+temperature.value -12.5
+bytes.value 1.5e+06
+small.value 2E-3
+novalue
+broken.value abc
+xvalue 12
+`))
+
+	m := newMuninPluginRunner().(*MuninPluginRunner)
+	m.Command = "/usr/share/munin/plugins/test"
+
+	err := m.Gather(mock)
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	expected := []keyValue{
+		{"load", 0.52},
+		{"users", 3.0},
+		{"temperature", -12.5},
+		{"bytes", 1500000.0},
+		{"small", 0.002},
+	}
+
+	if len(m.kv) != len(expected) {
+		t.Fatalf("Got %d values, expected %d: %+v", len(m.kv), len(expected), m.kv)
+	}
+
+	for i, kv := range expected {
+		if m.kv[i] != kv {
+			t.Errorf("Value %d is %+v, expected %+v", i, m.kv[i], kv)
+		}
+	}
+}
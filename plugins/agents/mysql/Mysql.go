@@ -1,58 +1,69 @@
 package mysql
 
 import (
+	"database/sql"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/abrander/agento/plugins"
 	"github.com/abrander/agento/timeseries"
 )
 
+// Mysql will read status and variables from a MySQL server. Fields tagged
+// with counter are cumulative and will be converted to per-second rates by
+// Sub().
 type Mysql struct {
+	sampletime time.Time
+
 	//General
-	Connections        int64 `json:"c" stat:"Connections"`
-	AccessDeniedErrors int64 `json:"ade" stat:"Access_denied_errors"`
-	AbortedClients     int64 `json:"gac" stat:"Aborted_clients"`
-	AbortedConnects    int64 `json:"gabc" stat:"Aborted_connects"`
-	ThreadsConnected   int64 `json:"gtc" stat:"Threads_connected"`
-	BytesReceived      int64 `json:"gbr" stat:"Bytes_received"`
-	BytesSent          int64 `json:"gbs" stat:"Bytes_sent"`
+	Connections           float64 `json:"c" stat:"Connections" counter:"true"`
+	AccessDeniedErrors    float64 `json:"ade" stat:"Access_denied_errors" counter:"true"`
+	AbortedClients        float64 `json:"gac" stat:"Aborted_clients" counter:"true"`
+	AbortedConnects       float64 `json:"gabc" stat:"Aborted_connects" counter:"true"`
+	ThreadsConnected      int64   `json:"gtc" stat:"Threads_connected"`
+	ThreadsRunning        int64   `json:"gtr" stat:"Threads_running"`
+	MaxConnections        int64   `json:"gmc" stat:"max_connections"`
+	BytesReceived         float64 `json:"gbr" stat:"Bytes_received" counter:"true"`
+	BytesSent             float64 `json:"gbs" stat:"Bytes_sent" counter:"true"`
+	Queries               float64 `json:"gq" stat:"Queries" counter:"true"`
+	InnodbBufferPoolReads float64 `json:"ibpr" stat:"Innodb_buffer_pool_reads" counter:"true"`
 
 	//Binlog stuff
-	BinlogCacheDiskUse               int64 `json:"bcdu" stat:"Binlog_cache_disk_use"`
-	BinlogCacheUse                   int64 `json:"bcu" stat:"Binlog_cache_use"`
-	BinlogCommits                    int64 `json:"bc" stat:"Binlog_commits"`
-	BinlogGroupCommits               int64 `json:"bgc" stat:"Binlog_group_commits"`
-	BinlogGroupCommitTriggerCount    int64 `json:"bgctc" stat:"Binlog_group_commit_trigger_count"`
-	BinlogGroupCommitTriggerLockWait int64 `json:"bgctlw" stat:"Binlog_group_commit_trigger_lock_wait"`
-	BinlogGroupCommitTriggerTimeout  int64 `json:"bgctt" stat:"Binlog_group_commit_trigger_timeout"`
-	MaBinlogSize                     int64 `json:"mbs" stat:"ma_binlog_size"`
-	RelayLogSpace                    int64 `json:"rls" stat:"relay_log_space"`
+	BinlogCacheDiskUse               float64 `json:"bcdu" stat:"Binlog_cache_disk_use" counter:"true"`
+	BinlogCacheUse                   float64 `json:"bcu" stat:"Binlog_cache_use" counter:"true"`
+	BinlogCommits                    float64 `json:"bc" stat:"Binlog_commits" counter:"true"`
+	BinlogGroupCommits               float64 `json:"bgc" stat:"Binlog_group_commits" counter:"true"`
+	BinlogGroupCommitTriggerCount    float64 `json:"bgctc" stat:"Binlog_group_commit_trigger_count" counter:"true"`
+	BinlogGroupCommitTriggerLockWait float64 `json:"bgctlw" stat:"Binlog_group_commit_trigger_lock_wait" counter:"true"`
+	BinlogGroupCommitTriggerTimeout  float64 `json:"bgctt" stat:"Binlog_group_commit_trigger_timeout" counter:"true"`
+	MaBinlogSize                     int64   `json:"mbs" stat:"ma_binlog_size"`
+	RelayLogSpace                    int64   `json:"rls" stat:"relay_log_space"`
 
 	//query counters
-	ComDelete           int64 `json:"ccd" stat:"Com_delete"`
-	ComInsert           int64 `json:"cci" stat:"Com_insert"`
-	ComInsertSelect     int64 `json:"ccis" stat:"Com_insert_select"`
-	ComLoad             int64 `json:"ccl" stat:"Com_load"`
-	ComReplace          int64 `json:"ccr" stat:"Com_replace"`
-	ComReplaceSelect    int64 `json:"ccrs" stat:"Com_replace_select"`
-	ComSelect           int64 `json:"ccs" stat:"Com_select"`
-	ComUpdate           int64 `json:"ccu" stat:"Com_update"`
-	ComUpdateMulti      int64 `json:"ccum" stat:"Com_update_multi"`
-	SelectFullJoin      int64 `json:"csfj" stat:"Select_full_join"`
-	SelectFullRangeJoin int64 `json:"csfrj" stat:"Select_full_range_join"`
-	SelectRange         int64 `json:"csr" stat:"Select_range"`
-	SelectRangeCheck    int64 `json:"csrc" stat:"Select_range_check"`
-	SelectScan          int64 `json:"css" stat:"Select_scan"`
-	SlowQueries         int64 `json:"csq" stat:"Slow_queries"`
+	ComDelete           float64 `json:"ccd" stat:"Com_delete" counter:"true"`
+	ComInsert           float64 `json:"cci" stat:"Com_insert" counter:"true"`
+	ComInsertSelect     float64 `json:"ccis" stat:"Com_insert_select" counter:"true"`
+	ComLoad             float64 `json:"ccl" stat:"Com_load" counter:"true"`
+	ComReplace          float64 `json:"ccr" stat:"Com_replace" counter:"true"`
+	ComReplaceSelect    float64 `json:"ccrs" stat:"Com_replace_select" counter:"true"`
+	ComSelect           float64 `json:"ccs" stat:"Com_select" counter:"true"`
+	ComUpdate           float64 `json:"ccu" stat:"Com_update" counter:"true"`
+	ComUpdateMulti      float64 `json:"ccum" stat:"Com_update_multi" counter:"true"`
+	SelectFullJoin      float64 `json:"csfj" stat:"Select_full_join" counter:"true"`
+	SelectFullRangeJoin float64 `json:"csfrj" stat:"Select_full_range_join" counter:"true"`
+	SelectRange         float64 `json:"csr" stat:"Select_range" counter:"true"`
+	SelectRangeCheck    float64 `json:"csrc" stat:"Select_range_check" counter:"true"`
+	SelectScan          float64 `json:"css" stat:"Select_scan" counter:"true"`
+	SlowQueries         float64 `json:"csq" stat:"Slow_queries" counter:"true"`
 
 	//files and tables
-	TableOpenCache int64 `json:"toc" stat:"table_open_cache"`
-	OpenFiles      int64 `json:"of" stat:"Open_files"`
-	OpenTables     int64 `json:"ot" stat:"Open_tables"`
-	OpenedTables   int64 `json:"odt" stat:"Opened_tables"`
+	TableOpenCache int64   `json:"toc" stat:"table_open_cache"`
+	OpenFiles      int64   `json:"of" stat:"Open_files"`
+	OpenTables     int64   `json:"ot" stat:"Open_tables"`
+	OpenedTables   float64 `json:"odt" stat:"Opened_tables" counter:"true"`
 
 	//galera stuff
 	WsrepOutOfOrderApply                     float64 `json:"wao" stat:"wsrep_apply_oool"`
@@ -84,7 +95,7 @@ type Mysql struct {
 	WsrepReplicationLatencyStandardDeviation float64 `json:"ws"`
 	WsrepReplicationLatencySampleSize        int64   `json:"wn"`
 
-	DSN string `toml:"dsn" json:"dsn" description:"Mysql DSN"`
+	DSN string `toml:"dsn" json:"dsn" description:"Mysql DSN" required:"true"`
 }
 
 func init() {
@@ -95,6 +106,7 @@ func NewMysql() interface{} {
 	return new(Mysql)
 }
 
+// Gather will read global status and variables from the server.
 func (m *Mysql) Gather(transport plugins.Transport) error {
 	db, err := Dial(transport, m.DSN)
 	if err != nil {
@@ -103,8 +115,25 @@ func (m *Mysql) Gather(transport plugins.Transport) error {
 
 	defer db.Close()
 
-	rows, err := db.Query("SHOW GLOBAL STATUS")
+	return m.gather(db)
+}
+
+// gather will read global status and variables from db.
+func (m *Mysql) gather(db *sql.DB) error {
+	m.sampletime = time.Now()
+
+	err := m.query(db, "SHOW GLOBAL STATUS")
+	if err != nil {
+		return err
+	}
+
+	return m.query(db, "SHOW GLOBAL VARIABLES")
+}
 
+// query will execute query and assign every returned name/value pair to the
+// field with a matching stat tag.
+func (m *Mysql) query(db *sql.DB, query string) error {
+	rows, err := db.Query(query)
 	if err != nil {
 		return err
 	}
@@ -225,8 +254,55 @@ func (m *Mysql) Gather(transport plugins.Transport) error {
 	return nil
 }
 
+// Sub will return a copy of m where all counters are converted to per-second
+// rates since previous. Gauges are copied as is. An empty Mysql is returned
+// if previous is nil or no time has passed.
+func (m *Mysql) Sub(previous *Mysql) *Mysql {
+	diff := &Mysql{
+		DSN: m.DSN,
+	}
+
+	if previous == nil {
+		return diff
+	}
+
+	duration := m.sampletime.Sub(previous.sampletime)
+	if duration <= 0 {
+		return diff
+	}
+
+	factor := duration.Seconds()
+
+	diff.sampletime = m.sampletime
+
+	structType := reflect.TypeOf(m).Elem()
+	current := reflect.ValueOf(m).Elem()
+	prev := reflect.ValueOf(previous).Elem()
+	mutable := reflect.ValueOf(diff).Elem()
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+
+		// Skip unexported fields.
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if field.Tag.Get("counter") != "" {
+			rate := plugins.CounterRate(current.Field(i).Float(), prev.Field(i).Float(), factor)
+			mutable.Field(i).SetFloat(rate)
+
+			continue
+		}
+
+		mutable.Field(i).Set(current.Field(i))
+	}
+
+	return diff
+}
+
 func (m *Mysql) GetPoints() []*timeseries.Point {
-	points := make([]*timeseries.Point, 35, 63)
+	points := make([]*timeseries.Point, 39, 67)
 
 	points[0] = plugins.SimplePoint("mysql.Connections", m.Connections)
 	points[1] = plugins.SimplePoint("mysql.AccessDeniedErrors", m.AccessDeniedErrors)
@@ -267,6 +343,11 @@ func (m *Mysql) GetPoints() []*timeseries.Point {
 	points[33] = plugins.SimplePoint("mysql.OpenTables", m.OpenTables)
 	points[34] = plugins.SimplePoint("mysql.OpenedTables", m.OpenedTables)
 
+	points[35] = plugins.SimplePoint("mysql.QueriesPerSec", m.Queries)
+	points[36] = plugins.SimplePoint("mysql.ThreadsRunning", m.ThreadsRunning)
+	points[37] = plugins.SimplePoint("mysql.MaxConnections", m.MaxConnections)
+	points[38] = plugins.SimplePoint("mysql.InnodbBufferPoolReads", m.InnodbBufferPoolReads)
+
 	// Only return these points if we're actually in a Galera-cluster. If the
 	// cluster size is zero we assume that no cluster is active.
 	if m.WsrepClusterSize > 0 {
@@ -345,6 +426,10 @@ func (m *Mysql) GetDoc() *plugins.Doc {
 	doc.AddMeasurement("mysql.OpenFiles", "The number of files that are open. This count includes regular files opened by the server. It does not include other types of files such as sockets or pipes.", "")
 	doc.AddMeasurement("mysql.OpenTables", "The number of tables that are open.", "")
 	doc.AddMeasurement("mysql.OpenedTables", "The number of tables that have been opened.", "")
+	doc.AddMeasurement("mysql.QueriesPerSec", "The number of statements executed by the server per second.", "/s")
+	doc.AddMeasurement("mysql.ThreadsRunning", "The number of threads that are not sleeping.", "n")
+	doc.AddMeasurement("mysql.MaxConnections", "The maximum permitted number of simultaneous client connections.", "n")
+	doc.AddMeasurement("mysql.InnodbBufferPoolReads", "The number of logical reads that InnoDB could not satisfy from the buffer pool, and had to read directly from disk.", "/s")
 	doc.AddMeasurement("mysql.WsrepOutOfOrderApply", "How often write-set was so slow to apply that write-set with higher seqno’s were applied earlier.", "")
 	doc.AddMeasurement("mysql.WsrepApplyWindow", "Average distance between highest and lowest concurrently applied seqno.", "")
 	doc.AddMeasurement("mysql.WsrepCertDistance", "Average distance between highest and lowest seqno value that can be possibly applied in parallel (potential degree of parallelization).", "")
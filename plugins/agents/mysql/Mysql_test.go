@@ -1,11 +1,117 @@
 package mysql
 
 import (
+	"database/sql"
+	"errors"
 	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
 
 	"github.com/abrander/agento/plugins"
 )
 
+// mockStats will return a database answering SHOW GLOBAL STATUS and SHOW
+// GLOBAL VARIABLES once.
+func mockStats(t *testing.T, queries int64, threadsRunning int64) *sql.DB {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() failed: %s", err.Error())
+	}
+
+	mock.ExpectQuery("SHOW GLOBAL STATUS").WillReturnRows(
+		sqlmock.NewRows([]string{"Variable_name", "Value"}).
+			AddRow("Aborted_connects", "3").
+			AddRow("Queries", queries).
+			AddRow("Slow_queries", "10").
+			AddRow("Threads_connected", "12").
+			AddRow("Threads_running", threadsRunning).
+			AddRow("Innodb_buffer_pool_reads", "1000").
+			AddRow("Uptime", "3600"),
+	)
+
+	mock.ExpectQuery("SHOW GLOBAL VARIABLES").WillReturnRows(
+		sqlmock.NewRows([]string{"Variable_name", "Value"}).
+			AddRow("max_connections", "151").
+			AddRow("table_open_cache", "2000").
+			AddRow("version", "8.0.36"),
+	)
+
+	return db
+}
+
 func TestAgent(t *testing.T) {
 	plugins.GenericAgentTest(t, NewMysql())
 }
+
+func TestGather(t *testing.T) {
+	db := mockStats(t, 5000, 2)
+	defer db.Close()
+
+	m := &Mysql{}
+	err := m.gather(db)
+	if err != nil {
+		t.Fatalf("gather() failed: %s", err.Error())
+	}
+
+	if m.Queries != 5000 || m.ThreadsConnected != 12 || m.ThreadsRunning != 2 || m.AbortedConnects != 3 {
+		t.Errorf("Wrong status: %+v", m)
+	}
+
+	if m.MaxConnections != 151 || m.TableOpenCache != 2000 {
+		t.Errorf("Variables not read: %+v", m)
+	}
+}
+
+func TestGatherError(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	mock.ExpectQuery("SHOW GLOBAL STATUS").WillReturnError(errors.New("access denied"))
+
+	m := &Mysql{}
+	err := m.gather(db)
+	if err == nil {
+		t.Fatalf("gather() did not return error")
+	}
+}
+
+func TestSub(t *testing.T) {
+	db := mockStats(t, 5000, 2)
+	defer db.Close()
+
+	previous := &Mysql{}
+	previous.gather(db)
+
+	db = mockStats(t, 5500, 4)
+	defer db.Close()
+
+	current := &Mysql{}
+	current.gather(db)
+	current.sampletime = previous.sampletime.Add(10 * time.Second)
+
+	diff := current.Sub(previous)
+	if diff.Queries != 50.0 {
+		t.Errorf("Got %f queries/s, expected 50", diff.Queries)
+	}
+
+	if diff.SlowQueries != 0.0 {
+		t.Errorf("Got %f slow queries/s, expected 0", diff.SlowQueries)
+	}
+
+	if diff.ThreadsRunning != 4 || diff.MaxConnections != 151 {
+		t.Errorf("Gauges not copied: %+v", diff)
+	}
+
+	// Counter reset after a server restart.
+	previous.sampletime = current.sampletime.Add(time.Second)
+	diff = previous.Sub(current)
+	if diff.Queries != 0.0 {
+		t.Errorf("Counter reset resulted in %f queries/s", diff.Queries)
+	}
+
+	diff = current.Sub(nil)
+	if diff.Queries != 0.0 || diff.ThreadsRunning != 0 {
+		t.Errorf("Sub(nil) returned values")
+	}
+}
@@ -18,7 +18,7 @@ type Connection struct {
 type MysqlSlave struct {
 	Connections []Connection `json:"c"`
 
-	DSN string `toml:"dsn" json:"dsn" description:"Mysql DSN"`
+	DSN string `toml:"dsn" json:"dsn" description:"Mysql DSN" required:"true"`
 }
 
 func init() {
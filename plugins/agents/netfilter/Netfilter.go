@@ -1,12 +1,10 @@
 package netfilter
 
 import (
-	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
-	"syscall"
 
 	"github.com/abrander/agento/configuration"
 	"github.com/abrander/agento/plugins"
@@ -24,51 +22,103 @@ func newNetfilter() interface{} {
 // Netfilter will collect information about the Linux kernel Netfilter.
 type Netfilter struct {
 	ConnTrackCount int64 `json:"c"`
+	ConnTrackMax   int64 `json:"m"`
+}
+
+// conntrackPaths lists the directories and prefixes used by nf_conntrack and
+// the older ip_conntrack.
+var conntrackPaths = []struct {
+	dir    string
+	prefix string
+}{
+	{"/sys/net/netfilter", "nf_conntrack_"},
+	{"/sys/net/ipv4/netfilter", "ip_conntrack_"},
+}
+
+// readInt will read a single integer from path.
+func readInt(transport plugins.Transport, path string) (int64, error) {
+	contents, err := transport.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	trimmed := strings.TrimSpace(string(contents))
+
+	return strconv.ParseInt(trimmed, 10, 64)
 }
 
 // Gather will read stats from /proc
 func (n *Netfilter) Gather(transport plugins.Transport) error {
-	path := filepath.Join(configuration.ProcPath, "/sys/net/netfilter/nf_conntrack_count")
-	contents, err := transport.ReadFile(path)
+	n.ConnTrackCount = -1
+	n.ConnTrackMax = -1
+
+	for _, c := range conntrackPaths {
+		dir := filepath.Join(configuration.ProcPath, c.dir)
+
+		count, err := readInt(transport, filepath.Join(dir, c.prefix+"count"))
 
-	// If the file doesn't exist, we assume that netfilter is not tracking
-	// connections. We set the value to -1 and abort.
-	if p, ok := err.(*os.PathError); ok && p.Err == syscall.ENOENT {
-		n.ConnTrackCount = -1
+		// If the file doesn't exist, try the next one.
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		max, err := readInt(transport, filepath.Join(dir, c.prefix+"max"))
+		if err != nil {
+			return err
+		}
+
+		n.ConnTrackCount = count
+		n.ConnTrackMax = max
 
 		return nil
 	}
 
-	if err != nil {
-		fmt.Printf("%T %+v\n", err, err)
-		return err
-	}
+	// If no file exists, we assume that netfilter is not tracking
+	// connections. The values are left at -1.
+	return nil
+}
 
-	trimmed := strings.TrimSpace(string(contents))
-	n.ConnTrackCount, err = strconv.ParseInt(trimmed, 10, 64)
-	if err != nil {
-		return err
+// UsedPercent returns the percentage of conntrack slots in use.
+func (n *Netfilter) UsedPercent() float64 {
+	if n.ConnTrackMax <= 0 || n.ConnTrackCount < 0 {
+		return 0.0
 	}
 
-	return nil
+	return float64(n.ConnTrackCount) / float64(n.ConnTrackMax) * 100.0
 }
 
-// GetPoints will return a single point for now.
+// GetPoints will return connection tracking usage. If connections are not
+// tracked, only netfilter.ConnectionsTracked is returned.
 func (n *Netfilter) GetPoints() []*timeseries.Point {
-	points := make([]*timeseries.Point, 1)
+	points := []*timeseries.Point{
+		plugins.SimplePoint("netfilter.ConnectionsTracked", n.ConnTrackCount),
+	}
 
-	points[0] = plugins.SimplePoint("netfilter.ConnectionsTracked", n.ConnTrackCount)
+	if n.ConnTrackCount < 0 {
+		return points
+	}
 
-	return points
+	return append(points,
+		plugins.SimplePoint("conntrack.Count", n.ConnTrackCount),
+		plugins.SimplePoint("conntrack.Max", n.ConnTrackMax),
+		plugins.SimplePoint("conntrack.UsedPercent", n.UsedPercent()),
+	)
 }
 
-// GetDoc tries to explain our single point.
+// GetDoc tries to explain our points.
 func (n *Netfilter) GetDoc() *plugins.Doc {
 	doc := plugins.NewDoc("Netfilter usage")
 
 	doc.AddMeasurement("netfilter.ConnectionsTracked",
 		"The number currently tracked connections (or -1 if tracking is disabled)",
 		"n")
+	doc.AddMeasurement("conntrack.Count", "The number of currently tracked connections", "n")
+	doc.AddMeasurement("conntrack.Max", "The maximum number of tracked connections", "n")
+	doc.AddMeasurement("conntrack.UsedPercent", "Percentage of the connection tracking table in use", "%")
 
 	return doc
 }
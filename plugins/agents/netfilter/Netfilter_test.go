@@ -1,11 +1,72 @@
 package netfilter
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/abrander/agento/configuration"
 	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
 )
 
 func TestAgent(t *testing.T) {
 	plugins.GenericAgentTest(t, newNetfilter())
 }
+
+func TestGather(t *testing.T) {
+	cases := []struct {
+		dir     string
+		prefix  string
+		count   int64
+		max     int64
+		points  int
+		percent float64
+	}{
+		{"", "", -1, -1, 1, 0.0},
+		{"sys/net/netfilter", "nf_conntrack_", 1024, 4096, 4, 25.0},
+		{"sys/net/ipv4/netfilter", "ip_conntrack_", 512, 1024, 4, 50.0},
+	}
+
+	transport := localtransport.NewLocalTransport().(plugins.Transport)
+	procPath := configuration.ProcPath
+	defer func() { configuration.ProcPath = procPath }()
+
+	for i, c := range cases {
+		dir, err := ioutil.TempDir("", "netfilter")
+		if err != nil {
+			t.Fatalf("TempDir() failed: %s", err.Error())
+		}
+		defer os.RemoveAll(dir)
+
+		if c.dir != "" {
+			os.MkdirAll(filepath.Join(dir, c.dir), 0755)
+			ioutil.WriteFile(filepath.Join(dir, c.dir, c.prefix+"count"), []byte(fmt.Sprintf("%d\n", c.count)), 0644)
+			ioutil.WriteFile(filepath.Join(dir, c.dir, c.prefix+"max"), []byte(fmt.Sprintf("%d\n", c.max)), 0644)
+		}
+
+		configuration.ProcPath = dir
+
+		n := newNetfilter().(*Netfilter)
+		err = n.Gather(transport)
+		if err != nil {
+			t.Fatalf("%d: Gather() failed: %s", i, err.Error())
+		}
+
+		if n.ConnTrackCount != c.count || n.ConnTrackMax != c.max {
+			t.Errorf("%d: Got %+v, expected count %d and max %d", i, *n, c.count, c.max)
+		}
+
+		if n.UsedPercent() != c.percent {
+			t.Errorf("%d: Got %f%% used, expected %f%%", i, n.UsedPercent(), c.percent)
+		}
+
+		if len(n.GetPoints()) != c.points {
+			t.Errorf("%d: Got %d points, expected %d", i, len(n.GetPoints()), c.points)
+		}
+
+		plugins.GenericAgentTest(t, n)
+	}
+}
@@ -17,6 +17,8 @@ func init() {
 }
 
 type NetStats struct {
+	IncludeLoopback bool `toml:"includeLoopback" json:"includeLoopback" description:"Include the loopback interface"`
+
 	sampletime       time.Time `json:"-"`
 	previousNetStats *NetStats
 	Interfaces       map[string]*SingleNetStats `json:"ifs"`
@@ -47,15 +49,52 @@ func (stat *NetStats) Gather(transport plugins.Transport) error {
 		}
 
 		if strings.HasSuffix(data[0], ":") {
+			name := strings.TrimSuffix(data[0], ":")
+			if name == "lo" && !stat.IncludeLoopback {
+				continue
+			}
+
 			s := SingleNetStats{}
 			s.ReadArray(data)
-			stat.Interfaces[strings.TrimSuffix(data[0], ":")] = &s
+			stat.Interfaces[name] = &s
 		}
 	}
 
 	return nil
 }
 
+// Sub will calculate per-second rates between previous and n. Like cpustats,
+// an empty NetStats is returned if previous is nil or no time has passed.
+// Interfaces not present in both samples are left out.
+func (n *NetStats) Sub(previous *NetStats) *NetStats {
+	diff := &NetStats{
+		IncludeLoopback: n.IncludeLoopback,
+		Interfaces:      make(map[string]*SingleNetStats),
+	}
+
+	if previous == nil {
+		return diff
+	}
+
+	duration := n.sampletime.Sub(previous.sampletime)
+	if duration <= 0 {
+		return diff
+	}
+
+	factor := duration.Seconds()
+
+	for key, value := range n.Interfaces {
+		prev, found := previous.Interfaces[key]
+		if found {
+			diff.Interfaces[key] = value.Sub(prev, factor)
+		}
+	}
+
+	diff.sampletime = n.sampletime
+
+	return diff
+}
+
 func (n *NetStats) GetPoints() []*timeseries.Point {
 	points := make([]*timeseries.Point, len(n.Interfaces)*16)
 
@@ -2,10 +2,130 @@ package netstat
 
 import (
 	"testing"
+	"time"
 
 	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/mock"
+)
+
+var (
+	testData1 = []byte(`Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo: 1000000   10000    0    0    0     0          0         0  1000000   10000    0    0    0     0       0          0
+  eth0: 2000000   20000    1    2    0     0          0         0  3000000   30000    3    4    0     0       0          0
+`)
+
+	testData2 = []byte(`Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo: 1000000   10000    0    0    0     0          0         0  1000000   10000    0    0    0     0       0          0
+  eth0: 2002000   20020    1    2    0     0          0         0  3000000   30000    3    4    0     0       0          0
+`)
 )
 
 func TestAgent(t *testing.T) {
 	plugins.GenericAgentTest(t, NewNetStats())
 }
+
+func TestGatherLoopback(t *testing.T) {
+	mock := mocktransport.NewMock().(*mocktransport.Mock)
+	mock.SetFile("/proc/net/dev", testData1)
+
+	stats := NewNetStats().(*NetStats)
+	err := stats.Gather(mock)
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if _, found := stats.Interfaces["lo"]; found {
+		t.Errorf("lo was not excluded by default")
+	}
+
+	stats.IncludeLoopback = true
+	stats.Gather(mock)
+	if _, found := stats.Interfaces["lo"]; !found {
+		t.Errorf("lo was excluded with IncludeLoopback set")
+	}
+}
+
+func TestSub(t *testing.T) {
+	mock := mocktransport.NewMock().(*mocktransport.Mock)
+
+	previous := NewNetStats().(*NetStats)
+	mock.SetFile("/proc/net/dev", testData1)
+	previous.Gather(mock)
+
+	current := NewNetStats().(*NetStats)
+	mock.SetFile("/proc/net/dev", testData2)
+	current.Gather(mock)
+	current.sampletime = previous.sampletime.Add(2 * time.Second)
+
+	diff := current.Sub(previous)
+	eth0, found := diff.Interfaces["eth0"]
+	if !found {
+		t.Fatalf("eth0 not found in diff")
+	}
+
+	if eth0.RxBytes != 1000.0 {
+		t.Errorf("RxBytes is %f, should be 1000", eth0.RxBytes)
+	}
+
+	if eth0.RxPackets != 10.0 {
+		t.Errorf("RxPackets is %f, should be 10", eth0.RxPackets)
+	}
+
+	// Swapping the samples simulates a counter reset.
+	previous.sampletime = current.sampletime.Add(time.Second)
+	diff = previous.Sub(current)
+	if diff.Interfaces["eth0"].RxBytes != 0.0 {
+		t.Errorf("Counter reset resulted in RxBytes %f", diff.Interfaces["eth0"].RxBytes)
+	}
+
+	// No time has passed.
+	current.sampletime = previous.sampletime
+	diff = current.Sub(previous)
+	if len(diff.Interfaces) != 0 {
+		t.Errorf("Sub() returned interfaces for a zero duration")
+	}
+}
+
+func TestRates(t *testing.T) {
+	mock := mocktransport.NewMock().(*mocktransport.Mock)
+	rates := plugins.NewRates()
+
+	previous := NewNetStats().(*NetStats)
+	mock.SetFile("/proc/net/dev", testData1)
+	previous.Gather(mock)
+
+	_, ok := rates.Apply("netio", previous)
+	if ok {
+		t.Fatalf("Got rates from a single sample")
+	}
+
+	// Pretend the first sample was taken two seconds ago.
+	previous.sampletime = previous.sampletime.Add(-2 * time.Second)
+
+	current := NewNetStats().(*NetStats)
+	mock.SetFile("/proc/net/dev", testData2)
+	current.Gather(mock)
+
+	agent, ok := rates.Apply("netio", current)
+	if !ok {
+		t.Fatalf("Got no rates from two samples")
+	}
+
+	for _, point := range agent.GetPoints() {
+		if point.Name != "net.RxBytes" || point.Tags["interface"] != "eth0" {
+			continue
+		}
+
+		// The samples are a few microseconds more than two seconds apart.
+		value := point.Fields["value"].(float64)
+		if value > 1000.0 || value < 999.0 {
+			t.Errorf("RxBytes is %f, should be 1000", value)
+		}
+
+		return
+	}
+
+	t.Errorf("net.RxBytes for eth0 not found")
+}
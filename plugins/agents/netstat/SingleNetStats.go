@@ -144,3 +144,30 @@ func (s *SingleNetStats) UnmarshalJSON(b []byte) error {
 
 	return err
 }
+
+func (s *SingleNetStats) Sub(previous *SingleNetStats, factor float64) *SingleNetStats {
+	diff := SingleNetStats{}
+
+	if factor <= 0 {
+		return &diff
+	}
+
+	diff.RxBytes = plugins.CounterRate(s.RxBytes, previous.RxBytes, factor)
+	diff.RxPackets = plugins.CounterRate(s.RxPackets, previous.RxPackets, factor)
+	diff.RxErrors = plugins.CounterRate(s.RxErrors, previous.RxErrors, factor)
+	diff.RxDropped = plugins.CounterRate(s.RxDropped, previous.RxDropped, factor)
+	diff.RxFifo = plugins.CounterRate(s.RxFifo, previous.RxFifo, factor)
+	diff.RxFrame = plugins.CounterRate(s.RxFrame, previous.RxFrame, factor)
+	diff.RxCompressed = plugins.CounterRate(s.RxCompressed, previous.RxCompressed, factor)
+	diff.RxMulticast = plugins.CounterRate(s.RxMulticast, previous.RxMulticast, factor)
+	diff.TxBytes = plugins.CounterRate(s.TxBytes, previous.TxBytes, factor)
+	diff.TxPackets = plugins.CounterRate(s.TxPackets, previous.TxPackets, factor)
+	diff.TxErrors = plugins.CounterRate(s.TxErrors, previous.TxErrors, factor)
+	diff.TxDropped = plugins.CounterRate(s.TxDropped, previous.TxDropped, factor)
+	diff.TxFifo = plugins.CounterRate(s.TxFifo, previous.TxFifo, factor)
+	diff.TxCollisions = plugins.CounterRate(s.TxCollisions, previous.TxCollisions, factor)
+	diff.TxCarrier = plugins.CounterRate(s.TxCarrier, previous.TxCarrier, factor)
+	diff.TxCompressed = plugins.CounterRate(s.TxCompressed, previous.TxCompressed, factor)
+
+	return &diff
+}
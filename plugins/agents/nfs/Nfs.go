@@ -0,0 +1,249 @@
+package nfs
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("nfs", NewNfs)
+}
+
+type (
+	// Nfs will read NFS client statistics per mount from
+	// /proc/self/mountstats. Counters are cumulative and will be converted
+	// to per-second rates by Sub().
+	Nfs struct {
+		sampletime time.Time
+
+		Mounts map[string]*Mount `json:"m"`
+	}
+
+	// Mount holds the statistics of a single NFS mount.
+	Mount struct {
+		Export     string         `json:"e"`
+		MountPoint string         `json:"p"`
+		ReadBytes  float64        `json:"r"`
+		WriteBytes float64        `json:"w"`
+		Ops        map[string]*Op `json:"o"`
+
+		// RttAvg is the average round trip time in milliseconds of all
+		// operations between two samples. It's only set by Sub().
+		RttAvg float64 `json:"a"`
+	}
+
+	// Op holds the statistics of a single RPC operation.
+	Op struct {
+		Ops float64 `json:"o"`
+		Rtt float64 `json:"r"`
+	}
+)
+
+// NewNfs will return a new Nfs.
+func NewNfs() interface{} {
+	return new(Nfs)
+}
+
+// Gather will read /proc/self/mountstats.
+func (n *Nfs) Gather(transport plugins.Transport) error {
+	file, err := transport.Open(filepath.Join(configuration.ProcPath, "/self/mountstats"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	n.sampletime = time.Now()
+
+	return n.parse(file)
+}
+
+// parse will parse mountstats from r. Only nfs and nfs4 mounts are read.
+func (n *Nfs) parse(r io.Reader) error {
+	n.Mounts = make(map[string]*Mount)
+
+	var mount *Mount
+	perOp := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		// device nas:/export mounted on /home with fstype nfs4 statvers=1.1
+		if fields[0] == "device" {
+			mount = nil
+			perOp = false
+
+			if len(fields) >= 8 && (fields[7] == "nfs" || fields[7] == "nfs4") {
+				mount = &Mount{
+					Export:     fields[1],
+					MountPoint: fields[4],
+					Ops:        make(map[string]*Op),
+				}
+				n.Mounts[mount.MountPoint] = mount
+			}
+
+			continue
+		}
+
+		if mount == nil {
+			continue
+		}
+
+		switch {
+		case fields[0] == "bytes:" && len(fields) >= 7:
+			// Bytes read and written to the server, not counting the
+			// page cache.
+			mount.ReadBytes, _ = strconv.ParseFloat(fields[5], 64)
+			mount.WriteBytes, _ = strconv.ParseFloat(fields[6], 64)
+		case fields[0] == "per-op":
+			perOp = true
+		case perOp && strings.HasSuffix(fields[0], ":") && len(fields) >= 8:
+			// op: ops transmissions timeouts bytes_sent bytes_recv
+			// queue_ms rtt_ms execute_ms [errors]
+			ops, _ := strconv.ParseFloat(fields[1], 64)
+			rtt, _ := strconv.ParseFloat(fields[7], 64)
+
+			// Leave out operations never used to save points.
+			if ops == 0 {
+				continue
+			}
+
+			mount.Ops[strings.TrimSuffix(fields[0], ":")] = &Op{Ops: ops, Rtt: rtt}
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Sub will calculate per-second rates and the average round trip time for
+// all mounts present in both previous and n. An empty Nfs is returned if
+// previous is nil or no time has passed.
+func (n *Nfs) Sub(previous *Nfs) *Nfs {
+	diff := &Nfs{
+		Mounts: make(map[string]*Mount),
+	}
+
+	if previous == nil {
+		return diff
+	}
+
+	duration := n.sampletime.Sub(previous.sampletime)
+	if duration <= 0 {
+		return diff
+	}
+
+	factor := duration.Seconds()
+
+	diff.sampletime = n.sampletime
+
+	for key, mount := range n.Mounts {
+		prev, found := previous.Mounts[key]
+		if !found {
+			continue
+		}
+
+		m := &Mount{
+			Export:     mount.Export,
+			MountPoint: mount.MountPoint,
+			ReadBytes:  plugins.CounterRate(mount.ReadBytes, prev.ReadBytes, factor),
+			WriteBytes: plugins.CounterRate(mount.WriteBytes, prev.WriteBytes, factor),
+			Ops:        make(map[string]*Op),
+		}
+
+		var ops, rtt float64
+		for name, op := range mount.Ops {
+			prevOp, found := prev.Ops[name]
+			if !found || op.Ops < prevOp.Ops || op.Rtt < prevOp.Rtt {
+				continue
+			}
+
+			m.Ops[name] = &Op{
+				Ops: plugins.CounterRate(op.Ops, prevOp.Ops, factor),
+			}
+
+			ops += op.Ops - prevOp.Ops
+			rtt += op.Rtt - prevOp.Rtt
+		}
+
+		if ops > 0 {
+			m.RttAvg = rtt / ops
+		}
+
+		diff.Mounts[key] = m
+	}
+
+	return diff
+}
+
+// GetPoints will return bytes and round trip time per mount, and the rate of
+// each RPC operation used since mount.
+func (n *Nfs) GetPoints() []*timeseries.Point {
+	keys := make([]string, 0, len(n.Mounts))
+	for key := range n.Mounts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var points []*timeseries.Point
+
+	for _, key := range keys {
+		mount := n.Mounts[key]
+		tags := map[string]string{
+			"export":     mount.Export,
+			"mountpoint": mount.MountPoint,
+		}
+
+		points = append(points,
+			plugins.PointWithTags("nfs.ReadBytes", mount.ReadBytes, tags),
+			plugins.PointWithTags("nfs.WriteBytes", mount.WriteBytes, tags),
+			plugins.PointWithTags("nfs.RttAvg", mount.RttAvg, tags),
+		)
+
+		names := make([]string, 0, len(mount.Ops))
+		for name := range mount.Ops {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			points = append(points, plugins.PointWithTags("nfs.Ops", mount.Ops[name].Ops, map[string]string{
+				"export":     mount.Export,
+				"mountpoint": mount.MountPoint,
+				"op":         name,
+			}))
+		}
+	}
+
+	return points
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (n *Nfs) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("NFS client statistics")
+
+	doc.AddMeasurement("nfs.ReadBytes", "Bytes read from the server", "b/s")
+	doc.AddMeasurement("nfs.WriteBytes", "Bytes written to the server", "b/s")
+	doc.AddMeasurement("nfs.RttAvg", "Average round trip time of RPC operations", "ms")
+	doc.AddMeasurement("nfs.Ops", "RPC operations", "/s")
+
+	doc.AddTag("export", "The exported filesystem (like server:/export)")
+	doc.AddTag("mountpoint", "Where the export is mounted")
+	doc.AddTag("op", "The RPC operation (only on nfs.Ops)")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*Nfs)(nil)
@@ -0,0 +1,150 @@
+package nfs
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+)
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewNfs())
+}
+
+func TestParse(t *testing.T) {
+	file, err := os.Open("testdata/self/mountstats")
+	if err != nil {
+		t.Fatalf("Open() failed: %s", err.Error())
+	}
+	defer file.Close()
+
+	n := NewNfs().(*Nfs)
+	err = n.parse(file)
+	if err != nil {
+		t.Fatalf("parse() failed: %s", err.Error())
+	}
+
+	if len(n.Mounts) != 2 {
+		t.Fatalf("Got %d mounts, expected 2", len(n.Mounts))
+	}
+
+	home := n.Mounts["/home"]
+	if home == nil || home.Export != "nas:/export/home" {
+		t.Fatalf("Wrong /home mount: %+v", home)
+	}
+
+	if home.ReadBytes != 4096000 || home.WriteBytes != 2048000 {
+		t.Errorf("Wrong bytes for /home: %+v", *home)
+	}
+
+	if len(home.Ops) != 4 {
+		t.Errorf("Got %d operations for /home, expected 4", len(home.Ops))
+	}
+
+	if read := home.Ops["READ"]; read == nil || read.Ops != 1000 || read.Rtt != 5000 {
+		t.Errorf("Wrong READ for /home: %+v", read)
+	}
+
+	data := n.Mounts["/srv/data"]
+	if data == nil || data.Export != "nas:/export/data" || len(data.Ops) != 1 {
+		t.Fatalf("Wrong /srv/data mount: %+v", data)
+	}
+
+	// 2 mounts with 3 points each, and 4+1 operations.
+	if len(n.GetPoints()) != 11 {
+		t.Errorf("Got %d points, expected 11", len(n.GetPoints()))
+	}
+}
+
+func TestGather(t *testing.T) {
+	procPath := configuration.ProcPath
+	configuration.ProcPath = "testdata"
+	defer func() { configuration.ProcPath = procPath }()
+
+	n := NewNfs().(*Nfs)
+	err := n.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if len(n.Mounts) != 2 {
+		t.Fatalf("Got %d mounts, expected 2", len(n.Mounts))
+	}
+
+	plugins.GenericAgentTest(t, n)
+}
+
+func TestSub(t *testing.T) {
+	now := time.Now()
+
+	previous := &Nfs{
+		sampletime: now,
+		Mounts: map[string]*Mount{
+			"/home": {
+				Export:     "nas:/export/home",
+				MountPoint: "/home",
+				ReadBytes:  1000,
+				WriteBytes: 2000,
+				Ops: map[string]*Op{
+					"READ":  {Ops: 100, Rtt: 500},
+					"WRITE": {Ops: 50, Rtt: 500},
+				},
+			},
+			"/gone": {Ops: map[string]*Op{}},
+		},
+	}
+
+	current := &Nfs{
+		sampletime: now.Add(10 * time.Second),
+		Mounts: map[string]*Mount{
+			"/home": {
+				Export:     "nas:/export/home",
+				MountPoint: "/home",
+				ReadBytes:  11000,
+				WriteBytes: 1000,
+				Ops: map[string]*Op{
+					"READ":  {Ops: 200, Rtt: 1500},
+					"WRITE": {Ops: 150, Rtt: 3500},
+					"OPEN":  {Ops: 5, Rtt: 5},
+				},
+			},
+		},
+	}
+
+	diff := current.Sub(previous)
+
+	if len(diff.Mounts) != 1 {
+		t.Fatalf("Got %d mounts, expected 1", len(diff.Mounts))
+	}
+
+	home := diff.Mounts["/home"]
+
+	if home.ReadBytes != 1000.0 {
+		t.Errorf("Got ReadBytes %f, expected 1000", home.ReadBytes)
+	}
+
+	// The counter went backwards, probably a remount.
+	if home.WriteBytes != 0.0 {
+		t.Errorf("Got WriteBytes %f after counter reset, expected 0", home.WriteBytes)
+	}
+
+	if home.Ops["READ"].Ops != 10.0 || home.Ops["WRITE"].Ops != 10.0 {
+		t.Errorf("Wrong operation rates: READ %f, WRITE %f", home.Ops["READ"].Ops, home.Ops["WRITE"].Ops)
+	}
+
+	// (1000 + 3000) ms over 200 operations.
+	if home.RttAvg != 20.0 {
+		t.Errorf("Got RttAvg %f, expected 20", home.RttAvg)
+	}
+
+	if len(current.Sub(nil).Mounts) != 0 {
+		t.Errorf("Sub(nil) returned rates")
+	}
+
+	if len(current.Sub(current).Mounts) != 0 {
+		t.Errorf("Sub() returned rates when no time has passed")
+	}
+}
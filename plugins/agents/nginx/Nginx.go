@@ -2,7 +2,9 @@ package nginx
 
 import (
 	"fmt"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/abrander/agento/plugins"
 	"github.com/abrander/agento/timeseries"
@@ -14,7 +16,10 @@ func init() {
 
 // Nginx will retrieve stub status.
 type Nginx struct {
-	URL string `toml:"url" description:"Nginx status URL"`
+	URL     string `toml:"url" description:"Nginx status URL" required:"true"`
+	Timeout int    `toml:"timeout" json:"timeout" description:"Request timeout in seconds (default 10)"`
+
+	sampletime time.Time
 
 	ActiveConnections int
 	Accepts           int
@@ -23,6 +28,11 @@ type Nginx struct {
 	Reading           int
 	Writing           int
 	Waiting           int
+
+	// The rates are only set by Sub().
+	AcceptsPerSecond  float64
+	HandledPerSecond  float64
+	RequestsPerSecond float64
 }
 
 const stubFormat = `Active connections: %d
@@ -35,9 +45,15 @@ func newNginx() interface{} {
 	return new(Nginx)
 }
 
-// Gather will measure how many bytes can be read from /dev/null.
+// Gather will retrieve and parse the stub status page.
 func (n *Nginx) Gather(transport plugins.Transport) error {
 	client := plugins.HTTPClient(transport)
+
+	client.Timeout = 10 * time.Second
+	if n.Timeout > 0 {
+		client.Timeout = time.Duration(n.Timeout) * time.Second
+	}
+
 	resp, err := client.Get(n.URL)
 	if err != nil {
 		return err
@@ -49,7 +65,14 @@ func (n *Nginx) Gather(transport plugins.Transport) error {
 		return fmt.Errorf("%s returned %d", n.URL, resp.StatusCode)
 	}
 
-	fmt.Fscanf(resp.Body, stubFormat,
+	n.sampletime = time.Now()
+
+	return n.parse(resp.Body)
+}
+
+// parse will parse a stub status page.
+func (n *Nginx) parse(r io.Reader) error {
+	_, err := fmt.Fscanf(r, stubFormat,
 		&n.ActiveConnections,
 		&n.Accepts,
 		&n.Handled,
@@ -59,12 +82,48 @@ func (n *Nginx) Gather(transport plugins.Transport) error {
 		&n.Waiting,
 	)
 
-	return nil
+	return err
+}
+
+// Sub will calculate per-second rates for accepts, handled and requests
+// between previous and n. The counters and connection gauges are copied as
+// is. Like cpustats, an empty Nginx is returned if previous is nil or no time
+// has passed.
+func (n *Nginx) Sub(previous *Nginx) *Nginx {
+	diff := &Nginx{
+		URL:     n.URL,
+		Timeout: n.Timeout,
+	}
+
+	if previous == nil {
+		return diff
+	}
+
+	duration := n.sampletime.Sub(previous.sampletime)
+	if duration <= 0 {
+		return diff
+	}
+
+	factor := duration.Seconds()
+
+	diff.sampletime = n.sampletime
+	diff.ActiveConnections = n.ActiveConnections
+	diff.Accepts = n.Accepts
+	diff.Handled = n.Handled
+	diff.Requests = n.Requests
+	diff.AcceptsPerSecond = plugins.CounterRate(float64(n.Accepts), float64(previous.Accepts), factor)
+	diff.HandledPerSecond = plugins.CounterRate(float64(n.Handled), float64(previous.Handled), factor)
+	diff.RequestsPerSecond = plugins.CounterRate(float64(n.Requests), float64(previous.Requests), factor)
+	diff.Reading = n.Reading
+	diff.Writing = n.Writing
+	diff.Waiting = n.Waiting
+
+	return diff
 }
 
-// GetPoints will return exactly one point. The number of bytes read.
+// GetPoints will return connection counters, rates and gauges.
 func (n *Nginx) GetPoints() []*timeseries.Point {
-	points := make([]*timeseries.Point, 7)
+	points := make([]*timeseries.Point, 10)
 
 	points[0] = plugins.SimplePoint("nginx.ActiveConnections", n.ActiveConnections)
 	points[1] = plugins.SimplePoint("nginx.Accepts", n.Accepts)
@@ -73,6 +132,9 @@ func (n *Nginx) GetPoints() []*timeseries.Point {
 	points[4] = plugins.SimplePoint("nginx.Reading", n.Reading)
 	points[5] = plugins.SimplePoint("nginx.Writing", n.Writing)
 	points[6] = plugins.SimplePoint("nginx.Waiting", n.Waiting)
+	points[7] = plugins.SimplePoint("nginx.AcceptsPerSecond", n.AcceptsPerSecond)
+	points[8] = plugins.SimplePoint("nginx.HandledPerSecond", n.HandledPerSecond)
+	points[9] = plugins.SimplePoint("nginx.RequestsPerSecond", n.RequestsPerSecond)
 
 	return points
 }
@@ -88,6 +150,14 @@ func (n *Nginx) GetDoc() *plugins.Doc {
 	doc.AddMeasurement("nginx.Reading", "The current number of connections where nginx is reading the request header.", "n")
 	doc.AddMeasurement("nginx.Writing", "The current number of connections where nginx is writing the response back to the client.", "n")
 	doc.AddMeasurement("nginx.Waiting", "The current number of idle client connections waiting for a request.", "n")
+	doc.AddMeasurement("nginx.AcceptsPerSecond", "Accepted client connections.", "/s")
+	doc.AddMeasurement("nginx.HandledPerSecond", "Handled connections.", "/s")
+	doc.AddMeasurement("nginx.RequestsPerSecond", "Client requests.", "/s")
+
+	// The counters were written as integers before rates were added.
+	doc.AddField("nginx.Accepts", "value", timeseries.FieldInteger)
+	doc.AddField("nginx.Handled", "value", timeseries.FieldInteger)
+	doc.AddField("nginx.Requests", "value", timeseries.FieldInteger)
 
 	return doc
 }
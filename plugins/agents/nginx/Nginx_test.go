@@ -0,0 +1,107 @@
+package nginx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+)
+
+const (
+	// This is the exact output of nginx, including trailing spaces.
+	stub1 = "Active connections: 291 \n" +
+		"server accepts handled requests\n" +
+		" 16630948 16630948 31070465 \n" +
+		"Reading: 6 Writing: 179 Waiting: 106 \n"
+
+	stub2 = `Active connections: 300
+server accepts handled requests
+ 16631048 16631048 31071465
+Reading: 4 Writing: 190 Waiting: 106
+`
+)
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, newNginx())
+}
+
+func TestGather(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(stub1))
+	}))
+	defer server.Close()
+
+	n := newNginx().(*Nginx)
+	n.URL = server.URL
+
+	err := n.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if n.ActiveConnections != 291 || n.Accepts != 16630948 || n.Requests != 31070465 || n.Writing != 179 || n.Waiting != 106 {
+		t.Fatalf("Wrong result: %+v", *n)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	n := &Nginx{}
+
+	err := n.parse(strings.NewReader("<html>Not found</html>"))
+	if err == nil {
+		t.Fatalf("parse() accepted garbage")
+	}
+}
+
+func TestSub(t *testing.T) {
+	previous := &Nginx{}
+	previous.parse(strings.NewReader(stub1))
+	previous.sampletime = time.Now()
+
+	current := &Nginx{}
+	current.parse(strings.NewReader(stub2))
+	current.sampletime = previous.sampletime.Add(10 * time.Second)
+
+	diff := current.Sub(previous)
+	if diff.AcceptsPerSecond != 10.0 || diff.HandledPerSecond != 10.0 || diff.RequestsPerSecond != 100.0 {
+		t.Errorf("Wrong rates: %+v", *diff)
+	}
+
+	if diff.Accepts != 16631048 || diff.Requests != 31071465 || diff.ActiveConnections != 300 || diff.Reading != 4 || diff.Writing != 190 || diff.Waiting != 106 {
+		t.Errorf("Counters and gauges not copied: %+v", *diff)
+	}
+
+	// Swapping the samples simulates a counter reset (nginx restart).
+	previous.sampletime = current.sampletime.Add(time.Second)
+	diff = previous.Sub(current)
+	if diff.RequestsPerSecond != 0.0 {
+		t.Errorf("Counter reset resulted in %f requests/s", diff.RequestsPerSecond)
+	}
+
+	// No time has passed.
+	current.sampletime = previous.sampletime
+	diff = current.Sub(previous)
+	if diff.RequestsPerSecond != 0.0 || diff.ActiveConnections != 0 {
+		t.Errorf("Sub() returned values for a zero duration")
+	}
+}
+
+func TestGetPointsTypes(t *testing.T) {
+	n := &Nginx{}
+	n.parse(strings.NewReader(stub1))
+
+	// The counters must keep the integer type used before rates were added,
+	// InfluxDB refuses changing the type of a field.
+	for _, point := range n.GetPoints() {
+		switch point.Name {
+		case "nginx.Accepts", "nginx.Handled", "nginx.Requests":
+			if _, ok := point.Fields["value"].(int); !ok {
+				t.Errorf("%s is %T, expected int", point.Name, point.Fields["value"])
+			}
+		}
+	}
+}
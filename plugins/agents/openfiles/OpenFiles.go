@@ -23,6 +23,7 @@ func NewOpenFiles() interface{} {
 
 type OpenFiles struct {
 	Open int64 `json:"o"`
+	Free int64 `json:"f"`
 	Max  int64 `json:"m"`
 }
 
@@ -33,22 +34,47 @@ func (stat *OpenFiles) Gather(transport plugins.Transport) error {
 		return err
 	}
 
-	fields := strings.Fields(string(contents))
-	if len(fields) != 3 {
+	err = stat.parse(string(contents))
+	if err != nil {
 		return errors.New("Unknown format read from " + path)
 	}
 
+	return nil
+}
+
+// parse will parse the three columns of file-nr: allocated file handles,
+// allocated but unused file handles and the maximum number of file handles.
+func (stat *OpenFiles) parse(contents string) error {
+	fields := strings.Fields(contents)
+	if len(fields) != 3 {
+		return errors.New("Unknown format")
+	}
+
 	stat.Open, _ = strconv.ParseInt(fields[0], 10, 64)
+	stat.Free, _ = strconv.ParseInt(fields[1], 10, 64)
 	stat.Max, _ = strconv.ParseInt(fields[2], 10, 64)
 
 	return nil
 }
 
+// UsedPercent returns the percentage of file handles used.
+func (o *OpenFiles) UsedPercent() float64 {
+	if o.Max <= 0 {
+		return 0.0
+	}
+
+	return float64(o.Open-o.Free) / float64(o.Max) * 100.0
+}
+
 func (o *OpenFiles) GetPoints() []*timeseries.Point {
-	points := make([]*timeseries.Point, 2)
+	points := make([]*timeseries.Point, 6)
 
 	points[0] = plugins.SimplePoint("misc.OpenFilesUsed", o.Open)
 	points[1] = plugins.SimplePoint("misc.OpenFilesFree", o.Max-o.Open)
+	points[2] = plugins.SimplePoint("fd.Allocated", o.Open)
+	points[3] = plugins.SimplePoint("fd.Free", o.Free)
+	points[4] = plugins.SimplePoint("fd.Max", o.Max)
+	points[5] = plugins.SimplePoint("fd.UsedPercent", o.UsedPercent())
 
 	return points
 }
@@ -58,6 +84,10 @@ func (o *OpenFiles) GetDoc() *plugins.Doc {
 
 	doc.AddMeasurement("misc.OpenFilesUsed", "The number of allocated file handles", "n")
 	doc.AddMeasurement("misc.OpenFilesFree", "The number of free file handles", "n")
+	doc.AddMeasurement("fd.Allocated", "The number of allocated file handles", "n")
+	doc.AddMeasurement("fd.Free", "The number of allocated but unused file handles", "n")
+	doc.AddMeasurement("fd.Max", "The maximum number of file handles", "n")
+	doc.AddMeasurement("fd.UsedPercent", "Percentage of the maximum file handles in use", "%")
 
 	return doc
 }
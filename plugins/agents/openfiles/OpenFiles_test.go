@@ -9,3 +9,47 @@ import (
 func TestAgent(t *testing.T) {
 	plugins.GenericAgentTest(t, NewOpenFiles())
 }
+
+func TestParse(t *testing.T) {
+	cases := map[string]OpenFiles{
+		"1024\t0\t8192\n": OpenFiles{Open: 1024, Free: 0, Max: 8192},
+		"3000 1000 4000":  OpenFiles{Open: 3000, Free: 1000, Max: 4000},
+	}
+
+	for contents, correct := range cases {
+		stat := OpenFiles{}
+
+		err := stat.parse(contents)
+		if err != nil {
+			t.Fatalf("parse() failed on '%s': %s", contents, err.Error())
+		}
+
+		if stat != correct {
+			t.Errorf("Parsing '%s' resulted in %+v, expected %+v", contents, stat, correct)
+		}
+	}
+
+	for _, contents := range []string{"", "1024 0"} {
+		stat := OpenFiles{}
+		if stat.parse(contents) == nil {
+			t.Errorf("parse() accepted '%s'", contents)
+		}
+	}
+}
+
+func TestUsedPercent(t *testing.T) {
+	cases := []struct {
+		stat     OpenFiles
+		expected float64
+	}{
+		{OpenFiles{Open: 1024, Free: 0, Max: 8192}, 12.5},
+		{OpenFiles{Open: 3000, Free: 1000, Max: 4000}, 50.0},
+		{OpenFiles{}, 0.0},
+	}
+
+	for _, c := range cases {
+		if c.stat.UsedPercent() != c.expected {
+			t.Errorf("UsedPercent() of %+v is %f, expected %f", c.stat, c.stat.UsedPercent(), c.expected)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package osrelease
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("osrelease", NewOsRelease)
+}
+
+type (
+	// OsRelease reports the kernel version and the operating system
+	// release as tags on an informational point.
+	OsRelease struct {
+		Path string `toml:"path" json:"path" description:"Path to os-release (default /etc/os-release, falling back to /usr/lib/os-release)"`
+
+		Kernel        string `json:"k"`
+		Distro        string `json:"d"`
+		DistroVersion string `json:"v"`
+	}
+)
+
+// defaultPaths lists the locations of os-release as described in
+// os-release(5), in the order they should be tried.
+var defaultPaths = []string{"/etc/os-release", "/usr/lib/os-release"}
+
+// NewOsRelease will return a new OsRelease.
+func NewOsRelease() interface{} {
+	return new(OsRelease)
+}
+
+// paths will return the paths to try when reading os-release.
+func (o *OsRelease) paths() []string {
+	if o.Path != "" {
+		return []string{o.Path}
+	}
+
+	return defaultPaths
+}
+
+// Gather will read the kernel release from proc and the distribution from
+// os-release.
+func (o *OsRelease) Gather(transport plugins.Transport) error {
+	o.Kernel = ""
+	o.Distro = ""
+	o.DistroVersion = ""
+
+	contents, err := transport.ReadFile(filepath.Join(configuration.ProcPath, "/sys/kernel/osrelease"))
+	if err != nil {
+		return err
+	}
+
+	o.Kernel = strings.TrimSpace(string(contents))
+
+	for _, path := range o.paths() {
+		contents, err = transport.ReadFile(path)
+		if err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	values := parse(contents)
+	o.Distro = values["ID"]
+	o.DistroVersion = values["VERSION_ID"]
+
+	return nil
+}
+
+// parse will parse the KEY=value lines of os-release. Values can be quoted
+// using shell quoting rules.
+func parse(contents []byte) map[string]string {
+	values := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		values[parts[0]] = unquote(parts[1])
+	}
+
+	return values
+}
+
+// unquote will remove quotes from a value in os-release.
+func unquote(value string) string {
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return value[1 : len(value)-1]
+	}
+
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		return unquoted
+	}
+
+	return value
+}
+
+// GetPoints will return a single point with the value 1 tagged with the
+// kernel and distribution.
+func (o *OsRelease) GetPoints() []*timeseries.Point {
+	tags := map[string]string{
+		"kernel":         o.Kernel,
+		"distro":         o.Distro,
+		"distro_version": o.DistroVersion,
+	}
+
+	return []*timeseries.Point{
+		plugins.PointWithTags("os.Info", int64(1), tags),
+	}
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (o *OsRelease) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("Kernel and OS release")
+
+	doc.AddMeasurement("os.Info", "Always 1. The information is carried in the tags", "")
+
+	doc.AddTag("kernel", "The kernel release as reported by uname -r")
+	doc.AddTag("distro", "The distribution ID from os-release, for example \"debian\"")
+	doc.AddTag("distro_version", "The distribution VERSION_ID from os-release, for example \"12\"")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*OsRelease)(nil)
@@ -0,0 +1,74 @@
+package osrelease
+
+import (
+	"testing"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+)
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewOsRelease())
+}
+
+func TestGather(t *testing.T) {
+	procPath := configuration.ProcPath
+	configuration.ProcPath = "testdata"
+	defer func() { configuration.ProcPath = procPath }()
+
+	transport := localtransport.NewLocalTransport().(plugins.Transport)
+
+	o := NewOsRelease().(*OsRelease)
+	o.Path = "testdata/os-release"
+
+	err := o.Gather(transport)
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	points := o.GetPoints()
+	if len(points) != 1 {
+		t.Fatalf("Got %d points, expected 1", len(points))
+	}
+
+	if points[0].Fields["value"] != int64(1) {
+		t.Errorf("Got value %v, expected 1", points[0].Fields["value"])
+	}
+
+	expected := map[string]string{
+		"kernel":         "6.1.0-18-amd64",
+		"distro":         "debian",
+		"distro_version": "12",
+	}
+
+	for key, value := range expected {
+		if points[0].Tags[key] != value {
+			t.Errorf("Got %s=%q, expected %q", key, points[0].Tags[key], value)
+		}
+	}
+
+	o.Path = "testdata/nonexisting"
+	err = o.Gather(transport)
+	if err == nil {
+		t.Errorf("Gather() did not fail for missing os-release")
+	}
+}
+
+func TestUnquote(t *testing.T) {
+	cases := map[string]string{
+		`debian`:        "debian",
+		`"12"`:          "12",
+		`'Arch Linux'`:  "Arch Linux",
+		`"say \"hi\""`:  `say "hi"`,
+		`"unterminated`: `"unterminated`,
+		`""`:            "",
+	}
+
+	for in, expected := range cases {
+		got := unquote(in)
+		if got != expected {
+			t.Errorf("unquote(%s) returned %q, expected %q", in, got, expected)
+		}
+	}
+}
@@ -1,111 +1,159 @@
+// Package ping implements an ICMP echo agent. It will use an unprivileged ICMP
+// socket if allowed by net.ipv4.ping_group_range, otherwise a raw socket is
+// used, which requires CAP_NET_RAW.
 package ping
 
 import (
+	"net"
 	"strings"
 	"time"
 
 	"github.com/abrander/agento/plugins"
 	"github.com/abrander/agento/timeseries"
-	gansoiPing "github.com/gansoi/gansoi/plugins/agents/ping"
 )
 
-var pinger *gansoiPing.ICMPService
-
 type Data struct {
-	Loss    int    `json:"loss"`
-	Sent    int    `json:"sent"`
-	Replies int    `json:"replies"`
-	TimeAvg int64  `json:"timeavg"`
-	TimeMin int64  `json:"timemin"`
-	TimeMax int64  `json:"timemax"`
-	IP      string `json:"server"`
+	Host    string        `json:"host"`
+	Sent    int           `json:"sent"`
+	Replies int           `json:"replies"`
+	Loss    float64       `json:"loss"`
+	RttAvg  time.Duration `json:"rttavg"`
+	RttMin  time.Duration `json:"rttmin"`
+	RttMax  time.Duration `json:"rttmax"`
 }
 
 type Ping struct {
 	Data []Data `json:"data"`
 
-	IP    string `toml:"ip" json:"ip" description:"The ip(s) to ping (multiple can be separated by comma)"`
-	Count int    `toml:"count" json:"count" description:"Number of packages to send"`
+	Host    string `toml:"host" json:"host" description:"The host(s) to ping (multiple can be separated by comma)"`
+	IP      string `toml:"ip" json:"ip" description:"Deprecated, use host"`
+	Count   int    `toml:"count" json:"count" description:"Number of packages to send"`
+	Timeout int    `toml:"timeout" json:"timeout" description:"Time to wait for each reply in milliseconds (default 1000)"`
+
+	warnings []string
 }
 
 func init() {
 	plugins.Register("ping", NewPing)
-	pinger = gansoiPing.NewICMPService()
-	pinger.Start()
 }
 
 func NewPing() interface{} {
 	return new(Ping)
 }
 
+// Gather will ping all hosts. Hosts failing to resolve are reported as 100%
+// loss and by Warnings(), the other hosts are still pinged.
 func (p *Ping) Gather(transport plugins.Transport) error {
+	p.Data = nil
+	p.warnings = nil
+
 	// Default to "one ping only" if nothing else is specified in config
 	count := 1
 	if p.Count > 0 {
 		count = p.Count
 	}
 
-	ips := strings.Split(p.IP, ",")
-	for _, ip := range ips {
-		data := Data{}
-		ip = strings.TrimSpace(ip)
+	timeout := time.Second
+	if p.Timeout > 0 {
+		timeout = time.Duration(p.Timeout) * time.Millisecond
+	}
 
-		summary, err := pinger.Ping(ip, count, time.Second)
+	hosts := p.Host
+	if hosts == "" {
+		hosts = p.IP
+	}
 
+	pinger, err := newPinger()
+	if err != nil {
+		return err
+	}
+	defer pinger.close()
+
+	for _, host := range strings.Split(hosts, ",") {
+		host = strings.TrimSpace(host)
+
+		addr, err := net.ResolveIPAddr("ip4", host)
 		if err != nil {
-			return err
+			p.warnings = append(p.warnings, err.Error())
+			p.Data = append(p.Data, Data{Host: host, Loss: 100.0})
+
+			continue
+		}
+
+		data := Data{Host: host}
+
+		var total time.Duration
+		for seq := 0; seq < count; seq++ {
+			data.Sent++
+
+			rtt, err := pinger.ping(addr.IP, seq, timeout)
+			if err != nil {
+				continue
+			}
+
+			if data.Replies == 0 || rtt < data.RttMin {
+				data.RttMin = rtt
+			}
+
+			if rtt > data.RttMax {
+				data.RttMax = rtt
+			}
+
+			total += rtt
+			data.Replies++
 		}
 
-		data.Loss = 100 - (100 * summary.Replies / summary.Sent)
-		data.Sent = summary.Sent
-		data.Replies = summary.Replies
-		data.TimeAvg = summary.Average.Nanoseconds()
-		data.TimeMin = summary.Min.Nanoseconds()
-		data.TimeMax = summary.Max.Nanoseconds()
+		if data.Replies > 0 {
+			data.RttAvg = total / time.Duration(data.Replies)
+		}
 
-		data.IP = ip
+		data.Loss = 100.0 - (100.0 * float64(data.Replies) / float64(data.Sent))
 
 		p.Data = append(p.Data, data)
 	}
+
 	return nil
 }
 
+// Warnings will return the hosts not resolved by the last Gather().
+func (p *Ping) Warnings() []string {
+	return p.warnings
+}
+
+// GetPoints will return loss for all hosts and round trip times for hosts
+// answering.
 func (p *Ping) GetPoints() []*timeseries.Point {
-	points := make([]*timeseries.Point, len(p.Data))
-	for i, data := range p.Data {
-		tags := map[string]string{
-			"ip": data.IP,
-		}
+	var points []*timeseries.Point
+
+	for _, data := range p.Data {
+		points = append(points, plugins.PointWithTag("ping.PacketLoss", data.Loss, "host", data.Host))
 
-		values := map[string]interface{}{
-			"loss":    data.Loss,
-			"sent":    data.Sent,
-			"replies": data.Replies,
-			"timeAvg": data.TimeAvg,
-			"timeMin": data.TimeMin,
-			"timeMax": data.TimeMax,
+		if data.Replies == 0 {
+			continue
 		}
 
-		points[i] = plugins.PointValuesWithTags("ping", values, tags)
+		points = append(points,
+			plugins.PointWithTag("ping.RttAvg", data.RttAvg.Seconds()*1000.0, "host", data.Host),
+			plugins.PointWithTag("ping.RttMin", data.RttMin.Seconds()*1000.0, "host", data.Host),
+			plugins.PointWithTag("ping.RttMax", data.RttMax.Seconds()*1000.0, "host", data.Host),
+		)
 	}
 
 	return points
 }
 
 func (m *Ping) GetDoc() *plugins.Doc {
-	doc := plugins.NewDoc("Ping Time")
+	doc := plugins.NewDoc("Ping Time (may require CAP_NET_RAW)")
 
-	doc.AddTag("ip", "The ip(s) to ping (multiple can be separated by comma)")
-	doc.AddTag("count", "Number of packages to send")
-	doc.AddMeasurement("data.Loss", "Packetloss in percent", "n")
-	doc.AddMeasurement("data.Sent", "Number of pings sent", "n")
-	doc.AddMeasurement("data.Replies", "Number of replies", "n")
-	doc.AddMeasurement("data.TimeAvg", "Avg time for all replies", "n")
-	doc.AddMeasurement("data.TimeMin", "Min time for a reply", "n")
-	doc.AddMeasurement("data.TimeMax", "Max time for a reply", "n")
+	doc.AddTag("host", "The host pinged")
+	doc.AddMeasurement("ping.PacketLoss", "Packetloss in percent", "%")
+	doc.AddMeasurement("ping.RttAvg", "Avg time for all replies", "ms")
+	doc.AddMeasurement("ping.RttMin", "Min time for a reply", "ms")
+	doc.AddMeasurement("ping.RttMax", "Max time for a reply", "ms")
 
 	return doc
 }
 
 // Ensure compliance
 var _ plugins.Agent = (*Ping)(nil)
+var _ plugins.Warner = (*Ping)(nil)
@@ -0,0 +1,96 @@
+package ping
+
+import (
+	"testing"
+
+	"github.com/abrander/agento/plugins"
+)
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewPing())
+}
+
+func TestGatherLoopback(t *testing.T) {
+	pinger, err := newPinger()
+	if err != nil {
+		t.Skipf("Cannot ping: %s", err.Error())
+	}
+	pinger.close()
+
+	p := &Ping{Host: "127.0.0.1", Count: 3}
+
+	err = p.Gather(nil)
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if len(p.Data) != 1 {
+		t.Fatalf("Got data for %d hosts, expected 1", len(p.Data))
+	}
+
+	data := p.Data[0]
+	if data.Sent != 3 || data.Replies != 3 || data.Loss != 0.0 {
+		t.Fatalf("Loopback lost packages: %+v", data)
+	}
+
+	if data.RttMin > data.RttAvg || data.RttAvg > data.RttMax {
+		t.Fatalf("Inconsistent round trip times: %+v", data)
+	}
+
+	points := p.GetPoints()
+	if len(points) != 4 {
+		t.Fatalf("Got %d points, expected 4", len(points))
+	}
+
+	plugins.GenericAgentTest(t, p)
+}
+
+func TestGatherIPFallback(t *testing.T) {
+	pinger, err := newPinger()
+	if err != nil {
+		t.Skipf("Cannot ping: %s", err.Error())
+	}
+	pinger.close()
+
+	p := &Ping{IP: "127.0.0.1"}
+
+	err = p.Gather(nil)
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if len(p.Data) != 1 || p.Data[0].Host != "127.0.0.1" {
+		t.Fatalf("IP was not used as host: %+v", p.Data)
+	}
+}
+
+func TestGatherUnresolved(t *testing.T) {
+	pinger, err := newPinger()
+	if err != nil {
+		t.Skipf("Cannot ping: %s", err.Error())
+	}
+	pinger.close()
+
+	p := &Ping{Host: "nonexisting.invalid, 127.0.0.1"}
+
+	err = p.Gather(nil)
+	if err != nil {
+		t.Fatalf("Gather() failed for a single unresolved host: %s", err.Error())
+	}
+
+	if len(p.Data) != 2 {
+		t.Fatalf("Got data for %d hosts, expected 2", len(p.Data))
+	}
+
+	if p.Data[0].Host != "nonexisting.invalid" || p.Data[0].Loss != 100.0 {
+		t.Errorf("Unresolved host not reported as lost: %+v", p.Data[0])
+	}
+
+	if p.Data[1].Host != "127.0.0.1" || p.Data[1].Replies != 1 {
+		t.Errorf("Host after the unresolved host was not pinged: %+v", p.Data[1])
+	}
+
+	if len(p.Warnings()) != 1 {
+		t.Errorf("Got %d warnings, expected 1", len(p.Warnings()))
+	}
+}
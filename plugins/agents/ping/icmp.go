@@ -0,0 +1,121 @@
+package ping
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+type (
+	// pinger sends ICMP echo requests using either an unprivileged datagram
+	// socket or a raw socket.
+	pinger struct {
+		conn       *icmp.PacketConn
+		privileged bool
+		id         int
+	}
+)
+
+var (
+	// ErrNoSocket will be returned if neither an unprivileged nor a raw ICMP
+	// socket could be opened.
+	ErrNoSocket = errors.New("cannot open ICMP socket, check net.ipv4.ping_group_range or CAP_NET_RAW")
+)
+
+// newPinger will open an ICMP socket. The unprivileged "udp4" socket is tried
+// first, if not permitted by net.ipv4.ping_group_range we fall back to a raw
+// socket requiring CAP_NET_RAW.
+func newPinger() (*pinger, error) {
+	conn, err := icmp.ListenPacket("udp4", "0.0.0.0")
+	if err == nil {
+		return &pinger{conn: conn}, nil
+	}
+
+	conn, err = icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err == nil {
+		return &pinger{conn: conn, privileged: true, id: rand.Intn(0xffff)}, nil
+	}
+
+	return nil, ErrNoSocket
+}
+
+// ping will send a single echo request to ip and wait at most timeout for
+// the reply. The round trip time is returned. If no reply is received in
+// time, an error is returned.
+func (p *pinger) ping(ip net.IP, seq int, timeout time.Duration) (time.Duration, error) {
+	var dst net.Addr = &net.UDPAddr{IP: ip}
+	if p.privileged {
+		dst = &net.IPAddr{IP: ip}
+	}
+
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{
+			ID:   p.id,
+			Seq:  seq,
+			Data: []byte("agento"),
+		},
+	}
+
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	deadline := start.Add(timeout)
+
+	_, err = p.conn.WriteTo(b, dst)
+	if err != nil {
+		return 0, err
+	}
+
+	p.conn.SetReadDeadline(deadline)
+
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := p.conn.ReadFrom(buf)
+		if err != nil {
+			return 0, err
+		}
+
+		// 1 is the protocol number for ICMP.
+		reply, err := icmp.ParseMessage(1, buf[:n])
+		if err != nil || reply.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+
+		echo, ok := reply.Body.(*icmp.Echo)
+		if !ok || echo.Seq != seq || !sameIP(peer, ip) {
+			continue
+		}
+
+		// The kernel will rewrite the id for unprivileged sockets.
+		if p.privileged && echo.ID != p.id {
+			continue
+		}
+
+		return time.Now().Sub(start), nil
+	}
+}
+
+// close will close the socket.
+func (p *pinger) close() error {
+	return p.conn.Close()
+}
+
+// sameIP will check if addr is ip.
+func sameIP(addr net.Addr, ip net.IP) bool {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP.Equal(ip)
+	case *net.IPAddr:
+		return a.IP.Equal(ip)
+	}
+
+	return false
+}
@@ -0,0 +1,208 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("postgres", NewPostgres)
+}
+
+// Postgres will read per-database statistics from pg_stat_database and
+// pg_stat_activity.
+type Postgres struct {
+	DSN string `toml:"dsn" json:"dsn" description:"libpq connection string (host=... user=... dbname=...)" required:"true"`
+
+	sampletime time.Time                  `json:"-"`
+	Databases  map[string]*SingleDatabase `json:"d"`
+}
+
+// dialer is a pq.Dialer using a transport.
+type dialer struct {
+	transport plugins.Transport
+}
+
+const (
+	statDatabaseQuery = `SELECT datname, xact_commit, xact_rollback, blks_read, blks_hit, deadlocks
+		FROM pg_stat_database
+		WHERE datname IS NOT NULL`
+
+	statActivityQuery = `SELECT datname,
+			count(*) FILTER (WHERE state = 'active'),
+			count(*) FILTER (WHERE state = 'idle in transaction')
+		FROM pg_stat_activity
+		WHERE datname IS NOT NULL
+		GROUP BY datname`
+)
+
+var (
+	// ErrMissingDSN will be returned if no connection string is configured.
+	ErrMissingDSN = errors.New("dsn must be set")
+)
+
+// NewPostgres will return a new Postgres.
+func NewPostgres() interface{} {
+	return new(Postgres)
+}
+
+func (d dialer) Dial(network string, address string) (net.Conn, error) {
+	return d.transport.Dial(network, address)
+}
+
+// DialTimeout will ignore the timeout, transports do not support it.
+func (d dialer) DialTimeout(network string, address string, _ time.Duration) (net.Conn, error) {
+	return d.transport.Dial(network, address)
+}
+
+// Gather will connect to the server and read statistics for all databases.
+func (p *Postgres) Gather(transport plugins.Transport) error {
+	if p.DSN == "" {
+		return ErrMissingDSN
+	}
+
+	connector, err := pq.NewConnector(p.DSN)
+	if err != nil {
+		return err
+	}
+
+	connector.Dialer(dialer{transport: transport})
+
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	return p.gather(db)
+}
+
+// gather will read statistics from db.
+func (p *Postgres) gather(db *sql.DB) error {
+	p.Databases = make(map[string]*SingleDatabase)
+	p.sampletime = time.Now()
+
+	rows, err := db.Query(statDatabaseQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		s := &SingleDatabase{}
+
+		err = rows.Scan(&name, &s.Commits, &s.Rollbacks, &s.BlocksRead, &s.BlocksHit, &s.Deadlocks)
+		if err != nil {
+			return err
+		}
+
+		p.Databases[name] = s
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+
+	rows, err = db.Query(statActivityQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var active, idle int64
+
+		err = rows.Scan(&name, &active, &idle)
+		if err != nil {
+			return err
+		}
+
+		s, found := p.Databases[name]
+		if !found {
+			continue
+		}
+
+		s.ActiveConnections = active
+		s.IdleInTransaction = idle
+	}
+
+	return rows.Err()
+}
+
+// Sub will calculate per-second rates between previous and p. Databases not
+// present in both samples are left out. An empty Postgres is returned if
+// previous is nil or no time has passed.
+func (p *Postgres) Sub(previous *Postgres) *Postgres {
+	diff := &Postgres{
+		DSN:       p.DSN,
+		Databases: make(map[string]*SingleDatabase),
+	}
+
+	if previous == nil {
+		return diff
+	}
+
+	duration := p.sampletime.Sub(previous.sampletime)
+	if duration <= 0 {
+		return diff
+	}
+
+	factor := duration.Seconds()
+
+	for name, value := range p.Databases {
+		prev, found := previous.Databases[name]
+		if found {
+			diff.Databases[name] = value.Sub(prev, factor)
+		}
+	}
+
+	diff.sampletime = p.sampletime
+
+	return diff
+}
+
+// GetPoints will return a set of points for each database.
+func (p *Postgres) GetPoints() []*timeseries.Point {
+	points := make([]*timeseries.Point, 0, len(p.Databases)*7)
+
+	for name, value := range p.Databases {
+		points = append(points,
+			plugins.PointWithTag("pg.CommitsPerSec", value.Commits, "datname", name),
+			plugins.PointWithTag("pg.RollbacksPerSec", value.Rollbacks, "datname", name),
+			plugins.PointWithTag("pg.BlocksRead", value.BlocksRead, "datname", name),
+			plugins.PointWithTag("pg.BlocksHit", value.BlocksHit, "datname", name),
+			plugins.PointWithTag("pg.Deadlocks", value.Deadlocks, "datname", name),
+			plugins.PointWithTag("pg.ActiveConnections", value.ActiveConnections, "datname", name),
+			plugins.PointWithTag("pg.IdleInTransaction", value.IdleInTransaction, "datname", name),
+		)
+	}
+
+	return points
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (p *Postgres) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("PostgreSQL database statistics")
+
+	doc.AddMeasurement("pg.CommitsPerSec", "Transactions committed", "/s")
+	doc.AddMeasurement("pg.RollbacksPerSec", "Transactions rolled back", "/s")
+	doc.AddMeasurement("pg.BlocksRead", "Disk blocks read", "/s")
+	doc.AddMeasurement("pg.BlocksHit", "Disk blocks found in the buffer cache", "/s")
+	doc.AddMeasurement("pg.Deadlocks", "Deadlocks detected", "/s")
+	doc.AddMeasurement("pg.ActiveConnections", "Backends executing a query", "n")
+	doc.AddMeasurement("pg.IdleInTransaction", "Backends idle in a transaction", "n")
+
+	doc.AddTag("datname", "The database name")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*Postgres)(nil)
@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/abrander/agento/plugins"
+)
+
+func mockStats(t *testing.T, commits int64, active int64) *Postgres {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() failed: %s", err.Error())
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("FROM pg_stat_database").WillReturnRows(
+		sqlmock.NewRows([]string{"datname", "xact_commit", "xact_rollback", "blks_read", "blks_hit", "deadlocks"}).
+			AddRow("postgres", 100, 1, 50, 5000, 0).
+			AddRow("app", commits, 20, 1000, 100000, 2),
+	)
+
+	mock.ExpectQuery("FROM pg_stat_activity").WillReturnRows(
+		sqlmock.NewRows([]string{"datname", "active", "idle"}).
+			AddRow("app", active, 1).
+			AddRow("unknown", 1, 1),
+	)
+
+	p := NewPostgres().(*Postgres)
+	err = p.gather(db)
+	if err != nil {
+		t.Fatalf("gather() failed: %s", err.Error())
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatalf("Expectations not met: %s", err.Error())
+	}
+
+	return p
+}
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewPostgres())
+}
+
+func TestGather(t *testing.T) {
+	p := mockStats(t, 10000, 3)
+
+	if len(p.Databases) != 2 {
+		t.Fatalf("Got %d databases, expected 2", len(p.Databases))
+	}
+
+	app := p.Databases["app"]
+	if app.Commits != 10000 || app.Rollbacks != 20 || app.Deadlocks != 2 {
+		t.Errorf("Wrong counters: %+v", app)
+	}
+
+	if app.ActiveConnections != 3 || app.IdleInTransaction != 1 {
+		t.Errorf("Wrong connection counts: %+v", app)
+	}
+
+	if p.Databases["postgres"].ActiveConnections != 0 {
+		t.Errorf("Database without activity has active connections")
+	}
+
+	points := p.GetPoints()
+	if len(points) != 14 {
+		t.Errorf("Got %d points, expected 14", len(points))
+	}
+}
+
+func TestGatherError(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	mock.ExpectQuery("FROM pg_stat_database").WillReturnError(errors.New("permission denied"))
+
+	p := NewPostgres().(*Postgres)
+	err := p.gather(db)
+	if err == nil {
+		t.Fatalf("gather() did not return error")
+	}
+}
+
+func TestGatherMissingDSN(t *testing.T) {
+	p := NewPostgres().(*Postgres)
+
+	err := p.Gather(nil)
+	if err != ErrMissingDSN {
+		t.Fatalf("Gather() returned %v, expected ErrMissingDSN", err)
+	}
+}
+
+func TestSub(t *testing.T) {
+	previous := mockStats(t, 10000, 3)
+	current := mockStats(t, 10500, 5)
+	current.sampletime = previous.sampletime.Add(10 * time.Second)
+
+	diff := current.Sub(previous)
+
+	app := diff.Databases["app"]
+	if app.Commits != 50.0 || app.Rollbacks != 0.0 {
+		t.Errorf("Wrong rates: %+v", app)
+	}
+
+	if app.ActiveConnections != 5 {
+		t.Errorf("Gauges not copied: %+v", app)
+	}
+
+	// Counter reset after stats were reset.
+	previous.sampletime = current.sampletime.Add(time.Second)
+	diff = previous.Sub(current)
+	if diff.Databases["app"].Commits != 0.0 {
+		t.Errorf("Counter reset resulted in %f commits/s", diff.Databases["app"].Commits)
+	}
+
+	diff = current.Sub(nil)
+	if len(diff.Databases) != 0 {
+		t.Errorf("Sub(nil) returned databases")
+	}
+}
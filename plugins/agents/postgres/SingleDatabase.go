@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"github.com/abrander/agento/plugins"
+)
+
+// SingleDatabase holds statistics for a single database.
+type SingleDatabase struct {
+	Commits           float64 `json:"c"`
+	Rollbacks         float64 `json:"r"`
+	BlocksRead        float64 `json:"br"`
+	BlocksHit         float64 `json:"bh"`
+	Deadlocks         float64 `json:"d"`
+	ActiveConnections int64   `json:"a"`
+	IdleInTransaction int64   `json:"i"`
+}
+
+// Sub will calculate per-second rates for the cumulative counters. The
+// connection gauges are copied as is.
+func (s *SingleDatabase) Sub(previous *SingleDatabase, factor float64) *SingleDatabase {
+	return &SingleDatabase{
+		Commits:           plugins.CounterRate(s.Commits, previous.Commits, factor),
+		Rollbacks:         plugins.CounterRate(s.Rollbacks, previous.Rollbacks, factor),
+		BlocksRead:        plugins.CounterRate(s.BlocksRead, previous.BlocksRead, factor),
+		BlocksHit:         plugins.CounterRate(s.BlocksHit, previous.BlocksHit, factor),
+		Deadlocks:         plugins.CounterRate(s.Deadlocks, previous.Deadlocks, factor),
+		ActiveConnections: s.ActiveConnections,
+		IdleInTransaction: s.IdleInTransaction,
+	}
+}
@@ -0,0 +1,155 @@
+package pressure
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("pressure", NewPressure)
+}
+
+// Stall is the share of time in percent some or all tasks were stalled over
+// the last 10, 60 and 300 seconds.
+type Stall struct {
+	Avg10  float64 `json:"10"`
+	Avg60  float64 `json:"60"`
+	Avg300 float64 `json:"300"`
+}
+
+// Resource holds the pressure of a single resource. Full is not present for
+// cpu on older kernels.
+type Resource struct {
+	Some *Stall `json:"some,omitempty"`
+	Full *Stall `json:"full,omitempty"`
+}
+
+// Pressure reads pressure stall information from /proc/pressure. Resources
+// not available on the host are left out.
+// https://www.kernel.org/doc/html/latest/accounting/psi.html
+type Pressure struct {
+	Resources map[string]*Resource `json:"resources"`
+}
+
+// files maps resource names as used in measurements to files in
+// /proc/pressure.
+var files = map[string]string{
+	"Cpu": "cpu",
+	"Mem": "memory",
+	"Io":  "io",
+}
+
+// NewPressure will return a new Pressure.
+func NewPressure() interface{} {
+	return new(Pressure)
+}
+
+// Gather will read /proc/pressure. If PSI is not supported by the kernel, no
+// error is returned, but no points will be returned either.
+func (p *Pressure) Gather(transport plugins.Transport) error {
+	p.Resources = make(map[string]*Resource)
+
+	for name, file := range files {
+		path := filepath.Join(configuration.ProcPath, "/pressure", file)
+		contents, err := transport.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		p.Resources[name] = parse(string(contents))
+	}
+
+	return nil
+}
+
+// parse will parse the contents of a file in /proc/pressure.
+func parse(contents string) *Resource {
+	r := &Resource{}
+
+	for _, line := range strings.Split(contents, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+
+		s := &Stall{}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+
+			value, _ := strconv.ParseFloat(kv[1], 64)
+
+			switch kv[0] {
+			case "avg10":
+				s.Avg10 = value
+			case "avg60":
+				s.Avg60 = value
+			case "avg300":
+				s.Avg300 = value
+			}
+		}
+
+		switch fields[0] {
+		case "some":
+			r.Some = s
+		case "full":
+			r.Full = s
+		}
+	}
+
+	return r
+}
+
+// GetPoints will return points like pressure.CpuSome10 and pressure.IoFull300.
+func (p *Pressure) GetPoints() []*timeseries.Point {
+	var points []*timeseries.Point
+
+	add := func(prefix string, s *Stall) {
+		if s == nil {
+			return
+		}
+
+		points = append(points,
+			plugins.SimplePoint(prefix+"10", s.Avg10),
+			plugins.SimplePoint(prefix+"60", s.Avg60),
+			plugins.SimplePoint(prefix+"300", s.Avg300),
+		)
+	}
+
+	for name, r := range p.Resources {
+		add("pressure."+name+"Some", r.Some)
+		add("pressure."+name+"Full", r.Full)
+	}
+
+	return points
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (p *Pressure) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("Pressure stall information (Linux 4.20+)")
+
+	descriptions := map[string]string{
+		"Cpu": "CPU",
+		"Mem": "memory",
+		"Io":  "IO",
+	}
+
+	for name, description := range descriptions {
+		for _, window := range []string{"10", "60", "300"} {
+			doc.AddMeasurement("pressure."+name+"Some"+window, "Share of time some tasks were stalled on "+description+" over "+window+" seconds", "%")
+			doc.AddMeasurement("pressure."+name+"Full"+window, "Share of time all tasks were stalled on "+description+" over "+window+" seconds", "%")
+		}
+	}
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*Pressure)(nil)
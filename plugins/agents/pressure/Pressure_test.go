@@ -0,0 +1,72 @@
+package pressure
+
+import (
+	"testing"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/mock"
+)
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewPressure())
+}
+
+func TestParse(t *testing.T) {
+	r := parse(`some avg10=1.50 avg60=0.75 avg300=0.10 total=123456
+full avg10=0.50 avg60=0.25 avg300=0.05 total=23456
+`)
+
+	if r.Some == nil || *r.Some != (Stall{1.5, 0.75, 0.1}) {
+		t.Errorf("Wrong some: %+v", r.Some)
+	}
+
+	if r.Full == nil || *r.Full != (Stall{0.5, 0.25, 0.05}) {
+		t.Errorf("Wrong full: %+v", r.Full)
+	}
+
+	// Older kernels have no full line for cpu.
+	r = parse("some avg10=2.00 avg60=1.00 avg300=0.50 total=1\n")
+	if r.Full != nil {
+		t.Errorf("Got full without full line")
+	}
+}
+
+func TestGather(t *testing.T) {
+	mock := mocktransport.NewMock().(*mocktransport.Mock)
+
+	p := NewPressure().(*Pressure)
+	err := p.Gather(mock)
+	if err != nil {
+		t.Fatalf("Gather() failed without PSI: %s", err.Error())
+	}
+
+	if len(p.GetPoints()) != 0 {
+		t.Fatalf("Got points without PSI")
+	}
+
+	mock.SetFile("/proc/pressure/cpu", []byte("some avg10=2.00 avg60=1.00 avg300=0.50 total=1\n"))
+	mock.SetFile("/proc/pressure/memory", []byte("some avg10=0.00 avg60=0.00 avg300=0.00 total=0\nfull avg10=0.00 avg60=3.00 avg300=0.00 total=0\n"))
+
+	err = p.Gather(mock)
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	points := p.GetPoints()
+	if len(points) != 9 {
+		t.Fatalf("Got %d points, expected 9", len(points))
+	}
+
+	found := false
+	for _, point := range points {
+		if point.Name == "pressure.MemFull60" {
+			found = point.Fields["value"] == 3.0
+		}
+	}
+
+	if !found {
+		t.Errorf("pressure.MemFull60 not found or wrong")
+	}
+
+	plugins.GenericAgentTest(t, p)
+}
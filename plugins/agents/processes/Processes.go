@@ -0,0 +1,138 @@
+package processes
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("processes", NewProcesses)
+}
+
+// NewProcesses will return a new Processes.
+func NewProcesses() interface{} {
+	return new(Processes)
+}
+
+// Processes counts processes by state.
+// http://man7.org/linux/man-pages/man5/proc.5.html
+type Processes struct {
+	Running     int64 `json:"r"`
+	Sleeping    int64 `json:"s"`
+	DiskSleep   int64 `json:"d"`
+	Zombie      int64 `json:"z"`
+	Stopped     int64 `json:"t"`
+	Total       int64 `json:"T"`
+	ThreadTotal int64 `json:"h"`
+}
+
+// Gather will read /proc/[pid]/stat for all processes. Processes exiting
+// while we read are skipped.
+func (p *Processes) Gather(transport plugins.Transport) error {
+	*p = Processes{}
+
+	names, err := plugins.ReadDir(transport, configuration.ProcPath)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		_, err := strconv.Atoi(name)
+		if err != nil {
+			// Not a process.
+			continue
+		}
+
+		contents, err := transport.ReadFile(filepath.Join(configuration.ProcPath, name, "stat"))
+		if err != nil {
+			// The process has probably gone away.
+			continue
+		}
+
+		state, threads, err := parseStat(string(contents))
+		if err != nil {
+			continue
+		}
+
+		switch state {
+		case "R":
+			p.Running++
+		case "S", "I":
+			p.Sleeping++
+		case "D":
+			p.DiskSleep++
+		case "Z":
+			p.Zombie++
+		case "T", "t":
+			p.Stopped++
+		}
+
+		p.Total++
+		p.ThreadTotal += threads
+	}
+
+	return nil
+}
+
+// parseStat will return the state and number of threads from the contents of
+// /proc/[pid]/stat.
+func parseStat(contents string) (string, int64, error) {
+	// The command name is in parentheses and can contain anything, including
+	// spaces and parentheses. Everything after the last ')' is safe.
+	end := strings.LastIndex(contents, ")")
+	if end < 0 {
+		return "", 0, errors.New("unknown format")
+	}
+
+	// Field 3 is state and field 20 is num_threads.
+	fields := strings.Fields(contents[end+1:])
+	if len(fields) < 18 {
+		return "", 0, errors.New("unknown format")
+	}
+
+	threads, err := strconv.ParseInt(fields[17], 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return fields[0], threads, nil
+}
+
+// GetPoints will return process counts by state.
+func (p *Processes) GetPoints() []*timeseries.Point {
+	points := make([]*timeseries.Point, 7)
+
+	points[0] = plugins.SimplePoint("proc.Running", p.Running)
+	points[1] = plugins.SimplePoint("proc.Sleeping", p.Sleeping)
+	points[2] = plugins.SimplePoint("proc.DiskSleep", p.DiskSleep)
+	points[3] = plugins.SimplePoint("proc.Zombie", p.Zombie)
+	points[4] = plugins.SimplePoint("proc.Stopped", p.Stopped)
+	points[5] = plugins.SimplePoint("proc.Total", p.Total)
+	points[6] = plugins.SimplePoint("proc.ThreadTotal", p.ThreadTotal)
+
+	return points
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (p *Processes) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("Processes by state")
+
+	doc.AddMeasurement("proc.Running", "Processes running or runnable", "n")
+	doc.AddMeasurement("proc.Sleeping", "Processes sleeping or idle", "n")
+	doc.AddMeasurement("proc.DiskSleep", "Processes in uninterruptible sleep, usually waiting for IO", "n")
+	doc.AddMeasurement("proc.Zombie", "Processes terminated but not reaped by the parent", "n")
+	doc.AddMeasurement("proc.Stopped", "Processes stopped or traced", "n")
+	doc.AddMeasurement("proc.Total", "Total number of processes", "n")
+	doc.AddMeasurement("proc.ThreadTotal", "Total number of threads", "n")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*Processes)(nil)
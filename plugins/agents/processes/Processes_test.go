@@ -0,0 +1,87 @@
+package processes
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+)
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewProcesses())
+}
+
+func stat(pid int, comm string, state string, threads int) string {
+	return fmt.Sprintf("%d (%s) %s 1 1 1 0 -1 4194560 100 0 0 0 1 1 0 0 20 0 %d 0 10 1000 100 18446744073709551615", pid, comm, state, threads)
+}
+
+func TestGather(t *testing.T) {
+	dir, err := ioutil.TempDir("", "processes")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	procs := map[string]string{
+		"1":    stat(1, "init", "S", 1),
+		"2":    stat(2, "sh) R (x", "R", 1),
+		"3":    stat(3, "worker", "R", 4),
+		"4":    stat(4, "kworker/0:1", "I", 1),
+		"5":    stat(5, "defunct", "Z", 1),
+		"6":    stat(6, "dd", "D", 1),
+		"7":    stat(7, "stopped", "T", 2),
+		"self": stat(7, "self", "R", 1),
+	}
+
+	for pid, contents := range procs {
+		os.Mkdir(filepath.Join(dir, pid), 0755)
+		ioutil.WriteFile(filepath.Join(dir, pid, "stat"), []byte(contents), 0644)
+	}
+
+	// A process exiting while we read.
+	os.Mkdir(filepath.Join(dir, "8"), 0755)
+
+	procPath := configuration.ProcPath
+	configuration.ProcPath = dir
+	defer func() { configuration.ProcPath = procPath }()
+
+	p := NewProcesses().(*Processes)
+	err = p.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	expected := Processes{
+		Running:     2,
+		Sleeping:    2,
+		DiskSleep:   1,
+		Zombie:      1,
+		Stopped:     1,
+		Total:       7,
+		ThreadTotal: 11,
+	}
+
+	if *p != expected {
+		t.Fatalf("Got %+v, expected %+v", *p, expected)
+	}
+}
+
+func TestParseStat(t *testing.T) {
+	cases := []string{
+		"",
+		"1 (init) S",
+		"1 (init S 1 1 1 0 -1 4194560 100 0 0 0 1 1 0 0 20 0 1 0",
+	}
+
+	for _, contents := range cases {
+		_, _, err := parseStat(contents)
+		if err == nil {
+			t.Errorf("parseStat() accepted '%s'", contents)
+		}
+	}
+}
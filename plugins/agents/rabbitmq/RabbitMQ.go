@@ -0,0 +1,190 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("rabbitmq", NewRabbitMQ)
+}
+
+type (
+	// RabbitMQ will read connections and queue statistics from the RabbitMQ
+	// management HTTP API.
+	RabbitMQ struct {
+		URL      string `toml:"url" json:"url" description:"Base URL of the management API (like http://localhost:15672)" required:"true"`
+		Username string `toml:"username" json:"username" description:"Username (default guest)"`
+		Password string `toml:"password" json:"password" description:"Password (default guest)"`
+		Timeout  int    `toml:"timeout" json:"timeout" description:"Request timeout in seconds (default 10)"`
+
+		Connections int64   `json:"c"`
+		Queues      []Queue `json:"q"`
+	}
+
+	// Queue holds statistics for a single queue.
+	Queue struct {
+		Name                   string  `json:"n"`
+		Vhost                  string  `json:"v"`
+		MessagesReady          int64   `json:"r"`
+		MessagesUnacknowledged int64   `json:"u"`
+		PublishRate            float64 `json:"p"`
+		DeliverRate            float64 `json:"d"`
+	}
+
+	// overview is the parts of /api/overview we use.
+	overview struct {
+		ObjectTotals struct {
+			Connections int64 `json:"connections"`
+		} `json:"object_totals"`
+	}
+
+	// rate is a message rate as calculated by the management plugin.
+	rate struct {
+		Rate float64 `json:"rate"`
+	}
+
+	// queue is the parts of a queue from /api/queues we use.
+	queue struct {
+		Name                   string `json:"name"`
+		Vhost                  string `json:"vhost"`
+		MessagesReady          int64  `json:"messages_ready"`
+		MessagesUnacknowledged int64  `json:"messages_unacknowledged"`
+		MessageStats           struct {
+			PublishDetails    rate `json:"publish_details"`
+			DeliverGetDetails rate `json:"deliver_get_details"`
+		} `json:"message_stats"`
+	}
+)
+
+var (
+	// ErrMissingURL will be returned if no URL is configured.
+	ErrMissingURL = errors.New("url must be set")
+)
+
+// NewRabbitMQ will return a new RabbitMQ.
+func NewRabbitMQ() interface{} {
+	return new(RabbitMQ)
+}
+
+// Gather will request /api/overview and /api/queues.
+func (r *RabbitMQ) Gather(transport plugins.Transport) error {
+	if r.URL == "" {
+		return ErrMissingURL
+	}
+
+	client := plugins.HTTPClient(transport)
+
+	client.Timeout = 10 * time.Second
+	if r.Timeout > 0 {
+		client.Timeout = time.Duration(r.Timeout) * time.Second
+	}
+
+	var o overview
+	err := r.get(client, "/api/overview", &o)
+	if err != nil {
+		return err
+	}
+
+	var queues []queue
+	err = r.get(client, "/api/queues", &queues)
+	if err != nil {
+		return err
+	}
+
+	r.Connections = o.ObjectTotals.Connections
+	r.Queues = make([]Queue, len(queues))
+
+	for i, q := range queues {
+		r.Queues[i] = Queue{
+			Name:                   q.Name,
+			Vhost:                  q.Vhost,
+			MessagesReady:          q.MessagesReady,
+			MessagesUnacknowledged: q.MessagesUnacknowledged,
+			PublishRate:            q.MessageStats.PublishDetails.Rate,
+			DeliverRate:            q.MessageStats.DeliverGetDetails.Rate,
+		}
+	}
+
+	return nil
+}
+
+// get will request path relative to the base URL and decode the JSON
+// response into v.
+func (r *RabbitMQ) get(client *http.Client, path string, v interface{}) error {
+	url := strings.TrimRight(r.URL, "/") + path
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	username := r.Username
+	password := r.Password
+	if username == "" {
+		username = "guest"
+		password = "guest"
+	}
+	req.SetBasicAuth(username, password)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// GetPoints will return the number of connections and statistics per queue.
+func (r *RabbitMQ) GetPoints() []*timeseries.Point {
+	points := make([]*timeseries.Point, 1, 1+len(r.Queues)*4)
+
+	points[0] = plugins.SimplePoint("rabbitmq.Connections", r.Connections)
+
+	for _, q := range r.Queues {
+		tags := map[string]string{
+			"queue": q.Name,
+			"vhost": q.Vhost,
+		}
+
+		points = append(points,
+			plugins.PointWithTags("rabbitmq.MessagesReady", q.MessagesReady, tags),
+			plugins.PointWithTags("rabbitmq.MessagesUnacknowledged", q.MessagesUnacknowledged, tags),
+			plugins.PointWithTags("rabbitmq.PublishRate", q.PublishRate, tags),
+			plugins.PointWithTags("rabbitmq.DeliverRate", q.DeliverRate, tags),
+		)
+	}
+
+	return points
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (r *RabbitMQ) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("RabbitMQ management statistics")
+
+	doc.AddMeasurement("rabbitmq.Connections", "Open client connections", "n")
+	doc.AddMeasurement("rabbitmq.MessagesReady", "Messages ready for delivery", "n")
+	doc.AddMeasurement("rabbitmq.MessagesUnacknowledged", "Messages delivered but not yet acknowledged", "n")
+	doc.AddMeasurement("rabbitmq.PublishRate", "Messages published to the queue", "/s")
+	doc.AddMeasurement("rabbitmq.DeliverRate", "Messages delivered to consumers", "/s")
+
+	doc.AddTag("queue", "The queue name (not on rabbitmq.Connections)")
+	doc.AddTag("vhost", "The virtual host of the queue (not on rabbitmq.Connections)")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*RabbitMQ)(nil)
@@ -0,0 +1,123 @@
+package rabbitmq
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+)
+
+const (
+	overviewJSON = `{
+  "management_version": "3.12.4",
+  "rabbitmq_version": "3.12.4",
+  "object_totals": {"channels": 14, "connections": 7, "consumers": 3, "exchanges": 9, "queues": 2}
+}`
+
+	queuesJSON = `[
+  {
+    "name": "mail",
+    "vhost": "/",
+    "messages": 15,
+    "messages_ready": 12,
+    "messages_unacknowledged": 3,
+    "message_stats": {
+      "publish": 1200,
+      "publish_details": {"rate": 4.5},
+      "deliver_get": 1185,
+      "deliver_get_details": {"rate": 4.2}
+    }
+  },
+  {
+    "name": "idle",
+    "vhost": "jobs",
+    "messages": 0,
+    "messages_ready": 0,
+    "messages_unacknowledged": 0
+  }
+]`
+)
+
+func handler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "monitor" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/api/overview":
+			w.Write([]byte(overviewJSON))
+		case "/api/queues":
+			w.Write([]byte(queuesJSON))
+		default:
+			t.Errorf("Unexpected request for %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewRabbitMQ())
+}
+
+func TestGather(t *testing.T) {
+	server := httptest.NewServer(handler(t))
+	defer server.Close()
+
+	r := NewRabbitMQ().(*RabbitMQ)
+	r.URL = server.URL + "/"
+	r.Username = "monitor"
+	r.Password = "secret"
+
+	err := r.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if r.Connections != 7 {
+		t.Errorf("Got %d connections, expected 7", r.Connections)
+	}
+
+	expected := []Queue{
+		{"mail", "/", 12, 3, 4.5, 4.2},
+		{"idle", "jobs", 0, 0, 0, 0},
+	}
+
+	if !reflect.DeepEqual(r.Queues, expected) {
+		t.Errorf("Got queues %+v, expected %+v", r.Queues, expected)
+	}
+
+	points := r.GetPoints()
+	if len(points) != 9 {
+		t.Fatalf("Got %d points, expected 9", len(points))
+	}
+
+	if points[1].Name != "rabbitmq.MessagesReady" || points[1].Tags["queue"] != "mail" || points[1].Tags["vhost"] != "/" {
+		t.Errorf("Wrong point %+v", points[1])
+	}
+
+	plugins.GenericAgentTest(t, r)
+}
+
+func TestGatherUnauthorized(t *testing.T) {
+	server := httptest.NewServer(handler(t))
+	defer server.Close()
+
+	r := NewRabbitMQ().(*RabbitMQ)
+	r.URL = server.URL
+
+	err := r.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err == nil {
+		t.Fatalf("Gather() did not fail with default credentials")
+	}
+
+	r.URL = ""
+	if r.Gather(localtransport.NewLocalTransport().(plugins.Transport)) != ErrMissingURL {
+		t.Errorf("Gather() did not return ErrMissingURL")
+	}
+}
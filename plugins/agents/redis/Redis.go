@@ -0,0 +1,228 @@
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("redis", NewRedis)
+}
+
+// Redis will read server statistics using the INFO command. Keyspace hits,
+// misses and evicted keys are cumulative and will be converted to per-second
+// rates by Sub().
+type Redis struct {
+	Addr     string `toml:"addr" json:"addr" description:"Redis server address (host:port)" required:"true"`
+	Password string `toml:"password" json:"password" description:"Redis password"`
+	DB       int    `toml:"db" json:"db" description:"Redis database number"`
+	Timeout  int    `toml:"timeout" json:"timeout" description:"Connect and read timeout in seconds (default 5)"`
+
+	sampletime time.Time
+
+	ConnectedClients       int64            `json:"cc"`
+	UsedMemory             int64            `json:"um"`
+	InstantaneousOpsPerSec int64            `json:"io"`
+	KeyspaceHits           float64          `json:"kh"`
+	KeyspaceMisses         float64          `json:"km"`
+	EvictedKeys            float64          `json:"ek"`
+	Keys                   map[string]int64 `json:"k"`
+}
+
+var (
+	// ErrMissingAddr will be returned if no address is configured.
+	ErrMissingAddr = errors.New("addr must be set")
+)
+
+// NewRedis will return a new Redis.
+func NewRedis() interface{} {
+	return new(Redis)
+}
+
+// Gather will connect to the server and issue INFO.
+func (r *Redis) Gather(transport plugins.Transport) error {
+	if r.Addr == "" {
+		return ErrMissingAddr
+	}
+
+	timeout := 5 * time.Second
+	if r.Timeout > 0 {
+		timeout = time.Duration(r.Timeout) * time.Second
+	}
+
+	dial := func(network, addr string) (net.Conn, error) {
+		return transport.Dial(network, addr)
+	}
+
+	conn, err := redis.Dial("tcp", r.Addr,
+		redis.DialNetDial(dial),
+		redis.DialPassword(r.Password),
+		redis.DialDatabase(r.DB),
+		redis.DialConnectTimeout(timeout),
+		redis.DialReadTimeout(timeout),
+		redis.DialWriteTimeout(timeout),
+	)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	info, err := redis.String(conn.Do("INFO"))
+	if err != nil {
+		return err
+	}
+
+	r.sampletime = time.Now()
+
+	return r.parse(info)
+}
+
+// parse will parse the output of INFO. The output consists of "key:value"
+// lines grouped in sections starting with '#'.
+func (r *Redis) parse(info string) error {
+	r.ConnectedClients = 0
+	r.UsedMemory = 0
+	r.InstantaneousOpsPerSec = 0
+	r.KeyspaceHits = 0.0
+	r.KeyspaceMisses = 0.0
+	r.EvictedKeys = 0.0
+	r.Keys = make(map[string]int64)
+
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		key, value := parts[0], parts[1]
+
+		var err error
+		switch key {
+		case "connected_clients":
+			r.ConnectedClients, err = strconv.ParseInt(value, 10, 64)
+		case "used_memory":
+			r.UsedMemory, err = strconv.ParseInt(value, 10, 64)
+		case "instantaneous_ops_per_sec":
+			r.InstantaneousOpsPerSec, err = strconv.ParseInt(value, 10, 64)
+		case "keyspace_hits":
+			r.KeyspaceHits, err = strconv.ParseFloat(value, 64)
+		case "keyspace_misses":
+			r.KeyspaceMisses, err = strconv.ParseFloat(value, 64)
+		case "evicted_keys":
+			r.EvictedKeys, err = strconv.ParseFloat(value, 64)
+		default:
+			// Keyspace lines look like "db0:keys=1,expires=0,avg_ttl=0".
+			if strings.HasPrefix(key, "db") {
+				r.Keys[key], err = parseKeys(value)
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// parseKeys will extract the number of keys from a keyspace value.
+func parseKeys(value string) (int64, error) {
+	for _, field := range strings.Split(value, ",") {
+		if strings.HasPrefix(field, "keys=") {
+			return strconv.ParseInt(strings.TrimPrefix(field, "keys="), 10, 64)
+		}
+	}
+
+	return 0, nil
+}
+
+// Sub will calculate per-second rates for keyspace hits, misses and evicted
+// keys between previous and r. Gauges are copied as is. An empty Redis is
+// returned if previous is nil or no time has passed.
+func (r *Redis) Sub(previous *Redis) *Redis {
+	diff := &Redis{
+		Addr:     r.Addr,
+		Password: r.Password,
+		DB:       r.DB,
+		Timeout:  r.Timeout,
+		Keys:     make(map[string]int64),
+	}
+
+	if previous == nil {
+		return diff
+	}
+
+	duration := r.sampletime.Sub(previous.sampletime)
+	if duration <= 0 {
+		return diff
+	}
+
+	factor := duration.Seconds()
+
+	diff.sampletime = r.sampletime
+	diff.ConnectedClients = r.ConnectedClients
+	diff.UsedMemory = r.UsedMemory
+	diff.InstantaneousOpsPerSec = r.InstantaneousOpsPerSec
+	diff.KeyspaceHits = plugins.CounterRate(r.KeyspaceHits, previous.KeyspaceHits, factor)
+	diff.KeyspaceMisses = plugins.CounterRate(r.KeyspaceMisses, previous.KeyspaceMisses, factor)
+	diff.EvictedKeys = plugins.CounterRate(r.EvictedKeys, previous.EvictedKeys, factor)
+
+	for db, keys := range r.Keys {
+		diff.Keys[db] = keys
+	}
+
+	return diff
+}
+
+// GetPoints will return server statistics and a point per database.
+func (r *Redis) GetPoints() []*timeseries.Point {
+	points := []*timeseries.Point{
+		plugins.SimplePoint("redis.ConnectedClients", r.ConnectedClients),
+		plugins.SimplePoint("redis.UsedMemory", r.UsedMemory),
+		plugins.SimplePoint("redis.InstantaneousOpsPerSec", r.InstantaneousOpsPerSec),
+		plugins.SimplePoint("redis.KeyspaceHits", r.KeyspaceHits),
+		plugins.SimplePoint("redis.KeyspaceMisses", r.KeyspaceMisses),
+		plugins.SimplePoint("redis.EvictedKeys", r.EvictedKeys),
+	}
+
+	for db, keys := range r.Keys {
+		points = append(points, plugins.PointWithTag("redis.Keys", keys, "db", db))
+	}
+
+	return points
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (r *Redis) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("Redis server statistics")
+
+	doc.AddMeasurement("redis.ConnectedClients", "Number of client connections", "n")
+	doc.AddMeasurement("redis.UsedMemory", "Memory allocated by Redis", "b")
+	doc.AddMeasurement("redis.InstantaneousOpsPerSec", "Commands processed per second as reported by Redis", "/s")
+	doc.AddMeasurement("redis.KeyspaceHits", "Successful key lookups", "/s")
+	doc.AddMeasurement("redis.KeyspaceMisses", "Failed key lookups", "/s")
+	doc.AddMeasurement("redis.EvictedKeys", "Keys evicted due to the maxmemory limit", "/s")
+	doc.AddMeasurement("redis.Keys", "Number of keys in the database", "n")
+
+	doc.AddTag("db", "The database name (db0, db1, ...)")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*Redis)(nil)
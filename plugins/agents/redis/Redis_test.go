@@ -0,0 +1,172 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+)
+
+const (
+	info = "# Server\r\n" +
+		"redis_version:7.2.4\r\n" +
+		"uptime_in_seconds:86400\r\n" +
+		"\r\n" +
+		"# Clients\r\n" +
+		"connected_clients:42\r\n" +
+		"\r\n" +
+		"# Memory\r\n" +
+		"used_memory:1048576\r\n" +
+		"used_memory_human:1.00M\r\n" +
+		"\r\n" +
+		"# Stats\r\n" +
+		"instantaneous_ops_per_sec:117\r\n" +
+		"evicted_keys:5\r\n" +
+		"keyspace_hits:1000\r\n" +
+		"keyspace_misses:200\r\n" +
+		"\r\n" +
+		"# Keyspace\r\n" +
+		"db0:keys=1234,expires=12,avg_ttl=3600\r\n" +
+		"db3:keys=7,expires=0,avg_ttl=0\r\n"
+)
+
+// serve will start a fake Redis server answering every command with reply.
+// The commands received are sent to commands.
+func serve(t *testing.T, reply string, commands chan<- string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() failed: %s", err.Error())
+	}
+
+	go func() {
+		defer l.Close()
+
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		for {
+			// Commands are arrays of bulk strings: *<n>, then $<len> and
+			// the argument for each element.
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			var n int
+			fmt.Sscanf(line, "*%d", &n)
+
+			args := make([]string, n)
+			for i := range args {
+				reader.ReadString('\n')
+				arg, _ := reader.ReadString('\n')
+				args[i] = strings.TrimSpace(arg)
+			}
+
+			commands <- strings.Join(args, " ")
+
+			if args[0] == "INFO" {
+				fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(reply), reply)
+			} else {
+				fmt.Fprintf(conn, "+OK\r\n")
+			}
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewRedis())
+}
+
+func TestGather(t *testing.T) {
+	commands := make(chan string, 10)
+
+	r := NewRedis().(*Redis)
+	r.Addr = serve(t, info, commands)
+	r.Password = "secret"
+	r.DB = 3
+
+	err := r.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	expected := []string{"AUTH secret", "SELECT 3", "INFO"}
+	for _, e := range expected {
+		c := <-commands
+		if c != e {
+			t.Errorf("Got command '%s', expected '%s'", c, e)
+		}
+	}
+
+	if r.ConnectedClients != 42 || r.UsedMemory != 1048576 || r.InstantaneousOpsPerSec != 117 {
+		t.Errorf("Wrong gauges: %+v", r)
+	}
+
+	if r.KeyspaceHits != 1000 || r.KeyspaceMisses != 200 || r.EvictedKeys != 5 {
+		t.Errorf("Wrong counters: %+v", r)
+	}
+
+	if len(r.Keys) != 2 || r.Keys["db0"] != 1234 || r.Keys["db3"] != 7 {
+		t.Errorf("Wrong keyspace: %v", r.Keys)
+	}
+
+	points := r.GetPoints()
+	if len(points) != 8 {
+		t.Errorf("Got %d points, expected 8", len(points))
+	}
+}
+
+func TestGatherMissingAddr(t *testing.T) {
+	r := NewRedis().(*Redis)
+
+	err := r.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != ErrMissingAddr {
+		t.Fatalf("Gather() returned %v, expected ErrMissingAddr", err)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	r := NewRedis().(*Redis)
+
+	err := r.parse("connected_clients:many\r\n")
+	if err == nil {
+		t.Fatalf("parse() accepted invalid number")
+	}
+}
+
+func TestSub(t *testing.T) {
+	previous := NewRedis().(*Redis)
+	previous.parse(info)
+	previous.sampletime = time.Now()
+
+	current := NewRedis().(*Redis)
+	current.parse(strings.Replace(strings.Replace(info, "keyspace_hits:1000", "keyspace_hits:1500", 1), "keyspace_misses:200", "keyspace_misses:210", 1))
+	current.sampletime = previous.sampletime.Add(10 * time.Second)
+
+	diff := current.Sub(previous)
+	if diff.KeyspaceHits != 50.0 || diff.KeyspaceMisses != 1.0 || diff.EvictedKeys != 0.0 {
+		t.Errorf("Wrong rates: %+v", diff)
+	}
+
+	if diff.ConnectedClients != 42 || diff.Keys["db0"] != 1234 {
+		t.Errorf("Gauges not copied: %+v", diff)
+	}
+
+	// Counter reset after a server restart.
+	previous.sampletime = current.sampletime.Add(time.Second)
+	diff = previous.Sub(current)
+	if diff.KeyspaceHits != 0.0 {
+		t.Errorf("Counter reset resulted in %f hits/s", diff.KeyspaceHits)
+	}
+}
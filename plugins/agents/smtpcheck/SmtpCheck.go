@@ -0,0 +1,268 @@
+package smtpcheck
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("smtpcheck", NewSmtpCheck)
+}
+
+// SmtpCheck will check a mail server by reading the banner and doing the
+// EHLO/STARTTLS handshake. Optionally MAIL FROM and RCPT TO is tried for a
+// test address. Like tcpcheck, a failing server is not an error, it will be
+// reported as down.
+type SmtpCheck struct {
+	Host               string `toml:"host" json:"host" description:"The mail server to connect to" required:"true"`
+	Port               int    `toml:"port" json:"port" description:"The port to connect to (default 25)"`
+	Timeout            int    `toml:"timeout" json:"timeout" description:"Timeout for the complete check in seconds (default 10)"`
+	Helo               string `toml:"helo" json:"helo" description:"Name to send in EHLO (default localhost)"`
+	SkipStartTLS       bool   `toml:"skipStartTLS" json:"skipStartTLS" description:"Do not upgrade to TLS even if STARTTLS is offered"`
+	RequireStartTLS    bool   `toml:"requireStartTLS" json:"requireStartTLS" description:"Report the server as down if STARTTLS is not offered"`
+	InsecureSkipVerify bool   `toml:"insecureSkipVerify" json:"insecureSkipVerify" description:"Do not verify the server certificate"`
+	From               string `toml:"from" json:"from" description:"Sender for MAIL FROM (default empty sender)"`
+	Rcpt               string `toml:"rcpt" json:"rcpt" description:"Test address for RCPT TO. If empty, no mail transaction is tried"`
+
+	Up                bool          `json:"u"`
+	ConnectTime       time.Duration `json:"c"`
+	BannerCode        int           `json:"b"`
+	StartTLSSupported bool          `json:"s"`
+	RcptAccepted      bool          `json:"r"`
+	FailureReason     string        `json:"f"`
+}
+
+var (
+	// ErrMissingHost will be returned if no host is configured.
+	ErrMissingHost = errors.New("host must be set")
+)
+
+// NewSmtpCheck will return a new SmtpCheck.
+func NewSmtpCheck() interface{} {
+	return new(SmtpCheck)
+}
+
+// port will return the port to connect to.
+func (s *SmtpCheck) port() int {
+	if s.Port > 0 {
+		return s.Port
+	}
+
+	return 25
+}
+
+// Gather will connect to the mail server through transport and run the
+// check.
+func (s *SmtpCheck) Gather(transport plugins.Transport) error {
+	s.Up = false
+	s.ConnectTime = 0
+	s.BannerCode = 0
+	s.StartTLSSupported = false
+	s.RcptAccepted = false
+	s.FailureReason = ""
+
+	if s.Host == "" {
+		return ErrMissingHost
+	}
+
+	timeout := 10 * time.Second
+	if s.Timeout > 0 {
+		timeout = time.Duration(s.Timeout) * time.Second
+	}
+
+	start := time.Now()
+	conn, err := transport.Dial("tcp", net.JoinHostPort(s.Host, strconv.Itoa(s.port())))
+	if err != nil {
+		s.FailureReason = "connect"
+
+		return nil
+	}
+	defer conn.Close()
+
+	s.ConnectTime = time.Now().Sub(start)
+
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	s.FailureReason = s.check(conn)
+	s.Up = s.FailureReason == ""
+
+	return nil
+}
+
+// check will run the SMTP conversation on conn. The reason for the failure
+// is returned, or an empty string if the server is up.
+func (s *SmtpCheck) check(conn net.Conn) string {
+	text := textproto.NewConn(conn)
+
+	code, _, err := text.ReadResponse(0)
+	s.BannerCode = code
+	if err != nil || code != 220 {
+		return "banner"
+	}
+
+	extensions, err := s.ehlo(text)
+	if err != nil {
+		return "ehlo"
+	}
+
+	s.StartTLSSupported = extensions["STARTTLS"]
+
+	if s.RequireStartTLS && !s.StartTLSSupported {
+		return "starttls"
+	}
+
+	if s.StartTLSSupported && !s.SkipStartTLS {
+		_, _, err = cmd(text, 220, "STARTTLS")
+		if err != nil {
+			return "starttls"
+		}
+
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName:         s.Host,
+			InsecureSkipVerify: s.InsecureSkipVerify,
+		})
+
+		err = tlsConn.Handshake()
+		if err != nil {
+			return "tls"
+		}
+
+		text = textproto.NewConn(tlsConn)
+
+		// The session is reset after STARTTLS, we must greet again.
+		_, err = s.ehlo(text)
+		if err != nil {
+			return "ehlo"
+		}
+	}
+
+	if s.Rcpt != "" {
+		_, _, err = cmd(text, 250, "MAIL FROM:<%s>", s.From)
+		if err != nil {
+			return "mail"
+		}
+
+		_, _, err = cmd(text, 25, "RCPT TO:<%s>", s.Rcpt)
+		if err != nil {
+			return "rcpt"
+		}
+
+		s.RcptAccepted = true
+
+		cmd(text, 250, "RSET")
+	}
+
+	cmd(text, 221, "QUIT")
+
+	return ""
+}
+
+// ehlo will greet the server and return the supported extensions.
+func (s *SmtpCheck) ehlo(text *textproto.Conn) (map[string]bool, error) {
+	helo := s.Helo
+	if helo == "" {
+		helo = "localhost"
+	}
+
+	_, msg, err := cmd(text, 250, "EHLO %s", helo)
+	if err != nil {
+		return nil, err
+	}
+
+	// The first line is the greeting, the rest lists extensions.
+	extensions := make(map[string]bool)
+	lines := strings.Split(msg, "\n")
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) > 0 {
+			extensions[strings.ToUpper(fields[0])] = true
+		}
+	}
+
+	return extensions, nil
+}
+
+// cmd will send a command and read the response, expecting expectCode.
+func cmd(text *textproto.Conn, expectCode int, format string, args ...interface{}) (int, string, error) {
+	id, err := text.Cmd(format, args...)
+	if err != nil {
+		return 0, "", err
+	}
+
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+
+	return text.ReadResponse(expectCode)
+}
+
+// boolToInt will return 1 for true and 0 for false.
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// GetPoints will return the state of the server. Timing and handshake
+// results are only returned if the server is up.
+func (s *SmtpCheck) GetPoints() []*timeseries.Point {
+	tags := map[string]string{
+		"host": s.Host,
+		"port": strconv.Itoa(s.port()),
+	}
+
+	if !s.Up {
+		tags["failureReason"] = s.FailureReason
+
+		points := []*timeseries.Point{
+			plugins.PointWithTags("smtp.Up", 0, tags),
+		}
+
+		if s.BannerCode > 0 {
+			points = append(points, plugins.PointWithTags("smtp.BannerCode", s.BannerCode, tags))
+		}
+
+		return points
+	}
+
+	points := []*timeseries.Point{
+		plugins.PointWithTags("smtp.Up", 1, tags),
+		plugins.PointWithTags("smtp.ConnectTime", s.ConnectTime.Seconds()*1000.0, tags),
+		plugins.PointWithTags("smtp.BannerCode", s.BannerCode, tags),
+		plugins.PointWithTags("smtp.StartTLSSupported", boolToInt(s.StartTLSSupported), tags),
+	}
+
+	if s.Rcpt != "" {
+		points = append(points, plugins.PointWithTags("smtp.RcptAccepted", boolToInt(s.RcptAccepted), tags))
+	}
+
+	return points
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (s *SmtpCheck) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("SMTP server check")
+
+	doc.AddTag("host", "The mail server connected to")
+	doc.AddTag("port", "The port connected to")
+	doc.AddTag("failureReason", "Where the check failed (connect, banner, ehlo, starttls, tls, mail or rcpt)")
+	doc.AddMeasurement("smtp.Up", "1 if the server completed the check, 0 otherwise", "n")
+	doc.AddMeasurement("smtp.ConnectTime", "The time it took to open the connection", "ms")
+	doc.AddMeasurement("smtp.BannerCode", "The reply code of the server greeting (220 if ready)", "n")
+	doc.AddMeasurement("smtp.StartTLSSupported", "1 if the server offers STARTTLS, 0 otherwise", "n")
+	doc.AddMeasurement("smtp.RcptAccepted", "1 if the test address was accepted by RCPT TO (only if rcpt is set)", "n")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*SmtpCheck)(nil)
@@ -0,0 +1,229 @@
+package smtpcheck
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+)
+
+type (
+	// stub is a minimal SMTP server.
+	stub struct {
+		banner   string
+		cert     *tls.Certificate
+		rejectTo bool
+	}
+)
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewSmtpCheck())
+}
+
+// newCert will return a self-signed certificate for localhost.
+func newCert(t *testing.T) *tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() failed: %s", err.Error())
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() failed: %s", err.Error())
+	}
+
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// serve will serve SMTP until the returned listener is closed.
+func (s *stub) serve(t *testing.T) (net.Listener, int) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() failed: %s", err.Error())
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go s.handle(conn)
+		}
+	}()
+
+	return l, l.Addr().(*net.TCPAddr).Port
+}
+
+// handle will run a single SMTP session.
+func (s *stub) handle(conn net.Conn) {
+	defer func() { conn.Close() }()
+
+	text := textproto.NewConn(conn)
+	text.PrintfLine("%s", s.banner)
+
+	if !strings.HasPrefix(s.banner, "220") {
+		return
+	}
+
+	tlsActive := false
+
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb := strings.ToUpper(strings.Fields(line + " ")[0])
+
+		switch verb {
+		case "EHLO":
+			if s.cert != nil && !tlsActive {
+				text.PrintfLine("250-stub.example.com")
+				text.PrintfLine("250-PIPELINING")
+				text.PrintfLine("250 STARTTLS")
+			} else {
+				text.PrintfLine("250-stub.example.com")
+				text.PrintfLine("250 PIPELINING")
+			}
+		case "STARTTLS":
+			text.PrintfLine("220 Go ahead")
+
+			tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{*s.cert}})
+			if tlsConn.Handshake() != nil {
+				return
+			}
+
+			conn = tlsConn
+			text = textproto.NewConn(conn)
+			tlsActive = true
+		case "MAIL":
+			text.PrintfLine("250 OK")
+		case "RCPT":
+			if s.rejectTo {
+				text.PrintfLine("550 No such user")
+			} else {
+				text.PrintfLine("250 OK")
+			}
+		case "RSET":
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+func TestGather(t *testing.T) {
+	transport := localtransport.NewLocalTransport().(plugins.Transport)
+	cert := newCert(t)
+
+	cases := []struct {
+		stub     stub
+		check    SmtpCheck
+		up       bool
+		reason   string
+		banner   int
+		starttls bool
+		rcpt     bool
+		points   int
+	}{
+		{stub{banner: "220 stub ESMTP"}, SmtpCheck{}, true, "", 220, false, false, 4},
+		{stub{banner: "554 go away"}, SmtpCheck{}, false, "banner", 554, false, false, 2},
+		{stub{banner: "220 stub ESMTP", cert: cert}, SmtpCheck{InsecureSkipVerify: true}, true, "", 220, true, false, 4},
+		{stub{banner: "220 stub ESMTP", cert: cert}, SmtpCheck{}, false, "tls", 220, true, false, 2},
+		{stub{banner: "220 stub ESMTP", cert: cert}, SmtpCheck{SkipStartTLS: true}, true, "", 220, true, false, 4},
+		{stub{banner: "220 stub ESMTP"}, SmtpCheck{RequireStartTLS: true}, false, "starttls", 220, false, false, 2},
+		{stub{banner: "220 stub ESMTP", cert: cert}, SmtpCheck{InsecureSkipVerify: true, Rcpt: "test@example.com"}, true, "", 220, true, true, 5},
+		{stub{banner: "220 stub ESMTP", rejectTo: true}, SmtpCheck{Rcpt: "test@example.com"}, false, "rcpt", 220, false, false, 2},
+	}
+
+	for i, c := range cases {
+		l, port := c.stub.serve(t)
+
+		check := c.check
+		check.Host = "127.0.0.1"
+		check.Port = port
+		check.Timeout = 5
+
+		err := check.Gather(transport)
+		l.Close()
+
+		if err != nil {
+			t.Fatalf("%d: Gather() failed: %s", i, err.Error())
+		}
+
+		if check.Up != c.up || check.FailureReason != c.reason {
+			t.Errorf("%d: Got up=%v reason '%s', expected up=%v reason '%s'", i, check.Up, check.FailureReason, c.up, c.reason)
+		}
+
+		if check.BannerCode != c.banner {
+			t.Errorf("%d: Got banner code %d, expected %d", i, check.BannerCode, c.banner)
+		}
+
+		if check.StartTLSSupported != c.starttls {
+			t.Errorf("%d: Got StartTLSSupported %v, expected %v", i, check.StartTLSSupported, c.starttls)
+		}
+
+		if check.RcptAccepted != c.rcpt {
+			t.Errorf("%d: Got RcptAccepted %v, expected %v", i, check.RcptAccepted, c.rcpt)
+		}
+
+		if len(check.GetPoints()) != c.points {
+			t.Errorf("%d: Got %d points, expected %d", i, len(check.GetPoints()), c.points)
+		}
+
+		plugins.GenericAgentTest(t, &check)
+	}
+}
+
+func TestGatherClosed(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() failed: %s", err.Error())
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	check := &SmtpCheck{Host: "127.0.0.1", Port: port}
+	err = check.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if check.Up || check.FailureReason != "connect" {
+		t.Fatalf("Closed port reported up=%v reason '%s'", check.Up, check.FailureReason)
+	}
+}
+
+func TestGatherMissingHost(t *testing.T) {
+	check := &SmtpCheck{}
+	err := check.Gather(nil)
+	if err != ErrMissingHost {
+		t.Fatalf("Gather() did not fail on missing host")
+	}
+}
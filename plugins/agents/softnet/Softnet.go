@@ -0,0 +1,181 @@
+package softnet
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("softnet", NewSoftnet)
+}
+
+type (
+	// Softnet will read per-cpu network stack statistics from
+	// /proc/net/softnet_stat. Dropped packets means that the backlog queue
+	// was full, a sign of a saturated network stack.
+	Softnet struct {
+		sampletime time.Time
+		warnings   []string
+		Cpu        map[string]*SingleSoftnet `json:"cpu"`
+	}
+
+	// SingleSoftnet is the statistics for a single cpu.
+	SingleSoftnet struct {
+		Processed    float64 `json:"p"`
+		Dropped      float64 `json:"d"`
+		TimeSqueezed float64 `json:"t"`
+	}
+)
+
+// NewSoftnet will return a new Softnet.
+func NewSoftnet() interface{} {
+	return new(Softnet)
+}
+
+// Gather will read /proc/net/softnet_stat.
+func (s *Softnet) Gather(transport plugins.Transport) error {
+	path := filepath.Join(configuration.ProcPath, "/net/softnet_stat")
+	file, err := transport.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	s.sampletime = time.Now()
+
+	return s.parse(file)
+}
+
+// parse will parse the contents of softnet_stat. Each line is a cpu, all
+// values are hexadecimal. Since Linux 5.10 the 13th column holds the cpu
+// index, on older kernels we use the line number. Lines that cannot be parsed
+// are skipped and reported by Warnings().
+func (s *Softnet) parse(r io.Reader) error {
+	s.Cpu = make(map[string]*SingleSoftnet)
+	s.warnings = nil
+
+	line := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+
+		var values [3]uint64
+		var err error
+		for i := range values {
+			values[i], err = strconv.ParseUint(fields[i], 16, 32)
+			if err != nil {
+				break
+			}
+		}
+
+		if err != nil {
+			s.warnings = append(s.warnings, fmt.Sprintf("line %d: %s", line+1, err.Error()))
+			line++
+			continue
+		}
+
+		cpu := strconv.Itoa(line)
+		if len(fields) >= 13 {
+			index, err := strconv.ParseUint(fields[12], 16, 32)
+			if err == nil {
+				cpu = strconv.FormatUint(index, 10)
+			}
+		}
+
+		s.Cpu[cpu] = &SingleSoftnet{
+			Processed:    float64(values[0]),
+			Dropped:      float64(values[1]),
+			TimeSqueezed: float64(values[2]),
+		}
+
+		line++
+	}
+
+	return scanner.Err()
+}
+
+// Warnings will return the lines skipped by the last Gather().
+func (s *Softnet) Warnings() []string {
+	return s.warnings
+}
+
+// Sub will calculate per-second rates between previous and s. An empty
+// Softnet is returned if previous is nil or no time has passed. Cpus not
+// present in both samples are left out.
+func (s *Softnet) Sub(previous *Softnet) *Softnet {
+	diff := &Softnet{
+		Cpu: make(map[string]*SingleSoftnet),
+	}
+
+	if previous == nil {
+		return diff
+	}
+
+	duration := s.sampletime.Sub(previous.sampletime)
+	if duration <= 0 {
+		return diff
+	}
+
+	factor := duration.Seconds()
+
+	for key, value := range s.Cpu {
+		prev, found := previous.Cpu[key]
+		if found {
+			diff.Cpu[key] = &SingleSoftnet{
+				Processed:    plugins.CounterRate(value.Processed, prev.Processed, factor),
+				Dropped:      plugins.CounterRate(value.Dropped, prev.Dropped, factor),
+				TimeSqueezed: plugins.CounterRate(value.TimeSqueezed, prev.TimeSqueezed, factor),
+			}
+		}
+	}
+
+	diff.sampletime = s.sampletime
+
+	return diff
+}
+
+// GetPoints will return three points per cpu.
+func (s *Softnet) GetPoints() []*timeseries.Point {
+	points := make([]*timeseries.Point, 0, len(s.Cpu)*3)
+
+	for key, value := range s.Cpu {
+		points = append(points,
+			plugins.PointWithTag("softnet.Processed", value.Processed, "cpu", key),
+			plugins.PointWithTag("softnet.Dropped", value.Dropped, "cpu", key),
+			plugins.PointWithTag("softnet.TimeSqueezed", value.TimeSqueezed, "cpu", key),
+		)
+	}
+
+	return points
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (s *Softnet) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("Network stack backlog")
+
+	doc.AddTag("cpu", "The cpu index")
+
+	doc.AddMeasurement("softnet.Processed", "Packets processed", "packets/s")
+	doc.AddMeasurement("softnet.Dropped", "Packets dropped because the backlog queue was full", "packets/s")
+	doc.AddMeasurement("softnet.TimeSqueezed", "Times the softirq ran out of budget or time with work remaining", "/s")
+
+	return doc
+}
+
+// Ensure compliance.
+var (
+	_ plugins.Agent  = (*Softnet)(nil)
+	_ plugins.Warner = (*Softnet)(nil)
+)
@@ -0,0 +1,151 @@
+package softnet
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/mock"
+)
+
+var (
+	// Linux 4.x, no cpu index.
+	oldFormat = []byte(`0000a2b1 00000000 00000003 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000
+00001000 00000010 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000
+`)
+
+	// Linux 5.10+, cpu 1 is offline.
+	newFormat1 = []byte(`000003e8 00000000 00000001 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000
+000007d0 0000000a 00000002 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000002
+`)
+
+	newFormat2 = []byte(`000007d0 00000000 00000001 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000
+00000fa0 0000001e 00000006 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000002
+`)
+)
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewSoftnet())
+}
+
+func TestParse(t *testing.T) {
+	s := NewSoftnet().(*Softnet)
+
+	err := s.parse(bytes.NewReader(oldFormat))
+	if err != nil {
+		t.Fatalf("parse() failed: %s", err.Error())
+	}
+
+	expected := map[string]SingleSoftnet{
+		"0": {Processed: 0xa2b1, Dropped: 0, TimeSqueezed: 3},
+		"1": {Processed: 0x1000, Dropped: 0x10, TimeSqueezed: 0},
+	}
+
+	if len(s.Cpu) != len(expected) {
+		t.Fatalf("Got %d cpus, expected %d", len(s.Cpu), len(expected))
+	}
+
+	for cpu, e := range expected {
+		if *s.Cpu[cpu] != e {
+			t.Errorf("cpu%s is %+v, expected %+v", cpu, *s.Cpu[cpu], e)
+		}
+	}
+
+	// The cpu index must be used when present.
+	err = s.parse(bytes.NewReader(newFormat1))
+	if err != nil {
+		t.Fatalf("parse() failed: %s", err.Error())
+	}
+
+	if _, found := s.Cpu["2"]; !found {
+		t.Errorf("cpu index column was ignored: %+v", s.Cpu)
+	}
+
+	if len(s.Warnings()) != 0 {
+		t.Errorf("Got warnings for valid input: %v", s.Warnings())
+	}
+}
+
+func TestParseWarnings(t *testing.T) {
+	s := NewSoftnet().(*Softnet)
+
+	input := []byte("0000000a 00000000 00000000\nzzzzzzzz 00000000 00000000\n00000014 00000001 00000000\n")
+
+	err := s.parse(bytes.NewReader(input))
+	if err != nil {
+		t.Fatalf("parse() failed on a single invalid line: %s", err.Error())
+	}
+
+	if len(s.Cpu) != 2 {
+		t.Errorf("Got %d cpus, expected 2", len(s.Cpu))
+	}
+
+	// The line number must still count the skipped line.
+	if s.Cpu["2"] == nil || s.Cpu["2"].Processed != 20 {
+		t.Errorf("cpu2 is %+v, expected 20 processed", s.Cpu["2"])
+	}
+
+	warnings := plugins.GetWarnings(s)
+	if len(warnings) != 1 {
+		t.Fatalf("Got %d warnings, expected 1: %v", len(warnings), warnings)
+	}
+
+	if !strings.HasPrefix(warnings[0], "line 2:") {
+		t.Errorf("Got warning '%s', expected it to mention line 2", warnings[0])
+	}
+}
+
+func TestGather(t *testing.T) {
+	mock := mocktransport.NewMock().(*mocktransport.Mock)
+
+	s := NewSoftnet().(*Softnet)
+	err := s.Gather(mock)
+	if err == nil {
+		t.Fatalf("Gather() did not fail without softnet_stat")
+	}
+
+	mock.SetFile("/proc/net/softnet_stat", oldFormat)
+
+	err = s.Gather(mock)
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if len(s.GetPoints()) != 6 {
+		t.Fatalf("Got %d points, expected 6", len(s.GetPoints()))
+	}
+}
+
+func TestSub(t *testing.T) {
+	previous := NewSoftnet().(*Softnet)
+	previous.parse(bytes.NewReader(newFormat1))
+
+	current := NewSoftnet().(*Softnet)
+	current.parse(bytes.NewReader(newFormat2))
+	current.sampletime = previous.sampletime.Add(2 * time.Second)
+
+	diff := current.Sub(previous)
+
+	expected := map[string]SingleSoftnet{
+		"0": {Processed: 500, Dropped: 0, TimeSqueezed: 0},
+		"2": {Processed: 1000, Dropped: 10, TimeSqueezed: 2},
+	}
+
+	for cpu, e := range expected {
+		if *diff.Cpu[cpu] != e {
+			t.Errorf("cpu%s is %+v, expected %+v", cpu, *diff.Cpu[cpu], e)
+		}
+	}
+
+	// A zero duration must not give us Inf or NaN.
+	current.sampletime = previous.sampletime
+	if len(current.Sub(previous).Cpu) != 0 {
+		t.Errorf("Sub() returned rates for a zero duration")
+	}
+
+	if len(current.Sub(nil).Cpu) != 0 {
+		t.Errorf("Sub() returned rates without a previous sample")
+	}
+}
@@ -0,0 +1,151 @@
+package systemd
+
+import (
+	"context"
+	"strings"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("systemd", NewSystemd)
+}
+
+type (
+	// Systemd will report the state of a list of systemd units using the
+	// D-Bus API. The transport is not used, only local units can be
+	// monitored.
+	Systemd struct {
+		Units []string `toml:"units" json:"units" description:"Units to monitor (for example nginx.service)" required:"true"`
+
+		States map[string]*Unit `json:"s"`
+	}
+
+	// Unit is the state of a single unit.
+	Unit struct {
+		ActiveState string `json:"a"`
+		SubState    string `json:"s"`
+		NRestarts   int64  `json:"r"`
+	}
+
+	// conn is the subset of *dbus.Conn used by Systemd.
+	conn interface {
+		ListUnitsByNamesContext(ctx context.Context, units []string) ([]dbus.UnitStatus, error)
+		GetUnitTypePropertyContext(ctx context.Context, unit string, unitType string, propertyName string) (*dbus.Property, error)
+		Close()
+	}
+)
+
+const (
+	// NotFound is used as ActiveState and SubState for units unknown to
+	// systemd.
+	NotFound = "not-found"
+)
+
+var (
+	// dial will connect to systemd. Replaced when testing.
+	dial = func(ctx context.Context) (conn, error) {
+		return dbus.NewWithContext(ctx)
+	}
+)
+
+// NewSystemd will return a new Systemd.
+func NewSystemd() interface{} {
+	return new(Systemd)
+}
+
+// Gather will read the state of all configured units. Units not known to
+// systemd will be reported as NotFound.
+func (s *Systemd) Gather(_ plugins.Transport) error {
+	s.States = make(map[string]*Unit)
+
+	ctx := context.Background()
+
+	c, err := dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	statuses, err := c.ListUnitsByNamesContext(ctx, s.Units)
+	if err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		if status.LoadState == NotFound {
+			s.States[status.Name] = &Unit{
+				ActiveState: NotFound,
+				SubState:    NotFound,
+			}
+
+			continue
+		}
+
+		unit := &Unit{
+			ActiveState: status.ActiveState,
+			SubState:    status.SubState,
+		}
+
+		// Only services can be restarted.
+		if strings.HasSuffix(status.Name, ".service") {
+			prop, err := c.GetUnitTypePropertyContext(ctx, status.Name, "Service", "NRestarts")
+			if err == nil {
+				if n, ok := prop.Value.Value().(uint32); ok {
+					unit.NRestarts = int64(n)
+				}
+			}
+		}
+
+		s.States[status.Name] = unit
+	}
+
+	return nil
+}
+
+// active will map ActiveState to a number. 1 for active, -1 for units not
+// found and 0 for everything else.
+func (u *Unit) active() int64 {
+	switch u.ActiveState {
+	case "active":
+		return 1
+	case NotFound:
+		return -1
+	}
+
+	return 0
+}
+
+// GetPoints will return a set of points for each unit.
+func (s *Systemd) GetPoints() []*timeseries.Point {
+	points := make([]*timeseries.Point, 0, len(s.States)*3)
+
+	for name, unit := range s.States {
+		points = append(points,
+			plugins.PointWithTag("systemd.ActiveState", unit.active(), "unit", name),
+			plugins.PointWithTag("systemd.SubState", unit.SubState, "unit", name),
+			plugins.PointWithTag("systemd.NRestarts", unit.NRestarts, "unit", name),
+		)
+	}
+
+	return points
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (s *Systemd) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("Systemd unit state")
+
+	doc.AddMeasurement("systemd.ActiveState", "1 if the unit is active, 0 if not and -1 if the unit is unknown", "")
+	doc.AddMeasurement("systemd.SubState", "The unit specific state (running, exited, dead, ...)", "")
+	doc.AddMeasurement("systemd.NRestarts", "Number of automatic restarts of a service", "n")
+
+	doc.AddTag("unit", "The unit name")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*Systemd)(nil)
@@ -0,0 +1,154 @@
+package systemd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+	godbus "github.com/godbus/dbus/v5"
+
+	"github.com/abrander/agento/plugins"
+)
+
+type (
+	// fakeConn will answer with the units in units.
+	fakeConn struct {
+		units    map[string]dbus.UnitStatus
+		restarts map[string]uint32
+		closed   bool
+	}
+)
+
+func (c *fakeConn) ListUnitsByNamesContext(_ context.Context, names []string) ([]dbus.UnitStatus, error) {
+	var statuses []dbus.UnitStatus
+
+	for _, name := range names {
+		status, found := c.units[name]
+		if !found {
+			status = dbus.UnitStatus{
+				Name:        name,
+				LoadState:   "not-found",
+				ActiveState: "inactive",
+				SubState:    "dead",
+			}
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+func (c *fakeConn) GetUnitTypePropertyContext(_ context.Context, unit string, unitType string, propertyName string) (*dbus.Property, error) {
+	if unitType != "Service" || propertyName != "NRestarts" {
+		return nil, errors.New("unknown property")
+	}
+
+	return &dbus.Property{
+		Name:  propertyName,
+		Value: godbus.MakeVariant(c.restarts[unit]),
+	}, nil
+}
+
+func (c *fakeConn) Close() {
+	c.closed = true
+}
+
+// useConn will make Gather() use c.
+func useConn(t *testing.T, c conn, err error) {
+	original := dial
+	dial = func(_ context.Context) (conn, error) {
+		return c, err
+	}
+
+	t.Cleanup(func() { dial = original })
+}
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewSystemd())
+}
+
+func TestGather(t *testing.T) {
+	fake := &fakeConn{
+		units: map[string]dbus.UnitStatus{
+			"nginx.service": {Name: "nginx.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+			"cron.service":  {Name: "cron.service", LoadState: "loaded", ActiveState: "failed", SubState: "failed"},
+			"backup.timer":  {Name: "backup.timer", LoadState: "loaded", ActiveState: "active", SubState: "waiting"},
+		},
+		restarts: map[string]uint32{
+			"nginx.service": 3,
+			"cron.service":  7,
+		},
+	}
+	useConn(t, fake, nil)
+
+	s := NewSystemd().(*Systemd)
+	s.Units = []string{"nginx.service", "cron.service", "backup.timer", "missing.service"}
+
+	err := s.Gather(nil)
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if !fake.closed {
+		t.Errorf("Connection not closed")
+	}
+
+	expected := map[string]struct {
+		active    int64
+		subState  string
+		nRestarts int64
+	}{
+		"nginx.service":   {1, "running", 3},
+		"cron.service":    {0, "failed", 7},
+		"backup.timer":    {1, "waiting", 0},
+		"missing.service": {-1, NotFound, 0},
+	}
+
+	if len(s.States) != len(expected) {
+		t.Fatalf("Got %d units, expected %d", len(s.States), len(expected))
+	}
+
+	for name, e := range expected {
+		unit, found := s.States[name]
+		if !found {
+			t.Fatalf("Unit %s not found", name)
+		}
+
+		if unit.active() != e.active {
+			t.Errorf("%s: ActiveState is %d, expected %d", name, unit.active(), e.active)
+		}
+
+		if unit.SubState != e.subState {
+			t.Errorf("%s: SubState is '%s', expected '%s'", name, unit.SubState, e.subState)
+		}
+
+		if unit.NRestarts != e.nRestarts {
+			t.Errorf("%s: NRestarts is %d, expected %d", name, unit.NRestarts, e.nRestarts)
+		}
+	}
+
+	points := s.GetPoints()
+	if len(points) != 12 {
+		t.Fatalf("Got %d points, expected 12", len(points))
+	}
+
+	for _, point := range points {
+		if point.Tags["unit"] == "" {
+			t.Errorf("%s is missing the unit tag", point.Name)
+		}
+	}
+}
+
+func TestGatherDialError(t *testing.T) {
+	useConn(t, nil, errors.New("no bus"))
+
+	s := NewSystemd().(*Systemd)
+	s.Units = []string{"nginx.service"}
+
+	err := s.Gather(nil)
+	if err == nil {
+		t.Fatalf("Gather() did not return dial error")
+	}
+}
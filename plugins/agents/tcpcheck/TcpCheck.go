@@ -0,0 +1,121 @@
+package tcpcheck
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("tcpcheck", NewTcpCheck)
+}
+
+// TcpCheck will check if a TCP port is accepting connections. Unlike tcpport
+// a failed connection is not an error, it will be reported as down.
+type TcpCheck struct {
+	Host    string `toml:"host" json:"host" description:"The host to connect to" required:"true"`
+	Port    int    `toml:"port" json:"port" description:"The TCP port to connect to" required:"true"`
+	Timeout int    `toml:"timeout" json:"timeout" description:"Connect timeout in seconds (default 5)"`
+
+	Up            bool          `json:"u"`
+	ConnectTime   time.Duration `json:"c"`
+	FailureReason string        `json:"f"`
+}
+
+var (
+	// ErrMissingAddress will be returned if host or port is not configured.
+	ErrMissingAddress = errors.New("host and port must be set")
+)
+
+// NewTcpCheck will return a new TcpCheck.
+func NewTcpCheck() interface{} {
+	return new(TcpCheck)
+}
+
+// Gather will try to connect to the port. The transport is ignored, the
+// connection will always originate from the local host.
+func (t *TcpCheck) Gather(_ plugins.Transport) error {
+	t.Up = false
+	t.ConnectTime = 0
+	t.FailureReason = ""
+
+	if t.Host == "" || t.Port <= 0 {
+		return ErrMissingAddress
+	}
+
+	timeout := 5 * time.Second
+	if t.Timeout > 0 {
+		timeout = time.Duration(t.Timeout) * time.Second
+	}
+
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(t.Host, strconv.Itoa(t.Port)), timeout)
+	if err != nil {
+		t.FailureReason = failureReason(err)
+
+		return nil
+	}
+	t.ConnectTime = time.Now().Sub(start)
+	t.Up = true
+
+	return conn.Close()
+}
+
+// failureReason will classify a connection error.
+func failureReason(err error) string {
+	var netErr net.Error
+	var dnsErr *net.DNSError
+
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "refused"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &dnsErr):
+		return "dns"
+	default:
+		return "error"
+	}
+}
+
+// GetPoints will return the state of the port and the connect time if up.
+func (t *TcpCheck) GetPoints() []*timeseries.Point {
+	tags := map[string]string{
+		"host": t.Host,
+		"port": strconv.Itoa(t.Port),
+	}
+
+	if !t.Up {
+		tags["failureReason"] = t.FailureReason
+
+		return []*timeseries.Point{
+			plugins.PointWithTags("tcp.Up", 0, tags),
+		}
+	}
+
+	return []*timeseries.Point{
+		plugins.PointWithTags("tcp.Up", 1, tags),
+		plugins.PointWithTags("tcp.ConnectTime", t.ConnectTime.Seconds()*1000.0, tags),
+	}
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (t *TcpCheck) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("TCP port check")
+
+	doc.AddTag("host", "The host connected to")
+	doc.AddTag("port", "The port connected to")
+	doc.AddTag("failureReason", "Why the connection failed (refused, timeout, dns or error)")
+	doc.AddMeasurement("tcp.Up", "1 if the port accepted the connection, 0 otherwise", "n")
+	doc.AddMeasurement("tcp.ConnectTime", "The time it took to open the connection", "ms")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*TcpCheck)(nil)
@@ -0,0 +1,107 @@
+package tcpcheck
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/abrander/agento/plugins"
+)
+
+type (
+	timeoutError struct{}
+)
+
+func (e timeoutError) Error() string   { return "timeout" }
+func (e timeoutError) Timeout() bool   { return true }
+func (e timeoutError) Temporary() bool { return true }
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewTcpCheck())
+}
+
+func listen(t *testing.T) (net.Listener, int) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() failed: %s", err.Error())
+	}
+
+	return l, l.Addr().(*net.TCPAddr).Port
+}
+
+func TestGatherUp(t *testing.T) {
+	l, port := listen(t)
+	defer l.Close()
+
+	check := &TcpCheck{Host: "127.0.0.1", Port: port}
+	err := check.Gather(nil)
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if !check.Up {
+		t.Fatalf("Port reported down: %s", check.FailureReason)
+	}
+
+	points := check.GetPoints()
+	if len(points) != 2 {
+		t.Fatalf("Got %d points, expected 2", len(points))
+	}
+
+	plugins.GenericAgentTest(t, check)
+}
+
+func TestGatherClosed(t *testing.T) {
+	l, port := listen(t)
+	l.Close()
+
+	check := &TcpCheck{Host: "127.0.0.1", Port: port}
+	err := check.Gather(nil)
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if check.Up {
+		t.Fatalf("Closed port reported up")
+	}
+
+	if check.FailureReason != "refused" {
+		t.Fatalf("Wrong failure reason, got '%s', expected 'refused'", check.FailureReason)
+	}
+
+	points := check.GetPoints()
+	if len(points) != 1 || points[0].Tags["failureReason"] != "refused" {
+		t.Fatalf("Wrong points for closed port: %+v", points)
+	}
+
+	plugins.GenericAgentTest(t, check)
+}
+
+func TestGatherMissingAddress(t *testing.T) {
+	check := &TcpCheck{Host: "127.0.0.1"}
+	err := check.Gather(nil)
+	if err != ErrMissingAddress {
+		t.Fatalf("Gather() did not fail on missing port")
+	}
+}
+
+func TestFailureReason(t *testing.T) {
+	cases := []struct {
+		err      error
+		expected string
+	}{
+		{&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, "refused"},
+		{&net.OpError{Op: "dial", Err: timeoutError{}}, "timeout"},
+		{&net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "invalid"}}, "dns"},
+		{errors.New("something"), "error"},
+	}
+
+	for _, c := range cases {
+		reason := failureReason(c.err)
+		if reason != c.expected {
+			t.Errorf("failureReason(%s) returned '%s', expected '%s'", c.err, reason, c.expected)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package tcpconn
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("tcpconn", NewTcpConn)
+}
+
+// TcpConn counts TCP sockets by state for both IPv4 and IPv6. Unlike the
+// sockets agent, UDP sockets are not included.
+// https://www.kernel.org/doc/Documentation/networking/proc_net_tcp.txt
+type TcpConn struct {
+	Established int64 `json:"e"`
+	SynSent     int64 `json:"s"`
+	SynRecv     int64 `json:"S"`
+	FinWait1    int64 `json:"f"`
+	FinWait2    int64 `json:"F"`
+	TimeWait    int64 `json:"t"`
+	Close       int64 `json:"c"`
+	CloseWait   int64 `json:"C"`
+	LastAck     int64 `json:"a"`
+	Listen      int64 `json:"l"`
+	Closing     int64 `json:"o"`
+	NewSynRecv  int64 `json:"n"`
+}
+
+// NewTcpConn will return a new TcpConn.
+func NewTcpConn() interface{} {
+	return new(TcpConn)
+}
+
+// counter will return the field counting sockets in state, as found in the
+// "st" column. nil is returned for unknown states.
+func (c *TcpConn) counter(state string) *int64 {
+	switch state {
+	case "01":
+		return &c.Established
+	case "02":
+		return &c.SynSent
+	case "03":
+		return &c.SynRecv
+	case "04":
+		return &c.FinWait1
+	case "05":
+		return &c.FinWait2
+	case "06":
+		return &c.TimeWait
+	case "07":
+		return &c.Close
+	case "08":
+		return &c.CloseWait
+	case "09":
+		return &c.LastAck
+	case "0A":
+		return &c.Listen
+	case "0B":
+		return &c.Closing
+	case "0C":
+		return &c.NewSynRecv
+	}
+
+	return nil
+}
+
+// Gather will read /proc/net/tcp and /proc/net/tcp6. A missing tcp6 is not
+// an error, IPv6 could be disabled.
+func (c *TcpConn) Gather(transport plugins.Transport) error {
+	*c = TcpConn{}
+
+	err := c.read(transport, filepath.Join(configuration.ProcPath, "/net/tcp"))
+	if err != nil {
+		return err
+	}
+
+	err = c.read(transport, filepath.Join(configuration.ProcPath, "/net/tcp6"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// read will count the sockets listed in the tcp table at path.
+func (c *TcpConn) read(transport plugins.Transport, path string) error {
+	file, err := transport.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	// Skip the header.
+	scanner.Scan()
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+
+		counter := c.counter(fields[3])
+		if counter != nil {
+			*counter++
+		}
+	}
+
+	return scanner.Err()
+}
+
+// GetPoints will return a point per state.
+func (c *TcpConn) GetPoints() []*timeseries.Point {
+	points := make([]*timeseries.Point, 12)
+
+	points[0] = plugins.SimplePoint("tcpconn.Established", c.Established)
+	points[1] = plugins.SimplePoint("tcpconn.SynSent", c.SynSent)
+	points[2] = plugins.SimplePoint("tcpconn.SynRecv", c.SynRecv)
+	points[3] = plugins.SimplePoint("tcpconn.FinWait1", c.FinWait1)
+	points[4] = plugins.SimplePoint("tcpconn.FinWait2", c.FinWait2)
+	points[5] = plugins.SimplePoint("tcpconn.TimeWait", c.TimeWait)
+	points[6] = plugins.SimplePoint("tcpconn.Close", c.Close)
+	points[7] = plugins.SimplePoint("tcpconn.CloseWait", c.CloseWait)
+	points[8] = plugins.SimplePoint("tcpconn.LastAck", c.LastAck)
+	points[9] = plugins.SimplePoint("tcpconn.Listen", c.Listen)
+	points[10] = plugins.SimplePoint("tcpconn.Closing", c.Closing)
+	points[11] = plugins.SimplePoint("tcpconn.NewSynRecv", c.NewSynRecv)
+
+	return points
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (c *TcpConn) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("TCP connection states")
+
+	doc.AddMeasurement("tcpconn.Established", "TCP sockets in state ESTABLISHED", "n")
+	doc.AddMeasurement("tcpconn.SynSent", "TCP sockets in state SYN_SENT", "n")
+	doc.AddMeasurement("tcpconn.SynRecv", "TCP sockets in state SYN_RECV", "n")
+	doc.AddMeasurement("tcpconn.FinWait1", "TCP sockets in state FIN_WAIT1", "n")
+	doc.AddMeasurement("tcpconn.FinWait2", "TCP sockets in state FIN_WAIT2", "n")
+	doc.AddMeasurement("tcpconn.TimeWait", "TCP sockets in state TIME_WAIT", "n")
+	doc.AddMeasurement("tcpconn.Close", "TCP sockets in state CLOSE", "n")
+	doc.AddMeasurement("tcpconn.CloseWait", "TCP sockets in state CLOSE_WAIT", "n")
+	doc.AddMeasurement("tcpconn.LastAck", "TCP sockets in state LAST_ACK", "n")
+	doc.AddMeasurement("tcpconn.Listen", "TCP sockets in state LISTEN", "n")
+	doc.AddMeasurement("tcpconn.Closing", "TCP sockets in state CLOSING", "n")
+	doc.AddMeasurement("tcpconn.NewSynRecv", "TCP sockets in state NEW_SYN_RECV", "n")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*TcpConn)(nil)
@@ -0,0 +1,78 @@
+package tcpconn
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+)
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewTcpConn())
+}
+
+func TestGather(t *testing.T) {
+	procPath := configuration.ProcPath
+	configuration.ProcPath = "testdata/proc"
+	defer func() { configuration.ProcPath = procPath }()
+
+	c := NewTcpConn().(*TcpConn)
+	err := c.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	expected := TcpConn{
+		Established: 2,
+		SynSent:     1,
+		TimeWait:    3,
+		CloseWait:   1,
+		Listen:      3,
+	}
+
+	if *c != expected {
+		t.Fatalf("Got %+v, expected %+v", *c, expected)
+	}
+
+	if len(c.GetPoints()) != 12 {
+		t.Fatalf("Got %d points, expected 12", len(c.GetPoints()))
+	}
+}
+
+func TestGatherWithoutIPv6(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tcpconn")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	os.Mkdir(filepath.Join(dir, "net"), 0755)
+
+	procPath := configuration.ProcPath
+	configuration.ProcPath = dir
+	defer func() { configuration.ProcPath = procPath }()
+
+	transport := localtransport.NewLocalTransport().(plugins.Transport)
+
+	c := NewTcpConn().(*TcpConn)
+	err = c.Gather(transport)
+	if err == nil {
+		t.Fatalf("Gather() did not fail without /proc/net/tcp")
+	}
+
+	contents, _ := ioutil.ReadFile("testdata/proc/net/tcp")
+	ioutil.WriteFile(filepath.Join(dir, "net", "tcp"), contents, 0644)
+
+	err = c.Gather(transport)
+	if err != nil {
+		t.Fatalf("Gather() failed without /proc/net/tcp6: %s", err.Error())
+	}
+
+	if c.Listen != 2 || c.TimeWait != 2 {
+		t.Fatalf("Got %+v", *c)
+	}
+}
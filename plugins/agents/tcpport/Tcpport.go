@@ -13,7 +13,7 @@ func init() {
 
 // Tcpport will connect to a tcp port and measure timing.
 type Tcpport struct {
-	Address         string        `json:"address" description:"The address to connect to (host:port)"`
+	Address         string        `json:"address" description:"The address to connect to (host:port)" required:"true"`
 	ConnectDuration time.Duration `json:"c"`
 }
 
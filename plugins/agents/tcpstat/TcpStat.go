@@ -0,0 +1,185 @@
+package tcpstat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("tcpstat", NewTcpStat)
+}
+
+// TcpStat will read TCP counters from /proc/net/snmp and /proc/net/netstat.
+// All values are cumulative and will be converted to per-second rates by
+// Sub().
+type TcpStat struct {
+	sampletime time.Time
+
+	RetransSegs     float64 `json:"r"`
+	InErrs          float64 `json:"i"`
+	ActiveOpens     float64 `json:"a"`
+	PassiveOpens    float64 `json:"p"`
+	OutRsts         float64 `json:"o"`
+	ListenOverflows float64 `json:"lo"`
+	ListenDrops     float64 `json:"ld"`
+}
+
+// NewTcpStat will return a new TcpStat.
+func NewTcpStat() interface{} {
+	return new(TcpStat)
+}
+
+// Gather will read /proc/net/snmp and /proc/net/netstat. netstat is optional,
+// listen queue counters are left at zero if it's missing.
+func (s *TcpStat) Gather(transport plugins.Transport) error {
+	*s = TcpStat{}
+
+	snmp, err := readTable(transport, filepath.Join(configuration.ProcPath, "/net/snmp"))
+	if err != nil {
+		return err
+	}
+
+	netstat, err := readTable(transport, filepath.Join(configuration.ProcPath, "/net/netstat"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	s.sampletime = time.Now()
+	s.set(snmp, netstat)
+
+	return nil
+}
+
+// set will copy the values we need from the parsed tables.
+func (s *TcpStat) set(snmp map[string]float64, netstat map[string]float64) {
+	s.RetransSegs = snmp["Tcp.RetransSegs"]
+	s.InErrs = snmp["Tcp.InErrs"]
+	s.ActiveOpens = snmp["Tcp.ActiveOpens"]
+	s.PassiveOpens = snmp["Tcp.PassiveOpens"]
+	s.OutRsts = snmp["Tcp.OutRsts"]
+	s.ListenOverflows = netstat["TcpExt.ListenOverflows"]
+	s.ListenDrops = netstat["TcpExt.ListenDrops"]
+}
+
+// readTable will open and parse path.
+func readTable(transport plugins.Transport, path string) (map[string]float64, error) {
+	file, err := transport.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return parseTable(file)
+}
+
+// parseTable will parse the format used by /proc/net/snmp and
+// /proc/net/netstat. Lines come in pairs, a header line with names followed
+// by a line with values, both prefixed by the protocol. The returned map is
+// keyed by "Protocol.Name".
+func parseTable(r io.Reader) (map[string]float64, error) {
+	values := make(map[string]float64)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		names := strings.Fields(scanner.Text())
+		if len(names) == 0 {
+			continue
+		}
+
+		if !scanner.Scan() {
+			return nil, fmt.Errorf("missing values for %s", names[0])
+		}
+
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) != len(names) || fields[0] != names[0] {
+			return nil, fmt.Errorf("malformed table near '%s'", scanner.Text())
+		}
+
+		protocol := strings.TrimSuffix(names[0], ":")
+
+		for i := 1; i < len(names); i++ {
+			// Some values, like Tcp.MaxConn, can be negative.
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, err
+			}
+
+			values[protocol+"."+names[i]] = value
+		}
+	}
+
+	return values, scanner.Err()
+}
+
+// Sub will calculate per-second rates between previous and s. An empty
+// TcpStat is returned if previous is nil or no time has passed. Counters
+// going backwards are reported as zero.
+func (s *TcpStat) Sub(previous *TcpStat) *TcpStat {
+	diff := &TcpStat{}
+
+	if previous == nil {
+		return diff
+	}
+
+	duration := s.sampletime.Sub(previous.sampletime)
+	if duration <= 0 {
+		return diff
+	}
+
+	factor := duration.Seconds()
+
+	diff.sampletime = s.sampletime
+	diff.RetransSegs = plugins.CounterRate(s.RetransSegs, previous.RetransSegs, factor)
+	diff.InErrs = plugins.CounterRate(s.InErrs, previous.InErrs, factor)
+	diff.ActiveOpens = plugins.CounterRate(s.ActiveOpens, previous.ActiveOpens, factor)
+	diff.PassiveOpens = plugins.CounterRate(s.PassiveOpens, previous.PassiveOpens, factor)
+	diff.OutRsts = plugins.CounterRate(s.OutRsts, previous.OutRsts, factor)
+	diff.ListenOverflows = plugins.CounterRate(s.ListenOverflows, previous.ListenOverflows, factor)
+	diff.ListenDrops = plugins.CounterRate(s.ListenDrops, previous.ListenDrops, factor)
+
+	return diff
+}
+
+// GetPoints will return the TCP rates.
+func (s *TcpStat) GetPoints() []*timeseries.Point {
+	points := make([]*timeseries.Point, 7)
+
+	points[0] = plugins.SimplePoint("tcp.RetransSegsPerSec", s.RetransSegs)
+	points[1] = plugins.SimplePoint("tcp.InErrsPerSec", s.InErrs)
+	points[2] = plugins.SimplePoint("tcp.ActiveOpensPerSec", s.ActiveOpens)
+	points[3] = plugins.SimplePoint("tcp.PassiveOpensPerSec", s.PassiveOpens)
+	points[4] = plugins.SimplePoint("tcp.OutRstsPerSec", s.OutRsts)
+	points[5] = plugins.SimplePoint("tcp.ListenOverflowsPerSec", s.ListenOverflows)
+	points[6] = plugins.SimplePoint("tcp.ListenDropsPerSec", s.ListenDrops)
+
+	return points
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (s *TcpStat) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("TCP statistics")
+
+	doc.AddMeasurement("tcp.RetransSegsPerSec", "Segments retransmitted", "segments/s")
+	doc.AddMeasurement("tcp.InErrsPerSec", "Segments received in error", "segments/s")
+	doc.AddMeasurement("tcp.ActiveOpensPerSec", "Connections opened by this host", "/s")
+	doc.AddMeasurement("tcp.PassiveOpensPerSec", "Connections accepted by this host", "/s")
+	doc.AddMeasurement("tcp.OutRstsPerSec", "Segments sent with the RST flag", "segments/s")
+	doc.AddMeasurement("tcp.ListenOverflowsPerSec", "Connections dropped because the accept queue was full", "/s")
+	doc.AddMeasurement("tcp.ListenDropsPerSec", "Connections dropped while listening", "/s")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*TcpStat)(nil)
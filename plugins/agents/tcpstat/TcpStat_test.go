@@ -0,0 +1,129 @@
+package tcpstat
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+)
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewTcpStat())
+}
+
+// gather will gather from the fixture in testdata/dir.
+func gather(t *testing.T, dir string) *TcpStat {
+	procPath := configuration.ProcPath
+	configuration.ProcPath = "testdata/" + dir
+	defer func() { configuration.ProcPath = procPath }()
+
+	s := NewTcpStat().(*TcpStat)
+	err := s.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	return s
+}
+
+func TestGather(t *testing.T) {
+	s := gather(t, "proc1")
+
+	expected := TcpStat{
+		sampletime:      s.sampletime,
+		RetransSegs:     1000,
+		InErrs:          5,
+		ActiveOpens:     5000,
+		PassiveOpens:    3000,
+		OutRsts:         600,
+		ListenOverflows: 10,
+		ListenDrops:     12,
+	}
+
+	if *s != expected {
+		t.Fatalf("Got %+v, expected %+v", *s, expected)
+	}
+}
+
+func TestSub(t *testing.T) {
+	previous := gather(t, "proc1")
+	current := gather(t, "proc2")
+	current.sampletime = previous.sampletime.Add(10 * time.Second)
+
+	diff := current.Sub(previous)
+
+	expected := TcpStat{
+		sampletime:      current.sampletime,
+		RetransSegs:     5,
+		InErrs:          0.2,
+		ActiveOpens:     10,
+		PassiveOpens:    30,
+		OutRsts:         2,
+		ListenOverflows: 2,
+		ListenDrops:     2,
+	}
+
+	if *diff != expected {
+		t.Fatalf("Got %+v, expected %+v", *diff, expected)
+	}
+
+	// A clock going backwards must not give us rates.
+	previous.sampletime = current.sampletime.Add(-10 * time.Second)
+	diff = previous.Sub(current)
+	for _, point := range diff.GetPoints() {
+		if point.Fields["value"].(float64) != 0.0 {
+			t.Errorf("%s is %v after the clock went backwards", point.Name, point.Fields["value"])
+		}
+	}
+
+	// Same sample time must not divide by zero.
+	current.sampletime = previous.sampletime
+	diff = current.Sub(previous)
+	if diff.RetransSegs != 0.0 {
+		t.Errorf("Got rate %f for zero duration", diff.RetransSegs)
+	}
+
+	if current.Sub(nil).RetransSegs != 0.0 {
+		t.Errorf("Got rate without previous sample")
+	}
+}
+
+func TestSubCounterReset(t *testing.T) {
+	previous := gather(t, "proc2")
+	current := gather(t, "proc1")
+	current.sampletime = previous.sampletime.Add(time.Second)
+
+	for _, point := range current.Sub(previous).GetPoints() {
+		if point.Fields["value"].(float64) < 0.0 {
+			t.Errorf("%s is negative after counter reset", point.Name)
+		}
+	}
+}
+
+func TestParseTable(t *testing.T) {
+	cases := []string{
+		"Tcp: RtoAlgorithm RtoMin\n",
+		"Tcp: RtoAlgorithm RtoMin\nTcp: 1\n",
+		"Tcp: RtoAlgorithm RtoMin\nUdp: 1 2\n",
+		"Tcp: RtoAlgorithm RtoMin\nTcp: 1 x\n",
+	}
+
+	for _, c := range cases {
+		_, err := parseTable(strings.NewReader(c))
+		if err == nil {
+			t.Errorf("parseTable() accepted '%s'", c)
+		}
+	}
+
+	values, err := parseTable(strings.NewReader("Tcp: MaxConn ActiveOpens\nTcp: -1 42\n"))
+	if err != nil {
+		t.Fatalf("parseTable() failed: %s", err.Error())
+	}
+
+	if values["Tcp.MaxConn"] != -1 || values["Tcp.ActiveOpens"] != 42 {
+		t.Fatalf("Got %+v", values)
+	}
+}
@@ -0,0 +1,143 @@
+package temperature
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("temperature", NewTemperature)
+}
+
+type (
+	// Temperature will read temperature sensors exposed by hwmon in
+	// /sys/class/hwmon.
+	// https://www.kernel.org/doc/Documentation/hwmon/sysfs-interface
+	Temperature struct {
+		Sensors []Sensor `json:"s"`
+	}
+
+	// Sensor is a single temperature sensor.
+	Sensor struct {
+		Chip    string  `json:"c"`
+		Label   string  `json:"l"`
+		Celsius float64 `json:"t"`
+	}
+)
+
+// NewTemperature will return a new Temperature.
+func NewTemperature() interface{} {
+	return new(Temperature)
+}
+
+// Gather will read all temp*_input files for all hwmon devices. Sensors
+// failing to read are skipped.
+func (t *Temperature) Gather(transport plugins.Transport) error {
+	t.Sensors = nil
+
+	root := filepath.Join(configuration.SysfsPath, "class", "hwmon")
+
+	devices, err := plugins.ReadDir(transport, root)
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(devices)
+
+	for _, device := range devices {
+		if !strings.HasPrefix(device, "hwmon") {
+			continue
+		}
+
+		dir := filepath.Join(root, device)
+
+		files, err := plugins.ReadDir(transport, dir)
+		if err != nil {
+			continue
+		}
+
+		chip := readString(transport, filepath.Join(dir, "name"))
+		if chip == "" {
+			chip = device
+		}
+
+		sort.Strings(files)
+
+		for _, file := range files {
+			if !strings.HasPrefix(file, "temp") || !strings.HasSuffix(file, "_input") {
+				continue
+			}
+
+			sensor := strings.TrimSuffix(file, "_input")
+
+			contents, err := transport.ReadFile(filepath.Join(dir, file))
+			if err != nil {
+				continue
+			}
+
+			millidegrees, err := strconv.ParseInt(strings.TrimSpace(string(contents)), 10, 64)
+			if err != nil {
+				continue
+			}
+
+			label := readString(transport, filepath.Join(dir, sensor+"_label"))
+			if label == "" {
+				label = sensor
+			}
+
+			t.Sensors = append(t.Sensors, Sensor{
+				Chip:    chip,
+				Label:   label,
+				Celsius: float64(millidegrees) / 1000.0,
+			})
+		}
+	}
+
+	return nil
+}
+
+// readString will read a single line from path. An empty string is returned
+// if the file cannot be read.
+func readString(transport plugins.Transport, path string) string {
+	contents, err := transport.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(contents))
+}
+
+// GetPoints will return a point per sensor.
+func (t *Temperature) GetPoints() []*timeseries.Point {
+	points := make([]*timeseries.Point, len(t.Sensors))
+
+	for i, sensor := range t.Sensors {
+		points[i] = plugins.PointWithTags("temp.Celsius", sensor.Celsius, map[string]string{
+			"chip":  sensor.Chip,
+			"label": sensor.Label,
+		})
+	}
+
+	return points
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (t *Temperature) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("Hardware temperature sensors")
+
+	doc.AddMeasurement("temp.Celsius", "Temperature reported by the sensor", "°C")
+
+	doc.AddTag("chip", "The name of the hwmon chip (coretemp, nvme, ...)")
+	doc.AddTag("label", "The sensor label, or tempN if the sensor has no label")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*Temperature)(nil)
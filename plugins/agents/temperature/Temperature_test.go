@@ -0,0 +1,98 @@
+package temperature
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+)
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewTemperature())
+}
+
+func TestGather(t *testing.T) {
+	dir, err := ioutil.TempDir("", "temperature")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"hwmon0/name":        "coretemp\n",
+		"hwmon0/temp1_input": "45000\n",
+		"hwmon0/temp1_label": "Package id 0\n",
+		"hwmon0/temp2_input": "43500\n",
+		"hwmon0/temp2_label": "Core 0\n",
+		"hwmon1/name":        "nvme\n",
+		"hwmon1/temp1_input": "38850\n",
+		"hwmon2/name":        "broken\n",
+		"hwmon2/temp1_input": "N/A\n",
+		"hwmon2/fan1_input":  "1200\n",
+		"unrelated/name":     "nothing\n",
+	}
+
+	hwmon := filepath.Join(dir, "class", "hwmon")
+	for path, contents := range files {
+		path = filepath.Join(hwmon, path)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		ioutil.WriteFile(path, []byte(contents), 0644)
+	}
+
+	sysfsPath := configuration.SysfsPath
+	configuration.SysfsPath = dir
+	defer func() { configuration.SysfsPath = sysfsPath }()
+
+	temp := NewTemperature().(*Temperature)
+	err = temp.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	expected := []Sensor{
+		{"coretemp", "Package id 0", 45.0},
+		{"coretemp", "Core 0", 43.5},
+		{"nvme", "temp1", 38.85},
+	}
+
+	if len(temp.Sensors) != len(expected) {
+		t.Fatalf("Got %d sensors, expected %d: %+v", len(temp.Sensors), len(expected), temp.Sensors)
+	}
+
+	for i, sensor := range temp.Sensors {
+		if sensor != expected[i] {
+			t.Errorf("Sensor %d is %+v, expected %+v", i, sensor, expected[i])
+		}
+	}
+
+	points := temp.GetPoints()
+	if len(points) != len(expected) {
+		t.Fatalf("Got %d points, expected %d", len(points), len(expected))
+	}
+
+	if points[0].Tags["chip"] != "coretemp" || points[0].Tags["label"] != "Package id 0" {
+		t.Errorf("Wrong tags: %+v", points[0].Tags)
+	}
+}
+
+func TestGatherNoHwmon(t *testing.T) {
+	dir, err := ioutil.TempDir("", "temperature")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	sysfsPath := configuration.SysfsPath
+	configuration.SysfsPath = dir
+	defer func() { configuration.SysfsPath = sysfsPath }()
+
+	temp := NewTemperature().(*Temperature)
+	err = temp.Gather(localtransport.NewLocalTransport().(plugins.Transport))
+	if err == nil {
+		t.Fatalf("Gather() did not fail without hwmon")
+	}
+}
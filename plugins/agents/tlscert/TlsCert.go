@@ -0,0 +1,144 @@
+package tlscert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("tlscert", NewTlsCert)
+}
+
+// TlsCert will check the certificate presented by a TLS server.
+type TlsCert struct {
+	Host       string `toml:"host" json:"host" description:"The host to connect to" required:"true"`
+	Port       int    `toml:"port" json:"port" description:"The port to connect to (default 443)"`
+	ServerName string `toml:"serverName" json:"serverName" description:"The server name to request and verify (default is host)"`
+
+	DaysUntilExpiry int    `json:"d"`
+	Valid           bool   `json:"v"`
+	Subject         string `json:"s"`
+
+	// roots is used for verification. If nil, the system roots are used.
+	roots *x509.CertPool
+}
+
+var (
+	// ErrMissingHost will be returned if no host is configured.
+	ErrMissingHost = errors.New("host must be set")
+
+	// ErrNoCertificate will be returned if the server presented no
+	// certificates.
+	ErrNoCertificate = errors.New("no certificate presented")
+)
+
+// NewTlsCert will return a new TlsCert.
+func NewTlsCert() interface{} {
+	return new(TlsCert)
+}
+
+// Gather will connect and inspect the leaf certificate. Certificates failing
+// verification will still be reported, but with Valid set to false.
+func (c *TlsCert) Gather(transport plugins.Transport) error {
+	c.DaysUntilExpiry = 0
+	c.Valid = false
+	c.Subject = ""
+
+	if c.Host == "" {
+		return ErrMissingHost
+	}
+
+	port := 443
+	if c.Port > 0 {
+		port = c.Port
+	}
+
+	serverName := c.Host
+	if c.ServerName != "" {
+		serverName = c.ServerName
+	}
+
+	raw, err := transport.Dial("tcp", net.JoinHostPort(c.Host, strconv.Itoa(port)))
+	if err != nil {
+		return err
+	}
+
+	// We verify ourself below to be able to report on invalid certificates.
+	conn := tls.Client(raw, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+	})
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	err = conn.Handshake()
+	if err != nil {
+		return err
+	}
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return ErrNoCertificate
+	}
+
+	leaf := certs[0]
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err = leaf.Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         c.roots,
+		Intermediates: intermediates,
+	})
+
+	c.Valid = err == nil
+	c.Subject = leaf.Subject.CommonName
+	c.DaysUntilExpiry = int(math.Floor(leaf.NotAfter.Sub(time.Now()).Hours() / 24))
+
+	return nil
+}
+
+// GetPoints will return days until expiry and validity.
+func (c *TlsCert) GetPoints() []*timeseries.Point {
+	valid := 0
+	if c.Valid {
+		valid = 1
+	}
+
+	tags := map[string]string{
+		"host":    c.Host,
+		"subject": c.Subject,
+	}
+
+	return []*timeseries.Point{
+		plugins.PointWithTags("tls.DaysUntilExpiry", c.DaysUntilExpiry, tags),
+		plugins.PointWithTags("tls.Valid", valid, tags),
+	}
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (c *TlsCert) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("TLS certificate check")
+
+	doc.AddTag("host", "The host connected to")
+	doc.AddTag("subject", "The common name of the certificate")
+	doc.AddMeasurement("tls.DaysUntilExpiry", "Days until the certificate expires, negative if expired", "n")
+	doc.AddMeasurement("tls.Valid", "1 if the certificate chain could be verified, 0 otherwise", "n")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*TlsCert)(nil)
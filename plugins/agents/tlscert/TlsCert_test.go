@@ -0,0 +1,126 @@
+package tlscert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/local"
+)
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewTlsCert())
+}
+
+// newCert will return a self-signed certificate for localhost valid from
+// notBefore to notAfter.
+func newCert(t *testing.T, notBefore time.Time, notAfter time.Time) (tls.Certificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() failed: %s", err.Error())
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() failed: %s", err.Error())
+	}
+
+	cert, _ := x509.ParseCertificate(der)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, cert
+}
+
+// serve will serve TLS using cert until the returned listener is closed.
+func serve(t *testing.T, cert tls.Certificate) (net.Listener, int) {
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("Listen() failed: %s", err.Error())
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	return l, l.Addr().(*net.TCPAddr).Port
+}
+
+func TestGather(t *testing.T) {
+	now := time.Now()
+	transport := localtransport.NewLocalTransport().(plugins.Transport)
+
+	cases := []struct {
+		notBefore time.Time
+		notAfter  time.Time
+		trusted   bool
+		days      int
+		valid     bool
+	}{
+		{now.Add(-time.Hour), now.Add(36 * time.Hour), false, 1, false},
+		{now.Add(-time.Hour), now.Add(36 * time.Hour), true, 1, true},
+		{now.Add(-72 * time.Hour), now.Add(-36 * time.Hour), true, -2, false},
+	}
+
+	for i, c := range cases {
+		cert, x509Cert := newCert(t, c.notBefore, c.notAfter)
+		l, port := serve(t, cert)
+
+		check := &TlsCert{
+			Host:       "127.0.0.1",
+			Port:       port,
+			ServerName: "localhost",
+		}
+
+		if c.trusted {
+			check.roots = x509.NewCertPool()
+			check.roots.AddCert(x509Cert)
+		}
+
+		err := check.Gather(transport)
+		l.Close()
+		if err != nil {
+			t.Fatalf("%d: Gather() failed: %s", i, err.Error())
+		}
+
+		if check.DaysUntilExpiry != c.days {
+			t.Errorf("%d: Got %d days until expiry, expected %d", i, check.DaysUntilExpiry, c.days)
+		}
+
+		if check.Valid != c.valid {
+			t.Errorf("%d: Got valid %v, expected %v", i, check.Valid, c.valid)
+		}
+
+		if check.Subject != "localhost" {
+			t.Errorf("%d: Got subject '%s', expected 'localhost'", i, check.Subject)
+		}
+
+		plugins.GenericAgentTest(t, check)
+	}
+}
@@ -0,0 +1,296 @@
+package topprocesses
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("topprocesses", NewTopProcesses)
+}
+
+type (
+	// TopProcesses reports the processes using the most CPU and memory by
+	// reading /proc/[pid]/stat and /proc/[pid]/status. CPU time is
+	// cumulative and will be converted to percent by Sub().
+	TopProcesses struct {
+		sampletime time.Time
+
+		N int `toml:"n" json:"n" description:"Number of processes to report for CPU and memory each (default 5)"`
+
+		Processes map[string]*Process `json:"p"`
+	}
+
+	// Process holds the statistics of a single process.
+	Process struct {
+		Pid       string  `json:"i"`
+		Comm      string  `json:"c"`
+		StartTime uint64  `json:"s"`
+		CpuTime   float64 `json:"t"`
+		RssBytes  int64   `json:"r"`
+
+		// CpuPercent is the CPU usage between two samples, 100 being one
+		// core. It's only set by Sub().
+		CpuPercent float64 `json:"p"`
+	}
+)
+
+const (
+	// DefaultN is the number of processes reported if N is not set.
+	DefaultN = 5
+
+	// clockTicks is USER_HZ, the unit of CPU time in /proc/[pid]/stat. It's
+	// 100 on all Linux architectures.
+	clockTicks = 100.0
+)
+
+var (
+	// ErrUnknownFormat is returned when /proc/[pid]/stat cannot be parsed.
+	ErrUnknownFormat = errors.New("unknown format")
+)
+
+// NewTopProcesses will return a new TopProcesses.
+func NewTopProcesses() interface{} {
+	return new(TopProcesses)
+}
+
+// n will return the number of processes to report.
+func (t *TopProcesses) n() int {
+	if t.N <= 0 {
+		return DefaultN
+	}
+
+	return t.N
+}
+
+// Gather will read stat and status for all processes. Processes exiting
+// while we read are skipped.
+func (t *TopProcesses) Gather(transport plugins.Transport) error {
+	t.Processes = make(map[string]*Process)
+
+	names, err := plugins.ReadDir(transport, configuration.ProcPath)
+	if err != nil {
+		return err
+	}
+
+	t.sampletime = time.Now()
+
+	for _, name := range names {
+		_, err := strconv.Atoi(name)
+		if err != nil {
+			// Not a process.
+			continue
+		}
+
+		contents, err := transport.ReadFile(filepath.Join(configuration.ProcPath, name, "stat"))
+		if err != nil {
+			// The process has probably gone away.
+			continue
+		}
+
+		process, err := parseStat(contents)
+		if err != nil {
+			continue
+		}
+
+		process.Pid = name
+
+		contents, err = transport.ReadFile(filepath.Join(configuration.ProcPath, name, "status"))
+		if err != nil {
+			continue
+		}
+
+		process.RssBytes = parseRss(contents)
+
+		t.Processes[name] = process
+	}
+
+	return nil
+}
+
+// parseStat will return the command name, start time and CPU time from the
+// contents of /proc/[pid]/stat.
+func parseStat(contents []byte) (*Process, error) {
+	// The command name is in parentheses and can contain anything, including
+	// spaces and parentheses.
+	start := bytes.IndexByte(contents, '(')
+	end := bytes.LastIndexByte(contents, ')')
+	if start < 0 || end < start {
+		return nil, ErrUnknownFormat
+	}
+
+	// Field 3 is state, 14 is utime, 15 is stime and 22 is starttime.
+	fields := strings.Fields(string(contents[end+1:]))
+	if len(fields) < 20 {
+		return nil, ErrUnknownFormat
+	}
+
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	starttime, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Process{
+		Comm:      string(contents[start+1 : end]),
+		StartTime: starttime,
+		CpuTime:   float64(utime + stime),
+	}, nil
+}
+
+// parseRss will return the resident set size in bytes from the contents of
+// /proc/[pid]/status. Kernel threads have no VmRSS and 0 is returned.
+func parseRss(contents []byte) int64 {
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		// VmRSS:	    1234 kB
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "VmRSS:" {
+			continue
+		}
+
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0
+		}
+
+		return kb * 1024
+	}
+
+	return 0
+}
+
+// Sub will calculate the CPU usage of all processes present in both previous
+// and t. Processes started or exited between the samples are left out. An
+// empty TopProcesses is returned if previous is nil or no time has passed.
+func (t *TopProcesses) Sub(previous *TopProcesses) *TopProcesses {
+	diff := &TopProcesses{
+		N:         t.N,
+		Processes: make(map[string]*Process),
+	}
+
+	if previous == nil {
+		return diff
+	}
+
+	duration := t.sampletime.Sub(previous.sampletime)
+	if duration <= 0 {
+		return diff
+	}
+
+	factor := duration.Seconds()
+
+	diff.sampletime = t.sampletime
+
+	for pid, process := range t.Processes {
+		prev, found := previous.Processes[pid]
+
+		// A different start time means the pid was reused.
+		if !found || prev.StartTime != process.StartTime {
+			continue
+		}
+
+		diff.Processes[pid] = &Process{
+			Pid:        process.Pid,
+			Comm:       process.Comm,
+			StartTime:  process.StartTime,
+			CpuTime:    process.CpuTime,
+			RssBytes:   process.RssBytes,
+			CpuPercent: plugins.CounterRate(process.CpuTime, prev.CpuTime, factor) * (100.0 / clockTicks),
+		}
+	}
+
+	return diff
+}
+
+// top will return at most n processes ordered by less. Ties are ordered by
+// pid to keep the result stable.
+func (t *TopProcesses) top(n int, less func(a, b *Process) bool) []*Process {
+	processes := make([]*Process, 0, len(t.Processes))
+	for _, process := range t.Processes {
+		processes = append(processes, process)
+	}
+
+	sort.Slice(processes, func(i, j int) bool {
+		a, b := processes[i], processes[j]
+		if less(a, b) {
+			return true
+		}
+
+		if less(b, a) {
+			return false
+		}
+
+		return a.Pid < b.Pid
+	})
+
+	if len(processes) > n {
+		processes = processes[:n]
+	}
+
+	return processes
+}
+
+// GetPoints will return the CPU usage of the N processes using the most CPU
+// and the resident set size of the N processes using the most memory.
+func (t *TopProcesses) GetPoints() []*timeseries.Point {
+	n := t.n()
+
+	cpu := t.top(n, func(a, b *Process) bool { return a.CpuPercent > b.CpuPercent })
+	rss := t.top(n, func(a, b *Process) bool { return a.RssBytes > b.RssBytes })
+
+	points := plugins.NewPointSet(len(cpu) + len(rss))
+
+	for _, process := range cpu {
+		points.AddWithTags("proc.CpuPercent", process.CpuPercent, process.tags())
+	}
+
+	for _, process := range rss {
+		points.AddWithTags("proc.RssBytes", process.RssBytes, process.tags())
+	}
+
+	return points.Points()
+}
+
+// tags will return the tags identifying p.
+func (p *Process) tags() map[string]string {
+	return map[string]string{
+		"comm": p.Comm,
+		"pid":  p.Pid,
+	}
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (t *TopProcesses) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("Top processes")
+
+	doc.AddMeasurement("proc.CpuPercent", "CPU usage of the processes using the most CPU. 100 is one core", "%")
+	doc.AddMeasurement("proc.RssBytes", "Resident set size of the processes using the most memory", "b")
+
+	doc.AddTag("comm", "The command name of the process")
+	doc.AddTag("pid", "The process ID")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*TopProcesses)(nil)
@@ -0,0 +1,180 @@
+package topprocesses
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/mock"
+)
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewTopProcesses())
+}
+
+func stat(pid int, comm string, utime int, stime int, starttime int) string {
+	return fmt.Sprintf("%d (%s) S 1 1 1 0 -1 4194560 100 0 0 0 %d %d 0 0 20 0 1 0 %d 1000 100 18446744073709551615", pid, comm, utime, stime, starttime)
+}
+
+func status(rssKb int) string {
+	if rssKb == 0 {
+		// Kernel threads have no VmRSS.
+		return "Name:\tkthreadd\nState:\tS (sleeping)\n"
+	}
+
+	return fmt.Sprintf("Name:\tx\nState:\tS (sleeping)\nVmRSS:\t%8d kB\nThreads:\t1\n", rssKb)
+}
+
+// mockProc will return a mock transport serving a fake proc tree of procs.
+func mockProc(procs map[string][2]string) plugins.Transport {
+	m := mocktransport.NewMock().(*mocktransport.Mock)
+
+	var names []string
+	for pid, files := range procs {
+		names = append(names, pid)
+		m.SetFile(filepath.Join(configuration.ProcPath, pid, "stat"), []byte(files[0]))
+		m.SetFile(filepath.Join(configuration.ProcPath, pid, "status"), []byte(files[1]))
+	}
+
+	m.SetExec("ls", []byte(strings.Join(names, "\n")))
+
+	return m
+}
+
+func TestGather(t *testing.T) {
+	rates := plugins.NewRates()
+
+	transport := mockProc(map[string][2]string{
+		"1":  {stat(1, "init", 10, 10, 1), status(4000)},
+		"2":  {stat(2, "kthreadd", 0, 5, 2), status(0)},
+		"10": {stat(10, "web server", 1000, 500, 100), status(200000)},
+		"11": {stat(11, "db", 2000, 1000, 110), status(800000)},
+		"12": {stat(12, "cron", 5, 5, 120), status(2000)},
+		"13": {stat(13, "exits", 100, 100, 130), status(1000)},
+		"14": {stat(14, "old", 100, 100, 140), status(1000)},
+	})
+
+	first := NewTopProcesses().(*TopProcesses)
+	err := first.Gather(transport)
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if len(first.Processes) != 7 {
+		t.Fatalf("Got %d processes, expected 7", len(first.Processes))
+	}
+
+	if first.Processes["10"].Comm != "web server" || first.Processes["10"].RssBytes != 200000*1024 {
+		t.Errorf("Process 10 was not parsed correctly: %+v", first.Processes["10"])
+	}
+
+	_, ok := rates.Apply("top", first)
+	if ok {
+		t.Errorf("Got rates without a previous sample")
+	}
+
+	// Pretend the first sample was taken two seconds ago.
+	first.sampletime = first.sampletime.Add(-2 * time.Second)
+
+	// Between the samples 13 exits, 14 is reused by a new process and 15
+	// starts.
+	transport = mockProc(map[string][2]string{
+		"1":  {stat(1, "init", 10, 10, 1), status(4000)},
+		"2":  {stat(2, "kthreadd", 0, 5, 2), status(0)},
+		"10": {stat(10, "web server", 1150, 550, 100), status(210000)},
+		"11": {stat(11, "db", 2050, 1010, 110), status(800000)},
+		"12": {stat(12, "cron", 6, 5, 120), status(2000)},
+		"14": {stat(14, "new", 0, 0, 900), status(900000)},
+		"15": {stat(15, "fresh", 50, 50, 910), status(100)},
+	})
+
+	second := NewTopProcesses().(*TopProcesses)
+	second.N = 2
+	err = second.Gather(transport)
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	agent, ok := rates.Apply("top", second)
+	if !ok {
+		t.Fatalf("Got no rates from two samples")
+	}
+
+	diff := agent.(*TopProcesses)
+
+	if len(diff.Processes) != 5 {
+		t.Fatalf("Got %d processes in both samples, expected 5", len(diff.Processes))
+	}
+
+	expected := map[string]float64{
+		"1":  0.0,
+		"2":  0.0,
+		"10": 100.0,
+		"11": 30.0,
+		"12": 0.5,
+	}
+
+	// The samples are a few microseconds more than two seconds apart.
+	for pid, percent := range expected {
+		if math.Abs(diff.Processes[pid].CpuPercent-percent) > 0.1 {
+			t.Errorf("Got %f%% CPU for pid %s, expected %f%%", diff.Processes[pid].CpuPercent, pid, percent)
+		}
+	}
+
+	points := diff.GetPoints()
+	if len(points) != 4 {
+		t.Fatalf("Got %d points, expected 4", len(points))
+	}
+
+	wanted := []struct {
+		name string
+		pid  string
+		comm string
+	}{
+		{"proc.CpuPercent", "10", "web server"},
+		{"proc.CpuPercent", "11", "db"},
+		{"proc.RssBytes", "11", "db"},
+		{"proc.RssBytes", "10", "web server"},
+	}
+
+	for i, w := range wanted {
+		if points[i].Name != w.name || points[i].Tags["pid"] != w.pid || points[i].Tags["comm"] != w.comm {
+			t.Errorf("Point %d is %s %v, expected %s for pid %s (%s)", i, points[i].Name, points[i].Tags, w.name, w.pid, w.comm)
+		}
+	}
+
+	if len(second.Sub(nil).Processes) != 0 {
+		t.Errorf("Sub(nil) returned processes")
+	}
+
+	plugins.GenericAgentTest(t, diff)
+}
+
+func TestParseStat(t *testing.T) {
+	process, err := parseStat([]byte(stat(2, "sh) S (x", 7, 3, 42)))
+	if err != nil {
+		t.Fatalf("parseStat() failed: %s", err.Error())
+	}
+
+	if process.Comm != "sh) S (x" || process.CpuTime != 10 || process.StartTime != 42 {
+		t.Errorf("parseStat() returned %+v", process)
+	}
+
+	cases := []string{
+		"",
+		"1 (init) S",
+		"1 init) S 1 1 1 0 -1 4194560 100 0 0 0 1 1 0 0 20 0 1 0 1",
+	}
+
+	for _, contents := range cases {
+		_, err := parseStat([]byte(contents))
+		if err == nil {
+			t.Errorf("parseStat() accepted '%s'", contents)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package uptime
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("uptime", NewUptime)
+}
+
+// Uptime reads uptime and idle time from /proc/uptime.
+type Uptime struct {
+	Uptime   float64 `json:"u"`
+	IdleTime float64 `json:"i"`
+}
+
+// NewUptime will return a new Uptime.
+func NewUptime() interface{} {
+	return new(Uptime)
+}
+
+// Gather will read /proc/uptime.
+func (u *Uptime) Gather(transport plugins.Transport) error {
+	*u = Uptime{}
+
+	path := filepath.Join(configuration.ProcPath, "/uptime")
+	contents, err := transport.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return u.parse(string(contents))
+}
+
+// parse will parse the contents of /proc/uptime. The file contains two
+// numbers, seconds since boot and seconds spend idle summed over all cores.
+func (u *Uptime) parse(contents string) error {
+	fields := strings.Fields(contents)
+	if len(fields) != 2 {
+		return errors.New("Unknown format of uptime")
+	}
+
+	uptime, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return err
+	}
+
+	idle, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return err
+	}
+
+	u.Uptime = uptime
+	u.IdleTime = idle
+
+	return nil
+}
+
+// GetPoints will return uptime and idle time.
+func (u *Uptime) GetPoints() []*timeseries.Point {
+	points := make([]*timeseries.Point, 2)
+
+	points[0] = plugins.SimplePoint("misc.Uptime", u.Uptime)
+	points[1] = plugins.SimplePoint("misc.IdleTime", u.IdleTime)
+
+	return points
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (u *Uptime) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("Uptime")
+
+	doc.AddMeasurement("misc.Uptime", "Time since boot", "s")
+	doc.AddMeasurement("misc.IdleTime", "Time spend idle, summed over all cores", "s")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*Uptime)(nil)
@@ -0,0 +1,43 @@
+package uptime
+
+import (
+	"testing"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/mock"
+)
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewUptime())
+}
+
+func TestGather(t *testing.T) {
+	mock := mocktransport.NewMock().(*mocktransport.Mock)
+	mock.SetFile("/proc/uptime", []byte("350735.47 234388.90\n"))
+
+	u := NewUptime().(*Uptime)
+	err := u.Gather(mock)
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	if u.Uptime != 350735.47 || u.IdleTime != 234388.90 {
+		t.Fatalf("Got %+v, expected uptime 350735.47 and idle 234388.90", *u)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	cases := []string{
+		"",
+		"350735.47",
+		"350735.47 abc",
+		"abc 234388.90",
+	}
+
+	for _, contents := range cases {
+		u := Uptime{}
+		if u.parse(contents) == nil {
+			t.Errorf("parse() accepted '%s'", contents)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package vmstat
+
+import (
+	"bufio"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/timeseries"
+)
+
+func init() {
+	plugins.Register("vmstat", NewVmStat)
+}
+
+// VmStat reads paging and swapping counters from /proc/vmstat.
+type VmStat struct {
+	sampletime  time.Time `json:"-"`
+	PageIn      float64   `json:"pi"`
+	PageOut     float64   `json:"po"`
+	SwapIn      float64   `json:"si"`
+	SwapOut     float64   `json:"so"`
+	PageFaults  float64   `json:"pf"`
+	MajorFaults float64   `json:"mf"`
+}
+
+// NewVmStat will return a new VmStat.
+func NewVmStat() interface{} {
+	return new(VmStat)
+}
+
+// Gather will read /proc/vmstat.
+func (v *VmStat) Gather(transport plugins.Transport) error {
+	*v = VmStat{}
+
+	path := filepath.Join(configuration.ProcPath, "/vmstat")
+	file, err := transport.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	v.sampletime = time.Now()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		data := strings.Fields(scanner.Text())
+		if len(data) != 2 {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(data[1], 64)
+		if err != nil {
+			continue
+		}
+
+		switch data[0] {
+		case "pgpgin":
+			v.PageIn = value
+		case "pgpgout":
+			v.PageOut = value
+		case "pswpin":
+			v.SwapIn = value
+		case "pswpout":
+			v.SwapOut = value
+		case "pgfault":
+			v.PageFaults = value
+		case "pgmajfault":
+			v.MajorFaults = value
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Sub will calculate the per-second rates between previous and v. Like
+// cpustats, an empty VmStat is returned if previous is nil or no time has
+// passed. Counters going backwards will be reported as zero.
+func (v *VmStat) Sub(previous *VmStat) *VmStat {
+	diff := &VmStat{}
+
+	if previous == nil {
+		return diff
+	}
+
+	duration := v.sampletime.Sub(previous.sampletime)
+	if duration <= 0 {
+		return diff
+	}
+
+	factor := duration.Seconds()
+
+	diff.sampletime = v.sampletime
+	diff.PageIn = plugins.CounterRate(v.PageIn, previous.PageIn, factor)
+	diff.PageOut = plugins.CounterRate(v.PageOut, previous.PageOut, factor)
+	diff.SwapIn = plugins.CounterRate(v.SwapIn, previous.SwapIn, factor)
+	diff.SwapOut = plugins.CounterRate(v.SwapOut, previous.SwapOut, factor)
+	diff.PageFaults = plugins.CounterRate(v.PageFaults, previous.PageFaults, factor)
+	diff.MajorFaults = plugins.CounterRate(v.MajorFaults, previous.MajorFaults, factor)
+
+	return diff
+}
+
+// GetPoints will return paging and swapping activity.
+func (v *VmStat) GetPoints() []*timeseries.Point {
+	points := make([]*timeseries.Point, 6)
+
+	points[0] = plugins.SimplePoint("vm.PageIn", v.PageIn)
+	points[1] = plugins.SimplePoint("vm.PageOut", v.PageOut)
+	points[2] = plugins.SimplePoint("vm.SwapIn", v.SwapIn)
+	points[3] = plugins.SimplePoint("vm.SwapOut", v.SwapOut)
+	points[4] = plugins.SimplePoint("vm.PageFaults", v.PageFaults)
+	points[5] = plugins.SimplePoint("vm.MajorFaults", v.MajorFaults)
+
+	return points
+}
+
+// GetDoc explains the returned points from GetPoints().
+func (v *VmStat) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("Paging and swapping")
+
+	doc.AddMeasurement("vm.PageIn", "Kilobytes paged in from disk", "kb/s")
+	doc.AddMeasurement("vm.PageOut", "Kilobytes paged out to disk", "kb/s")
+	doc.AddMeasurement("vm.SwapIn", "Pages swapped in", "pages/s")
+	doc.AddMeasurement("vm.SwapOut", "Pages swapped out", "pages/s")
+	doc.AddMeasurement("vm.PageFaults", "Page faults", "/s")
+	doc.AddMeasurement("vm.MajorFaults", "Major page faults requiring disk access", "/s")
+
+	return doc
+}
+
+// Ensure compliance.
+var _ plugins.Agent = (*VmStat)(nil)
@@ -0,0 +1,88 @@
+package vmstat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/plugins"
+	"github.com/abrander/agento/plugins/transports/mock"
+)
+
+var (
+	testData1 = []byte(`nr_free_pages 1030711
+nr_zone_inactive_anon 72312
+pgpgin 1000000
+pgpgout 2000000
+pswpin 100
+pswpout 200
+pgalloc_normal 123456789
+pgfault 5000000
+pgmajfault 3000
+`)
+
+	testData2 = []byte(`nr_free_pages 1030711
+nr_zone_inactive_anon 72312
+pgpgin 1004000
+pgpgout 2000000
+pswpin 110
+pswpout 240
+pgalloc_normal 123456789
+pgfault 5100000
+pgmajfault 3004
+`)
+)
+
+func TestAgent(t *testing.T) {
+	plugins.GenericAgentTest(t, NewVmStat())
+}
+
+func gather(t *testing.T, data []byte) *VmStat {
+	mock := mocktransport.NewMock().(*mocktransport.Mock)
+	mock.SetFile("/proc/vmstat", data)
+
+	v := NewVmStat().(*VmStat)
+	err := v.Gather(mock)
+	if err != nil {
+		t.Fatalf("Gather() failed: %s", err.Error())
+	}
+
+	return v
+}
+
+func TestSub(t *testing.T) {
+	previous := gather(t, testData1)
+	current := gather(t, testData2)
+	current.sampletime = previous.sampletime.Add(4 * time.Second)
+
+	diff := current.Sub(previous)
+	expected := VmStat{
+		sampletime:  current.sampletime,
+		PageIn:      1000.0,
+		PageOut:     0.0,
+		SwapIn:      2.5,
+		SwapOut:     10.0,
+		PageFaults:  25000.0,
+		MajorFaults: 1.0,
+	}
+
+	if *diff != expected {
+		t.Fatalf("Got %+v, expected %+v", *diff, expected)
+	}
+
+	// Swapping the samples simulates a counter reset.
+	previous.sampletime = current.sampletime.Add(time.Second)
+	diff = previous.Sub(current)
+	if diff.PageIn != 0.0 || diff.PageFaults != 0.0 {
+		t.Errorf("Counter reset resulted in %+v", *diff)
+	}
+
+	// No time has passed.
+	current.sampletime = previous.sampletime
+	if *current.Sub(previous) != (VmStat{}) {
+		t.Errorf("Sub() returned rates for a zero duration")
+	}
+
+	if *current.Sub(nil) != (VmStat{}) {
+		t.Errorf("Sub(nil) returned rates")
+	}
+}
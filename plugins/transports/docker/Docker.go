@@ -0,0 +1,219 @@
+package dockertransport
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/abrander/agento/logger"
+	"github.com/abrander/agento/plugins"
+)
+
+func init() {
+	plugins.Register("dockertransport", NewDockerTransport)
+}
+
+// NewDockerTransport will instantiate a new transport using the default
+// Docker socket.
+func NewDockerTransport() interface{} {
+	return &DockerTransport{
+		Socket: "/var/run/docker.sock",
+	}
+}
+
+type (
+	// DockerTransport will execute commands inside a running container using
+	// the Docker Engine API.
+	DockerTransport struct {
+		Container string `toml:"container" json:"container" description:"Name or ID of the container"`
+		Socket    string `toml:"socket" json:"socket" description:"Path to the Docker socket" default:"/var/run/docker.sock"`
+	}
+
+	execCreate struct {
+		AttachStdout bool     `json:"AttachStdout"`
+		AttachStderr bool     `json:"AttachStderr"`
+		Cmd          []string `json:"Cmd"`
+	}
+
+	execStart struct {
+		Detach bool `json:"Detach"`
+		Tty    bool `json:"Tty"`
+	}
+
+	execInspect struct {
+		Running  bool `json:"Running"`
+		ExitCode int  `json:"ExitCode"`
+	}
+)
+
+// client returns a HTTP client talking to the Docker daemon.
+func (d *DockerTransport) client() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Dial: func(_ string, _ string) (net.Conn, error) {
+				return net.DialTimeout("unix", d.Socket, 10*time.Second)
+			},
+		},
+	}
+}
+
+// post will POST v as JSON to the Docker API and return the response.
+func (d *DockerTransport) post(client *http.Client, path string, v interface{}) (*http.Response, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Post("http://docker"+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		return nil, fmt.Errorf("docker returned %d for %s: %s", resp.StatusCode, path, bytes.TrimSpace(message))
+	}
+
+	return resp, nil
+}
+
+// demux will split a multiplexed Docker stream into stdout and stderr.
+func demux(r io.Reader, stdout io.Writer, stderr io.Writer) error {
+	var header [8]byte
+
+	for {
+		_, err := io.ReadFull(r, header[:])
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		var w io.Writer
+		switch header[0] {
+		case 0, 1:
+			w = stdout
+		case 2:
+			w = stderr
+		default:
+			return fmt.Errorf("unknown stream type %d", header[0])
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		_, err = io.CopyN(w, r, size)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// GetDoc implements plugins.Plugin.
+func (d *DockerTransport) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("Docker exec transport")
+
+	return doc
+}
+
+// Dial is not supported by the Docker transport.
+func (d *DockerTransport) Dial(network string, address string) (net.Conn, error) {
+	return nil, errors.New("dockertransport does not implement Dial()")
+}
+
+// Exec will run cmd inside the container and return stdout and stderr.
+func (d *DockerTransport) Exec(cmd string, arguments ...string) (io.Reader, io.Reader, error) {
+	logger.Yellow("docker", "Executing command '%s' in container %s", cmd, d.Container)
+
+	client := d.client()
+
+	create := execCreate{
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          append([]string{cmd}, arguments...),
+	}
+
+	resp, err := d.post(client, "/containers/"+url.PathEscape(d.Container)+"/exec", create)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var created struct {
+		ID string `json:"Id"`
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err = d.post(client, "/exec/"+created.ID+"/start", execStart{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+	err = demux(resp.Body, &stdoutBuf, &stderrBuf)
+	resp.Body.Close()
+	if err != nil {
+		return &stdoutBuf, &stderrBuf, err
+	}
+
+	resp, err = client.Get("http://docker/exec/" + created.ID + "/json")
+	if err != nil {
+		return &stdoutBuf, &stderrBuf, err
+	}
+	defer resp.Body.Close()
+
+	var inspect execInspect
+	err = json.NewDecoder(resp.Body).Decode(&inspect)
+	if err != nil {
+		return &stdoutBuf, &stderrBuf, err
+	}
+
+	if inspect.ExitCode != 0 {
+		return &stdoutBuf, &stderrBuf, fmt.Errorf("'%s' exited with status %d", cmd, inspect.ExitCode)
+	}
+
+	return &stdoutBuf, &stderrBuf, nil
+}
+
+// Open will read path from inside the container by using cat.
+func (d *DockerTransport) Open(path string) (io.ReadCloser, error) {
+	r, _, err := d.Exec("cat", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.NopCloser(r), nil
+}
+
+// ReadFile reads the complete file at path from inside the container.
+func (d *DockerTransport) ReadFile(path string) ([]byte, error) {
+	r, err := d.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(r)
+}
+
+// Statfs is not supported by the Docker transport.
+func (d *DockerTransport) Statfs(path string, buf *syscall.Statfs_t) error {
+	return errors.New("dockertransport does not implement Statfs()")
+}
+
+// Ensure compliance
+var _ plugins.Transport = (*DockerTransport)(nil)
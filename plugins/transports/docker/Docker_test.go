@@ -0,0 +1,94 @@
+package dockertransport
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func frame(stream byte, payload string) []byte {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+
+	return append(header, []byte(payload)...)
+}
+
+func TestDemux(t *testing.T) {
+	var stream []byte
+	stream = append(stream, frame(1, "hello ")...)
+	stream = append(stream, frame(2, "oops")...)
+	stream = append(stream, frame(1, "world")...)
+
+	var stdout, stderr bytes.Buffer
+	err := demux(bytes.NewReader(stream), &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("demux() failed: %s", err.Error())
+	}
+
+	if stdout.String() != "hello world" {
+		t.Errorf("stdout is '%s', expected 'hello world'", stdout.String())
+	}
+
+	if stderr.String() != "oops" {
+		t.Errorf("stderr is '%s', expected 'oops'", stderr.String())
+	}
+}
+
+func TestExec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dockertransport")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Listen() failed: %s", err.Error())
+	}
+	defer listener.Close()
+
+	var cmd []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/containers/web/exec", func(w http.ResponseWriter, r *http.Request) {
+		var create execCreate
+		json.NewDecoder(r.Body).Decode(&create)
+		cmd = create.Cmd
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"Id":"abc"}`))
+	})
+	mux.HandleFunc("/exec/abc/start", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(frame(1, "1.00 2.00 3.00 1/100 1\n"))
+	})
+	mux.HandleFunc("/exec/abc/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Running":false,"ExitCode":0}`))
+	})
+
+	go http.Serve(listener, mux)
+
+	d := NewDockerTransport().(*DockerTransport)
+	d.Container = "web"
+	d.Socket = socket
+
+	b, err := d.ReadFile("/proc/loadavg")
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %s", err.Error())
+	}
+
+	if string(b) != "1.00 2.00 3.00 1/100 1\n" {
+		t.Errorf("ReadFile() returned '%s'", string(b))
+	}
+
+	if len(cmd) != 2 || cmd[0] != "cat" || cmd[1] != "/proc/loadavg" {
+		t.Errorf("Wrong command executed: %v", cmd)
+	}
+}
@@ -17,6 +17,7 @@ func init() {
 func NewMock() interface{} {
 	return &Mock{
 		files: make(map[string][]byte),
+		execs: make(map[string][]byte),
 	}
 }
 
@@ -24,6 +25,7 @@ type (
 	// Mock is a type that can help in writing tests for agents.
 	Mock struct {
 		files map[string][]byte
+		execs map[string][]byte
 	}
 )
 
@@ -39,6 +41,12 @@ func (m *Mock) SetFile(path string, contents []byte) {
 	m.files[path] = contents
 }
 
+// SetExec sets the output to be returned on stdout if an agent tries to
+// Exec() cmd.
+func (m *Mock) SetExec(cmd string, stdout []byte) {
+	m.execs[cmd] = stdout
+}
+
 func (m *Mock) GetDoc() *plugins.Doc {
 	doc := plugins.NewDoc("Mock transport for testing")
 
@@ -50,7 +58,12 @@ func (m *Mock) Dial(network string, address string) (net.Conn, error) {
 }
 
 func (m *Mock) Exec(cmd string, arguments ...string) (io.Reader, io.Reader, error) {
-	return nil, nil, errors.New("Not supported yet")
+	stdout, found := m.execs[cmd]
+	if !found {
+		return nil, nil, errors.New("command not found")
+	}
+
+	return bytes.NewReader(stdout), bytes.NewReader(nil), nil
 }
 
 func (m *Mock) Open(path string) (io.ReadCloser, error) {
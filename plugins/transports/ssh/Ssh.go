@@ -6,12 +6,14 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"path"
 	"sync"
 
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 
 	"github.com/abrander/agento/configuration"
 	"github.com/abrander/agento/logger"
@@ -19,9 +21,13 @@ import (
 
 type (
 	Ssh struct {
-		Host     string `json:"host" description:"Hostname or IP adress to connect to"`
-		Port     uint16 `json:"port" description:"TCP port to connect to" default:"22"`
-		Username string `json:"username" description:"Username"`
+		Host       string `toml:"host" json:"host" description:"Hostname or IP adress to connect to"`
+		Port       uint16 `toml:"port" json:"port" description:"TCP port to connect to" default:"22"`
+		Username   string `toml:"username" json:"username" description:"Username"`
+		Password   string `toml:"password" json:"password" description:"Password (leave empty to use key authentication)"`
+		PrivateKey string `toml:"privateKey" json:"privateKey" description:"PEM encoded private key (leave empty to use Agento's own key)"`
+		HostKey    string `toml:"hostKey" json:"hostKey" description:"Public key of the host in authorized_keys format"`
+		KnownHosts string `toml:"knownHosts" json:"knownHosts" description:"Path to a known_hosts file to check the host key against"`
 	}
 )
 
@@ -31,6 +37,11 @@ const (
 )
 
 var (
+	// ErrNoHostKey is returned when password authentication is configured
+	// without a way to verify the host key. The password would be sent to
+	// anyone able to intercept the connection.
+	ErrNoHostKey = errors.New("password authentication requires a host key or known_hosts file")
+
 	signer    ssh.Signer
 	publicKey string
 	lock      sync.Mutex
@@ -48,20 +59,20 @@ func PublicKey() string {
 	if err != nil {
 		pemBytes, err = GenerateKey()
 		if err != nil {
-			logger.Error("ssh1", err.Error())
+			logger.Error("ssh1", "%s", err.Error())
 		}
 	}
 
 	// Parse private key for ssh
 	signer, err = ssh.ParsePrivateKey(pemBytes)
 	if err != nil {
-		logger.Error("ssh", err.Error())
+		logger.Error("ssh", "%s", err.Error())
 	}
 
 	// Parse private key for generating public key
 	key, err := ssh.ParseRawPrivateKey(pemBytes)
 	if err != nil {
-		logger.Error("ssh", err.Error())
+		logger.Error("ssh", "%s", err.Error())
 		return ""
 	}
 
@@ -75,7 +86,7 @@ func PublicKey() string {
 	// Generate public key (this is deterministic)
 	rsaPubKey, err := ssh.NewPublicKey(&rsaKey.PublicKey)
 	if err != nil {
-		logger.Error("ssh", err.Error())
+		logger.Error("ssh", "%s", err.Error())
 		return ""
 	}
 
@@ -85,7 +96,7 @@ func PublicKey() string {
 	// Write file for convenience and automation
 	err = ioutil.WriteFile(path.Join(configuration.StateDir, publicKeyFilename), []byte(publicKey), 0644)
 	if err != nil {
-		logger.Error("ssh", err.Error())
+		logger.Error("ssh", "%s", err.Error())
 	}
 
 	return publicKey
@@ -116,17 +127,68 @@ func GenerateKey() ([]byte, error) {
 	return pemBuffer.Bytes(), nil
 }
 
-// Connect to a remote ssh server using public key authentication
+// authMethods returns the authentication methods to use for s. A configured
+// private key or password takes precedence over Agento's own key.
+func (s *Ssh) authMethods() ([]ssh.AuthMethod, error) {
+	if s.PrivateKey != "" {
+		keySigner, err := ssh.ParsePrivateKey([]byte(s.PrivateKey))
+		if err != nil {
+			return nil, err
+		}
+
+		return []ssh.AuthMethod{ssh.PublicKeys(keySigner)}, nil
+	}
+
+	if s.Password != "" {
+		return []ssh.AuthMethod{ssh.Password(s.Password)}, nil
+	}
+
+	// We have to call PublicKey() to make sure signer is initialized
+	PublicKey()
+
+	return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil
+}
+
+// hostKeyCallback returns the callback verifying the host key of s. If
+// neither HostKey nor KnownHosts is set, any host key is accepted, unless
+// password authentication is used.
+func (s *Ssh) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	switch {
+	case s.HostKey != "":
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(s.HostKey))
+		if err != nil {
+			return nil, err
+		}
+
+		return ssh.FixedHostKey(key), nil
+	case s.KnownHosts != "":
+		return knownhosts.New(s.KnownHosts)
+	case s.Password != "" && s.PrivateKey == "":
+		return nil, ErrNoHostKey
+	}
+
+	return ssh.InsecureIgnoreHostKey(), nil
+}
+
+// Connect to a remote ssh server using password or public key authentication
 func (s *Ssh) Connect() (*ssh.Client, error) {
 	dialString := fmt.Sprintf("%s:%d", s.Host, s.Port)
 	logger.Yellow("ssh", "Connecting to %s as %s", dialString, s.Username)
 
-	// We have to call PublicKey() to make sure signer is initialized
-	PublicKey()
+	auth, err := s.authMethods()
+	if err != nil {
+		return nil, err
+	}
+
+	hostKeyCallback, err := s.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
 
 	config := &ssh.ClientConfig{
-		User: s.Username,
-		Auth: []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		User:            s.Username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
 	}
 	client, err := ssh.Dial("tcp", dialString, config)
 	if err != nil {
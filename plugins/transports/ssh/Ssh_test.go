@@ -0,0 +1,73 @@
+package ssh
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestHostKeyCallback(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() failed: %s", err.Error())
+	}
+
+	key, err := ssh.NewPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatalf("NewPublicKey() failed: %s", err.Error())
+	}
+
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	otherKey, _ := ssh.NewPublicKey(&other.PublicKey)
+
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 22}
+
+	s := &Ssh{Password: "secret"}
+	_, err = s.hostKeyCallback()
+	if err != ErrNoHostKey {
+		t.Errorf("Password authentication without host key returned %v, expected %v", err, ErrNoHostKey)
+	}
+
+	s.HostKey = string(ssh.MarshalAuthorizedKey(key))
+	callback, err := s.hostKeyCallback()
+	if err != nil {
+		t.Fatalf("hostKeyCallback() failed: %s", err.Error())
+	}
+
+	if callback("127.0.0.1:22", addr, key) != nil {
+		t.Errorf("Configured host key was rejected")
+	}
+
+	if callback("127.0.0.1:22", addr, otherKey) == nil {
+		t.Errorf("Wrong host key was accepted")
+	}
+
+	file, err := ioutil.TempFile("", "known_hosts")
+	if err != nil {
+		t.Fatalf("TempFile() failed: %s", err.Error())
+	}
+	defer os.Remove(file.Name())
+
+	file.WriteString(knownhosts.Line([]string{"127.0.0.1"}, key) + "\n")
+	file.Close()
+
+	s = &Ssh{Password: "secret", KnownHosts: file.Name()}
+	callback, err = s.hostKeyCallback()
+	if err != nil {
+		t.Fatalf("hostKeyCallback() failed: %s", err.Error())
+	}
+
+	if callback("127.0.0.1:22", addr, key) != nil {
+		t.Errorf("Known host key was rejected")
+	}
+
+	if callback("127.0.0.1:22", addr, otherKey) == nil {
+		t.Errorf("Unknown host key was accepted")
+	}
+}
@@ -0,0 +1,138 @@
+package winrmtransport
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/masterzen/winrm"
+
+	"github.com/abrander/agento/logger"
+	"github.com/abrander/agento/plugins"
+)
+
+func init() {
+	plugins.Register("winrmtransport", NewWinRMTransport)
+}
+
+// NewWinRMTransport will instantiate a new transport using the default
+// WinRM HTTP port.
+func NewWinRMTransport() interface{} {
+	return &WinRMTransport{
+		Port: 5985,
+	}
+}
+
+type (
+	// WinRMTransport will execute commands on Windows hosts using Windows
+	// Remote Management.
+	WinRMTransport struct {
+		Host               string `toml:"host" json:"host" description:"Host to connect to" required:"true"`
+		Port               int    `toml:"port" json:"port" description:"WinRM port (5985 for HTTP, 5986 for HTTPS)" default:"5985"`
+		Username           string `toml:"username" json:"username" description:"Username"`
+		Password           string `toml:"password" json:"password" description:"Password"`
+		HTTPS              bool   `toml:"https" json:"https" description:"Use HTTPS"`
+		InsecureSkipVerify bool   `toml:"insecureSkipVerify" json:"insecureSkipVerify" description:"Skip verification of the server certificate"`
+	}
+)
+
+// timeout is the timeout for each WinRM request.
+const timeout = 60 * time.Second
+
+// GetDoc implements plugins.Plugin.
+func (w *WinRMTransport) GetDoc() *plugins.Doc {
+	doc := plugins.NewDoc("WinRM transport")
+
+	return doc
+}
+
+// client will return a WinRM client for the configured host.
+func (w *WinRMTransport) client() (*winrm.Client, error) {
+	endpoint := winrm.NewEndpoint(w.Host, w.Port, w.HTTPS, w.InsecureSkipVerify, nil, nil, nil, timeout)
+
+	return winrm.NewClient(endpoint, w.Username, w.Password)
+}
+
+// Dial is not supported by the WinRM transport.
+func (w *WinRMTransport) Dial(network string, address string) (net.Conn, error) {
+	return nil, errors.New("winrmtransport does not implement Dial()")
+}
+
+// Exec will run cmd in a new cmd.exe shell and return stdout and stderr.
+func (w *WinRMTransport) Exec(cmd string, arguments ...string) (io.Reader, io.Reader, error) {
+	logger.Yellow("winrm", "Executing command '%s' on %s:%d as %s", cmd, w.Host, w.Port, w.Username)
+
+	client, err := w.client()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	shell, err := client.CreateShell()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer shell.Close()
+
+	command, err := shell.Execute(cmd, arguments...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer command.Close()
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		io.Copy(&stdoutBuf, command.Stdout)
+	}()
+
+	go func() {
+		defer wg.Done()
+		io.Copy(&stderrBuf, command.Stderr)
+	}()
+
+	command.Wait()
+	wg.Wait()
+
+	if command.ExitCode() != 0 {
+		return &stdoutBuf, &stderrBuf, fmt.Errorf("'%s' exited with status %d", cmd, command.ExitCode())
+	}
+
+	return &stdoutBuf, &stderrBuf, nil
+}
+
+// Open will read path from the host by using type.
+func (w *WinRMTransport) Open(path string) (io.ReadCloser, error) {
+	r, _, err := w.Exec("type", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.NopCloser(r), nil
+}
+
+// ReadFile reads the complete file at path from the host.
+func (w *WinRMTransport) ReadFile(path string) ([]byte, error) {
+	r, err := w.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(r)
+}
+
+// Statfs is not supported by the WinRM transport.
+func (w *WinRMTransport) Statfs(path string, buf *syscall.Statfs_t) error {
+	return errors.New("winrmtransport does not implement Statfs()")
+}
+
+// Ensure compliance
+var _ plugins.Transport = (*WinRMTransport)(nil)
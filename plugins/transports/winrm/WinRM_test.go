@@ -0,0 +1,135 @@
+package winrmtransport
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeServer will answer WinRM requests with fixtures from testdata. The
+// body of the Command request is recorded.
+type fakeServer struct {
+	exitCode string
+
+	lock    sync.Mutex
+	command string
+	user    string
+	pass    string
+	deleted bool
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	b, _ := ioutil.ReadAll(r.Body)
+	body := string(b)
+
+	s.user, s.pass, _ = r.BasicAuth()
+
+	var fixture string
+	switch {
+	case strings.Contains(body, "transfer/Create"):
+		fixture = "create.xml"
+	case strings.Contains(body, "shell/Command"):
+		s.command = body
+		fixture = "command.xml"
+	case strings.Contains(body, "shell/Receive"):
+		fixture = "receive" + s.exitCode + ".xml"
+	case strings.Contains(body, "transfer/Delete"):
+		s.deleted = true
+		fixture = "empty.xml"
+	default:
+		fixture = "empty.xml"
+	}
+
+	response, _ := ioutil.ReadFile(filepath.Join("testdata", fixture))
+
+	w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	w.Write(response)
+}
+
+func newTransport(t *testing.T, server *httptest.Server) *WinRMTransport {
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("SplitHostPort() failed: %s", err.Error())
+	}
+
+	w := NewWinRMTransport().(*WinRMTransport)
+	w.Host = host
+	w.Port, _ = strconv.Atoi(port)
+	w.Username = "Administrator"
+	w.Password = "secret"
+
+	return w
+}
+
+func TestExec(t *testing.T) {
+	fake := &fakeServer{exitCode: "0"}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	w := newTransport(t, server)
+
+	stdout, stderr, err := w.Exec("ipconfig", "/all")
+	if err != nil {
+		t.Fatalf("Exec() failed: %s", err.Error())
+	}
+
+	out, _ := ioutil.ReadAll(stdout)
+	if string(out) != "Windows IP Configuration\r\n" {
+		t.Errorf("Wrong stdout '%s'", out)
+	}
+
+	errOut, _ := ioutil.ReadAll(stderr)
+	if string(errOut) != "warning\r\n" {
+		t.Errorf("Wrong stderr '%s'", errOut)
+	}
+
+	if !strings.Contains(fake.command, "<rsp:Command><![CDATA[ipconfig]]></rsp:Command>") ||
+		!strings.Contains(fake.command, "<rsp:Arguments><![CDATA[/all]]></rsp:Arguments>") {
+		t.Errorf("Command not dispatched correctly: %s", fake.command)
+	}
+
+	if fake.user != "Administrator" || fake.pass != "secret" {
+		t.Errorf("Wrong credentials %s:%s", fake.user, fake.pass)
+	}
+
+	if !fake.deleted {
+		t.Errorf("Shell was not deleted")
+	}
+}
+
+func TestExecExitCode(t *testing.T) {
+	server := httptest.NewServer(&fakeServer{exitCode: "3"})
+	defer server.Close()
+
+	w := newTransport(t, server)
+
+	stdout, _, err := w.Exec("ipconfig")
+	if err == nil || !strings.Contains(err.Error(), "status 3") {
+		t.Fatalf("Exec() returned %v, expected exit status error", err)
+	}
+
+	out, _ := ioutil.ReadAll(stdout)
+	if string(out) != "Windows IP Configuration\r\n" {
+		t.Errorf("stdout not captured on failure: '%s'", out)
+	}
+}
+
+func TestExecUnreachable(t *testing.T) {
+	server := httptest.NewServer(&fakeServer{})
+	w := newTransport(t, server)
+	server.Close()
+
+	_, _, err := w.Exec("ipconfig")
+	if err == nil {
+		t.Fatalf("Exec() did not fail for an unreachable host")
+	}
+}
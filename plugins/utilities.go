@@ -57,3 +57,14 @@ func Round(value float64, places int) float64 {
 
 	return round / pow
 }
+
+// CounterRate will calculate the per-second rate of change between two
+// samples of a cumulative counter taken seconds apart. If the counter went
+// backwards (reset or wraparound) or no time has passed, zero is returned.
+func CounterRate(current float64, previous float64, seconds float64) float64 {
+	if seconds <= 0 || current < previous {
+		return 0.0
+	}
+
+	return (current - previous) / seconds
+}
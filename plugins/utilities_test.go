@@ -0,0 +1,23 @@
+package plugins
+
+import (
+	"testing"
+)
+
+func TestPointSanitize(t *testing.T) {
+	points := []struct {
+		line     string
+		expected string
+	}{
+		{SimplePoint("cpu.User\n", 1.0).LineProtocol(), "cpu.User\\  value=1"},
+		{PointWithTag("disk.Used", 1.0, "mountpoint", "/mnt/my disk\\").LineProtocol(), "disk.Used,mountpoint=/mnt/my\\ disk value=1"},
+		{PointWithTag("net.Rx", 1.0, "if", "eth0,vlan=2\n").LineProtocol(), "net.Rx,if=eth0\\,vlan\\=2\\  value=1"},
+		{PointWithTags("p", 1.0, map[string]string{"": "x", "a": "b"}).LineProtocol(), "p,a=b value=1"},
+	}
+
+	for _, p := range points {
+		if p.line != p.expected {
+			t.Errorf("Got '%s', expected '%s'", p.line, p.expected)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/abrander/agento/configuration"
+)
+
+type (
+	// health tracks reports written to the database, to let /health fail
+	// when nothing has been written for a while or writes keep failing.
+	health struct {
+		maxStaleness     time.Duration
+		maxWriteFailures int
+
+		lock           sync.Mutex
+		started        time.Time
+		lastReport     time.Time
+		lastWriteError string
+		failures       int
+
+		// flushFailures counts consecutive failed background writes of
+		// buffered points. Reports are buffered successfully while the
+		// database is down, they must not reset this.
+		flushFailures int
+	}
+
+	// healthStatus is the body returned by /health.
+	healthStatus struct {
+		Status         string     `json:"status"`
+		LastReport     *time.Time `json:"lastReport"`
+		LastWriteError string     `json:"lastWriteError,omitempty"`
+	}
+)
+
+// defaultMaxWriteFailures is the number of consecutive failed writes making
+// the server unhealthy unless configured.
+const defaultMaxWriteFailures = 3
+
+// newHealth will return a health tracker for cfg.
+func newHealth(cfg configuration.HealthConfiguration) *health {
+	maxWriteFailures := cfg.MaxWriteFailures
+	if maxWriteFailures == 0 {
+		maxWriteFailures = defaultMaxWriteFailures
+	}
+
+	return &health{
+		maxStaleness:     time.Duration(cfg.MaxStaleness) * time.Second,
+		maxWriteFailures: maxWriteFailures,
+		started:          time.Now(),
+	}
+}
+
+// written will record the result of writing a report to the database.
+func (h *health) written(err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if err != nil {
+		h.lastWriteError = err.Error()
+		h.failures++
+
+		return
+	}
+
+	h.lastReport = time.Now()
+	h.failures = 0
+}
+
+// flushed will record the result of writing buffered points to the
+// database in the background.
+func (h *health) flushed(err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if err != nil {
+		h.lastWriteError = err.Error()
+		h.flushFailures++
+
+		return
+	}
+
+	h.flushFailures = 0
+}
+
+// status will return the health at t. A server that never received a report
+// is stale maxStaleness after it was started.
+func (h *health) status(t time.Time) (int, healthStatus) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	status := healthStatus{
+		Status:         "ok",
+		LastWriteError: h.lastWriteError,
+	}
+
+	since := h.started
+	if !h.lastReport.IsZero() {
+		lastReport := h.lastReport
+		status.LastReport = &lastReport
+		since = lastReport
+	}
+
+	switch {
+	case h.maxWriteFailures > 0 && (h.failures >= h.maxWriteFailures || h.flushFailures >= h.maxWriteFailures):
+		status.Status = "failing"
+	case h.maxStaleness > 0 && t.Sub(since) > h.maxStaleness:
+		status.Status = "stale"
+	default:
+		return http.StatusOK, status
+	}
+
+	return http.StatusServiceUnavailable, status
+}
+
+func (s *Server) healthHandler(c *gin.Context) {
+	if c.Request.Method != "GET" {
+		c.Header("Allow", "GET")
+		c.String(http.StatusMethodNotAllowed, "only GET allowed")
+		return
+	}
+
+	c.JSON(s.health.status(time.Now()))
+}
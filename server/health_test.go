@@ -0,0 +1,195 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/timeseries"
+	"github.com/abrander/agento/userdb"
+)
+
+type (
+	// flushingTSDB is a database writing points in the background.
+	flushingTSDB struct {
+		mockTSDB
+		onFlush func(notWritten []*timeseries.Point, err error)
+	}
+)
+
+func (f *flushingTSDB) OnFlush(fn func(notWritten []*timeseries.Point, err error)) {
+	f.onFlush = fn
+}
+
+// getHealth will GET /health and decode the body.
+func getHealth(t *testing.T, engine *gin.Engine) (int, healthStatus) {
+	req, _ := http.NewRequest("GET", "/health", nil)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	var status healthStatus
+	err := json.Unmarshal(w.Body.Bytes(), &status)
+	if err != nil {
+		t.Fatalf("Health is not JSON: %s", err.Error())
+	}
+
+	return w.Code, status
+}
+
+func TestHealthHealthy(t *testing.T) {
+	s, engine, _ := newTestServer()
+	s.health = newHealth(configuration.HealthConfiguration{MaxStaleness: 60})
+
+	code, status := getHealth(t, engine)
+	if code != http.StatusOK || status.Status != "ok" || status.LastReport != nil {
+		t.Fatalf("Got %d %+v before any report, expected 200 ok", code, status)
+	}
+
+	w := report(engine, []byte(`{"hostname": "web1"}`), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Report failed with %d: %s", w.Code, w.Body.String())
+	}
+
+	code, status = getHealth(t, engine)
+	if code != http.StatusOK || status.Status != "ok" {
+		t.Fatalf("Got %d %+v after report, expected 200 ok", code, status)
+	}
+
+	if status.LastReport == nil || time.Since(*status.LastReport) > time.Minute {
+		t.Errorf("Wrong lastReport %v", status.LastReport)
+	}
+
+	req, _ := http.NewRequest("POST", "/health", nil)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Got %d for POST, expected %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestHealthStale(t *testing.T) {
+	h := newHealth(configuration.HealthConfiguration{MaxStaleness: 60})
+
+	// Never reported, but just started.
+	code, _ := h.status(h.started.Add(30 * time.Second))
+	if code != http.StatusOK {
+		t.Errorf("Got %d right after start, expected 200", code)
+	}
+
+	code, status := h.status(h.started.Add(2 * time.Minute))
+	if code != http.StatusServiceUnavailable || status.Status != "stale" {
+		t.Errorf("Got %d %+v without reports, expected 503 stale", code, status)
+	}
+
+	h.written(nil)
+
+	code, _ = h.status(h.lastReport.Add(30 * time.Second))
+	if code != http.StatusOK {
+		t.Errorf("Got %d 30s after a report, expected 200", code)
+	}
+
+	code, status = h.status(h.lastReport.Add(time.Hour))
+	if code != http.StatusServiceUnavailable || status.Status != "stale" {
+		t.Errorf("Got %d %+v an hour after a report, expected 503 stale", code, status)
+	}
+
+	// Staleness is disabled by default.
+	h = newHealth(configuration.HealthConfiguration{})
+	code, _ = h.status(h.started.Add(24 * time.Hour))
+	if code != http.StatusOK {
+		t.Errorf("Got %d with staleness disabled, expected 200", code)
+	}
+}
+
+func TestHealthWriteFailures(t *testing.T) {
+	s, engine, _ := newTestServer()
+
+	s.health.written(nil)
+	s.health.written(errors.New("connection refused"))
+	s.health.written(errors.New("connection refused"))
+
+	code, status := getHealth(t, engine)
+	if code != http.StatusOK {
+		t.Errorf("Got %d after 2 failures, expected 200", code)
+	}
+
+	if status.LastWriteError != "connection refused" {
+		t.Errorf("Got lastWriteError '%s'", status.LastWriteError)
+	}
+
+	s.health.written(errors.New("timeout"))
+
+	code, status = getHealth(t, engine)
+	if code != http.StatusServiceUnavailable || status.Status != "failing" || status.LastWriteError != "timeout" {
+		t.Errorf("Got %d %+v after 3 failures, expected 503 failing", code, status)
+	}
+
+	s.health.written(nil)
+
+	code, _ = getHealth(t, engine)
+	if code != http.StatusOK {
+		t.Errorf("Got %d after recovering, expected 200", code)
+	}
+
+	// Negative disables the check.
+	h := newHealth(configuration.HealthConfiguration{MaxWriteFailures: -1})
+	for i := 0; i < 10; i++ {
+		h.written(errors.New("timeout"))
+	}
+
+	code, _ = h.status(time.Now())
+	if code != http.StatusOK {
+		t.Errorf("Got %d with failure check disabled, expected 200", code)
+	}
+}
+
+func TestHealthFlushFailures(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tsdb := &flushingTSDB{}
+	engine := gin.New()
+	_, err := NewServer(engine, configuration.ServerConfiguration{}, userdb.NewSingleUser("secret"), nil, tsdb)
+	if err != nil {
+		t.Fatalf("NewServer() failed: %s", err.Error())
+	}
+
+	if tsdb.onFlush == nil {
+		t.Fatalf("NewServer() did not ask for flush results")
+	}
+
+	before := scrape(t, engine, "agento_influxdb_write_failures_total")
+
+	// Reports are buffered just fine while the flushes keep failing.
+	for i := 0; i < 3; i++ {
+		w := report(engine, []byte(`{"hostname": "testhost", "entropy": 123}`), nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Got status %d, expected %d: %s", w.Code, http.StatusOK, w.Body.String())
+		}
+
+		tsdb.onFlush(nil, errors.New("connection refused"))
+	}
+
+	code, status := getHealth(t, engine)
+	if code != http.StatusServiceUnavailable || status.Status != "failing" || status.LastWriteError != "connection refused" {
+		t.Errorf("Got %d %+v after 3 failed flushes, expected 503 failing", code, status)
+	}
+
+	after := scrape(t, engine, "agento_influxdb_write_failures_total")
+	if after != before+3 {
+		t.Errorf("Write failure counter is %f after 3 failed flushes, expected %f", after, before+3)
+	}
+
+	tsdb.onFlush(nil, nil)
+
+	code, _ = getHealth(t, engine)
+	if code != http.StatusOK {
+		t.Errorf("Got %d after a successful flush, expected 200", code)
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/abrander/agento/configuration"
+)
+
+type (
+	// rateLimiter keeps a token bucket per account.
+	rateLimiter struct {
+		cfg configuration.RateLimitConfiguration
+
+		lock     sync.Mutex
+		limiters map[string]*rate.Limiter
+	}
+)
+
+// newRateLimiter will return a limiter for cfg. If no rate is configured
+// globally or for any account, nil is returned.
+func newRateLimiter(cfg configuration.RateLimitConfiguration) *rateLimiter {
+	if cfg.Rate <= 0 && len(cfg.Accounts) == 0 {
+		return nil
+	}
+
+	return &rateLimiter{
+		cfg:      cfg,
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+// limiter will return the token bucket for id, creating it if needed. nil
+// is returned if id is not limited.
+func (r *rateLimiter) limiter(id string) *rate.Limiter {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	l, found := r.limiters[id]
+	if found {
+		return l
+	}
+
+	limit := configuration.AccountRateLimit{
+		Rate:  r.cfg.Rate,
+		Burst: r.cfg.Burst,
+	}
+
+	override, found := r.cfg.Accounts[id]
+	if found {
+		limit = override
+	}
+
+	if limit.Rate > 0 {
+		burst := limit.Burst
+		if burst < 1 {
+			burst = 1
+		}
+
+		l = rate.NewLimiter(rate.Limit(limit.Rate), burst)
+	}
+
+	r.limiters[id] = l
+
+	return l
+}
+
+// allow will take a token from the bucket for id. If the bucket is empty,
+// false is returned with the time until a token is available. A nil
+// rateLimiter allows everything.
+func (r *rateLimiter) allow(id string) (bool, time.Duration) {
+	if r == nil {
+		return true, 0
+	}
+
+	l := r.limiter(id)
+	if l == nil {
+		return true, 0
+	}
+
+	reservation := l.Reserve()
+	delay := reservation.Delay()
+	if delay == 0 {
+		return true, 0
+	}
+
+	// We're not going to wait, give the token back.
+	reservation.Cancel()
+
+	return false, delay
+}
@@ -1,15 +1,21 @@
 package server
 
 import (
+	"compress/gzip"
 	"crypto/tls"
+	"errors"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/abrander/agento/configuration"
 	"github.com/abrander/agento/core"
 	"github.com/abrander/agento/logger"
+	"github.com/abrander/agento/metrics"
 	"github.com/abrander/agento/plugins"
 	"github.com/abrander/agento/plugins/agents/hostname"
 	"github.com/abrander/agento/timeseries"
@@ -21,51 +27,197 @@ type (
 		inventory map[string]*inventory
 		http      configuration.HTTPConfiguration
 		https     configuration.HTTPSConfiguration
+		tlsConfig *tls.Config
 		udp       configuration.UDPConfiguration
 		secret    string
 		db        userdb.Database
 		tsdb      timeseries.Database
 		store     core.HostStore
+
+		// maxReportBytes is the maximum size of a report body.
+		maxReportBytes int64
+
+		// limiter limits reports per account. nil means no limit.
+		limiter *rateLimiter
+
+		// health tracks writes for /health.
+		health *health
 	}
 )
 
-func NewServer(router gin.IRouter, cfg configuration.ServerConfiguration, db userdb.Database, store core.HostStore) (*Server, error) {
+func NewServer(router gin.IRouter, cfg configuration.ServerConfiguration, db userdb.Database, store core.HostStore, tsdb timeseries.Database) (*Server, error) {
 	s := &Server{}
 
+	router.Use(metrics.Middleware())
 	router.Any("/report", s.reportHandler)
 	router.Any("/health", s.healthHandler)
+	router.GET("/docs", s.docsHandler)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
 
 	var err error
 	s.http = cfg.HTTP
 	s.https = cfg.HTTPS
-	s.udp = cfg.UDP
-	s.secret = cfg.Secret
-	s.db = db
-	s.tsdb, err = timeseries.NewInfluxDb(&cfg.Influxdb)
+	s.tlsConfig, err = newTLSConfig(cfg.HTTPS)
 	if err != nil {
 		return nil, err
 	}
+
+	s.udp = cfg.UDP
+	s.maxReportBytes = cfg.MaxReportBytes
+	s.limiter = newRateLimiter(cfg.RateLimit)
+	s.health = newHealth(cfg.Health)
+	s.secret = cfg.Secret
+	s.db = db
+	s.tsdb = tsdb
 	s.store = store
 
+	if statser, ok := tsdb.(timeseries.Statser); ok {
+		metrics.SetPointStats(statser.Stats)
+	}
+
+	// Buffered points are written in the background, WritePoints() will
+	// not tell us about failures.
+	if flusher, ok := tsdb.(timeseries.Flusher); ok {
+		flusher.OnFlush(s.flushed)
+	}
+
 	s.inventory = make(map[string]*inventory)
 
 	return s, nil
 }
 
-func (s *Server) sendToInflux(stats plugins.Results, id string) error {
+var (
+	// ErrMissingHostname will be returned if a report doesn't include a
+	// hostname.
+	ErrMissingHostname = errors.New("report is missing hostname")
+
+	// ErrForeignHost will be returned if a report is for a host belonging to
+	// another account.
+	ErrForeignHost = errors.New("The hostname belongs to another account")
+
+	// ErrAddHost will be returned if a host reporting for the first time
+	// could not be added to the store.
+	ErrAddHost = errors.New("Cannot add host")
+
+	// ErrNotAccount will be returned if a report is made using a key not
+	// belonging to an account.
+	ErrNotAccount = errors.New("Only account keys can report metrics")
+
+	// ErrConflictingKeys will be returned if a report carries different
+	// keys in X-Agento-Secret and Authorization.
+	ErrConflictingKeys = errors.New("X-Agento-Secret and Authorization headers do not match")
+
+	// ErrRateLimited will be returned if a report over UDP exceeds the rate
+	// limit of the account.
+	ErrRateLimited = errors.New("rate limit exceeded")
+)
+
+const (
+	// defaultMaxReportBytes is used if no limit is configured.
+	defaultMaxReportBytes = 5 * 1024 * 1024
+)
+
+// getHostname will extract the hostname from a report.
+func getHostname(results plugins.Results) (string, error) {
+	h, ok := results["hostname"].(*hostname.Hostname)
+	if !ok || h == nil || *h == "" {
+		return "", ErrMissingHostname
+	}
+
+	return string(*h), nil
+}
+
+func (s *Server) sendToInflux(stats plugins.Results, id string, received time.Time) error {
 	points := stats.GetPoints()
 
 	// Add hostname tag to all points
-	hostname := string(*stats["hostname"].(*hostname.Hostname))
+	hostname, err := getHostname(stats)
+	if err != nil {
+		return err
+	}
+
 	for _, point := range points {
 		point.Tags["hostname"] = hostname
 
-		if id != "000000000000000000000000" {
-			point.Tags["id"] = id
+		// Reports are sampled just before sending, stamp points with the
+		// time we received the report rather than the time they're written.
+		if point.Time.IsZero() {
+			point.Time = received
 		}
+
+		timeseries.TagAccount(point, id)
+	}
+
+	err = s.tsdb.WritePoints(points)
+	if err != nil {
+		metrics.InfluxWriteFailures.Inc()
+	}
+
+	s.health.written(err)
+
+	return err
+}
+
+// flushed will record the result of writing buffered points in the
+// background.
+func (s *Server) flushed(notWritten []*timeseries.Point, err error) {
+	if err != nil {
+		metrics.InfluxWriteFailures.Inc()
 	}
 
-	return s.tsdb.WritePoints(points)
+	s.health.flushed(err)
+}
+
+// ingest will add the reporting host to the store if needed and write results
+// received at received to InfluxDB. This is shared by the HTTP and UDP
+// listeners.
+func (s *Server) ingest(account userdb.Account, results plugins.Results, received time.Time) error {
+	hostname, err := getHostname(results)
+	if err != nil {
+		return err
+	}
+
+	if s.store != nil {
+		_, err = s.store.GetHostByName(account, hostname)
+		if err == userdb.ErrorNoAccess {
+			return ErrForeignHost
+		} else if err != nil {
+			host := &core.Host{
+				Name:        hostname,
+				TransportID: "localtransport",
+			}
+
+			err = s.store.AddHost(account, host)
+			if err != nil {
+				return ErrAddHost
+			}
+		}
+	}
+
+	return s.sendToInflux(results, account.GetId(), received)
+}
+
+// reportKey will return the key from the X-Agento-Secret header or from an
+// "Authorization: Bearer" header for clients behind proxies stripping custom
+// headers. If both are present, they must match.
+func reportKey(r *http.Request) (string, error) {
+	secret := r.Header.Get("X-Agento-Secret")
+
+	var bearer string
+	authorization := r.Header.Get("Authorization")
+	if len(authorization) > 7 && strings.EqualFold(authorization[:7], "Bearer ") {
+		bearer = strings.TrimSpace(authorization[7:])
+	}
+
+	if secret != "" && bearer != "" && secret != bearer {
+		return "", ErrConflictingKeys
+	}
+
+	if secret != "" {
+		return secret, nil
+	}
+
+	return bearer, nil
 }
 
 func (s *Server) reportHandler(c *gin.Context) {
@@ -75,7 +227,13 @@ func (s *Server) reportHandler(c *gin.Context) {
 		return
 	}
 
-	key := c.Request.Header.Get("X-Agento-Secret")
+	received := time.Now()
+
+	key, err := reportKey(c.Request)
+	if err != nil {
+		c.String(http.StatusBadRequest, "%s", err.Error())
+		return
+	}
 
 	subject, err := s.db.ResolveKey(key)
 	if err != nil {
@@ -84,55 +242,74 @@ func (s *Server) reportHandler(c *gin.Context) {
 	}
 	account, ok := subject.(userdb.Account)
 	if !ok {
-		c.String(http.StatusForbidden, "Only account keys can report metrics")
+		c.String(http.StatusForbidden, "%s", ErrNotAccount.Error())
 		return
 	}
 
-	var results = plugins.Results{}
-
-	err = c.BindJSON(&results)
-	if err != nil {
-		c.String(http.StatusBadRequest, "%s", err.Error())
+	allowed, wait := s.limiter.allow(account.GetId())
+	if !allowed {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.String(http.StatusTooManyRequests, "rate limit exceeded")
 		return
 	}
 
-	if s.store != nil {
-		hostname := string(*results["hostname"].(*hostname.Hostname))
-		_, err = s.store.GetHostByName(account, hostname)
-		if err == userdb.ErrorNoAccess {
-			c.String(http.StatusForbidden, "The hostname belongs to another account")
-			return
-		} else if err != nil {
-			host := &core.Host{
-				Name:        hostname,
-				TransportID: "localtransport",
-			}
+	maxBytes := s.maxReportBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxReportBytes
+	}
 
-			err = s.store.AddHost(account, host)
-			if err != nil {
-				c.String(http.StatusInternalServerError, "Cannot add host")
-				return
-			}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+
+	// Clients may compress the report. The limit applies to the
+	// decompressed report as well.
+	if c.Request.Header.Get("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(c.Request.Body)
+		if err != nil {
+			c.String(http.StatusBadRequest, "%s", err.Error())
+			return
 		}
+		defer reader.Close()
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, reader, maxBytes)
 	}
 
-	err = s.sendToInflux(results, subject.GetId())
-	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+	var results = plugins.Results{}
+
+	err = c.ShouldBindJSON(&results)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.String(http.StatusRequestEntityTooLarge, "report exceeds %d bytes", maxBytes)
+		return
+	} else if err != nil {
+		c.String(http.StatusBadRequest, "%s", err.Error())
 		return
 	}
 
-	c.String(http.StatusOK, "%s", "Got it")
-}
+	metrics.ReportsReceived.Inc()
 
-func (s *Server) healthHandler(c *gin.Context) {
-	if c.Request.Method != "GET" {
-		c.Header("Allow", "GET")
-		c.String(http.StatusMethodNotAllowed, "only GET allowed")
+	err = s.ingest(account, results, received)
+	switch err {
+	case nil:
+	case ErrMissingHostname:
+		c.String(http.StatusBadRequest, "%s", err.Error())
+		return
+	case ErrForeignHost:
+		c.String(http.StatusForbidden, "%s", err.Error())
+		return
+	case ErrAddHost:
+		c.String(http.StatusInternalServerError, "%s", err.Error())
+		return
+	default:
+		c.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
 
-	c.String(200, "ok")
+	c.String(http.StatusOK, "%s", "Got it")
+}
+
+// docsHandler will serve the documentation catalog for all plugins.
+func (s *Server) docsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, plugins.Catalog())
 }
 
 func (s *Server) ListenAndServe(engine *gin.Engine) {
@@ -147,27 +324,12 @@ func (s *Server) ListenAndServe(engine *gin.Engine) {
 }
 
 func (s *Server) ListenAndServeTLS(engine *gin.Engine) {
-	// Choose strong TLS defaults
-	tlsConfig := &tls.Config{
-		MinVersion: tls.VersionTLS12,
-		CipherSuites: []uint16{
-			tls.TLS_RSA_WITH_AES_128_CBC_SHA,
-			tls.TLS_RSA_WITH_AES_256_CBC_SHA,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
-			tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
-			tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
-		},
-	}
-
 	addr := s.https.Bind + ":" + strconv.Itoa(int(s.https.Port))
 
 	server := &http.Server{
 		Addr:      addr,
 		Handler:   engine,
-		TLSConfig: tlsConfig}
+		TLSConfig: s.tlsConfig}
 
 	err := server.ListenAndServeTLS(s.https.CertPath, s.https.KeyPath)
 	if err != nil {
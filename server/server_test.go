@@ -0,0 +1,375 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/metrics"
+	"github.com/abrander/agento/plugins"
+	_ "github.com/abrander/agento/plugins/agents/entropy"
+	"github.com/abrander/agento/timeseries"
+	"github.com/abrander/agento/userdb"
+)
+
+type (
+	mockTSDB struct {
+		sync.Mutex
+		points []*timeseries.Point
+	}
+)
+
+func (m *mockTSDB) WritePoints(points []*timeseries.Point) error {
+	m.Lock()
+	m.points = append(m.points, points...)
+	m.Unlock()
+
+	return nil
+}
+
+func newTestServer() (*Server, *gin.Engine, *mockTSDB) {
+	gin.SetMode(gin.TestMode)
+
+	tsdb := &mockTSDB{}
+	s := &Server{
+		db:     userdb.NewSingleUser("secret"),
+		tsdb:   tsdb,
+		health: newHealth(configuration.HealthConfiguration{}),
+	}
+
+	engine := gin.New()
+	engine.Any("/report", s.reportHandler)
+	engine.Any("/health", s.healthHandler)
+	engine.GET("/docs", s.docsHandler)
+	engine.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	return s, engine, tsdb
+}
+
+// count will return the number of points written.
+func (m *mockTSDB) count() int {
+	m.Lock()
+	defer m.Unlock()
+
+	return len(m.points)
+}
+
+func report(engine *gin.Engine, body []byte, headers map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/report", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Agento-Secret", "secret")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestReportMissingHostname(t *testing.T) {
+	_, engine, tsdb := newTestServer()
+
+	cases := []string{
+		`{}`,
+		`{"entropy": 123}`,
+		`{"hostname": ""}`,
+	}
+
+	for _, body := range cases {
+		w := report(engine, []byte(body), nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Got status %d for '%s', expected %d", w.Code, body, http.StatusBadRequest)
+		}
+	}
+
+	if len(tsdb.points) > 0 {
+		t.Errorf("Points was written for invalid reports")
+	}
+}
+
+func TestReport(t *testing.T) {
+	_, engine, tsdb := newTestServer()
+
+	before := time.Now()
+	w := report(engine, []byte(`{"hostname": "testhost", "entropy": 123}`), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Got status %d, expected %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	if len(tsdb.points) != 1 {
+		t.Fatalf("Got %d points, expected 1", len(tsdb.points))
+	}
+
+	if tsdb.points[0].Tags["hostname"] != "testhost" {
+		t.Errorf("Point is tagged with hostname '%s'", tsdb.points[0].Tags["hostname"])
+	}
+
+	if id, found := tsdb.points[0].Tags[timeseries.AccountTag]; found {
+		t.Errorf("Point of the single user account was tagged with '%s'", id)
+	}
+
+	if tsdb.points[0].Time.Before(before) || tsdb.points[0].Time.After(time.Now()) {
+		t.Errorf("Point is not stamped with the receive time, got %s", tsdb.points[0].Time)
+	}
+}
+
+func TestReportAuthorization(t *testing.T) {
+	_, engine, _ := newTestServer()
+
+	body := []byte(`{"hostname": "testhost", "entropy": 123}`)
+
+	cases := []struct {
+		secret        string
+		authorization string
+		status        int
+	}{
+		// Custom header only.
+		{"secret", "", http.StatusOK},
+		{"wrong", "", http.StatusForbidden},
+
+		// Bearer token only.
+		{"", "Bearer secret", http.StatusOK},
+		{"", "bearer secret", http.StatusOK},
+		{"", "Bearer wrong", http.StatusForbidden},
+		{"", "Basic c2VjcmV0", http.StatusForbidden},
+
+		// Both headers.
+		{"secret", "Bearer secret", http.StatusOK},
+		{"secret", "Bearer wrong", http.StatusBadRequest},
+		{"wrong", "Bearer secret", http.StatusBadRequest},
+	}
+
+	for _, c := range cases {
+		w := report(engine, body, map[string]string{
+			"X-Agento-Secret": c.secret,
+			"Authorization":   c.authorization,
+		})
+
+		if w.Code != c.status {
+			t.Errorf("Got status %d for secret '%s' and authorization '%s', expected %d", w.Code, c.secret, c.authorization, c.status)
+		}
+	}
+}
+
+func TestReportGzip(t *testing.T) {
+	_, engine, plain := newTestServer()
+	_, gzipEngine, compressed := newTestServer()
+
+	body := []byte(`{"hostname": "testhost", "entropy": 123}`)
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(body)
+	w.Close()
+
+	report(engine, body, nil)
+	r := report(gzipEngine, buf.Bytes(), map[string]string{"Content-Encoding": "gzip"})
+	if r.Code != http.StatusOK {
+		t.Fatalf("Got status %d, expected %d: %s", r.Code, http.StatusOK, r.Body.String())
+	}
+
+	if len(compressed.points) != len(plain.points) {
+		t.Fatalf("Got %d points, expected %d", len(compressed.points), len(plain.points))
+	}
+
+	for i := range plain.points {
+		p := plain.points[i]
+		c := compressed.points[i]
+
+		if p.Name != c.Name || p.Tags["hostname"] != c.Tags["hostname"] || p.Fields["value"] != c.Fields["value"] {
+			t.Errorf("Point %d differs: %+v != %+v", i, c, p)
+		}
+	}
+}
+
+func TestReportGzipMalformed(t *testing.T) {
+	_, engine, tsdb := newTestServer()
+
+	cases := [][]byte{
+		[]byte(`{"hostname": "testhost", "entropy": 123}`),
+		[]byte{0x1f, 0x8b, 0x08, 0x00, 0x00},
+	}
+
+	for _, body := range cases {
+		w := report(engine, body, map[string]string{"Content-Encoding": "gzip"})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Got status %d for malformed gzip, expected %d", w.Code, http.StatusBadRequest)
+		}
+	}
+
+	if len(tsdb.points) > 0 {
+		t.Errorf("Points was written for malformed gzip")
+	}
+}
+
+func TestDocs(t *testing.T) {
+	_, engine, _ := newTestServer()
+
+	req, _ := http.NewRequest("GET", "/docs", nil)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Got status %d, expected %d", w.Code, http.StatusOK)
+	}
+
+	var catalog []plugins.CatalogEntry
+	err := json.Unmarshal(w.Body.Bytes(), &catalog)
+	if err != nil {
+		t.Fatalf("Failed to decode catalog: %s", err.Error())
+	}
+
+	for _, entry := range catalog {
+		if entry.Plugin == "entropy" && len(entry.Measurements) > 0 {
+			return
+		}
+	}
+
+	t.Fatalf("Catalog does not include entropy")
+}
+
+// scrape will return the value of the metric named name from /metrics.
+func scrape(t *testing.T, engine *gin.Engine, name string) float64 {
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Got status %d from /metrics", w.Code)
+	}
+
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == name {
+			value, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil {
+				t.Fatalf("Cannot parse %s: %s", name, err.Error())
+			}
+
+			return value
+		}
+	}
+
+	t.Fatalf("%s not found in /metrics", name)
+
+	return 0
+}
+
+func TestMetrics(t *testing.T) {
+	_, engine, _ := newTestServer()
+
+	before := scrape(t, engine, "agento_reports_received_total")
+
+	w := report(engine, []byte(`{"hostname": "testhost", "entropy": 123}`), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Got status %d, expected %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	after := scrape(t, engine, "agento_reports_received_total")
+	if after != before+1 {
+		t.Fatalf("Report counter is %f after report, expected %f", after, before+1)
+	}
+}
+
+func TestReportTooLarge(t *testing.T) {
+	s, engine, tsdb := newTestServer()
+	s.maxReportBytes = 1024
+
+	// A valid report padded well beyond the limit.
+	padding := strings.Repeat(" ", 10*1024)
+	body := []byte(`{"hostname": "testhost",` + padding + `"entropy": 123}`)
+
+	w := report(engine, body, nil)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Got status %d, expected %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	// The limit must apply to the decompressed size as well.
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(body)
+	gz.Close()
+
+	if buf.Len() >= 1024 {
+		t.Fatalf("Compressed body is %d bytes, the test needs it below the limit", buf.Len())
+	}
+
+	w = report(engine, buf.Bytes(), map[string]string{"Content-Encoding": "gzip"})
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Got status %d for compressed body, expected %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	if tsdb.count() > 0 {
+		t.Errorf("Points was written for oversized reports")
+	}
+
+	// Small reports must still be accepted.
+	w = report(engine, []byte(`{"hostname": "testhost", "entropy": 123}`), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Got status %d for small report, expected %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestReportRateLimit(t *testing.T) {
+	s, engine, _ := newTestServer()
+	s.limiter = newRateLimiter(configuration.RateLimitConfiguration{
+		AccountRateLimit: configuration.AccountRateLimit{Rate: 0.01, Burst: 3},
+	})
+
+	body := []byte(`{"hostname": "testhost", "entropy": 123}`)
+
+	for i := 0; i < 3; i++ {
+		w := report(engine, body, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Report %d got status %d, expected %d", i, w.Code, http.StatusOK)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		w := report(engine, body, nil)
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("Got status %d after burst was exhausted, expected %d", w.Code, http.StatusTooManyRequests)
+		}
+
+		retry, err := strconv.Atoi(w.Header().Get("Retry-After"))
+		if err != nil || retry < 1 {
+			t.Fatalf("Got invalid Retry-After '%s'", w.Header().Get("Retry-After"))
+		}
+	}
+}
+
+func TestReportRateLimitOverride(t *testing.T) {
+	s, engine, _ := newTestServer()
+	s.limiter = newRateLimiter(configuration.RateLimitConfiguration{
+		AccountRateLimit: configuration.AccountRateLimit{Rate: 0.01, Burst: 1},
+		Accounts: map[string]configuration.AccountRateLimit{
+			userdb.NewSingleUser("").GetId(): {Rate: 0.01, Burst: 5},
+		},
+	})
+
+	body := []byte(`{"hostname": "testhost", "entropy": 123}`)
+
+	for i := 0; i < 5; i++ {
+		w := report(engine, body, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Report %d got status %d, expected %d", i, w.Code, http.StatusOK)
+		}
+	}
+
+	w := report(engine, body, nil)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Got status %d after burst was exhausted, expected %d", w.Code, http.StatusTooManyRequests)
+	}
+}
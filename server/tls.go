@@ -0,0 +1,72 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	"github.com/abrander/agento/configuration"
+)
+
+var (
+	// defaultCipherSuites is used when no cipher suites are configured.
+	defaultCipherSuites = []uint16{
+		tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	}
+
+	tlsVersions = map[string]uint16{
+		"1.0": tls.VersionTLS10,
+		"1.1": tls.VersionTLS11,
+		"1.2": tls.VersionTLS12,
+		"1.3": tls.VersionTLS13,
+	}
+)
+
+// newTLSConfig will build a tls.Config from cfg. If no minimum version is
+// configured TLS 1.2 is used. Cipher suites are named like the constants in
+// crypto/tls, for example "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256". Please
+// note that cipher suites cannot be configured for TLS 1.3.
+func newTLSConfig(cfg configuration.HTTPSConfiguration) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		CipherSuites: defaultCipherSuites,
+	}
+
+	if cfg.MinVersion != "" {
+		version, found := tlsVersions[cfg.MinVersion]
+		if !found {
+			return nil, fmt.Errorf("unknown TLS version '%s'", cfg.MinVersion)
+		}
+
+		tlsConfig.MinVersion = version
+	}
+
+	if len(cfg.CipherSuites) > 0 {
+		suites := make(map[string]uint16)
+		for _, suite := range tls.CipherSuites() {
+			suites[suite.Name] = suite.ID
+		}
+
+		for _, suite := range tls.InsecureCipherSuites() {
+			suites[suite.Name] = suite.ID
+		}
+
+		tlsConfig.CipherSuites = make([]uint16, len(cfg.CipherSuites))
+		for i, name := range cfg.CipherSuites {
+			id, found := suites[name]
+			if !found {
+				return nil, fmt.Errorf("unknown TLS cipher suite '%s'", name)
+			}
+
+			tlsConfig.CipherSuites[i] = id
+		}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/abrander/agento/configuration"
+)
+
+func TestNewTLSConfigDefaults(t *testing.T) {
+	tlsConfig, err := newTLSConfig(configuration.HTTPSConfiguration{})
+	if err != nil {
+		t.Fatalf("newTLSConfig() failed: %s", err.Error())
+	}
+
+	if tlsConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("Wrong default minimum version %x", tlsConfig.MinVersion)
+	}
+
+	if len(tlsConfig.CipherSuites) != len(defaultCipherSuites) {
+		t.Errorf("Default cipher suites not used")
+	}
+}
+
+func TestNewTLSConfigRestricted(t *testing.T) {
+	cfg := configuration.HTTPSConfiguration{
+		MinVersion: "1.3",
+		CipherSuites: []string{
+			"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+			"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+		},
+	}
+
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		t.Fatalf("newTLSConfig() failed: %s", err.Error())
+	}
+
+	if tlsConfig.MinVersion != tls.VersionTLS13 {
+		t.Errorf("Wrong minimum version %x", tlsConfig.MinVersion)
+	}
+
+	expected := []uint16{
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	}
+
+	if len(tlsConfig.CipherSuites) != len(expected) {
+		t.Fatalf("Got %d cipher suites, expected %d", len(tlsConfig.CipherSuites), len(expected))
+	}
+
+	for i, id := range expected {
+		if tlsConfig.CipherSuites[i] != id {
+			t.Errorf("Cipher suite %d is %x, expected %x", i, tlsConfig.CipherSuites[i], id)
+		}
+	}
+}
+
+func TestNewTLSConfigUnknown(t *testing.T) {
+	cases := []configuration.HTTPSConfiguration{
+		{MinVersion: "1.4"},
+		{CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ROT13"}},
+	}
+
+	for _, cfg := range cases {
+		_, err := newTLSConfig(cfg)
+		if err == nil {
+			t.Errorf("newTLSConfig() accepted %+v", cfg)
+		}
+	}
+}
@@ -2,16 +2,20 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"sort"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/rcrowley/go-metrics"
 
 	"github.com/abrander/agento/logger"
+	"github.com/abrander/agento/plugins"
 	"github.com/abrander/agento/timeseries"
+	"github.com/abrander/agento/userdb"
 )
 
 type (
@@ -24,6 +28,13 @@ type (
 		Value       float64           `json:"v"`
 	}
 
+	// UDPReport is a full report received over UDP. Unlike HTTP, the secret
+	// is included in the payload.
+	UDPReport struct {
+		Secret  string          `json:"secret"`
+		Results plugins.Results `json:"results"`
+	}
+
 	inventory struct {
 		Histogram  metrics.Histogram
 		Identifier string
@@ -37,6 +48,15 @@ const (
 	// committing them to the histogram.
 	// Before writing the data, we divide by this exponent.
 	exponent = 1000000.0
+
+	// defaultMaxDatagramSize is the largest datagram accepted if nothing
+	// else is configured.
+	defaultMaxDatagramSize = 8192
+
+	// minReadBackoff and maxReadBackoff bound the delay between reads
+	// after a read error, the delay doubles for each consecutive error.
+	minReadBackoff = 10 * time.Millisecond
+	maxReadBackoff = time.Second
 )
 
 func (s *Sample) computeKey() string {
@@ -116,47 +136,155 @@ func (s *Server) reportToInfluxdb() {
 
 // ListenAndServeUDP starts the listener.
 func (s *Server) ListenAndServeUDP() {
-	samples := make(chan *Sample)
+	addr := s.udp.Bind + ":" + strconv.Itoa(int(s.udp.Port))
 
-	// UDP reader loop
-	go func() {
-		addr := s.udp.Bind + ":" + strconv.Itoa(int(s.udp.Port))
+	laddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		logger.Red("server", "ResolveUDPAddr(%s): %s", addr, err.Error())
+		return
+	}
 
-		laddr, err := net.ResolveUDPAddr("udp", addr)
-		if err != nil {
-			logger.Red("server", "ResolveUDPAddr(%s): %s", addr, err.Error())
-			return
-		}
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		logger.Red("server", "ListenUDP(%s): %s", addr, err.Error())
+		return
+	}
 
-		conn, err := net.ListenUDP("udp", laddr)
-		if err != nil {
-			logger.Red("server", "ListenUDP(%s): %s", addr, err.Error())
-			return
-		}
+	defer conn.Close()
 
-		defer conn.Close()
+	s.serveUDP(conn)
+}
 
-		buf := make([]byte, 65535)
+// serveUDP will read datagrams from conn until it's closed. A datagram can
+// either be a Sample or a UDPReport. Datagrams are read and parsed by
+// s.udp.Readers goroutines, samples are added to the inventory by a single
+// goroutine.
+func (s *Server) serveUDP(conn net.PacketConn) {
+	samples := make(chan *Sample)
+	done := make(chan struct{})
 
-		for {
-			var sample Sample
-			n, _, err := conn.ReadFromUDP(buf)
+	maxSize := s.udp.MaxDatagramSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxDatagramSize
+	}
 
-			if err == nil && json.Unmarshal(buf[:n], &sample) == nil {
-				samples <- &sample
-			}
-		}
+	readers := s.udp.Readers
+	if readers <= 0 {
+		readers = 1
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(readers)
+	for i := 0; i < readers; i++ {
+		go func() {
+			defer wg.Done()
+
+			s.readUDP(conn, maxSize, samples)
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(done)
 	}()
 
-	c := time.Tick(time.Second * time.Duration(s.udp.Interval))
+	interval := s.udp.Interval
+	if interval <= 0 {
+		interval = 60
+	}
+
+	ticker := time.NewTicker(time.Second * time.Duration(interval))
+	defer ticker.Stop()
 
 	// Main loop
 	for {
 		select {
+		case <-done:
+			return
 		case sample := <-samples:
 			s.addUDPSample(sample)
-		case <-c:
+		case <-ticker.C:
 			s.reportToInfluxdb()
 		}
 	}
 }
+
+// readUDP will read datagrams from conn until it's closed. Reports are
+// ingested directly, samples are sent to samples. Several readers can share
+// conn.
+func (s *Server) readUDP(conn net.PacketConn, maxSize int, samples chan<- *Sample) {
+	// We read one byte more than allowed to detect oversized datagrams.
+	buf := make([]byte, maxSize+1)
+
+	var backoff time.Duration
+
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		received := time.Now()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+
+		// A persistent error would spin the reader, back off until reads
+		// succeed again.
+		if err != nil {
+			if backoff == 0 {
+				backoff = minReadBackoff
+			} else if backoff < maxReadBackoff {
+				backoff *= 2
+				if backoff > maxReadBackoff {
+					backoff = maxReadBackoff
+				}
+			}
+
+			logger.Red("server", "Error reading UDP, retrying in %s: %s", backoff, err.Error())
+			time.Sleep(backoff)
+
+			continue
+		}
+
+		backoff = 0
+
+		if n > maxSize {
+			logger.Yellow("server", "Dropping oversized datagram from %s", addr.String())
+			continue
+		}
+
+		report := UDPReport{Results: plugins.Results{}}
+		if json.Unmarshal(buf[:n], &report) == nil && report.Secret != "" {
+			err = s.ingestUDP(&report, received)
+			if err != nil {
+				logger.Yellow("server", "Rejected report from %s: %s", addr.String(), err.Error())
+			}
+
+			continue
+		}
+
+		var sample Sample
+		if json.Unmarshal(buf[:n], &sample) == nil {
+			samples <- &sample
+		}
+	}
+}
+
+// ingestUDP will validate the secret of report and send the results to
+// InfluxDB like a report received using HTTP. Reports are subject to the same
+// per-account rate limit.
+func (s *Server) ingestUDP(report *UDPReport, received time.Time) error {
+	subject, err := s.db.ResolveKey(report.Secret)
+	if err != nil {
+		return err
+	}
+
+	account, ok := subject.(userdb.Account)
+	if !ok {
+		return ErrNotAccount
+	}
+
+	allowed, _ := s.limiter.allow(account.GetId())
+	if !allowed {
+		return ErrRateLimited
+	}
+
+	return s.ingest(account, report.Results, received)
+}
@@ -1,7 +1,17 @@
 package server
 
 import (
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/abrander/agento/configuration"
+	"github.com/abrander/agento/timeseries"
 )
 
 func TestComputeKey(t *testing.T) {
@@ -42,3 +52,230 @@ func TestComputeKey(t *testing.T) {
 		}
 	}
 }
+
+// startUDP will start serving UDP on a random port on localhost. The
+// returned function will stop the server.
+func startUDP(t *testing.T, s *Server) (net.Conn, func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() failed: %s", err.Error())
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.serveUDP(conn)
+		close(done)
+	}()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Dial() failed: %s", err.Error())
+	}
+
+	return client, func() {
+		client.Close()
+		conn.Close()
+		<-done
+	}
+}
+
+// waitForPoints will wait up to a second for n points to be written.
+func waitForPoints(tsdb *mockTSDB, n int) bool {
+	deadline := time.Now().Add(time.Second)
+
+	for time.Now().Before(deadline) {
+		if tsdb.count() >= n {
+			return true
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return false
+}
+
+func TestUDPReport(t *testing.T) {
+	s, _, tsdb := newTestServer()
+
+	client, stop := startUDP(t, s)
+	defer stop()
+
+	// Wrong secret first, this must not be written.
+	client.Write([]byte(`{"secret": "wrong", "results": {"hostname": "testhost", "entropy": 123}}`))
+	client.Write([]byte(`{"secret": "secret", "results": {"hostname": "testhost", "entropy": 123}}`))
+
+	if !waitForPoints(tsdb, 1) {
+		t.Fatalf("No points written")
+	}
+
+	tsdb.Lock()
+	defer tsdb.Unlock()
+
+	if len(tsdb.points) != 1 {
+		t.Fatalf("Got %d points, expected 1", len(tsdb.points))
+	}
+
+	if tsdb.points[0].Tags["hostname"] != "testhost" {
+		t.Fatalf("Wrong hostname tag: %v", tsdb.points[0].Tags)
+	}
+
+	if tsdb.points[0].Time.IsZero() {
+		t.Errorf("Point is not stamped with the receive time")
+	}
+}
+
+func TestUDPOversized(t *testing.T) {
+	s, _, tsdb := newTestServer()
+	s.udp.MaxDatagramSize = 100
+
+	client, stop := startUDP(t, s)
+	defer stop()
+
+	padding := strings.Repeat(" ", 100)
+	client.Write([]byte(`{"secret": "secret", "results": {"hostname": "big", "entropy": 123}}` + padding))
+	client.Write([]byte(`{"secret": "secret", "results": {"hostname": "small", "entropy": 123}}`))
+
+	if !waitForPoints(tsdb, 1) {
+		t.Fatalf("No points written")
+	}
+
+	// Give the server a chance to write points for the oversized datagram.
+	time.Sleep(50 * time.Millisecond)
+
+	tsdb.Lock()
+	defer tsdb.Unlock()
+
+	for _, point := range tsdb.points {
+		if point.Tags["hostname"] == "big" {
+			t.Fatalf("Oversized datagram was accepted")
+		}
+	}
+}
+
+func TestUDPReaders(t *testing.T) {
+	s, _, tsdb := newTestServer()
+	s.udp.Readers = 4
+
+	client, stop := startUDP(t, s)
+	defer stop()
+
+	for i := 0; i < 20; i++ {
+		client.Write([]byte(fmt.Sprintf(`{"secret": "secret", "results": {"hostname": "host%d", "entropy": 123}}`, i)))
+	}
+
+	if !waitForPoints(tsdb, 20) {
+		t.Fatalf("Got %d points, expected 20", tsdb.count())
+	}
+}
+
+func TestUDPRateLimit(t *testing.T) {
+	s, _, tsdb := newTestServer()
+	s.limiter = newRateLimiter(configuration.RateLimitConfiguration{
+		AccountRateLimit: configuration.AccountRateLimit{Rate: 0.01, Burst: 3},
+	})
+
+	client, stop := startUDP(t, s)
+	defer stop()
+
+	for i := 0; i < 10; i++ {
+		client.Write([]byte(fmt.Sprintf(`{"secret": "secret", "results": {"hostname": "host%d", "entropy": 123}}`, i)))
+	}
+
+	if !waitForPoints(tsdb, 3) {
+		t.Fatalf("Got %d points, expected 3", tsdb.count())
+	}
+
+	// Give the server a chance to write points for the limited reports.
+	time.Sleep(50 * time.Millisecond)
+
+	if tsdb.count() != 3 {
+		t.Fatalf("Got %d points, expected 3 after burst was exhausted", tsdb.count())
+	}
+}
+
+func TestUDPReadBackoff(t *testing.T) {
+	s, _, _ := newTestServer()
+
+	conn := &errConn{memConn{n: 3}}
+
+	start := time.Now()
+	s.readUDP(conn, defaultMaxDatagramSize, nil)
+
+	// The reader must back off 10ms, 20ms and 40ms before giving up.
+	expected := minReadBackoff * 7
+	if time.Since(start) < expected {
+		t.Errorf("Reader returned after %s, expected at least %s", time.Since(start), expected)
+	}
+}
+
+type (
+	// memConn is a PacketConn returning the same datagram n times before
+	// acting closed.
+	memConn struct {
+		datagram []byte
+		n        int64
+		read     int64
+	}
+
+	// errConn is a PacketConn failing n reads before acting closed.
+	errConn struct {
+		memConn
+	}
+
+	// discardTSDB will throw away all points.
+	discardTSDB struct{}
+)
+
+func (c *memConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	if atomic.AddInt64(&c.read, 1) > c.n {
+		return 0, nil, net.ErrClosed
+	}
+
+	return copy(b, c.datagram), &net.UDPAddr{}, nil
+}
+
+func (c *errConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	if atomic.AddInt64(&c.read, 1) > c.n {
+		return 0, nil, net.ErrClosed
+	}
+
+	return 0, nil, errors.New("read failed")
+}
+
+func (c *memConn) WriteTo(b []byte, addr net.Addr) (int, error) { return len(b), nil }
+func (c *memConn) Close() error                                 { return nil }
+func (c *memConn) LocalAddr() net.Addr                          { return &net.UDPAddr{} }
+func (c *memConn) SetDeadline(t time.Time) error                { return nil }
+func (c *memConn) SetReadDeadline(t time.Time) error            { return nil }
+func (c *memConn) SetWriteDeadline(t time.Time) error           { return nil }
+
+func (d discardTSDB) WritePoints(points []*timeseries.Point) error {
+	return nil
+}
+
+// benchmarkUDP will measure ingestion of reports using readers goroutines.
+func benchmarkUDP(b *testing.B, readers int) {
+	s, _, _ := newTestServer()
+	s.tsdb = discardTSDB{}
+	s.udp.Readers = readers
+
+	conn := &memConn{
+		datagram: []byte(`{"secret": "secret", "results": {"hostname": "testhost", "entropy": 123}}`),
+		n:        int64(b.N),
+	}
+
+	b.ResetTimer()
+	start := time.Now()
+
+	s.serveUDP(conn)
+
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "datagrams/s")
+}
+
+func BenchmarkUDPSingleReader(b *testing.B) {
+	benchmarkUDP(b, 1)
+}
+
+func BenchmarkUDPMultiReader(b *testing.B) {
+	benchmarkUDP(b, runtime.NumCPU())
+}
@@ -0,0 +1,213 @@
+package timeseries
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// accountRouter will write points to a database per account. The
+	// account is read from the AccountTag of each point. Points without
+	// the tag are written to the default database. Databases are created
+	// the first time they are written to.
+	accountRouter struct {
+		client    *http.Client
+		base      url.URL
+		query     url.Values
+		unit      time.Duration
+		auth      func(req *http.Request)
+		defaultDB string
+		template  string
+		databases map[string]string
+
+		lock    sync.Mutex
+		writers map[string]*httpWriter
+		created map[string]bool
+	}
+)
+
+const (
+	// AccountTag is the tag holding the account id of a point.
+	AccountTag = "id"
+
+	// DefaultAccount is the account of single user installations. Points
+	// of this account are not tagged and end up in the default database.
+	DefaultAccount = "000000000000000000000000"
+
+	// accountPlaceholder is replaced with the account id in database
+	// templates.
+	accountPlaceholder = "{account}"
+)
+
+// TagAccount will tag point with the account id. Points of the
+// DefaultAccount are left untagged.
+func TagAccount(point *Point, id string) {
+	if id == "" || id == DefaultAccount {
+		return
+	}
+
+	point.Tags[AccountTag] = id
+}
+
+// newAccountRouter will return a router writing to the InfluxDB 1.x server
+// at base. databases maps account ids to databases, accounts not mapped
+// will use template. All requests are made using client. Timestamps are
+// written in unit.
+func newAccountRouter(client *http.Client, base url.URL, query url.Values, unit time.Duration, auth func(req *http.Request), defaultDB string, template string, databases map[string]string) *accountRouter {
+	return &accountRouter{
+		client:    client,
+		base:      base,
+		query:     query,
+		unit:      unit,
+		auth:      auth,
+		defaultDB: defaultDB,
+		template:  template,
+		databases: databases,
+		writers:   make(map[string]*httpWriter),
+		created:   make(map[string]bool),
+	}
+}
+
+// database will return the database to use for account.
+func (r *accountRouter) database(account string) string {
+	if account == "" {
+		return r.defaultDB
+	}
+
+	db, found := r.databases[account]
+	if found {
+		return db
+	}
+
+	if r.template != "" {
+		return strings.Replace(r.template, accountPlaceholder, account, -1)
+	}
+
+	return r.defaultDB
+}
+
+// writer will return the writer for db, creating the database if not done
+// already.
+func (r *accountRouter) writer(db string) (*httpWriter, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.created[db] {
+		err := r.create(db)
+		if err != nil {
+			return nil, err
+		}
+
+		r.created[db] = true
+	}
+
+	w, found := r.writers[db]
+	if !found {
+		u := r.base
+		u.Path = path.Join(u.Path, "write")
+
+		query := url.Values{}
+		for key, values := range r.query {
+			query[key] = values
+		}
+		query.Set("db", db)
+		u.RawQuery = query.Encode()
+
+		w = newHTTPWriter(r.client, u.String(), r.unit, r.auth)
+		r.writers[db] = w
+	}
+
+	return w, nil
+}
+
+// create will issue CREATE DATABASE for db. This is a no-op for existing
+// databases.
+func (r *accountRouter) create(db string) error {
+	u := r.base
+	u.Path = path.Join(u.Path, "query")
+
+	form := url.Values{}
+	form.Set("q", `CREATE DATABASE "`+strings.Replace(db, `"`, `\"`, -1)+`"`)
+
+	req, err := http.NewRequest("POST", u.String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "agento-server")
+	if r.auth != nil {
+		r.auth(req)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{StatusCode: resp.StatusCode, Message: "CREATE DATABASE " + db + " failed"}
+	}
+
+	return nil
+}
+
+// Write implements conn. Points are grouped by database and written in a
+// request per database. All groups are tried, if some fail a PartialError
+// holding the points of the failed groups is returned, allowing only those
+// to be retried.
+func (r *accountRouter) Write(points []*Point) error {
+	groups := make(map[string][]*Point)
+	var order []string
+
+	for _, point := range points {
+		db := r.database(point.Tags[AccountTag])
+
+		if _, found := groups[db]; !found {
+			order = append(order, db)
+		}
+
+		groups[db] = append(groups[db], point)
+	}
+
+	var firstErr error
+	var notWritten []*Point
+	for _, db := range order {
+		w, err := r.writer(db)
+		if err == nil {
+			err = w.Write(groups[db])
+		}
+
+		if err != nil {
+			notWritten = append(notWritten, groups[db]...)
+
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if firstErr == nil {
+		return nil
+	}
+
+	if len(notWritten) == len(points) {
+		return firstErr
+	}
+
+	return &PartialError{Points: notWritten, Err: firstErr}
+}
+
+// Close implements conn. There's nothing to close.
+func (r *accountRouter) Close() error {
+	return nil
+}
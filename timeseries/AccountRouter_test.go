@@ -0,0 +1,214 @@
+package timeseries
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/configuration"
+)
+
+// influxServer will record databases created and lines written per database.
+type influxServer struct {
+	lock    sync.Mutex
+	created []string
+	lines   map[string]int
+}
+
+func (s *influxServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch r.URL.Path {
+	case "/query":
+		r.ParseForm()
+		s.created = append(s.created, r.PostForm.Get("q"))
+		w.Write([]byte(`{"results":[{"statement_id":0}]}`))
+	case "/write":
+		body, _ := ioutil.ReadAll(r.Body)
+		s.lines[r.URL.Query().Get("db")] += strings.Count(string(body), "\n")
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func accountPoint(account string) *Point {
+	tags := map[string]string{}
+	if account != "" {
+		tags[AccountTag] = account
+	}
+
+	return NewPoint("test", tags, map[string]interface{}{"value": 1})
+}
+
+func TestAccountRouting(t *testing.T) {
+	recorder := &influxServer{lines: make(map[string]int)}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	i, err := NewInfluxDb(&configuration.InfluxdbConfiguration{
+		URL:              server.URL,
+		Database:         "agento",
+		DatabaseTemplate: "agento_{account}",
+		Databases:        map[string]string{"c": "customer"},
+	})
+	if err != nil {
+		t.Fatalf("NewInfluxDb() failed: %s", err.Error())
+	}
+
+	for n := 0; n < 2; n++ {
+		err = i.WritePoints([]*Point{
+			accountPoint("a"),
+			accountPoint("b"),
+			accountPoint("a"),
+			accountPoint("c"),
+			accountPoint(""),
+		})
+		if err != nil {
+			t.Fatalf("WritePoints() failed: %s", err.Error())
+		}
+	}
+
+	expected := map[string]int{
+		"agento_a": 4,
+		"agento_b": 2,
+		"customer": 2,
+		"agento":   2,
+	}
+
+	for db, lines := range expected {
+		if recorder.lines[db] != lines {
+			t.Errorf("Got %d points in '%s', expected %d", recorder.lines[db], db, lines)
+		}
+	}
+
+	if len(recorder.created) != len(expected) {
+		t.Errorf("Databases not created exactly once: %v", recorder.created)
+	}
+
+	for _, q := range recorder.created {
+		if !strings.HasPrefix(q, `CREATE DATABASE "`) {
+			t.Errorf("Unexpected query '%s'", q)
+		}
+	}
+
+	// All databases must be written using the same client.
+	router := i.conn.(*accountRouter)
+	for db, w := range router.writers {
+		if w.client != router.client {
+			t.Errorf("Writer for '%s' has its own client", db)
+		}
+	}
+}
+
+func TestTagAccount(t *testing.T) {
+	r := newAccountRouter(nil, url.URL{}, nil, time.Nanosecond, nil, "agento", "agento_{account}", nil)
+
+	// The scheduler tags points with the account of the probe, the server
+	// with the account of the reporting key. The single user account must
+	// end up in the default database either way.
+	cases := map[string]string{
+		"":                         "agento",
+		DefaultAccount:             "agento",
+		"000000000000000000000001": "agento_000000000000000000000001",
+	}
+
+	for id, expected := range cases {
+		point := NewPoint("test", map[string]string{}, map[string]interface{}{"value": 1})
+		TagAccount(point, id)
+
+		if id == DefaultAccount && point.Tags[AccountTag] != "" {
+			t.Errorf("Point of the default account was tagged with '%s'", point.Tags[AccountTag])
+		}
+
+		db := r.database(point.Tags[AccountTag])
+		if db != expected {
+			t.Errorf("Point tagged with '%s' was routed to '%s', expected '%s'", id, db, expected)
+		}
+	}
+}
+
+func TestAccountRoutingCreateFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/query" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	i, _ := NewInfluxDb(&configuration.InfluxdbConfiguration{
+		URL:              server.URL,
+		DatabaseTemplate: "agento_{account}",
+	})
+
+	err := i.WritePoints([]*Point{accountPoint("a")})
+	statusErr, ok := err.(*StatusError)
+	if !ok || statusErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("WritePoints() returned %v, expected a 403 StatusError", err)
+	}
+}
+
+func TestAccountRoutingPartialFailure(t *testing.T) {
+	recorder := &influxServer{lines: make(map[string]int)}
+
+	// Writes to agento_b fail once.
+	var lock sync.Mutex
+	failures := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		fail := r.URL.Path == "/write" && r.URL.Query().Get("db") == "agento_b" && failures > 0
+		if fail {
+			failures--
+		}
+		lock.Unlock()
+
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		recorder.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	i, _ := NewInfluxDb(&configuration.InfluxdbConfiguration{
+		URL:              server.URL,
+		DatabaseTemplate: "agento_{account}",
+		Retries:          1,
+	})
+	i.retryDelay = 0
+
+	err := i.WritePoints([]*Point{accountPoint("a"), accountPoint("b")})
+	if err != nil {
+		t.Fatalf("WritePoints() failed: %s", err.Error())
+	}
+
+	// Only the failed group must be retried.
+	if recorder.lines["agento_a"] != 1 || recorder.lines["agento_b"] != 1 {
+		t.Errorf("Got %v lines, expected one in each database", recorder.lines)
+	}
+
+	written, dropped := i.Stats()
+	if written != 2 || dropped != 0 {
+		t.Errorf("Got %d written and %d dropped, expected 2 and 0", written, dropped)
+	}
+
+	// Without retries the points not written are returned.
+	i.retries = 0
+	failures = 1
+
+	err = i.WritePoints([]*Point{accountPoint("a"), accountPoint("b")})
+	partial, ok := err.(*PartialError)
+	if !ok || len(partial.Points) != 1 || partial.Points[0].Tags[AccountTag] != "b" {
+		t.Fatalf("WritePoints() returned %v, expected a PartialError with the point for b", err)
+	}
+}
@@ -0,0 +1,63 @@
+package timeseries
+
+import (
+	"errors"
+	"time"
+
+	"github.com/abrander/agento/configuration"
+)
+
+const (
+	// BackendInfluxDB stores points in InfluxDB. This is the default.
+	BackendInfluxDB = "influxdb"
+
+	// BackendStdout writes points as JSON to stdout.
+	BackendStdout = "stdout"
+
+	// BackendOpenTSDB stores points in OpenTSDB.
+	BackendOpenTSDB = "opentsdb"
+
+	// defaultSpoolInterval is used if no spool interval is configured.
+	defaultSpoolInterval = 10 * time.Second
+)
+
+var (
+	// ErrUnknownBackend will be returned by NewDatabase() for unknown
+	// backends.
+	ErrUnknownBackend = errors.New("unknown timeseries backend")
+)
+
+// NewDatabase will return the Database selected by cfg.Backend. For InfluxDB
+// the API version in cfg.Influxdb decides between NewInfluxDbV2() and
+// NewInfluxDb(). If a spool path is configured, the Database is wrapped in a
+// Spool.
+func NewDatabase(cfg *configuration.ServerConfiguration) (Database, error) {
+	var db Database
+	var err error
+
+	switch cfg.Backend {
+	case "", BackendInfluxDB:
+		db, err = newInfluxDatabase(&cfg.Influxdb)
+	case BackendStdout:
+		db = NewStdout(nil)
+	case BackendOpenTSDB:
+		db, err = NewOpenTSDB(&cfg.OpenTSDB)
+	default:
+		err = ErrUnknownBackend
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Spool.Path != "" {
+		interval := time.Duration(cfg.Spool.Interval) * time.Second
+		if interval <= 0 {
+			interval = defaultSpoolInterval
+		}
+
+		db = NewSpool(db, cfg.Spool.Path, cfg.Spool.MaxBytes, interval)
+	}
+
+	return db, nil
+}
@@ -0,0 +1,201 @@
+package timeseries
+
+import (
+	"math"
+	"sync"
+
+	"github.com/abrander/agento/logger"
+)
+
+type (
+	// FieldType is the type of a field as seen by InfluxDB.
+	FieldType int
+
+	// fieldGuard will keep field types consistent per measurement. InfluxDB
+	// rejects a whole batch if a field changes type. Fields use the type
+	// declared using DeclareFieldTypes(), undeclared fields keep the first
+	// type seen.
+	fieldGuard struct {
+		lock   sync.Mutex
+		types  map[string]FieldType
+		warned map[string]bool
+	}
+)
+
+const (
+	// FieldInteger is a signed 64 bit integer.
+	FieldInteger FieldType = iota
+
+	// FieldFloat is a 64 bit float.
+	FieldFloat
+
+	// FieldBool is a boolean.
+	FieldBool
+
+	// FieldString is a string.
+	FieldString
+)
+
+var (
+	// declaredLock protects declared.
+	declaredLock sync.RWMutex
+
+	// declared holds the declared type of fields by measurement and field
+	// name separated by a dot.
+	declared = make(map[string]FieldType)
+)
+
+func (t FieldType) String() string {
+	switch t {
+	case FieldInteger:
+		return "integer"
+	case FieldFloat:
+		return "float"
+	case FieldBool:
+		return "boolean"
+	}
+
+	return "string"
+}
+
+// DeclareFieldTypes will declare the type of fields. types is keyed by
+// measurement and field name separated by a dot, like "nginx.Accepts.value".
+// Points are converted to the declared type before being written to
+// InfluxDB.
+func DeclareFieldTypes(types map[string]FieldType) {
+	declaredLock.Lock()
+	defer declaredLock.Unlock()
+
+	for key, typ := range types {
+		declared[key] = typ
+	}
+}
+
+// declaredType will return the declared type of the field key.
+func declaredType(key string) (FieldType, bool) {
+	declaredLock.RLock()
+	defer declaredLock.RUnlock()
+
+	typ, found := declared[key]
+
+	return typ, found
+}
+
+func newFieldGuard() *fieldGuard {
+	return &fieldGuard{
+		types:  make(map[string]FieldType),
+		warned: make(map[string]bool),
+	}
+}
+
+// normalizeValue will convert all integers to int64 and all floats to
+// float64. Unsigned integers too large for int64 will become float64.
+func normalizeValue(value interface{}) (interface{}, FieldType) {
+	switch v := value.(type) {
+	case float64:
+		return v, FieldFloat
+	case float32:
+		return float64(v), FieldFloat
+	case int:
+		return int64(v), FieldInteger
+	case int8:
+		return int64(v), FieldInteger
+	case int16:
+		return int64(v), FieldInteger
+	case int32:
+		return int64(v), FieldInteger
+	case int64:
+		return v, FieldInteger
+	case uint:
+		return normalizeUint(uint64(v))
+	case uint8:
+		return int64(v), FieldInteger
+	case uint16:
+		return int64(v), FieldInteger
+	case uint32:
+		return int64(v), FieldInteger
+	case uint64:
+		return normalizeUint(v)
+	case bool:
+		return v, FieldBool
+	}
+
+	return value, FieldString
+}
+
+func normalizeUint(v uint64) (interface{}, FieldType) {
+	if v > math.MaxInt64 {
+		return float64(v), FieldFloat
+	}
+
+	return int64(v), FieldInteger
+}
+
+// convert will try to convert a normalized numeric value to typ. Integers
+// can become floats, floats are never rounded to integers.
+func convert(value interface{}, typ FieldType) (interface{}, bool) {
+	v, ok := value.(int64)
+	if ok && typ == FieldFloat {
+		return float64(v), true
+	}
+
+	return nil, false
+}
+
+// normalize will return points with field types consistent with the
+// declared types or earlier points. Integers are converted to floats where
+// needed, other mismatching fields are dropped. A warning is logged the first
+// time a field mismatches. The points given are not modified.
+func (g *fieldGuard) normalize(points []*Point) []*Point {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	result := make([]*Point, 0, len(points))
+
+	for _, p := range points {
+		fields := make(map[string]interface{}, len(p.Fields))
+
+		for name, value := range p.Fields {
+			value, typ := normalizeValue(value)
+			key := p.Name + "." + name
+
+			expected, found := declaredType(key)
+			if !found {
+				expected, found = g.types[key]
+			}
+
+			if !found {
+				g.types[key] = typ
+				expected = typ
+			}
+
+			if typ != expected {
+				if !g.warned[key] {
+					g.warned[key] = true
+					logger.Yellow("influxdb", "Field %s is %s, expected %s", key, typ, expected)
+				}
+
+				var ok bool
+				value, ok = convert(value, expected)
+				if !ok {
+					continue
+				}
+			}
+
+			fields[name] = value
+		}
+
+		if len(fields) == 0 {
+			continue
+		}
+
+		result = append(result, &Point{
+			Time:   p.Time,
+			Name:   p.Name,
+			Tags:   p.Tags,
+			Fields: fields,
+		})
+	}
+
+	return result
+}
@@ -0,0 +1,176 @@
+package timeseries
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type (
+	// httpWriter will POST points as line protocol to an InfluxDB write
+	// endpoint.
+	httpWriter struct {
+		client   *http.Client
+		writeURL string
+
+		// unit is the unit of timestamps given by the precision in
+		// writeURL.
+		unit time.Duration
+
+		// auth will add authentication to requests.
+		auth func(req *http.Request)
+	}
+
+	// StatusError will be returned if the database responds with anything
+	// but success.
+	StatusError struct {
+		StatusCode int
+		Message    string
+	}
+
+	// PartialError will be returned if only some of the points were
+	// written. Points holds the points not written, Err the first error.
+	PartialError struct {
+		Points []*Point
+		Err    error
+	}
+)
+
+const (
+	// defaultHTTPTimeout is the timeout for requests to the database.
+	defaultHTTPTimeout = 30 * time.Second
+
+	// maxIdleConnsPerHost is the number of connections kept open to the
+	// database. Go defaults to 2, closing connections as soon as more
+	// writes run at once.
+	maxIdleConnsPerHost = 32
+)
+
+// newHTTPClient will return a client for writing to the database.
+// Connections are kept alive and reused between writes. All writers for a
+// database should share a client.
+func newHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	return &http.Client{
+		Timeout: defaultHTTPTimeout,
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			MaxIdleConns:          maxIdleConnsPerHost * 4,
+			MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
+}
+
+// newHTTPWriter will return a writer posting to writeURL using client.
+// Timestamps are written in unit.
+func newHTTPWriter(client *http.Client, writeURL string, unit time.Duration, auth func(req *http.Request)) *httpWriter {
+	return &httpWriter{
+		client:   client,
+		writeURL: writeURL,
+		unit:     unit,
+		auth:     auth,
+	}
+}
+
+// Error implements error.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("database returned %d: %s", e.StatusCode, e.Message)
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("%d points not written: %s", len(e.Points), e.Err.Error())
+}
+
+// failed will return the points not written when writing points returned
+// err.
+func failed(points []*Point, err error) []*Point {
+	partial, ok := err.(*PartialError)
+	if ok {
+		return partial.Points
+	}
+
+	return points
+}
+
+// Write will write points in a single request. Points without fields are
+// invalid and left out.
+func (w *httpWriter) Write(points []*Point) error {
+	var body bytes.Buffer
+
+	for _, point := range points {
+		if len(point.Fields) == 0 {
+			continue
+		}
+
+		body.WriteString(point.LineProtocolPrecision(w.unit))
+		body.WriteByte('\n')
+	}
+
+	if body.Len() == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequest("POST", w.writeURL, &body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("User-Agent", "agento-server")
+	if w.auth != nil {
+		w.auth(req)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+
+		return &StatusError{
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(msg)),
+		}
+	}
+
+	// The body must be read to the end for the connection to be reused.
+	io.Copy(ioutil.Discard, resp.Body)
+
+	return nil
+}
+
+// Close implements conn. There's nothing to close.
+func (w *httpWriter) Close() error {
+	return nil
+}
+
+// transient will return true if err is worth retrying. Network errors and
+// server errors (5xx) are transient, client errors (4xx) are permanent.
+func transient(err error) bool {
+	switch e := err.(type) {
+	case *PartialError:
+		return transient(e.Err)
+	case *StatusError:
+		return e.StatusCode >= 500
+	case net.Error:
+		return true
+	}
+
+	return false
+}
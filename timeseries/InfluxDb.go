@@ -1,71 +1,303 @@
 package timeseries
 
 import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/influxdata/influxdb1-client/v2"
-
 	"github.com/abrander/agento/configuration"
 	"github.com/abrander/agento/logger"
 )
 
 type (
 	InfluxDb struct {
-		conn    client.Client
-		retries int
-		bpsConf client.BatchPointsConfig
+		// written and dropped count points. They're updated atomically
+		// and must stay first in the struct to be 64-bit aligned.
+		written uint64
+		dropped uint64
+
+		conn       conn
+		retries    int
+		retryDelay time.Duration
+		guard      *fieldGuard
+
+		// Points are buffered if batchSize or flushInterval is set.
+		batchSize     int
+		flushInterval time.Duration
+		bufferLock    sync.Mutex
+		buffer        []*Point
+		stop          chan struct{}
+		stopped       sync.WaitGroup
+
+		// onFlush is called after background writes of the buffer.
+		onFlushLock sync.Mutex
+		onFlush     []func(notWritten []*Point, err error)
+	}
+
+	// conn is a connection able to write points to InfluxDB.
+	conn interface {
+		Write(points []*Point) error
+		Close() error
 	}
 )
 
+const (
+	// defaultRetryDelay is the delay before the first retry. It's doubled
+	// for each retry up to maxRetryDelay.
+	defaultRetryDelay = 500 * time.Millisecond
+
+	// maxRetryDelay is the maximum delay between retries.
+	maxRetryDelay = 10 * time.Second
+)
+
+// newInfluxDatabase will return an InfluxDB Database for the API version
+// configured.
+func newInfluxDatabase(cfg *configuration.InfluxdbConfiguration) (Database, error) {
+	if cfg.Version == 2 {
+		db, err := NewInfluxDbV2(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		return db, nil
+	}
+
+	db, err := NewInfluxDb(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// NewInfluxDb will return a Database writing to InfluxDB 1.x using the
+// /write endpoint. If a database template or per account databases are
+// configured, points are routed to a database per account.
 func NewInfluxDb(cfg *configuration.InfluxdbConfiguration) (*InfluxDb, error) {
-	conf := client.HTTPConfig{
-		Addr:      cfg.URL,
-		Username:  cfg.Username,
-		Password:  cfg.Password,
-		UserAgent: "agento-server",
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported protocol scheme '%s'", u.Scheme)
 	}
 
-	conn, err := client.NewHTTPClient(conf)
+	precision, unit, err := parsePrecision(cfg.Precision)
 	if err != nil {
 		return nil, err
 	}
 
-	return &InfluxDb{
-		conn:    conn,
-		retries: cfg.Retries,
-		bpsConf: client.BatchPointsConfig{
-			Database:         cfg.Database,
-			RetentionPolicy:  cfg.RetentionPolicy,
-			WriteConsistency: "one",
-		},
-	}, nil
+	query := url.Values{}
+	query.Set("db", cfg.Database)
+	query.Set("rp", cfg.RetentionPolicy)
+	query.Set("precision", precision)
+	query.Set("consistency", "one")
+
+	username := cfg.Username
+	password := cfg.Password
+	auth := func(req *http.Request) {
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+	}
+
+	if cfg.DatabaseTemplate != "" || len(cfg.Databases) > 0 {
+		router := newAccountRouter(newHTTPClient(), *u, query, unit, auth, cfg.Database, cfg.DatabaseTemplate, cfg.Databases)
+
+		return newInfluxDb(router, cfg), nil
+	}
+
+	u.Path = path.Join(u.Path, "write")
+	u.RawQuery = query.Encode()
+
+	return newInfluxDb(newHTTPWriter(newHTTPClient(), u.String(), unit, auth), cfg), nil
+}
+
+func newInfluxDb(conn conn, cfg *configuration.InfluxdbConfiguration) *InfluxDb {
+	i := &InfluxDb{
+		conn:          conn,
+		retries:       cfg.Retries,
+		retryDelay:    defaultRetryDelay,
+		guard:         newFieldGuard(),
+		batchSize:     cfg.BatchSize,
+		flushInterval: time.Duration(cfg.FlushInterval) * time.Second,
+		stop:          make(chan struct{}),
+	}
+
+	if i.flushInterval > 0 {
+		i.stopped.Add(1)
+		go i.flushLoop()
+	}
+
+	return i
+}
+
+// flushLoop will flush the buffer every flushInterval until Close() is called.
+func (i *InfluxDb) flushLoop() {
+	defer i.stopped.Done()
+
+	ticker := time.NewTicker(i.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-i.stop:
+			return
+		case <-ticker.C:
+			i.flushed(i.flush())
+		}
+	}
+}
+
+// OnFlush implements Flusher. The callers of WritePoints never see errors
+// writing buffered points in the background, fn is called instead. Errors
+// from a flush caused by a full buffer are returned by WritePoints.
+func (i *InfluxDb) OnFlush(fn func(notWritten []*Point, err error)) {
+	i.onFlushLock.Lock()
+	i.onFlush = append(i.onFlush, fn)
+	i.onFlushLock.Unlock()
 }
 
-// WritePoints Implements Database.
+// flushed will log the result of a background flush of points and pass it
+// on to OnFlush() callbacks. Nothing is done if no points were buffered.
+func (i *InfluxDb) flushed(points []*Point, err error) {
+	if len(points) == 0 {
+		return
+	}
+
+	var notWritten []*Point
+	if err != nil {
+		notWritten = failed(points, err)
+
+		logger.Red("influxdb", "Error flushing %d points: %s", len(notWritten), err.Error())
+	}
+
+	i.onFlushLock.Lock()
+	callbacks := i.onFlush
+	i.onFlushLock.Unlock()
+
+	for _, fn := range callbacks {
+		fn(notWritten, err)
+	}
+}
+
+// WritePoints Implements Database. If buffering is enabled, points will be
+// written when the batch size is reached or at the next flush interval.
+// Field types are normalized to avoid InfluxDB rejecting the batch.
 func (i *InfluxDb) WritePoints(points []*Point) error {
-	bps, err := client.NewBatchPoints(i.bpsConf)
+	points = i.guard.normalize(points)
+
+	if i.batchSize <= 0 && i.flushInterval <= 0 {
+		return i.write(points)
+	}
+
+	i.bufferLock.Lock()
+	i.buffer = append(i.buffer, points...)
+	full := i.batchSize > 0 && len(i.buffer) >= i.batchSize
+	i.bufferLock.Unlock()
+
+	if full {
+		return i.Flush()
+	}
+
+	return nil
+}
+
+// Flush will write all buffered points to InfluxDB.
+func (i *InfluxDb) Flush() error {
+	_, err := i.flush()
+
+	return err
+}
+
+// flush will write all buffered points to InfluxDB and return the points
+// flushed.
+func (i *InfluxDb) flush() ([]*Point, error) {
+	i.bufferLock.Lock()
+	points := i.buffer
+	i.buffer = nil
+	i.bufferLock.Unlock()
+
+	if len(points) == 0 {
+		return nil, nil
+	}
+
+	return points, i.write(points)
+}
+
+// Close will flush buffered points and close the connection to InfluxDB.
+// Points failing the final flush are passed to OnFlush() callbacks as well.
+func (i *InfluxDb) Close() error {
+	close(i.stop)
+	i.stopped.Wait()
+
+	points, err := i.flush()
+	i.flushed(points, err)
 	if err != nil {
+		i.conn.Close()
+
 		return err
 	}
 
-	for _, point := range points {
-		bps.AddPoint(point.InfluxDBPoint())
-	}
+	return i.conn.Close()
+}
+
+// write will write points, retrying transient errors up to retries times
+// with exponential backoff. Permanent errors are returned at once. If only
+// some points fail, only those are retried.
+func (i *InfluxDb) write(points []*Point) error {
+	delay := i.retryDelay
+	total := len(points)
+
+	err := i.conn.Write(points)
+	for retry := 1; err != nil && transient(err) && retry <= i.retries; retry++ {
+		logger.Yellow("influxdb", "Error writing to influxdb: %s, retry %d/%d in %s", err.Error(), retry, i.retries, delay)
+		time.Sleep(delay)
 
-	retries := i.retries
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+
+		retrying := failed(points, err)
+		atomic.AddUint64(&i.written, uint64(len(points)-len(retrying)))
+		points = retrying
+
+		err = i.conn.Write(points)
+	}
 
-	err = i.conn.Write(bps)
+	var notWritten []*Point
 	if err != nil {
-		var retry int
-		for retry = 1; retry <= retries; retry++ {
-			logger.Yellow("influxdb", "Error writing to influxdb: "+err.Error()+", retry %d/%d", retry, 5)
-			time.Sleep(time.Millisecond * 500)
-			err = i.conn.Write(bps)
-			if err == nil {
-				break
-			}
+		notWritten = failed(points, err)
+	}
+
+	atomic.AddUint64(&i.written, uint64(len(points)-len(notWritten)))
+	atomic.AddUint64(&i.dropped, uint64(len(notWritten)))
+
+	// Some points may have been written by earlier tries.
+	if err != nil && len(notWritten) < total {
+		if partial, ok := err.(*PartialError); ok {
+			err = partial.Err
 		}
+
+		return &PartialError{Points: notWritten, Err: err}
 	}
 
 	return err
 }
+
+// Stats will return the number of points written to InfluxDB and the number
+// of points dropped because the write failed after all retries. Buffered
+// points are not counted until flushed.
+func (i *InfluxDb) Stats() (written, dropped uint64) {
+	return atomic.LoadUint64(&i.written), atomic.LoadUint64(&i.dropped)
+}
+
+// Ensure compliance.
+var _ Statser = (*InfluxDb)(nil)
+var _ Flusher = (*InfluxDb)(nil)
@@ -0,0 +1,49 @@
+package timeseries
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/abrander/agento/configuration"
+)
+
+var (
+	// ErrMissingBucket will be returned by NewInfluxDbV2() if org or bucket
+	// is not configured.
+	ErrMissingBucket = errors.New("org and bucket must be set for InfluxDB 2.x")
+)
+
+// NewInfluxDbV2 will return a Database writing to InfluxDB 2.x using the
+// /api/v2/write endpoint and token authentication.
+func NewInfluxDbV2(cfg *configuration.InfluxdbConfiguration) (*InfluxDb, error) {
+	if cfg.Org == "" || cfg.Bucket == "" {
+		return nil, ErrMissingBucket
+	}
+
+	u, err := url.Parse(strings.TrimRight(cfg.URL, "/") + "/api/v2/write")
+	if err != nil {
+		return nil, err
+	}
+
+	precision, unit, err := parsePrecision(cfg.Precision)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("org", cfg.Org)
+	query.Set("bucket", cfg.Bucket)
+	query.Set("precision", precision)
+	u.RawQuery = query.Encode()
+
+	token := cfg.Token
+	auth := func(req *http.Request) {
+		if token != "" {
+			req.Header.Set("Authorization", "Token "+token)
+		}
+	}
+
+	return newInfluxDb(newHTTPWriter(newHTTPClient(), u.String(), unit, auth), cfg), nil
+}
@@ -0,0 +1,175 @@
+package timeseries
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+
+	"github.com/abrander/agento/configuration"
+)
+
+func TestLineProtocolRoundTrip(t *testing.T) {
+	// InfluxDB 2.x always supports unsigned integers.
+	models.EnableUintSupport()
+
+	ts := time.Date(2024, 3, 1, 12, 0, 0, 123, time.UTC)
+
+	points := []*Point{
+		NewPoint("cpu.User", map[string]string{"cpu": "0"}, map[string]interface{}{"value": 1.5}, ts),
+		NewPoint("disk usage", map[string]string{
+			"mount point": "/mnt/my disk",
+			"label":       "a,b=c",
+			"empty":       "",
+		}, map[string]interface{}{
+			"used bytes": int64(1024),
+			"free,pct":   uint64(42),
+			"ok=":        true,
+			"note":       `say "hi" \o/`,
+		}, ts),
+		NewPoint("net,stat", nil, map[string]interface{}{"value": 3}),
+	}
+
+	for _, point := range points {
+		line := point.LineProtocol()
+
+		parsed, err := models.ParsePointsString(line)
+		if err != nil {
+			t.Fatalf("Failed to parse '%s': %s", line, err.Error())
+		}
+
+		if len(parsed) != 1 {
+			t.Fatalf("Got %d points from '%s', expected 1", len(parsed), line)
+		}
+
+		p := parsed[0]
+		if string(p.Name()) != point.Name {
+			t.Errorf("Got name '%s', expected '%s'", p.Name(), point.Name)
+		}
+
+		for key, value := range point.Tags {
+			if value == "" {
+				continue
+			}
+
+			if p.Tags().GetString(key) != value {
+				t.Errorf("Got tag %s='%s', expected '%s' from '%s'", key, p.Tags().GetString(key), value, line)
+			}
+		}
+
+		fields, err := p.Fields()
+		if err != nil {
+			t.Fatalf("Failed to parse fields of '%s': %s", line, err.Error())
+		}
+
+		if len(fields) != len(point.Fields) {
+			t.Errorf("Got %d fields, expected %d from '%s'", len(fields), len(point.Fields), line)
+		}
+
+		for key, value := range point.Fields {
+			got := fields[key]
+
+			// Plain ints are written as int64.
+			if i, ok := value.(int); ok {
+				value = int64(i)
+			}
+
+			if got != value {
+				t.Errorf("Got field %s=%v (%T), expected %v (%T) from '%s'", key, got, got, value, value, line)
+			}
+		}
+
+		if !point.Time.IsZero() && !p.Time().Equal(point.Time) {
+			t.Errorf("Got time %s, expected %s", p.Time(), point.Time)
+		}
+	}
+}
+
+func TestLineProtocol(t *testing.T) {
+	ts := time.Unix(0, 1500000000000000000)
+
+	point := NewPoint("cpu", map[string]string{"host": "a b", "cpu": "0"}, map[string]interface{}{"value": 2, "idle": 0.5}, ts)
+
+	expected := `cpu,cpu=0,host=a\ b idle=0.5,value=2i 1500000000000000000`
+	if point.LineProtocol() != expected {
+		t.Fatalf("Got '%s', expected '%s'", point.LineProtocol(), expected)
+	}
+}
+
+func TestInfluxDbV2(t *testing.T) {
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first request to test retries.
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		if r.URL.Path != "/api/v2/write" {
+			t.Errorf("Wrong path '%s'", r.URL.Path)
+		}
+
+		q := r.URL.Query()
+		if q.Get("org") != "acme" || q.Get("bucket") != "agento" || q.Get("precision") != "ns" {
+			t.Errorf("Wrong query '%s'", r.URL.RawQuery)
+		}
+
+		if r.Header.Get("Authorization") != "Token s3cret" {
+			t.Errorf("Wrong authorization '%s'", r.Header.Get("Authorization"))
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		expected := "test value=1i\ntest value=2i\n"
+		if string(body) != expected {
+			t.Errorf("Got body '%s', expected '%s'", body, expected)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	db, err := NewDatabase(&configuration.ServerConfiguration{
+		Influxdb: configuration.InfluxdbConfiguration{
+			Version: 2,
+			URL:     server.URL + "/",
+			Org:     "acme",
+			Bucket:  "agento",
+			Token:   "s3cret",
+			Retries: 1,
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewDatabase() failed: %s", err.Error())
+	}
+
+	influx, ok := db.(*InfluxDb)
+	if !ok {
+		t.Fatalf("NewDatabase() returned %T for version 2", db)
+	}
+	influx.retryDelay = time.Millisecond
+
+	err = db.WritePoints([]*Point{
+		NewPoint("test", nil, map[string]interface{}{"value": 1}),
+		NewPoint("empty", nil, nil),
+		NewPoint("test", nil, map[string]interface{}{"value": 2}),
+	})
+	if err != nil {
+		t.Fatalf("WritePoints() failed: %s", err.Error())
+	}
+
+	if atomic.LoadInt32(&requests) != 2 {
+		t.Fatalf("Got %d requests, expected 2", requests)
+	}
+}
+
+func TestInfluxDbV2MissingBucket(t *testing.T) {
+	_, err := NewInfluxDbV2(&configuration.InfluxdbConfiguration{Version: 2, URL: "http://localhost:8086"})
+	if err != ErrMissingBucket {
+		t.Fatalf("NewInfluxDbV2() returned %v, expected ErrMissingBucket", err)
+	}
+}
@@ -0,0 +1,551 @@
+package timeseries
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/configuration"
+)
+
+type (
+	// mockConn will count writes and points written. If err is set, all
+	// writes fail with err.
+	mockConn struct {
+		lock   sync.Mutex
+		writes int
+		points int
+		closed bool
+		last   []*Point
+		err    error
+	}
+)
+
+func (c *mockConn) Write(points []*Point) error {
+	c.lock.Lock()
+	if c.err != nil {
+		c.lock.Unlock()
+
+		return c.err
+	}
+
+	c.writes++
+	c.points += len(points)
+	c.last = points
+	c.lock.Unlock()
+
+	return nil
+}
+
+func (c *mockConn) Close() error {
+	c.closed = true
+
+	return nil
+}
+
+func (c *mockConn) counts() (int, int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.writes, c.points
+}
+
+func points(n int) []*Point {
+	points := make([]*Point, n)
+	for i := range points {
+		points[i] = NewPoint("test", nil, map[string]interface{}{"value": i})
+	}
+
+	return points
+}
+
+func TestWritePointsUnbuffered(t *testing.T) {
+	conn := &mockConn{}
+	i := newInfluxDb(conn, &configuration.InfluxdbConfiguration{})
+
+	i.WritePoints(points(2))
+	i.WritePoints(points(3))
+
+	writes, n := conn.counts()
+	if writes != 2 || n != 5 {
+		t.Fatalf("Got %d writes of %d points, expected 2 writes of 5", writes, n)
+	}
+}
+
+func TestWritePointsBatchSize(t *testing.T) {
+	conn := &mockConn{}
+	i := newInfluxDb(conn, &configuration.InfluxdbConfiguration{BatchSize: 10})
+
+	i.WritePoints(points(4))
+	i.WritePoints(points(4))
+
+	writes, _ := conn.counts()
+	if writes != 0 {
+		t.Fatalf("Got %d writes before batch size was reached", writes)
+	}
+
+	i.WritePoints(points(4))
+
+	writes, n := conn.counts()
+	if writes != 1 || n != 12 {
+		t.Fatalf("Got %d writes of %d points, expected 1 write of 12", writes, n)
+	}
+
+	// Close must flush what's left.
+	i.WritePoints(points(3))
+	i.Close()
+
+	writes, n = conn.counts()
+	if writes != 2 || n != 15 {
+		t.Fatalf("Got %d writes of %d points after Close(), expected 2 writes of 15", writes, n)
+	}
+
+	if !conn.closed {
+		t.Fatalf("Close() did not close the connection")
+	}
+}
+
+func TestWritePointsFlushInterval(t *testing.T) {
+	conn := &mockConn{}
+	i := newInfluxDb(conn, &configuration.InfluxdbConfiguration{FlushInterval: 1})
+	defer i.Close()
+
+	i.WritePoints(points(3))
+
+	writes, _ := conn.counts()
+	if writes != 0 {
+		t.Fatalf("Points written before flush interval")
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		writes, n := conn.counts()
+		if writes == 1 && n == 3 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Got %d writes of %d points, expected 1 write of 3", writes, n)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestOnFlush(t *testing.T) {
+	conn := &mockConn{err: &StatusError{StatusCode: http.StatusServiceUnavailable}}
+	i := newInfluxDb(conn, &configuration.InfluxdbConfiguration{FlushInterval: 1})
+
+	results := make(chan error, 10)
+	notWritten := make(chan int, 10)
+	i.OnFlush(func(points []*Point, err error) {
+		notWritten <- len(points)
+		results <- err
+	})
+
+	i.WritePoints(points(3))
+
+	select {
+	case err := <-results:
+		if err == nil {
+			t.Fatalf("OnFlush() callback got no error for a failed flush")
+		}
+
+		if n := <-notWritten; n != 3 {
+			t.Fatalf("OnFlush() callback got %d points, expected 3", n)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("OnFlush() callback not called for a failed flush")
+	}
+
+	// The final flush when closing must be reported too.
+	conn.lock.Lock()
+	conn.err = nil
+	conn.lock.Unlock()
+
+	i.WritePoints(points(2))
+	i.Close()
+
+	select {
+	case err := <-results:
+		if err != nil {
+			t.Fatalf("OnFlush() callback got error %s for a successful flush", err.Error())
+		}
+	default:
+		t.Fatalf("OnFlush() callback not called when closing")
+	}
+}
+
+func TestFlushEmpty(t *testing.T) {
+	conn := &mockConn{}
+	i := newInfluxDb(conn, &configuration.InfluxdbConfiguration{BatchSize: 10})
+
+	i.Flush()
+
+	writes, _ := conn.counts()
+	if writes != 0 {
+		t.Fatalf("Flush() wrote an empty batch")
+	}
+}
+
+// flakyServer will fail the first failures requests with status and count
+// the lines written by successful requests.
+func flakyServer(failures int32, status int, requests *int32, lines *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(requests, 1) <= failures {
+			w.WriteHeader(status)
+			w.Write([]byte(`{"error":"failing"}`))
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		atomic.AddInt32(lines, int32(strings.Count(string(body), "\n")))
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func TestWriteRetry(t *testing.T) {
+	var requests, lines int32
+
+	server := flakyServer(2, http.StatusServiceUnavailable, &requests, &lines)
+	defer server.Close()
+
+	i, err := NewInfluxDb(&configuration.InfluxdbConfiguration{
+		URL:      server.URL,
+		Database: "agento",
+		Retries:  3,
+	})
+	if err != nil {
+		t.Fatalf("NewInfluxDb() failed: %s", err.Error())
+	}
+	i.retryDelay = time.Millisecond
+
+	err = i.WritePoints(points(5))
+	if err != nil {
+		t.Fatalf("WritePoints() failed: %s", err.Error())
+	}
+
+	if requests != 3 || lines != 5 {
+		t.Fatalf("Got %d requests writing %d points, expected 3 requests writing 5", requests, lines)
+	}
+}
+
+func TestWriteRetryGiveUp(t *testing.T) {
+	var requests, lines int32
+
+	server := flakyServer(10, http.StatusInternalServerError, &requests, &lines)
+	defer server.Close()
+
+	i, _ := NewInfluxDb(&configuration.InfluxdbConfiguration{URL: server.URL, Retries: 2})
+	i.retryDelay = time.Millisecond
+
+	err := i.WritePoints(points(1))
+	if err == nil {
+		t.Fatalf("WritePoints() did not fail")
+	}
+
+	if requests != 3 {
+		t.Fatalf("Got %d requests, expected 3", requests)
+	}
+}
+
+func TestWritePermanentError(t *testing.T) {
+	var requests, lines int32
+
+	server := flakyServer(1, http.StatusBadRequest, &requests, &lines)
+	defer server.Close()
+
+	i, _ := NewInfluxDb(&configuration.InfluxdbConfiguration{URL: server.URL, Retries: 3})
+	i.retryDelay = time.Millisecond
+
+	err := i.WritePoints(points(1))
+	statusErr, ok := err.(*StatusError)
+	if !ok || statusErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("WritePoints() returned %v, expected a 400 StatusError", err)
+	}
+
+	if requests != 1 {
+		t.Fatalf("A permanent error was retried, got %d requests", requests)
+	}
+}
+
+func TestWriteURL(t *testing.T) {
+	var query string
+	var user, pass string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/influx/write" {
+			t.Errorf("Wrong path '%s'", r.URL.Path)
+		}
+
+		query = r.URL.RawQuery
+		user, pass, _ = r.BasicAuth()
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	i, err := NewInfluxDb(&configuration.InfluxdbConfiguration{
+		URL:             server.URL + "/influx/",
+		Username:        "root",
+		Password:        "secret",
+		Database:        "agento",
+		RetentionPolicy: "default",
+	})
+	if err != nil {
+		t.Fatalf("NewInfluxDb() failed: %s", err.Error())
+	}
+
+	err = i.WritePoints(points(1))
+	if err != nil {
+		t.Fatalf("WritePoints() failed: %s", err.Error())
+	}
+
+	if query != "consistency=one&db=agento&precision=ns&rp=default" {
+		t.Errorf("Wrong query '%s'", query)
+	}
+
+	if user != "root" || pass != "secret" {
+		t.Errorf("Wrong credentials %s:%s", user, pass)
+	}
+
+	_, err = NewInfluxDb(&configuration.InfluxdbConfiguration{URL: "localhost:8086"})
+	if err == nil {
+		t.Errorf("NewInfluxDb() accepted an URL without scheme")
+	}
+}
+
+func TestWritePointsFieldTypes(t *testing.T) {
+	conn := &mockConn{}
+	i := newInfluxDb(conn, &configuration.InfluxdbConfiguration{})
+
+	i.WritePoints([]*Point{
+		NewPoint("misc", nil, map[string]interface{}{"count": int(1), "rate": 1.5, "name": "a"}),
+	})
+
+	i.WritePoints([]*Point{
+		NewPoint("misc", nil, map[string]interface{}{"count": int32(2), "rate": uint64(2)}),
+		NewPoint("misc", nil, map[string]interface{}{"count": 3.0, "rate": float32(3)}),
+		NewPoint("misc", nil, map[string]interface{}{"count": uint8(4), "name": 5}),
+		NewPoint("misc", nil, map[string]interface{}{"name": true}),
+	})
+
+	if len(conn.last) != 3 {
+		t.Fatalf("Got %d points, expected 3", len(conn.last))
+	}
+
+	for _, p := range conn.last {
+		count, found := p.Fields["count"]
+		if _, ok := count.(int64); found && !ok {
+			t.Errorf("count is %T, expected int64", count)
+		}
+
+		rate, found := p.Fields["rate"]
+		if _, ok := rate.(float64); found && !ok {
+			t.Errorf("rate is %T, expected float64", rate)
+		}
+
+		if _, found := p.Fields["name"]; found {
+			t.Errorf("name was not dropped")
+		}
+	}
+
+	// Floats must never be rounded to integers.
+	if _, found := conn.last[1].Fields["count"]; found {
+		t.Errorf("count was converted to %v, expected it dropped", conn.last[1].Fields["count"])
+	}
+}
+
+func TestWritePointsDeclaredFieldTypes(t *testing.T) {
+	DeclareFieldTypes(map[string]FieldType{
+		"declared.counter.value": FieldInteger,
+		"declared.rate.value":    FieldFloat,
+	})
+
+	conn := &mockConn{}
+	i := newInfluxDb(conn, &configuration.InfluxdbConfiguration{})
+
+	// The declared type wins over the type seen first.
+	i.WritePoints([]*Point{
+		NewPoint("declared.rate", nil, map[string]interface{}{"value": 1}),
+		NewPoint("declared.counter", nil, map[string]interface{}{"value": 1.5}),
+		NewPoint("declared.counter", nil, map[string]interface{}{"value": 2}),
+	})
+
+	if len(conn.last) != 2 {
+		t.Fatalf("Got %d points, expected 2", len(conn.last))
+	}
+
+	if conn.last[0].Fields["value"] != 1.0 {
+		t.Errorf("rate is %T %v, expected float64 1", conn.last[0].Fields["value"], conn.last[0].Fields["value"])
+	}
+
+	if conn.last[1].Fields["value"] != int64(2) {
+		t.Errorf("counter is %T %v, expected int64 2", conn.last[1].Fields["value"], conn.last[1].Fields["value"])
+	}
+}
+
+type (
+	// flakyConn will fail every other write.
+	flakyConn struct {
+		writes int
+	}
+)
+
+func (c *flakyConn) Write(points []*Point) error {
+	c.writes++
+
+	if c.writes%2 == 0 {
+		return &StatusError{StatusCode: http.StatusBadRequest, Message: "bad"}
+	}
+
+	return nil
+}
+
+func (c *flakyConn) Close() error {
+	return nil
+}
+
+func TestStats(t *testing.T) {
+	i := newInfluxDb(&flakyConn{}, &configuration.InfluxdbConfiguration{})
+
+	for n := 0; n < 4; n++ {
+		i.WritePoints(points(5))
+	}
+
+	written, dropped := i.Stats()
+	if written != 10 || dropped != 10 {
+		t.Fatalf("Stats() returned %d written and %d dropped, expected 10 and 10", written, dropped)
+	}
+}
+
+func TestWritePrecision(t *testing.T) {
+	var query string
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("precision")
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	i, err := NewInfluxDb(&configuration.InfluxdbConfiguration{URL: server.URL, Database: "agento", Precision: "s"})
+	if err != nil {
+		t.Fatalf("NewInfluxDb() failed: %s", err.Error())
+	}
+
+	// A sample taken well before it's written.
+	sampletime := time.Date(2017, 3, 14, 15, 9, 26, 535897932, time.UTC)
+	point := NewPoint("cpu.User", nil, map[string]interface{}{"value": 1.0}, sampletime)
+
+	err = i.WritePoints([]*Point{point})
+	if err != nil {
+		t.Fatalf("WritePoints() failed: %s", err.Error())
+	}
+
+	if query != "s" {
+		t.Errorf("Got precision '%s', expected 's'", query)
+	}
+
+	expected := "cpu.User value=1 1489504166\n"
+	if body != expected {
+		t.Errorf("Got '%s', expected '%s'", body, expected)
+	}
+
+	_, err = NewInfluxDb(&configuration.InfluxdbConfiguration{URL: server.URL, Precision: "h"})
+	if err == nil {
+		t.Errorf("NewInfluxDb() accepted an unknown precision")
+	}
+
+	_, err = NewInfluxDbV2(&configuration.InfluxdbConfiguration{URL: server.URL, Org: "o", Bucket: "b", Precision: "m"})
+	if err == nil {
+		t.Errorf("NewInfluxDbV2() accepted an unknown precision")
+	}
+}
+
+// countingServer will return a server counting new connections. It responds
+// with a body like InfluxDB does for some errors and proxies.
+func countingServer(conns *int32) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results":[]}`))
+	}))
+
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(conns, 1)
+		}
+	}
+
+	server.Start()
+
+	return server
+}
+
+func TestWriteReusesConnection(t *testing.T) {
+	var conns int32
+	server := countingServer(&conns)
+	defer server.Close()
+
+	i, err := NewInfluxDb(&configuration.InfluxdbConfiguration{URL: server.URL, Database: "agento"})
+	if err != nil {
+		t.Fatalf("NewInfluxDb() failed: %s", err.Error())
+	}
+
+	client := i.conn.(*httpWriter).client
+
+	for n := 0; n < 10; n++ {
+		err = i.WritePoints(points(1))
+		if err != nil {
+			t.Fatalf("WritePoints() failed: %s", err.Error())
+		}
+	}
+
+	if i.conn.(*httpWriter).client != client {
+		t.Errorf("Client was replaced")
+	}
+
+	if atomic.LoadInt32(&conns) != 1 {
+		t.Errorf("Got %d connections for 10 writes, expected 1", conns)
+	}
+}
+
+// benchmarkWriteConnections will write from 32 goroutines per CPU using client
+// and report the number of connections opened per write.
+func benchmarkWriteConnections(b *testing.B, client *http.Client) {
+	var conns int32
+	server := countingServer(&conns)
+	defer server.Close()
+
+	w := newHTTPWriter(client, server.URL+"/write", time.Nanosecond, nil)
+	p := points(1)
+
+	b.SetParallelism(32)
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			w.Write(p)
+		}
+	})
+
+	b.ReportMetric(float64(atomic.LoadInt32(&conns))/float64(b.N), "conns/op")
+}
+
+func BenchmarkWriteConnectionsDefault(b *testing.B) {
+	benchmarkWriteConnections(b, &http.Client{Transport: &http.Transport{}})
+}
+
+func BenchmarkWriteConnectionsShared(b *testing.B) {
+	benchmarkWriteConnections(b, newHTTPClient())
+}
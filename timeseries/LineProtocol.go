@@ -0,0 +1,126 @@
+package timeseries
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// measurementEscaper escapes measurement names.
+	measurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `)
+
+	// keyEscaper escapes tag keys, tag values and field keys.
+	keyEscaper = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `)
+
+	// stringEscaper escapes string field values.
+	stringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// LineProtocol will return the point encoded as InfluxDB line protocol
+// without a trailing newline. Tags and fields are sorted by key. If the point
+// has no time, the timestamp is left out and the server will assign one.
+// Names and keys are sanitized again, as tags may have been added after the
+// point was created. The timestamp is in nanoseconds.
+func (p *Point) LineProtocol() string {
+	return p.LineProtocolPrecision(time.Nanosecond)
+}
+
+// LineProtocolPrecision is like LineProtocol() but with the timestamp
+// truncated to unit, which must match the precision of the write.
+func (p *Point) LineProtocolPrecision(unit time.Duration) string {
+	var b bytes.Buffer
+
+	b.WriteString(measurementEscaper.Replace(sanitize(p.Name)))
+
+	for _, key := range sortedKeys(p.Tags) {
+		value := sanitize(p.Tags[key])
+		key = sanitize(key)
+
+		// Empty tag keys and values are not allowed.
+		if key == "" || value == "" {
+			continue
+		}
+
+		b.WriteByte(',')
+		b.WriteString(keyEscaper.Replace(key))
+		b.WriteByte('=')
+		b.WriteString(keyEscaper.Replace(value))
+	}
+
+	fields := make([]string, 0, len(p.Fields))
+	for key := range p.Fields {
+		fields = append(fields, key)
+	}
+	sort.Strings(fields)
+
+	for i, key := range fields {
+		if i == 0 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteByte(',')
+		}
+
+		b.WriteString(keyEscaper.Replace(sanitize(key)))
+		b.WriteByte('=')
+		b.WriteString(fieldValue(p.Fields[key]))
+	}
+
+	if !p.Time.IsZero() {
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatInt(p.Time.UnixNano()/int64(unit), 10))
+	}
+
+	return b.String()
+}
+
+// sortedKeys will return the keys of m in sorted order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// fieldValue will encode a single field value. Integers get the "i" suffix,
+// unsigned integers "u", strings are quoted.
+func fieldValue(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case int:
+		return strconv.FormatInt(int64(v), 10) + "i"
+	case int8:
+		return strconv.FormatInt(int64(v), 10) + "i"
+	case int16:
+		return strconv.FormatInt(int64(v), 10) + "i"
+	case int32:
+		return strconv.FormatInt(int64(v), 10) + "i"
+	case int64:
+		return strconv.FormatInt(v, 10) + "i"
+	case uint:
+		return strconv.FormatUint(uint64(v), 10) + "u"
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10) + "u"
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10) + "u"
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10) + "u"
+	case uint64:
+		return strconv.FormatUint(v, 10) + "u"
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return `"` + stringEscaper.Replace(v) + `"`
+	}
+
+	return `"` + stringEscaper.Replace(fmt.Sprintf("%v", value)) + `"`
+}
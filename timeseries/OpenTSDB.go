@@ -0,0 +1,221 @@
+package timeseries
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/abrander/agento/configuration"
+)
+
+type (
+	// OpenTSDB will write points to OpenTSDB using the HTTP /api/put
+	// endpoint.
+	OpenTSDB struct {
+		client *http.Client
+		putURL string
+	}
+
+	// openTSDBPoint is a single data point as accepted by /api/put.
+	openTSDBPoint struct {
+		Metric    string            `json:"metric"`
+		Timestamp int64             `json:"timestamp"`
+		Value     float64           `json:"value"`
+		Tags      map[string]string `json:"tags"`
+	}
+)
+
+const (
+	// openTSDBDefaultTag is added to points without tags. OpenTSDB requires
+	// at least one tag per data point.
+	openTSDBDefaultTag = "source"
+
+	// openTSDBDefaultTagValue is the value of openTSDBDefaultTag.
+	openTSDBDefaultTagValue = "agento"
+)
+
+// NewOpenTSDB will return a Database writing to the OpenTSDB server at
+// cfg.URL.
+func NewOpenTSDB(cfg *configuration.OpenTSDBConfiguration) (*OpenTSDB, error) {
+	u, err := url.Parse(strings.TrimRight(cfg.URL, "/") + "/api/put")
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported protocol scheme '%s'", u.Scheme)
+	}
+
+	timeout := defaultHTTPTimeout
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+
+	return &OpenTSDB{
+		client: &http.Client{Timeout: timeout},
+		putURL: u.String(),
+	}, nil
+}
+
+// WritePoints implements Database. All points are sent as a JSON array in a
+// single request.
+func (o *OpenTSDB) WritePoints(points []*Point) error {
+	converted := openTSDBPoints(points)
+	if len(converted) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(converted)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", o.putURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "agento-server")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+
+		return &StatusError{
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(msg)),
+		}
+	}
+
+	return nil
+}
+
+// openTSDBPoints will convert points to OpenTSDB data points. A data point
+// is created per field. The field "value" is stored as the measurement name
+// itself, other fields as "measurement.field". Non-numeric fields are left
+// out.
+func openTSDBPoints(points []*Point) []openTSDBPoint {
+	converted := make([]openTSDBPoint, 0, len(points))
+
+	for _, point := range points {
+		timestamp := point.Time
+		if timestamp.IsZero() {
+			timestamp = time.Now()
+		}
+
+		tags := make(map[string]string, len(point.Tags))
+		for key, value := range point.Tags {
+			key = sanitizeOpenTSDB(key)
+			value = sanitizeOpenTSDB(value)
+
+			if key == "" || value == "" {
+				continue
+			}
+
+			tags[key] = value
+		}
+
+		if len(tags) == 0 {
+			tags[openTSDBDefaultTag] = openTSDBDefaultTagValue
+		}
+
+		// Sort fields to get a stable order.
+		fields := make([]string, 0, len(point.Fields))
+		for field := range point.Fields {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		for _, field := range fields {
+			value, ok := openTSDBValue(point.Fields[field])
+			if !ok {
+				continue
+			}
+
+			metric := point.Name
+			if field != "value" {
+				metric += "." + field
+			}
+
+			converted = append(converted, openTSDBPoint{
+				Metric:    sanitizeOpenTSDB(metric),
+				Timestamp: timestamp.UnixNano() / int64(time.Millisecond),
+				Value:     value,
+				Tags:      tags,
+			})
+		}
+	}
+
+	return converted
+}
+
+// openTSDBValue will return v as a float64. Booleans are stored as 0 and 1.
+func openTSDBValue(v interface{}) (float64, bool) {
+	switch value := v.(type) {
+	case float64:
+		return value, true
+	case float32:
+		return float64(value), true
+	case int:
+		return float64(value), true
+	case int8:
+		return float64(value), true
+	case int16:
+		return float64(value), true
+	case int32:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case uint:
+		return float64(value), true
+	case uint8:
+		return float64(value), true
+	case uint16:
+		return float64(value), true
+	case uint32:
+		return float64(value), true
+	case uint64:
+		return float64(value), true
+	case bool:
+		if value {
+			return 1.0, true
+		}
+
+		return 0.0, true
+	}
+
+	return 0.0, false
+}
+
+// sanitizeOpenTSDB will replace all characters not allowed by OpenTSDB in
+// metric names and tags with underscores. Allowed are letters, digits and
+// "-_./".
+func sanitizeOpenTSDB(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r):
+			return r
+		case r == '-', r == '_', r == '.', r == '/':
+			return r
+		}
+
+		return '_'
+	}, s)
+}
+
+// Ensure compliance.
+var _ Database = (*OpenTSDB)(nil)
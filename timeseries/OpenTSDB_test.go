@@ -0,0 +1,125 @@
+package timeseries
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/configuration"
+)
+
+func TestSanitizeOpenTSDB(t *testing.T) {
+	cases := map[string]string{
+		"cpu.User":        "cpu.User",
+		"disk/sda1":       "disk/sda1",
+		"with space":      "with_space",
+		"a:b,c=d":         "a_b_c_d",
+		"tab\there":       "tab_here",
+		"ærøskøbing-2_a.": "ærøskøbing-2_a.",
+		"":                "",
+	}
+
+	for in, expected := range cases {
+		got := sanitizeOpenTSDB(in)
+		if got != expected {
+			t.Errorf("sanitizeOpenTSDB(%q) returned %q, expected %q", in, got, expected)
+		}
+	}
+}
+
+func TestOpenTSDBPoints(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	points := []*Point{
+		NewPoint("cpu.User", map[string]string{"cpu id": "0", "empty": ""}, map[string]interface{}{"value": 1.5}, ts),
+		NewPoint("net", map[string]string{"if": "eth0"}, map[string]interface{}{"rx": int64(10), "up": true, "name": "eth0"}, ts),
+		NewPoint("load Load1", nil, map[string]interface{}{"value": uint32(2)}, ts),
+	}
+
+	// Round trip through JSON as OpenTSDB would see it.
+	j, err := json.Marshal(openTSDBPoints(points))
+	if err != nil {
+		t.Fatalf("Marshal() failed: %s", err.Error())
+	}
+
+	var got []openTSDBPoint
+	err = json.Unmarshal(j, &got)
+	if err != nil {
+		t.Fatalf("Unmarshal() failed: %s", err.Error())
+	}
+
+	ms := ts.UnixNano() / int64(time.Millisecond)
+	expected := []openTSDBPoint{
+		{Metric: "cpu.User", Timestamp: ms, Value: 1.5, Tags: map[string]string{"cpu_id": "0"}},
+		{Metric: "net.rx", Timestamp: ms, Value: 10, Tags: map[string]string{"if": "eth0"}},
+		{Metric: "net.up", Timestamp: ms, Value: 1, Tags: map[string]string{"if": "eth0"}},
+		{Metric: "load_Load1", Timestamp: ms, Value: 2, Tags: map[string]string{"source": "agento"}},
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Got:\n%+v\nExpected:\n%+v", got, expected)
+	}
+}
+
+func TestOpenTSDBWritePoints(t *testing.T) {
+	var requests int
+	var received []openTSDBPoint
+	status := http.StatusNoContent
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if r.URL.Path != "/api/put" {
+			t.Errorf("Wrong path: %s", r.URL.Path)
+		}
+
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Wrong content type: %s", r.Header.Get("Content-Type"))
+		}
+
+		received = nil
+		json.NewDecoder(r.Body).Decode(&received)
+
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	db, err := NewDatabase(&configuration.ServerConfiguration{
+		Backend:  BackendOpenTSDB,
+		OpenTSDB: configuration.OpenTSDBConfiguration{URL: server.URL + "/"},
+	})
+	if err != nil {
+		t.Fatalf("NewDatabase() failed: %s", err.Error())
+	}
+
+	err = db.WritePoints([]*Point{
+		NewPoint("a", nil, map[string]interface{}{"value": 1}),
+		NewPoint("b", nil, map[string]interface{}{"value": 2}),
+	})
+	if err != nil {
+		t.Fatalf("WritePoints() failed: %s", err.Error())
+	}
+
+	if requests != 1 {
+		t.Fatalf("Expected a single request, got %d", requests)
+	}
+
+	if len(received) != 2 {
+		t.Fatalf("Expected 2 data points, got %d", len(received))
+	}
+
+	status = http.StatusBadRequest
+	err = db.WritePoints([]*Point{NewPoint("a", nil, map[string]interface{}{"value": 1})})
+	if _, ok := err.(*StatusError); !ok {
+		t.Fatalf("WritePoints() did not return a StatusError, got %v", err)
+	}
+
+	// Nothing to write, no request.
+	err = db.WritePoints([]*Point{NewPoint("a", nil, map[string]interface{}{"text": "x"})})
+	if err != nil || requests != 2 {
+		t.Fatalf("WritePoints() sent a request without data points")
+	}
+}
@@ -16,6 +16,8 @@ type (
 	}
 )
 
+// NewPoint will return a new point. The name, tags and field keys are
+// sanitized to avoid corrupting a batch written to the database.
 func NewPoint(name string, tags map[string]string, fields map[string]interface{}, t ...time.Time) *Point {
 	var T time.Time
 	if len(t) > 0 {
@@ -32,9 +34,9 @@ func NewPoint(name string, tags map[string]string, fields map[string]interface{}
 
 	return &Point{
 		Time:   T,
-		Name:   name,
-		Tags:   tags,
-		Fields: fields,
+		Name:   sanitize(name),
+		Tags:   sanitizeTags(tags),
+		Fields: sanitizeFields(fields),
 	}
 }
 
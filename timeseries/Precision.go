@@ -0,0 +1,33 @@
+package timeseries
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultPrecision is the timestamp precision used if none is configured.
+const DefaultPrecision = "ns"
+
+// precisions maps the precisions understood by both InfluxDB 1.x and 2.x to
+// the unit of a timestamp.
+var precisions = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+}
+
+// parsePrecision will return the precision to use in queries and the unit of
+// timestamps. An empty precision means DefaultPrecision.
+func parsePrecision(precision string) (string, time.Duration, error) {
+	if precision == "" {
+		precision = DefaultPrecision
+	}
+
+	unit, found := precisions[precision]
+	if !found {
+		return "", 0, fmt.Errorf("unknown precision '%s', must be one of ns, us, ms or s", precision)
+	}
+
+	return precision, unit, nil
+}
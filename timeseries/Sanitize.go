@@ -0,0 +1,106 @@
+package timeseries
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// sanitize will make s safe for use as a measurement name, tag or field key
+// or tag value. Control characters like newlines can't be escaped in line
+// protocol and are replaced by spaces, invalid UTF-8 is removed and
+// trailing backslashes are removed, as they would escape the following
+// delimiter. Commas, spaces and equal signs are left as is, they're escaped
+// when encoding.
+func sanitize(s string) string {
+	if clean(s) {
+		return s
+	}
+
+	s = strings.ToValidUTF8(s, "")
+
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+
+		return r
+	}, s)
+
+	return strings.TrimRight(s, `\`)
+}
+
+// clean will return true if s doesn't need to be sanitized.
+func clean(s string) bool {
+	if strings.HasSuffix(s, `\`) {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c == 0x7f || c >= utf8.RuneSelf {
+			// Take the slow path for anything but printable ASCII.
+			return s == strings.ToValidUTF8(s, "") && strings.IndexFunc(s, unicode.IsControl) < 0
+		}
+	}
+
+	return true
+}
+
+// sanitizeTags will return tags with keys and values sanitized. Tags with
+// an empty key after sanitizing are removed. tags is returned as is if
+// nothing needs to change, otherwise a copy is returned as tag maps are
+// often shared between points.
+func sanitizeTags(tags map[string]string) map[string]string {
+	dirty := false
+	for key, value := range tags {
+		if key == "" || !clean(key) || !clean(value) {
+			dirty = true
+			break
+		}
+	}
+
+	if !dirty {
+		return tags
+	}
+
+	sanitized := make(map[string]string, len(tags))
+	for key, value := range tags {
+		key = sanitize(key)
+		if key == "" {
+			continue
+		}
+
+		sanitized[key] = sanitize(value)
+	}
+
+	return sanitized
+}
+
+// sanitizeFields will return fields with keys sanitized. Like
+// sanitizeTags(), fields is only copied if needed.
+func sanitizeFields(fields map[string]interface{}) map[string]interface{} {
+	dirty := false
+	for key := range fields {
+		if key == "" || !clean(key) {
+			dirty = true
+			break
+		}
+	}
+
+	if !dirty {
+		return fields
+	}
+
+	sanitized := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		key = sanitize(key)
+		if key == "" {
+			continue
+		}
+
+		sanitized[key] = value
+	}
+
+	return sanitized
+}
@@ -0,0 +1,121 @@
+package timeseries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+func TestSanitize(t *testing.T) {
+	cases := map[string]string{
+		"cpu.User":         "cpu.User",
+		"/mnt/my disk":     "/mnt/my disk",
+		"host,with,commas": "host,with,commas",
+		"line\nbreak":      "line break",
+		"cr\r\nlf":         "cr  lf",
+		"tab\there":        "tab here",
+		"nul\x00":          "nul ",
+		`trailing\`:        "trailing",
+		`trailing\\`:       "trailing",
+		`in\side`:          `in\side`,
+		"invalid\xffutf8":  "invalidutf8",
+		"ærø":              "ærø",
+		"":                 "",
+		"\n":               " ",
+		"next\u0085line":   "next line",
+	}
+
+	for in, expected := range cases {
+		got := sanitize(in)
+		if got != expected {
+			t.Errorf("sanitize(%q) returned %q, expected %q", in, got, expected)
+		}
+	}
+}
+
+func TestNewPointSanitize(t *testing.T) {
+	tags := map[string]string{"mount": "/mnt/a\nb", "ok": "fine"}
+
+	point := NewPoint("disk\nusage", tags, map[string]interface{}{"used\n": 1})
+
+	if point.Name != "disk usage" {
+		t.Errorf("Name was not sanitized: %q", point.Name)
+	}
+
+	if point.Tags["mount"] != "/mnt/a b" || point.Tags["ok"] != "fine" {
+		t.Errorf("Tags were not sanitized: %+v", point.Tags)
+	}
+
+	if _, found := point.Fields["used "]; !found {
+		t.Errorf("Field keys were not sanitized: %+v", point.Fields)
+	}
+
+	// The tag map may be shared between points and must not be changed.
+	if tags["mount"] != "/mnt/a\nb" {
+		t.Errorf("Tags passed to NewPoint() was modified")
+	}
+
+	// Clean tags are used as is.
+	clean := map[string]string{"a": "b"}
+	point = NewPoint("p", clean, nil)
+	point.Tags["c"] = "d"
+	if clean["c"] != "d" {
+		t.Errorf("Clean tags were copied")
+	}
+}
+
+func TestLineProtocolPathological(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	values := []string{
+		"/mnt/with space",
+		"host,with,commas",
+		"key=value",
+		"new\nline",
+		"carriage\rreturn",
+		`trailing\`,
+		`back\slash`,
+		`"quoted"`,
+		"invalid\xffutf8",
+		"\x00",
+		`\`,
+	}
+
+	for _, value := range values {
+		point := NewPoint("disk.Used", map[string]string{"mountpoint": value, "extra": "x"}, map[string]interface{}{"value": 1.0}, ts)
+
+		// Tags added after creation must be sanitized too.
+		point.Tags["hostname"] = value
+
+		line := point.LineProtocol()
+
+		parsed, err := models.ParsePointsString(line)
+		if err != nil {
+			t.Fatalf("Failed to parse '%s' for %q: %s", line, value, err.Error())
+		}
+
+		if len(parsed) != 1 {
+			t.Fatalf("Got %d points from '%s' for %q, expected 1", len(parsed), line, value)
+		}
+
+		p := parsed[0]
+
+		if p.Tags().GetString("extra") != "x" {
+			t.Errorf("Tag following %q was corrupted in '%s'", value, line)
+		}
+
+		if p.Tags().GetString("mountpoint") != sanitize(value) {
+			t.Errorf("Got mountpoint %q, expected %q from '%s'", p.Tags().GetString("mountpoint"), sanitize(value), line)
+		}
+
+		if p.Tags().GetString("hostname") != sanitize(value) {
+			t.Errorf("Got hostname %q, expected %q from '%s'", p.Tags().GetString("hostname"), sanitize(value), line)
+		}
+
+		fields, err := p.Fields()
+		if err != nil || fields["value"] != 1.0 {
+			t.Errorf("Fields were corrupted in '%s'", line)
+		}
+	}
+}
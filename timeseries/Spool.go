@@ -0,0 +1,377 @@
+package timeseries
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+
+	"github.com/abrander/agento/logger"
+)
+
+type (
+	// Spool will append batches the wrapped Database failed to write to a
+	// file as line protocol, and replay them when the database recovers.
+	Spool struct {
+		db       Database
+		path     string
+		maxBytes int64
+
+		// lock protects the spool file.
+		lock sync.Mutex
+
+		stop    chan struct{}
+		stopped sync.WaitGroup
+	}
+)
+
+const (
+	// replayBatchSize is the number of points written at once when
+	// replaying the spool.
+	replayBatchSize = 5000
+)
+
+func init() {
+	// We write unsigned integers, we must be able to read them back.
+	models.EnableUintSupport()
+}
+
+// NewSpool will return a Database writing to db, spooling failed batches to
+// path. The spool will be replayed every interval. If the spool grows beyond
+// maxBytes, the oldest points are dropped.
+func NewSpool(db Database, path string, maxBytes int64, interval time.Duration) *Spool {
+	s := &Spool{
+		db:       db,
+		path:     path,
+		maxBytes: maxBytes,
+		stop:     make(chan struct{}),
+	}
+
+	// Points buffered by db are written in the background, we must spool
+	// them if that fails.
+	if flusher, ok := db.(Flusher); ok {
+		flusher.OnFlush(s.flushed)
+	}
+
+	s.stopped.Add(1)
+	go s.replayLoop(interval)
+
+	return s
+}
+
+// WritePoints implements Database. If the wrapped Database fails, the points
+// not written are spooled and nil is returned, unless spooling fails too.
+// Points rejected permanently are never spooled, they would block replay.
+func (s *Spool) WritePoints(points []*Point) error {
+	err := s.db.WritePoints(points)
+	if err == nil {
+		return nil
+	}
+
+	points = failed(points, err)
+
+	if !transient(err) {
+		logger.Red("spool", "Dropping %d points rejected by the database: %s", len(points), err.Error())
+
+		return err
+	}
+
+	logger.Yellow("spool", "Spooling %d points: %s", len(points), err.Error())
+
+	return s.append(points)
+}
+
+// flushed will spool points the wrapped Database failed to write in the
+// background.
+func (s *Spool) flushed(notWritten []*Point, err error) {
+	if err == nil || len(notWritten) == 0 {
+		return
+	}
+
+	if !transient(err) {
+		logger.Red("spool", "Dropping %d buffered points rejected by the database: %s", len(notWritten), err.Error())
+
+		return
+	}
+
+	logger.Yellow("spool", "Spooling %d buffered points: %s", len(notWritten), err.Error())
+
+	err = s.append(notWritten)
+	if err != nil {
+		logger.Red("spool", "Error spooling %d points: %s", len(notWritten), err.Error())
+	}
+}
+
+// OnFlush implements Flusher if the wrapped Database does.
+func (s *Spool) OnFlush(fn func(notWritten []*Point, err error)) {
+	if flusher, ok := s.db.(Flusher); ok {
+		flusher.OnFlush(fn)
+	}
+}
+
+// Stats implements Statser if the wrapped Database does. Points dropped by
+// the wrapped Database are usually spooled and replayed later.
+func (s *Spool) Stats() (written, dropped uint64) {
+	statser, ok := s.db.(Statser)
+	if !ok {
+		return 0, 0
+	}
+
+	return statser.Stats()
+}
+
+// Close will stop replaying the spool and close the wrapped Database if it
+// implements Closer. Spooled points are left on disk to be replayed next
+// time.
+func (s *Spool) Close() error {
+	close(s.stop)
+	s.stopped.Wait()
+
+	closer, ok := s.db.(Closer)
+	if !ok {
+		return nil
+	}
+
+	return closer.Close()
+}
+
+// append will append points to the spool file, dropping the oldest lines if
+// the spool would grow beyond maxBytes. Points without time are spooled with
+// the current time to keep them from being stamped at replay.
+func (s *Spool) append(points []*Point) error {
+	buf := bytes.NewBuffer(lines(points))
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var size int64
+	info, err := os.Stat(s.path)
+	if err == nil {
+		size = info.Size()
+	}
+
+	if s.maxBytes > 0 && size+int64(buf.Len()) > s.maxBytes {
+		return s.evict(buf.Bytes())
+	}
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(buf.Bytes())
+	if err != nil {
+		f.Close()
+
+		return err
+	}
+
+	return f.Close()
+}
+
+// evict will rewrite the spool with the newest lines from the spool and
+// added fitting in maxBytes. Must be called with lock held.
+func (s *Spool) evict(added []byte) error {
+	existing, err := ioutil.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	all := append(existing, added...)
+
+	dropped := 0
+	for int64(len(all)) > s.maxBytes {
+		i := bytes.IndexByte(all, '\n')
+		if i < 0 {
+			all = nil
+			break
+		}
+
+		all = all[i+1:]
+		dropped++
+	}
+
+	logger.Red("spool", "Spool is full, dropped %d points", dropped)
+
+	return s.replace(all)
+}
+
+// replace will atomically replace the spool file with contents. Must be
+// called with lock held.
+func (s *Spool) replace(contents []byte) error {
+	if len(contents) == 0 {
+		err := os.Remove(s.path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+
+	err := ioutil.WriteFile(tmp, contents, 0600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
+
+// replayLoop will replay the spool every interval until Close() is called.
+func (s *Spool) replayLoop(interval time.Duration) {
+	defer s.stopped.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			err := s.Replay()
+			if err != nil {
+				logger.Yellow("spool", "Replay failed: %s", err.Error())
+			}
+		}
+	}
+}
+
+// Replay will write all spooled points to the wrapped Database. Points
+// written are removed from the spool, if a write fails the rest is kept for
+// next time.
+func (s *Spool) Replay() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	contents, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// offset is the number of bytes written successfully. kept holds the
+	// points of a partially written batch to keep in the spool.
+	offset := 0
+	var kept []byte
+	var batch []*Point
+	var batchBytes int
+
+	flush := func() error {
+		if len(batch) > 0 {
+			err := s.db.WritePoints(batch)
+
+			// Permanently rejected points will never be written, skip
+			// them to let the rest of the spool replay.
+			if err != nil && !transient(err) {
+				logger.Red("spool", "Dropping %d spooled points rejected by the database: %s", len(failed(batch, err)), err.Error())
+				err = nil
+			}
+
+			if partial, ok := err.(*PartialError); ok {
+				kept = lines(partial.Points)
+				offset += batchBytes
+
+				return err
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		offset += batchBytes
+		batch = nil
+		batchBytes = 0
+
+		return nil
+	}
+
+	var writeErr error
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	scanner.Buffer(make([]byte, 64*1024), len(contents)+1)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		batchBytes += len(line) + 1
+
+		parsed, parseErr := models.ParsePoints(line)
+		if parseErr != nil {
+			// A corrupt line will never succeed, skip it.
+			logger.Red("spool", "Dropping unparsable line '%s': %s", line, parseErr.Error())
+			continue
+		}
+
+		for _, p := range parsed {
+			batch = append(batch, fromModel(p))
+		}
+
+		if len(batch) >= replayBatchSize {
+			writeErr = flush()
+			if writeErr != nil {
+				break
+			}
+		}
+	}
+
+	if writeErr == nil {
+		writeErr = flush()
+	}
+
+	// The last line may lack a newline.
+	if offset > len(contents) {
+		offset = len(contents)
+	}
+
+	if offset == 0 {
+		return writeErr
+	}
+
+	logger.Green("spool", "Replayed %d bytes of spooled points", offset-len(kept))
+
+	err = s.replace(append(kept, contents[offset:]...))
+	if writeErr != nil {
+		return writeErr
+	}
+
+	return err
+}
+
+// lines will return points as line protocol, a point per line. Points
+// without time are stamped with the current time to keep them from being
+// stamped at replay.
+func lines(points []*Point) []byte {
+	now := time.Now()
+
+	var buf bytes.Buffer
+	for _, point := range points {
+		if len(point.Fields) == 0 {
+			continue
+		}
+
+		if point.Time.IsZero() {
+			stamped := *point
+			stamped.Time = now
+			point = &stamped
+		}
+
+		buf.WriteString(point.LineProtocol())
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes()
+}
+
+// fromModel will convert a parsed line protocol point to a Point.
+func fromModel(p models.Point) *Point {
+	fields, _ := p.Fields()
+
+	return NewPoint(string(p.Name()), p.Tags().Map(), fields, p.Time())
+}
@@ -0,0 +1,363 @@
+package timeseries
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/configuration"
+)
+
+type (
+	// switchDB will fail writes while down is true, reject points tagged
+	// with reject and remember points written.
+	switchDB struct {
+		lock   sync.Mutex
+		down   bool
+		points []*Point
+	}
+
+	// partialDB will fail points tagged with fail and remember points
+	// written.
+	partialDB struct {
+		switchDB
+	}
+
+	// closingDB remembers if it was closed.
+	closingDB struct {
+		switchDB
+		closed bool
+	}
+)
+
+// errDown is returned by test databases while down.
+var errDown = &StatusError{StatusCode: http.StatusServiceUnavailable, Message: "database is down"}
+
+func (d *switchDB) WritePoints(points []*Point) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.down {
+		return errDown
+	}
+
+	// Like InfluxDB, the points not rejected are written.
+	rejected := false
+	for _, point := range points {
+		if point.Tags["reject"] != "" {
+			rejected = true
+			continue
+		}
+
+		d.points = append(d.points, point)
+	}
+
+	if rejected {
+		return &StatusError{StatusCode: http.StatusBadRequest, Message: "partial write: field type conflict"}
+	}
+
+	return nil
+}
+
+func (d *switchDB) setDown(down bool) {
+	d.lock.Lock()
+	d.down = down
+	d.lock.Unlock()
+}
+
+func (d *switchDB) written() []*Point {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.points
+}
+
+func (d *partialDB) WritePoints(points []*Point) error {
+	var written, notWritten []*Point
+	for _, point := range points {
+		if point.Tags["fail"] != "" {
+			notWritten = append(notWritten, point)
+		} else {
+			written = append(written, point)
+		}
+	}
+
+	d.switchDB.WritePoints(written)
+
+	if len(notWritten) > 0 {
+		return &PartialError{Points: notWritten, Err: errDown}
+	}
+
+	return nil
+}
+
+func (d *closingDB) Close() error {
+	d.closed = true
+
+	return nil
+}
+
+// newTestSpool will return a spool in a temporary directory. The replay
+// interval is an hour, tests must call Replay().
+func newTestSpool(t *testing.T, maxBytes int64) (*Spool, *switchDB, string) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err.Error())
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	db := &switchDB{down: true}
+	path := filepath.Join(dir, "spool")
+	s := NewSpool(db, path, maxBytes, time.Hour)
+	t.Cleanup(func() { s.Close() })
+
+	return s, db, path
+}
+
+func spoolLines(t *testing.T, path string) []string {
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %s", err.Error())
+	}
+
+	return strings.Split(strings.TrimSpace(string(contents)), "\n")
+}
+
+func TestSpoolWrite(t *testing.T) {
+	s, _, path := newTestSpool(t, 0)
+
+	ts := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	err := s.WritePoints([]*Point{
+		NewPoint("cpu", map[string]string{"host": "web 1"}, map[string]interface{}{"value": 1.5}, ts),
+		NewPoint("load", nil, map[string]interface{}{"value": 2}),
+	})
+	if err != nil {
+		t.Fatalf("WritePoints() failed while spooling: %s", err.Error())
+	}
+
+	lines := spoolLines(t, path)
+	if len(lines) != 2 {
+		t.Fatalf("Got %d spooled lines, expected 2", len(lines))
+	}
+
+	if lines[0] != `cpu,host=web\ 1 value=1.5 1709294400000000000` {
+		t.Errorf("Wrong spooled line '%s'", lines[0])
+	}
+
+	// Points without time must be stamped when spooled.
+	if !strings.HasPrefix(lines[1], "load value=2i ") {
+		t.Errorf("Point without time was not stamped: '%s'", lines[1])
+	}
+}
+
+func TestSpoolReplay(t *testing.T) {
+	s, db, path := newTestSpool(t, 0)
+
+	ts := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.WritePoints([]*Point{NewPoint("cpu", map[string]string{"cpu": "0"}, map[string]interface{}{"value": 1.5}, ts)})
+	s.WritePoints([]*Point{NewPoint("mem", nil, map[string]interface{}{"free": int64(1024)}, ts)})
+
+	// Still down, nothing must be lost.
+	err := s.Replay()
+	if err == nil {
+		t.Fatalf("Replay() succeeded while down")
+	}
+
+	if len(spoolLines(t, path)) != 2 {
+		t.Fatalf("Failed replay changed the spool")
+	}
+
+	db.setDown(false)
+
+	err = s.Replay()
+	if err != nil {
+		t.Fatalf("Replay() failed: %s", err.Error())
+	}
+
+	points := db.written()
+	if len(points) != 2 {
+		t.Fatalf("Got %d points replayed, expected 2", len(points))
+	}
+
+	if points[0].Name != "cpu" || points[0].Tags["cpu"] != "0" || points[0].Fields["value"] != 1.5 || !points[0].Time.Equal(ts) {
+		t.Errorf("Wrong replayed point: %+v", *points[0])
+	}
+
+	if points[1].Fields["free"] != int64(1024) {
+		t.Errorf("Wrong replayed point: %+v", *points[1])
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Spool was not removed after replay")
+	}
+
+	// Writes must go straight through now.
+	s.WritePoints([]*Point{NewPoint("cpu", nil, map[string]interface{}{"value": 2.0}, ts)})
+	if len(db.written()) != 3 || spoolLines(t, path) != nil {
+		t.Errorf("Point was spooled while the database was up")
+	}
+}
+
+func TestSpoolEviction(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	line := NewPoint("p", nil, map[string]interface{}{"v": 0}, ts).LineProtocol()
+
+	// Room for three lines.
+	s, _, path := newTestSpool(t, int64(3*(len(line)+1)))
+
+	for i := 0; i < 5; i++ {
+		err := s.WritePoints([]*Point{NewPoint("p", nil, map[string]interface{}{"v": i}, ts)})
+		if err != nil {
+			t.Fatalf("WritePoints() failed: %s", err.Error())
+		}
+	}
+
+	lines := spoolLines(t, path)
+	if len(lines) != 3 {
+		t.Fatalf("Got %d spooled lines, expected 3", len(lines))
+	}
+
+	// The oldest must be dropped.
+	for i, l := range lines {
+		if !strings.HasPrefix(l, "p v="+string('2'+rune(i))+"i ") {
+			t.Errorf("Line %d is '%s', expected v=%d", i, l, i+2)
+		}
+	}
+}
+
+func TestSpoolClose(t *testing.T) {
+	db := &closingDB{}
+	s := NewSpool(db, filepath.Join(os.TempDir(), "unused-spool"), 0, time.Hour)
+
+	err := s.Close()
+	if err != nil {
+		t.Fatalf("Close() failed: %s", err.Error())
+	}
+
+	if !db.closed {
+		t.Errorf("Close() did not close the wrapped database")
+	}
+}
+
+func TestSpoolPartial(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "spool")
+	db := &partialDB{}
+	s := NewSpool(db, path, 0, time.Hour)
+	defer s.Close()
+
+	ts := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	err = s.WritePoints([]*Point{
+		NewPoint("ok", nil, map[string]interface{}{"value": 1}, ts),
+		NewPoint("failing", map[string]string{"fail": "yes"}, map[string]interface{}{"value": 2}, ts),
+	})
+	if err != nil {
+		t.Fatalf("WritePoints() failed while spooling: %s", err.Error())
+	}
+
+	// Only the point not written must be spooled.
+	lines := spoolLines(t, path)
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "failing,") {
+		t.Fatalf("Got spooled lines %v, expected only the failing point", lines)
+	}
+
+	ioutil.WriteFile(path, []byte(
+		NewPoint("ok", nil, map[string]interface{}{"value": 3}, ts).LineProtocol()+"\n"+
+			NewPoint("failing", map[string]string{"fail": "yes"}, map[string]interface{}{"value": 4}, ts).LineProtocol()+"\n"), 0600)
+
+	err = s.Replay()
+	if err == nil {
+		t.Fatalf("Replay() succeeded with a failing point")
+	}
+
+	// Points written by a partial replay must not be replayed again.
+	lines = spoolLines(t, path)
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "failing,") {
+		t.Fatalf("Got spooled lines %v after partial replay, expected only the failing point", lines)
+	}
+
+	if len(db.written()) != 2 {
+		t.Errorf("Got %d points written, expected 2", len(db.written()))
+	}
+}
+
+func TestSpoolRejected(t *testing.T) {
+	s, db, path := newTestSpool(t, 0)
+	db.setDown(false)
+
+	ts := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	err := s.WritePoints([]*Point{NewPoint("rejected", map[string]string{"reject": "yes"}, map[string]interface{}{"value": 1}, ts)})
+	if err == nil {
+		t.Fatalf("WritePoints() hid a permanent error")
+	}
+
+	if spoolLines(t, path) != nil {
+		t.Fatalf("Permanently rejected points was spooled")
+	}
+
+	db.setDown(true)
+	s.WritePoints([]*Point{NewPoint("later", nil, map[string]interface{}{"value": 2}, ts)})
+
+	// A rejected line at the head of the spool must not block the rest,
+	// even if spooled by an earlier version.
+	contents, _ := ioutil.ReadFile(path)
+	ioutil.WriteFile(path, append([]byte(NewPoint("rejected", map[string]string{"reject": "yes"}, map[string]interface{}{"value": 3}, ts).LineProtocol()+"\n"), contents...), 0600)
+
+	db.setDown(false)
+
+	err = s.Replay()
+	if err != nil {
+		t.Fatalf("Replay() failed: %s", err.Error())
+	}
+
+	points := db.written()
+	if len(points) != 1 || points[0].Name != "later" {
+		t.Fatalf("Got %d points replayed, expected only the later point", len(points))
+	}
+
+	if spoolLines(t, path) != nil {
+		t.Errorf("Spool was not emptied after replay")
+	}
+}
+
+func TestSpoolBuffered(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	conn := &mockConn{err: errDown}
+	db := newInfluxDb(conn, &configuration.InfluxdbConfiguration{BatchSize: 100})
+
+	path := filepath.Join(dir, "spool")
+	s := NewSpool(db, path, 0, time.Hour)
+
+	err = s.WritePoints(points(3))
+	if err != nil {
+		t.Fatalf("WritePoints() failed while buffering: %s", err.Error())
+	}
+
+	// The buffer is flushed and fails when closing, the points must end
+	// up in the spool.
+	s.Close()
+
+	lines := spoolLines(t, path)
+	if len(lines) != 3 {
+		t.Fatalf("Got %d spooled lines, expected 3", len(lines))
+	}
+}
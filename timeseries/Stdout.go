@@ -0,0 +1,41 @@
+package timeseries
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+type (
+	// Stdout will write points as JSON to a writer instead of storing them.
+	// Useful for development and debugging.
+	Stdout struct {
+		lock    sync.Mutex
+		encoder *json.Encoder
+	}
+)
+
+// NewStdout will return a Database writing each batch of points as a JSON
+// array on a line of its own to w. If w is nil, os.Stdout is used.
+func NewStdout(w io.Writer) *Stdout {
+	if w == nil {
+		w = os.Stdout
+	}
+
+	return &Stdout{
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// WritePoints implements Database. Empty batches are not written.
+func (s *Stdout) WritePoints(points []*Point) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.encoder.Encode(points)
+}
@@ -0,0 +1,53 @@
+package timeseries
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/abrander/agento/configuration"
+)
+
+func TestStdout(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewStdout(&buf)
+
+	ts := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	err := s.WritePoints([]*Point{
+		NewPoint("cpu.User", map[string]string{"cpu": "0"}, map[string]interface{}{"value": 1.5}, ts),
+		NewPoint("load.Load1", nil, map[string]interface{}{"value": 2}, ts),
+	})
+	if err != nil {
+		t.Fatalf("WritePoints() failed: %s", err.Error())
+	}
+
+	// Empty batches must be left out.
+	err = s.WritePoints(nil)
+	if err != nil {
+		t.Fatalf("WritePoints() failed: %s", err.Error())
+	}
+
+	expected := `[{"time":"2024-03-01T12:00:00Z","name":"cpu.User","tags":{"cpu":"0"},"fields":{"value":1.5}},` +
+		`{"time":"2024-03-01T12:00:00Z","name":"load.Load1","tags":{},"fields":{"value":2}}]` + "\n"
+
+	if buf.String() != expected {
+		t.Fatalf("Got:\n%s\nExpected:\n%s", buf.String(), expected)
+	}
+}
+
+func TestNewDatabaseBackend(t *testing.T) {
+	db, err := NewDatabase(&configuration.ServerConfiguration{Backend: BackendStdout})
+	if err != nil {
+		t.Fatalf("NewDatabase() failed: %s", err.Error())
+	}
+
+	if _, ok := db.(*Stdout); !ok {
+		t.Fatalf("NewDatabase() returned %T for the stdout backend", db)
+	}
+
+	_, err = NewDatabase(&configuration.ServerConfiguration{Backend: "carbon"})
+	if err != ErrUnknownBackend {
+		t.Fatalf("NewDatabase() returned %v for an unknown backend", err)
+	}
+}
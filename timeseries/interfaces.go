@@ -4,4 +4,23 @@ type (
 	Database interface {
 		WritePoints(points []*Point) error
 	}
+
+	// Statser is a Database able to tell how many points it has written
+	// and dropped.
+	Statser interface {
+		Stats() (written, dropped uint64)
+	}
+
+	// Closer is a Database holding buffered points or connections that
+	// must be flushed and closed before exiting.
+	Closer interface {
+		Close() error
+	}
+
+	// Flusher is a Database buffering points and writing them in the
+	// background. fn is called after each background write with the
+	// points not written and the error, or nil and nil on success.
+	Flusher interface {
+		OnFlush(fn func(notWritten []*Point, err error))
+	}
 )
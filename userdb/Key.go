@@ -0,0 +1,50 @@
+package userdb
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+type (
+	// Key is an API key belonging to an account. An account can have
+	// multiple keys to allow rotation and revocation of individual keys.
+	Key struct {
+		Key       string    `json:"key"`
+		Label     string    `json:"label"`
+		AccountID string    `json:"accountId"`
+		Created   time.Time `json:"created"`
+		Revoked   bool      `json:"revoked"`
+
+		// ExpiresAt is the time the key expires. If zero, the key never
+		// expires.
+		ExpiresAt time.Time `json:"expiresAt"`
+	}
+)
+
+// newKey will generate a new random key for accountID.
+func newKey(accountID string, label string) (*Key, error) {
+	b := make([]byte, 20)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{
+		Key:       hex.EncodeToString(b),
+		Label:     label,
+		AccountID: accountID,
+		Created:   time.Now(),
+	}, nil
+}
+
+// GetAccountId implements Object.
+func (k *Key) GetAccountId() string {
+	return k.AccountID
+}
+
+// Expired will return true if the key has an expiry at or before now.
+func (k *Key) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
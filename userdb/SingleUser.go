@@ -2,12 +2,18 @@ package userdb
 
 import (
 	"errors"
+	"sync"
+	"time"
 )
 
 type (
 	// This implements Subject, User, Account and Database for a single user system.
 	SingleUser struct {
-		key string
+		keysLock sync.RWMutex
+		keys     map[string]*Key
+
+		// now returns the current time. Can be replaced in tests.
+		now func() time.Time
 	}
 )
 
@@ -17,8 +23,22 @@ var (
 	God = &SingleUser{}
 )
 
+// NewSingleUser will return a new single user system. key will be accepted as
+// an API key. More keys can be added using AddKey().
 func NewSingleUser(key string) *SingleUser {
-	return &SingleUser{key: key}
+	s := &SingleUser{
+		keys: make(map[string]*Key),
+		now:  time.Now,
+	}
+
+	s.keys[key] = &Key{
+		Key:       key,
+		Label:     "configuration",
+		AccountID: s.GetId(),
+		Created:   time.Now(),
+	}
+
+	return s
 }
 
 func (s *SingleUser) GetId() string {
@@ -29,13 +49,97 @@ func (s *SingleUser) GetAccountId() string {
 	return s.GetId()
 }
 
+// ResolveKey will return the SingleUser if key is known and not revoked. If
+// the key has expired, ErrorExpiredKey is returned.
 func (s *SingleUser) ResolveKey(key string) (Subject, error) {
-	if key == s.key {
-		return s, nil
+	s.keysLock.RLock()
+	defer s.keysLock.RUnlock()
+
+	k, found := s.keys[key]
+	if !found || k.Revoked {
+		return nil, ErrorUnknownKey
+	}
+
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+
+	if k.Expired(now()) {
+		return nil, ErrorExpiredKey
+	}
+
+	return s, nil
+}
+
+// AddKey will add a new key. account must be the SingleUser itself.
+func (s *SingleUser) AddKey(account Account, label string) (*Key, error) {
+	if account.GetId() != s.GetId() {
+		return nil, ErrorInvalidAccountId
+	}
 
+	k, err := newKey(s.GetId(), label)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, errors.New("Wrong key")
+	s.keysLock.Lock()
+	if s.keys == nil {
+		s.keys = make(map[string]*Key)
+	}
+	s.keys[k.Key] = k
+	s.keysLock.Unlock()
+
+	added := *k
+
+	return &added, nil
+}
+
+// ListKeys will list all keys. account must be the SingleUser itself.
+func (s *SingleUser) ListKeys(account Account) ([]Key, error) {
+	if account.GetId() != s.GetId() {
+		return nil, ErrorInvalidAccountId
+	}
+
+	s.keysLock.RLock()
+	defer s.keysLock.RUnlock()
+
+	keys := make([]Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, *k)
+	}
+
+	return keys, nil
+}
+
+// RevokeKey will revoke key.
+func (s *SingleUser) RevokeKey(key string) error {
+	s.keysLock.Lock()
+	defer s.keysLock.Unlock()
+
+	k, found := s.keys[key]
+	if !found {
+		return ErrorUnknownKey
+	}
+
+	k.Revoked = true
+
+	return nil
+}
+
+// SetKeyExpiry will set the expiry time of key.
+func (s *SingleUser) SetKeyExpiry(key string, expiresAt time.Time) error {
+	s.keysLock.Lock()
+	defer s.keysLock.Unlock()
+
+	k, found := s.keys[key]
+	if !found {
+		return ErrorUnknownKey
+	}
+
+	k.ExpiresAt = expiresAt
+
+	return nil
 }
 
 // This is only here to satisfy the Database interface. This doesn't work
@@ -67,3 +171,4 @@ var _ Object = (*SingleUser)(nil)
 var _ Subject = (*SingleUser)(nil)
 var _ User = (*SingleUser)(nil)
 var _ Account = (*SingleUser)(nil)
+var _ Database = (*SingleUser)(nil)
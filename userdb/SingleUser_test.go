@@ -0,0 +1,158 @@
+package userdb
+
+import (
+	"testing"
+	"time"
+)
+
+type (
+	// foreignAccount is an account with another id.
+	foreignAccount struct {
+		*SingleUser
+	}
+)
+
+func (a *foreignAccount) GetId() string {
+	return "111111111111111111111111"
+}
+
+func TestResolveKey(t *testing.T) {
+	s := NewSingleUser("secret")
+
+	subject, err := s.ResolveKey("secret")
+	if err != nil {
+		t.Fatalf("ResolveKey() failed: %s", err.Error())
+	}
+
+	if subject != s {
+		t.Fatalf("ResolveKey() returned wrong subject")
+	}
+
+	_, err = s.ResolveKey("wrong")
+	if err != ErrorUnknownKey {
+		t.Fatalf("ResolveKey() returned %v for unknown key", err)
+	}
+}
+
+func TestAddKey(t *testing.T) {
+	s := NewSingleUser("secret")
+
+	k1, err := s.AddKey(s, "web01")
+	if err != nil {
+		t.Fatalf("AddKey() failed: %s", err.Error())
+	}
+
+	k2, err := s.AddKey(s, "web02")
+	if err != nil {
+		t.Fatalf("AddKey() failed: %s", err.Error())
+	}
+
+	if k1.Key == k2.Key || k1.Key == "" {
+		t.Fatalf("AddKey() returned bad keys '%s' and '%s'", k1.Key, k2.Key)
+	}
+
+	if k1.Label != "web01" || k1.AccountID != s.GetId() {
+		t.Fatalf("Wrong key: %+v", k1)
+	}
+
+	// All keys must resolve.
+	for _, key := range []string{"secret", k1.Key, k2.Key} {
+		_, err = s.ResolveKey(key)
+		if err != nil {
+			t.Errorf("ResolveKey(%s) failed: %s", key, err.Error())
+		}
+	}
+
+	keys, err := s.ListKeys(s)
+	if err != nil {
+		t.Fatalf("ListKeys() failed: %s", err.Error())
+	}
+
+	if len(keys) != 3 {
+		t.Fatalf("ListKeys() returned %d keys, expected 3", len(keys))
+	}
+
+	_, err = s.AddKey(&foreignAccount{s}, "foreign")
+	if err != ErrorInvalidAccountId {
+		t.Fatalf("AddKey() returned %v for foreign account", err)
+	}
+}
+
+func TestRevokeKey(t *testing.T) {
+	s := NewSingleUser("secret")
+
+	k, _ := s.AddKey(s, "web01")
+
+	err := s.RevokeKey(k.Key)
+	if err != nil {
+		t.Fatalf("RevokeKey() failed: %s", err.Error())
+	}
+
+	_, err = s.ResolveKey(k.Key)
+	if err != ErrorUnknownKey {
+		t.Fatalf("Revoked key resolved, got %v", err)
+	}
+
+	// Other keys must be unaffected.
+	_, err = s.ResolveKey("secret")
+	if err != nil {
+		t.Fatalf("ResolveKey() failed after revoking another key: %s", err.Error())
+	}
+
+	keys, _ := s.ListKeys(s)
+	for _, key := range keys {
+		if key.Key == k.Key && !key.Revoked {
+			t.Fatalf("ListKeys() does not show key as revoked")
+		}
+	}
+
+	err = s.RevokeKey("unknown")
+	if err != ErrorUnknownKey {
+		t.Fatalf("RevokeKey() returned %v for unknown key", err)
+	}
+}
+
+func TestKeyExpiry(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	s := NewSingleUser("secret")
+	s.now = func() time.Time { return now }
+
+	k, _ := s.AddKey(s, "provisioning")
+
+	err := s.SetKeyExpiry(k.Key, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("SetKeyExpiry() failed: %s", err.Error())
+	}
+
+	_, err = s.ResolveKey(k.Key)
+	if err != nil {
+		t.Fatalf("ResolveKey() failed before expiry: %s", err.Error())
+	}
+
+	now = now.Add(time.Hour)
+
+	_, err = s.ResolveKey(k.Key)
+	if err != ErrorExpiredKey {
+		t.Fatalf("ResolveKey() returned %v after expiry, expected ErrorExpiredKey", err)
+	}
+
+	// Keys without expiry must be unaffected.
+	_, err = s.ResolveKey("secret")
+	if err != nil {
+		t.Fatalf("ResolveKey() failed for key without expiry: %s", err.Error())
+	}
+
+	// Removing the expiry makes the key valid again.
+	s.SetKeyExpiry(k.Key, time.Time{})
+
+	_, err = s.ResolveKey(k.Key)
+	if err != nil {
+		t.Fatalf("ResolveKey() failed after removing expiry: %s", err.Error())
+	}
+
+	err = s.SetKeyExpiry("unknown", now)
+	if err != ErrorUnknownKey {
+		t.Fatalf("SetKeyExpiry() returned %v for unknown key", err)
+	}
+}
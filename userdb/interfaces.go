@@ -2,6 +2,7 @@ package userdb
 
 import (
 	"errors"
+	"time"
 )
 
 type (
@@ -48,6 +49,18 @@ type (
 
 		// Should map a cookie secret to a User.
 		ResolveCookie(value string) (User, error)
+
+		// Add a new labeled key to account.
+		AddKey(account Account, label string) (*Key, error)
+
+		// List all keys belonging to account, including revoked keys.
+		ListKeys(account Account) ([]Key, error)
+
+		// Revoke a key. The key cannot be resolved after this.
+		RevokeKey(key string) error
+
+		// Set the expiry time of a key. A zero time removes the expiry.
+		SetKeyExpiry(key string, expiresAt time.Time) error
 	}
 )
 
@@ -60,4 +73,10 @@ var (
 
 	// Error indicating an invalid user id.
 	ErrorInvalidUserId = errors.New("invalid user id")
+
+	// Error indicating an unknown or revoked key.
+	ErrorUnknownKey = errors.New("Wrong key")
+
+	// Error indicating a key past its expiry time.
+	ErrorExpiredKey = errors.New("key expired")
 )